@@ -97,7 +97,7 @@ func loadFields(a interface{}, u *User) []Choice {
 		return []Choice{}
 	}
 
-	s := Schema[getModelName(modelList[int(m.ModelName)])]
+	s := registeredSchema()[getModelName(modelList[int(m.ModelName)])]
 	c := []Choice{}
 	for i, f := range s.Fields {
 		c = append(c, Choice{K: uint(i), V: f.Name})
@@ -181,7 +181,7 @@ func syncABTests() {
 		if t.Type != t.Type.Model() {
 			continue
 		}
-		schema := Schema[getModelName(modelList[int(t.ModelName)])]
+		schema := registeredSchema()[getModelName(modelList[int(t.ModelName)])]
 		fName := schema.Fields[int(t.Field)].Name
 		values := []ABTestValue{}
 		Filter(&values, "ab_test_id = ? AND `active` = ?", t.ID, true)
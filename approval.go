@@ -32,10 +32,12 @@ type Approval struct {
 	ChangedBy           string    `uadmin:"read_only"`
 	ChangeDate          time.Time `uadmin:"read_only"`
 	ApprovalAction      ApprovalAction
-	ApprovalBy          string     `uadmin:"read_only"`
-	ApprovalDate        *time.Time `uadmin:"read_only"`
-	ViewRecord          string     `uadmin:"link"`
-	UpdatedBy           string     `uadmin:"read_only;hidden;list_exclude"`
+	ApprovalBy          string          `uadmin:"read_only"`
+	ApprovalDate        *time.Time      `uadmin:"read_only"`
+	ViewRecord          string          `uadmin:"link"`
+	UpdatedBy           string          `uadmin:"read_only;hidden;list_exclude"`
+	ApprovalRequest     ApprovalRequest `uadmin:"read_only;list_exclude"`
+	ApprovalRequestID   uint            `uadmin:"read_only;list_exclude"`
 }
 
 func (a *Approval) String() string {
@@ -44,22 +46,28 @@ func (a *Approval) String() string {
 
 // Save overides save
 func (a *Approval) Save() {
+	schemas := registeredSchema()
 	if a.ViewRecord == "" {
 		a.ViewRecord = RootURL + a.ModelName + "/" + fmt.Sprint(a.ModelPK)
 	}
-	if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cLIST {
+	if schemas[a.ModelName].FieldByName(a.ColumnName).Type == cLIST {
 		m, _ := NewModel(a.ModelName, false)
 		intVal, _ := strconv.ParseInt(a.NewValue, 10, 64)
 		m.FieldByName(a.ColumnName).SetInt((intVal))
 		a.NewValueDescription = GetString(m.FieldByName(a.ColumnName).Interface())
-	} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cFK {
-		m, _ := NewModel(strings.ToLower(Schema[a.ModelName].FieldByName(a.ColumnName).TypeName), true)
+	} else if schemas[a.ModelName].FieldByName(a.ColumnName).Type == cFK {
+		m, _ := NewModel(strings.ToLower(schemas[a.ModelName].FieldByName(a.ColumnName).TypeName), true)
 		Get(m.Interface(), "id = ?", a.NewValue)
 		a.NewValueDescription = GetString(m.Interface())
 	} else {
 		a.NewValueDescription = a.NewValue
 	}
 
+	// Route new approvals to a matching approval workflow
+	if a.ID == 0 && a.ApprovalRequestID == 0 && a.ApprovalAction == 0 {
+		routeApproval(a)
+	}
+
 	// Run Approval handle func
 	saveApproval := true
 	if ApprovalHandleFunc != nil {
@@ -71,41 +79,28 @@ func (a *Approval) Save() {
 	if a.ID != 0 {
 		Get(&old, "id = ?", a.ID)
 	}
+	if a.ID != 0 {
+		// The request of an approval can't be changed
+		a.ApprovalRequestID = old.ApprovalRequestID
+	}
+	if old.ApprovalAction != a.ApprovalAction && old.ApprovalRequestID != 0 {
+		// Approvals in a workflow are decided through their approval request
+		Trail(WARNING, "Approval.Save %s is part of an approval request and cannot be decided on its own", a.String())
+		a.ApprovalAction = old.ApprovalAction
+	}
 	if old.ApprovalAction != a.ApprovalAction {
 		a.ApprovalBy = a.UpdatedBy
 		now := time.Now()
 		a.ApprovalDate = &now
 		m, _ := NewModelArray(a.ModelName, true)
-		model, _ := NewModel(a.ModelName, false)
+		var column string
+		var value interface{}
 		if a.ApprovalAction == a.ApprovalAction.Approved() {
-			if model.FieldByName(a.ColumnName).Type().String() == "*time.Time" && a.NewValue == "" {
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), nil, "id = ?", a.ModelPK)
-			} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cFK {
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName)+"_id", a.NewValue, "id = ?", a.ModelPK)
-			} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cBOOL {
-				tempBool := a.NewValue == "true"
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), tempBool, "id = ?", a.ModelPK)
-			} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cDATE {
-				tempDate, _ := time.Parse("2006-01-02 15:04:05-07:00", a.NewValue)
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), tempDate, "id = ?", a.ModelPK)
-			} else {
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), a.NewValue, "id = ?", a.ModelPK)
-			}
+			column, value = a.getColumnValue(a.NewValue)
 		} else {
-			if model.FieldByName(a.ColumnName).Type().String() == "*time.Time" && a.OldValue == "" {
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), nil, "id = ?", a.ModelPK)
-			} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cFK {
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName)+"_id", a.OldValue, "id = ?", a.ModelPK)
-			} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cBOOL {
-				tempBool := a.OldValue == "true"
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), tempBool, "id = ?", a.ModelPK)
-			} else if Schema[a.ModelName].FieldByName(a.ColumnName).Type == cDATE {
-				tempDate, _ := time.Parse("2006-01-02 15:04:05-07:00", a.OldValue)
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), tempDate, "id = ?", a.ModelPK)
-			} else {
-				Update(m.Interface(), db.Config.NamingStrategy.ColumnName("", a.ColumnName), a.OldValue, "id = ?", a.ModelPK)
-			}
+			column, value = a.getColumnValue(a.OldValue)
 		}
+		Update(m.Interface(), column, value, "id = ?", a.ModelPK)
 	}
 
 	if !saveApproval {
@@ -115,6 +110,27 @@ func (a *Approval) Save() {
 	Save(a)
 }
 
+// getColumnValue returns the column name and the database value of a
+// string value of the approval's field
+func (a *Approval) getColumnValue(value string) (string, interface{}) {
+	model, _ := NewModel(a.ModelName, false)
+	f := registeredSchema()[a.ModelName].FieldByName(a.ColumnName)
+	column := db.Config.NamingStrategy.ColumnName("", a.ColumnName)
+	if model.FieldByName(a.ColumnName).Type().String() == "*time.Time" && value == "" {
+		return column, nil
+	}
+	switch f.Type {
+	case cFK:
+		return column + "_id", value
+	case cBOOL:
+		return column, value == "true"
+	case cDATE:
+		tempDate, _ := time.Parse("2006-01-02 15:04:05-07:00", value)
+		return column, tempDate
+	}
+	return column, value
+}
+
 // HideInDashboard indicates that startup register call
 // should hide this model in dashboard menu
 func (Approval) HideInDashboard() bool {
@@ -0,0 +1,342 @@
+package uadmin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApprovalRequestStatus is the status of an approval request
+type ApprovalRequestStatus int
+
+// Pending is a request waiting for a decision
+func (ApprovalRequestStatus) Pending() ApprovalRequestStatus {
+	return 1
+}
+
+// Approved is a request approved in all its stages
+func (ApprovalRequestStatus) Approved() ApprovalRequestStatus {
+	return 2
+}
+
+// Rejected is a request rejected in any of its stages
+func (ApprovalRequestStatus) Rejected() ApprovalRequestStatus {
+	return 3
+}
+
+// ApprovalRequest is a model that stores the progress of the pending changes
+// of a record through the stages of an approval workflow. Approvers decide on
+// a request by setting the Decision with an optional Comment.
+type ApprovalRequest struct {
+	Model
+	Workflow    ApprovalWorkflow      `uadmin:"read_only;filter"`
+	WorkflowID  uint                  `uadmin:"read_only"`
+	Stage       ApprovalStage         `uadmin:"read_only"`
+	StageID     uint                  `uadmin:"read_only"`
+	ModelName   string                `uadmin:"read_only;filter"`
+	ModelPK     uint                  `uadmin:"read_only"`
+	Status      ApprovalRequestStatus `uadmin:"read_only;filter"`
+	RequestedBy string                `uadmin:"read_only"`
+	RequestDate time.Time             `uadmin:"read_only"`
+	DueDate     *time.Time            `uadmin:"read_only"`
+	Decision    ApprovalAction        `uadmin:"list_exclude"`
+	Comment     string                `uadmin:"multiline;list_exclude"`
+	ViewRecord  string                `uadmin:"link"`
+	UpdatedBy   string                `uadmin:"read_only;hidden;list_exclude"`
+	// StageNotified is true after the approvers of the current stage were
+	// notified
+	StageNotified bool `uadmin:"read_only;hidden;list_exclude"`
+	// OverdueNotified is true after the approvers and the requester were
+	// notified that the current stage passed its deadline
+	OverdueNotified bool `uadmin:"read_only;hidden;list_exclude"`
+}
+
+func (a *ApprovalRequest) String() string {
+	return fmt.Sprintf("%s %d", a.ModelName, a.ModelPK)
+}
+
+// Save overides save to process the decision and comment of approvers
+func (a *ApprovalRequest) Save() {
+	if a.ViewRecord == "" {
+		a.ViewRecord = a.getLink()
+	}
+	if a.ID == 0 || (a.Decision == 0 && a.Comment == "") {
+		Save(a)
+		return
+	}
+
+	decision := a.Decision
+	comment := a.Comment
+	user := User{}
+	Get(&user, "username = ?", a.UpdatedBy)
+
+	// Reload the request to process the decision on its stored state
+	*a = ApprovalRequest{Model: Model{ID: a.ID}}
+	Get(a, "id = ?", a.ID)
+	var err error
+	switch decision {
+	case decision.Approved():
+		err = a.Approve(&user, comment)
+	case decision.Rejected():
+		err = a.Reject(&user, comment)
+	default:
+		err = a.AddComment(&user, comment)
+	}
+	if err != nil {
+		Trail(WARNING, "ApprovalRequest.Save unable to process %s. %s", a.String(), err)
+	}
+}
+
+// Approvals returns the pending changes of the request
+func (a *ApprovalRequest) Approvals() []Approval {
+	approvals := []Approval{}
+	FilterSorted("id", true, &approvals, "approval_request_id = ? AND approval_action = 0", a.ID)
+	return approvals
+}
+
+// Changes returns a description of the pending changes of the request
+func (a *ApprovalRequest) Changes() string {
+	changes := ""
+	for _, approval := range a.Approvals() {
+		changes += fmt.Sprintf("%s: %s -> %s\n", approval.ColumnName, approval.OldValue, approval.NewValueDescription)
+	}
+	return changes
+}
+
+// Comments returns the comments and decisions made on the request
+func (a *ApprovalRequest) Comments() []ApprovalComment {
+	comments := []ApprovalComment{}
+	FilterSorted("id", true, &comments, "approval_request_id = ?", a.ID)
+	return comments
+}
+
+// Overdue returns true if the current stage passed its deadline
+func (a *ApprovalRequest) Overdue() bool {
+	return a.Status == a.Status.Pending() && a.DueDate != nil && time.Now().After(*a.DueDate)
+}
+
+// CanDecide returns true if the user can approve or reject the current stage
+func (a *ApprovalRequest) CanDecide(user *User) bool {
+	if a.Status != a.Status.Pending() {
+		return false
+	}
+	a.Stage = ApprovalStage{}
+	Get(&a.Stage, "id = ?", a.StageID)
+	return a.Stage.IsApprover(user)
+}
+
+// AddComment adds a comment to the current stage of the request
+func (a *ApprovalRequest) AddComment(user *User, comment string) error {
+	if comment == "" {
+		return fmt.Errorf("comment is empty")
+	}
+	return a.addComment(user, 0, comment)
+}
+
+// Approve approves the current stage of the request. The request moves to the
+// next stage or, if this is the final stage, all its pending changes are
+// applied to the record in one transaction.
+func (a *ApprovalRequest) Approve(user *User, comment string) error {
+	if !a.CanDecide(user) {
+		return fmt.Errorf("%s cannot approve %s", user.Username, a.String())
+	}
+
+	a.Workflow = ApprovalWorkflow{}
+	Get(&a.Workflow, "id = ?", a.WorkflowID)
+	stages := a.Workflow.Stages()
+	for i := range stages {
+		if stages[i].ID == a.StageID && i+1 < len(stages) {
+			if err := a.addComment(user, ApprovalAction(0).Approved(), comment); err != nil {
+				return err
+			}
+			a.startStage(stages[i+1])
+			a.notifyStage()
+			return nil
+		}
+	}
+
+	if err := a.apply(user); err != nil {
+		return err
+	}
+	a.addComment(user, ApprovalAction(0).Approved(), comment)
+	notifyApprovalRequester(a)
+	return nil
+}
+
+// Reject rejects the request in its current stage. None of the pending
+// changes are applied to the record.
+func (a *ApprovalRequest) Reject(user *User, comment string) error {
+	if !a.CanDecide(user) {
+		return fmt.Errorf("%s cannot reject %s", user.Username, a.String())
+	}
+
+	now := time.Now()
+	err := Transaction(context.Background(), func(tx *Tx) error {
+		err := tx.DB().Model(&Approval{}).Where("approval_request_id = ? AND approval_action = 0", a.ID).Updates(map[string]interface{}{
+			"approval_action": ApprovalAction(0).Rejected(),
+			"approval_by":     user.Username,
+			"approval_date":   now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.DB().Model(&ApprovalRequest{}).Where("id = ?", a.ID).Update("status", a.Status.Rejected()).Error
+	})
+	if err != nil {
+		Trail(ERROR, "ApprovalRequest.Reject unable to reject %s. %s", a.String(), err)
+		return err
+	}
+
+	a.Status = a.Status.Rejected()
+	a.addComment(user, ApprovalAction(0).Rejected(), comment)
+	notifyApprovalRequester(a)
+	return nil
+}
+
+// startStage moves the request to a stage. Its approvers are notified with
+// notifyStage.
+func (a *ApprovalRequest) startStage(stage ApprovalStage) {
+	a.StageID = stage.ID
+	a.Stage = ApprovalStage{}
+	Get(&a.Stage, "id = ?", stage.ID)
+	a.DueDate = nil
+	if stage.DeadlineHours > 0 {
+		dueDate := time.Now().Add(time.Duration(stage.DeadlineHours) * time.Hour)
+		a.DueDate = &dueDate
+	}
+	a.StageNotified = false
+	a.OverdueNotified = false
+	a.Decision = 0
+	a.Comment = ""
+	Save(a)
+}
+
+// notifyStage notifies the approvers of the current stage if they weren't
+// notified yet. The stage is marked as notified first so it is notified once
+// when requests are notified concurrently.
+func (a *ApprovalRequest) notifyStage() {
+	if a.ID == 0 || a.Status != a.Status.Pending() {
+		return
+	}
+	result := db.Model(&ApprovalRequest{}).Where("id = ? AND stage_id = ? AND stage_notified = ?", a.ID, a.StageID, false).Update("stage_notified", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	a.StageNotified = true
+	a.Stage = ApprovalStage{}
+	Get(&a.Stage, "id = ?", a.StageID)
+	notifyApprovalStage(a)
+}
+
+// apply writes all pending changes of the request to the record, marks them
+// as approved and logs the change in one transaction. Records of models in
+// named databases are written in a transaction of their database.
+func (a *ApprovalRequest) apply(user *User) error {
+	approvals := a.Approvals()
+	now := time.Now()
+
+	s, _ := getSchema(a.ModelName)
+	model, ok := NewModel(a.ModelName, true)
+	if !ok {
+		return fmt.Errorf("invalid model name: %s", a.ModelName)
+	}
+	Get(model.Interface(), "id = ?", a.ModelPK)
+	activity := getRecordSnapshot(model, &s)
+
+	// updateRecord writes the changes to the record
+	updateRecord := func(conn *gorm.DB) error {
+		for _, approval := range approvals {
+			column, value := approval.getColumnValue(approval.NewValue)
+			if err := conn.Table(s.TableName).Where("id = ?", a.ModelPK).Update(column, value).Error; err != nil {
+				return fmt.Errorf("unable to apply %s. %s", approval.ColumnName, err)
+			}
+		}
+		return nil
+	}
+	err := Transaction(context.Background(), func(tx *Tx) error {
+		for _, approval := range approvals {
+			err := tx.DB().Model(&Approval{}).Where("id = ?", approval.ID).Updates(map[string]interface{}{
+				"approval_action": ApprovalAction(0).Approved(),
+				"approval_by":     user.Username,
+				"approval_date":   now,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("unable to apply %s. %s", approval.ColumnName, err)
+			}
+		}
+		if err := tx.DB().Model(&ApprovalRequest{}).Where("id = ?", a.ID).Update("status", a.Status.Approved()).Error; err != nil {
+			return err
+		}
+		if getDBName(model.Interface()) == "" {
+			return updateRecord(tx.DB())
+		}
+		// Models in named databases are updated in a transaction of their
+		// database. It runs last so a failed update rolls back the approval.
+		return modelDB(model.Interface()).Transaction(updateRecord)
+	})
+	if err != nil {
+		Trail(ERROR, "ApprovalRequest.apply unable to approve %s. %s", a.String(), err)
+		return err
+	}
+	a.Status = a.Status.Approved()
+
+	// Modified logs store the record before the change
+	if LogEdit && len(approvals) != 0 {
+		activity["_ApprovalRequest"] = fmt.Sprint(a.ID)
+		b, _ := json.Marshal(activity)
+		log := &Log{
+			Username:  user.Username,
+			TableName: a.ModelName,
+			TableID:   int(a.ModelPK),
+			Action:    Action(0).Modified(),
+			Activity:  string(b),
+		}
+		log.Save()
+	}
+	return nil
+}
+
+func (a *ApprovalRequest) addComment(user *User, action ApprovalAction, comment string) error {
+	if action == 0 && comment == "" {
+		return nil
+	}
+	c := ApprovalComment{
+		ApprovalRequestID: a.ID,
+		StageName:         a.Stage.Name,
+		Username:          user.Username,
+		Action:            action,
+		Comment:           comment,
+		Date:              time.Now(),
+	}
+	return Save(&c)
+}
+
+func (a *ApprovalRequest) getLink() string {
+	return RootURL + a.ModelName + "/" + fmt.Sprint(a.ModelPK)
+}
+
+// ApprovalComment is a model that stores a comment or decision made on an
+// approval request
+type ApprovalComment struct {
+	Model
+	ApprovalRequest   ApprovalRequest `uadmin:"read_only;filter"`
+	ApprovalRequestID uint            `uadmin:"read_only"`
+	StageName         string          `uadmin:"read_only"`
+	Username          string          `uadmin:"read_only;filter"`
+	Action            ApprovalAction  `uadmin:"read_only"`
+	Comment           string          `uadmin:"read_only;multiline"`
+	Date              time.Time       `uadmin:"read_only"`
+}
+
+func (c ApprovalComment) String() string {
+	return fmt.Sprintf("%s %s", c.Username, c.Date.Format("2006-01-02 15:04"))
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (ApprovalComment) HideInDashboard() bool {
+	return true
+}
@@ -0,0 +1,269 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// approvalNotifyJob is the name of the job that notifies the approvers of
+// new and overdue approval stages
+const approvalNotifyJob = "uadmin/approval-notify"
+
+func init() {
+	RegisterJob(approvalNotifyJob, "@every 15m", func(ctx context.Context) error {
+		notifyApprovalStages()
+		notifyOverdueApprovals()
+		return nil
+	})
+}
+
+// ApprovalWorkflow is a model that stores a multi-stage approval workflow.
+// Changes that require approval are routed to the first active workflow that
+// matches their model, field and new value ordered by priority.
+type ApprovalWorkflow struct {
+	Model
+	Name       string `uadmin:"required;search"`
+	ModelName  string `uadmin:"required;filter"`
+	FieldName  string `uadmin:"help:Leave empty to match all fields"`
+	ValueMatch string `uadmin:"help:Regular expression for the new value. Leave empty to match all values"`
+	Priority   int    `uadmin:"help:Workflows with higher priority are checked first"`
+	Active     bool   `uadmin:"filter"`
+}
+
+func (w ApprovalWorkflow) String() string {
+	return w.Name
+}
+
+// Match returns true if an approval should be routed to this workflow
+func (w ApprovalWorkflow) Match(a *Approval) bool {
+	if !strings.EqualFold(w.ModelName, a.ModelName) {
+		return false
+	}
+	if w.FieldName != "" && !strings.EqualFold(w.FieldName, a.ColumnName) {
+		return false
+	}
+	if w.ValueMatch != "" {
+		re, err := regexp.Compile(w.ValueMatch)
+		if err != nil {
+			Trail(WARNING, "ApprovalWorkflow.Match invalid value match for workflow %s. %s", w.Name, err)
+			return false
+		}
+		return re.MatchString(a.NewValue)
+	}
+	return true
+}
+
+// Stages returns the stages of the workflow in order
+func (w ApprovalWorkflow) Stages() []ApprovalStage {
+	stages := []ApprovalStage{}
+	FilterSorted("sequence", true, &stages, "workflow_id = ?", w.ID)
+	return stages
+}
+
+// ApprovalStage is a model that stores a stage of an approval workflow. Users
+// of any of the approver groups can approve or reject changes in this stage.
+type ApprovalStage struct {
+	Model
+	Name           string           `uadmin:"required"`
+	Workflow       ApprovalWorkflow `uadmin:"required;filter"`
+	WorkflowID     uint
+	Sequence       int         `uadmin:"help:Stages are processed in ascending order"`
+	ApproverGroups []UserGroup `gorm:"-" uadmin:"list_exclude"`
+	DeadlineHours  int         `uadmin:"help:Number of hours approvers have to decide. Zero for no deadline"`
+}
+
+func (s ApprovalStage) String() string {
+	return s.Name
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (ApprovalStage) HideInDashboard() bool {
+	return true
+}
+
+// IsApprover returns true if the user can decide on changes in this stage
+func (s ApprovalStage) IsApprover(user *User) bool {
+	if user == nil || user.ID == 0 {
+		return false
+	}
+	if user.Admin {
+		return true
+	}
+	for _, g := range s.ApproverGroups {
+		if g.ID == user.UserGroupID {
+			return true
+		}
+	}
+	return false
+}
+
+// getApprovers returns the active users of the stage's approver groups
+func (s ApprovalStage) getApprovers() []User {
+	users := []User{}
+	if len(s.ApproverGroups) == 0 {
+		return users
+	}
+	groupIDs := []uint{}
+	for _, g := range s.ApproverGroups {
+		groupIDs = append(groupIDs, g.ID)
+	}
+	Filter(&users, "user_group_id IN (?) AND active = ?", groupIDs, true)
+	return users
+}
+
+// getApprovalWorkflow returns the workflow an approval should be routed to
+func getApprovalWorkflow(a *Approval) (ApprovalWorkflow, bool) {
+	workflows := []ApprovalWorkflow{}
+	FilterSorted("priority", false, &workflows, "active = ? AND model_name = ?", true, a.ModelName)
+	for _, w := range workflows {
+		if w.Match(a) {
+			return w, true
+		}
+	}
+	return ApprovalWorkflow{}, false
+}
+
+// routeApproval links a new approval to the pending approval request of its
+// record in a matching workflow. A new request is started at the first stage
+// if there is no pending request. The approvers of the stage are notified
+// by notifyApprovalRequests once all the approvals of the change are saved.
+// It returns false if there is no workflow for the approval.
+func routeApproval(a *Approval) bool {
+	w, ok := getApprovalWorkflow(a)
+	if !ok {
+		return false
+	}
+
+	req := ApprovalRequest{}
+	Get(&req, "workflow_id = ? AND model_name = ? AND model_pk = ? AND status = ?", w.ID, a.ModelName, a.ModelPK, req.Status.Pending())
+	if req.ID == 0 {
+		stages := w.Stages()
+		if len(stages) == 0 {
+			Trail(WARNING, "routeApproval workflow %s has no stages", w.Name)
+			return false
+		}
+		req = ApprovalRequest{
+			Workflow:    w,
+			WorkflowID:  w.ID,
+			ModelName:   a.ModelName,
+			ModelPK:     a.ModelPK,
+			Status:      req.Status.Pending(),
+			RequestedBy: a.ChangedBy,
+			RequestDate: time.Now(),
+		}
+		if err := Save(&req); err != nil {
+			return false
+		}
+		req.startStage(stages[0])
+	}
+	a.ApprovalRequestID = req.ID
+	return true
+}
+
+// notifyApprovalRequests notifies the approvers of the requests of approvals
+// whose current stage wasn't notified yet
+func notifyApprovalRequests(approvals []Approval) {
+	notified := map[uint]bool{}
+	for _, approval := range approvals {
+		if approval.ApprovalRequestID == 0 || notified[approval.ApprovalRequestID] {
+			continue
+		}
+		notified[approval.ApprovalRequestID] = true
+		req := ApprovalRequest{}
+		Get(&req, "id = ?", approval.ApprovalRequestID)
+		req.notifyStage()
+	}
+}
+
+// notifyApprovalStages notifies the approvers of pending requests whose
+// current stage wasn't notified yet. It notifies requests of approvals that
+// were saved without notifyApprovalRequests.
+func notifyApprovalStages() {
+	requests := []ApprovalRequest{}
+	Filter(&requests, "status = ? AND stage_notified = ?", ApprovalRequestStatus(0).Pending(), false)
+	for i := range requests {
+		requests[i].notifyStage()
+	}
+}
+
+// notifyApprovalStage emails the approvers of the current stage of a request
+func notifyApprovalStage(req *ApprovalRequest) {
+	to := []string{}
+	for _, u := range req.Stage.getApprovers() {
+		if u.Email != "" {
+			to = append(to, u.Email)
+		}
+	}
+	if len(to) == 0 {
+		return
+	}
+	subject := fmt.Sprintf("Approval required: %s (%s)", req.String(), req.Stage.Name)
+	body := fmt.Sprintf("Changes to %s requested by %s are waiting for your approval in stage %s.\n\n%s%s",
+		req.String(), req.RequestedBy, req.Stage.Name, req.Changes(), req.getLink())
+	if req.DueDate != nil {
+		body += fmt.Sprintf("\n\nPlease decide before %s.", req.DueDate.Format("2006-01-02 15:04"))
+	}
+	SendEmail(to, []string{}, []string{}, subject, body)
+}
+
+// notifyApprovalRequester emails the user who requested the changes with the
+// final decision on the request
+func notifyApprovalRequester(req *ApprovalRequest) {
+	requester := User{}
+	Get(&requester, "username = ?", req.RequestedBy)
+	if requester.Email == "" {
+		return
+	}
+	decision := "approved"
+	if req.Status == req.Status.Rejected() {
+		decision = "rejected"
+	}
+	subject := fmt.Sprintf("Changes %s: %s", decision, req.String())
+	body := fmt.Sprintf("Your changes to %s were %s.\n\n%s%s", req.String(), decision, req.Changes(), req.getLink())
+	SendEmail([]string{requester.Email}, []string{}, []string{}, subject, body)
+}
+
+// notifyOverdueApprovals emails the approvers and the requester of pending
+// requests whose current stage passed its deadline. Each stage is notified
+// once.
+func notifyOverdueApprovals() {
+	requests := []ApprovalRequest{}
+	Filter(&requests, "status = ? AND due_date < ? AND overdue_notified = ?", ApprovalRequestStatus(0).Pending(), time.Now(), false)
+	for i := range requests {
+		req := &requests[i]
+		if !req.Overdue() {
+			continue
+		}
+		Get(&req.Stage, "id = ?", req.StageID)
+		notifyApprovalOverdue(req)
+		Update(req, "overdue_notified", true, "id = ?", req.ID)
+	}
+}
+
+// notifyApprovalOverdue emails the approvers of the current stage of a
+// request and the user who requested the changes that the stage passed its
+// deadline
+func notifyApprovalOverdue(req *ApprovalRequest) {
+	to := []string{}
+	for _, u := range req.Stage.getApprovers() {
+		if u.Email != "" {
+			to = append(to, u.Email)
+		}
+	}
+	requester := User{}
+	Get(&requester, "username = ?", req.RequestedBy)
+	if requester.Email != "" {
+		to = append(to, requester.Email)
+	}
+	if len(to) == 0 {
+		return
+	}
+	subject := fmt.Sprintf("Approval overdue: %s (%s)", req.String(), req.Stage.Name)
+	body := fmt.Sprintf("Changes to %s requested by %s passed the deadline of stage %s on %s.\n\n%s%s",
+		req.String(), req.RequestedBy, req.Stage.Name, req.DueDate.Format("2006-01-02 15:04"), req.Changes(), req.getLink())
+	SendEmail(to, []string{}, []string{}, subject, body)
+}
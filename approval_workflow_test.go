@@ -0,0 +1,184 @@
+package uadmin
+
+import (
+	"strings"
+	"time"
+)
+
+// TestApprovalWorkflow is a unit testing function for multi-stage approval
+// workflows
+func (t *UAdminTests) TestApprovalWorkflow() {
+	emails := []string{}
+	bodies := []string{}
+	CustomEmailHandler = func(to, cc, bcc *[]string, subject, body *string, attachments ...*string) (bool, error) {
+		emails = append(emails, strings.Join(*to, ",")+" "+*subject)
+		bodies = append(bodies, *body)
+		return false, nil
+	}
+	defer func() {
+		CustomEmailHandler = nil
+	}()
+
+	g1 := UserGroup{GroupName: "Team Leads"}
+	Save(&g1)
+	g2 := UserGroup{GroupName: "Finance"}
+	Save(&g2)
+	lead := &User{Username: "lead", Password: "lead", Email: "lead@example.com", Active: true, UserGroupID: g1.ID}
+	Save(lead)
+	fin := &User{Username: "fin", Password: "fin", Email: "fin@example.com", Active: true, UserGroupID: g2.ID}
+	Save(fin)
+
+	w := ApprovalWorkflow{Name: "Name changes", ModelName: "testapproval", Active: true}
+	Save(&w)
+	stage1 := ApprovalStage{Name: "Lead", WorkflowID: w.ID, Sequence: 1, ApproverGroups: []UserGroup{g1}}
+	Save(&stage1)
+	stage2 := ApprovalStage{Name: "Finance", WorkflowID: w.ID, Sequence: 2, ApproverGroups: []UserGroup{g2}, DeadlineHours: 24}
+	Save(&stage2)
+
+	// Test routing rules
+	examples := []struct {
+		w     ApprovalWorkflow
+		a     Approval
+		match bool
+	}{
+		{w, Approval{ModelName: "testapproval", ColumnName: "Name"}, true},
+		{w, Approval{ModelName: "testmodela", ColumnName: "Name"}, false},
+		{ApprovalWorkflow{ModelName: "testapproval", FieldName: "Count"}, Approval{ModelName: "testapproval", ColumnName: "Name"}, false},
+		{ApprovalWorkflow{ModelName: "testapproval", ValueMatch: "^[0-9]{4,}$"}, Approval{ModelName: "testapproval", NewValue: "999"}, false},
+		{ApprovalWorkflow{ModelName: "testapproval", ValueMatch: "^[0-9]{4,}$"}, Approval{ModelName: "testapproval", NewValue: "1000"}, true},
+	}
+	for i, e := range examples {
+		if e.w.Match(&e.a) != e.match {
+			t.Errorf("ApprovalWorkflow.Match returned invalid value for example %d. Expected %v", i, e.match)
+		}
+	}
+
+	m := TestApproval{Name: "old", Count: 1}
+	Save(&m)
+
+	a1 := Approval{ModelName: "testapproval", ModelPK: m.ID, ColumnName: "Name", OldValue: "old", NewValue: "new", ChangedBy: "admin"}
+	a1.Save()
+	a2 := Approval{ModelName: "testapproval", ModelPK: m.ID, ColumnName: "Count", OldValue: "1", NewValue: "5", ChangedBy: "admin"}
+	a2.Save()
+	if a1.ApprovalRequestID == 0 || a1.ApprovalRequestID != a2.ApprovalRequestID {
+		t.Errorf("Approval.Save didn't route the approvals to one request. Got %d and %d", a1.ApprovalRequestID, a2.ApprovalRequestID)
+	}
+
+	req := ApprovalRequest{}
+	Get(&req, "id = ?", a1.ApprovalRequestID)
+	if req.StageID != stage1.ID || req.Status != req.Status.Pending() {
+		t.Errorf("Approval request didn't start in the first stage. Got stage %d status %d", req.StageID, req.Status)
+	}
+	if len(emails) != 0 {
+		t.Errorf("Approval request notified the first stage approvers before its approvals were collected. Got %v", emails)
+	}
+	notifyApprovalRequests([]Approval{a1, a2})
+	notifyApprovalStages()
+	if len(emails) != 1 || !strings.HasPrefix(emails[0], "lead@example.com") {
+		t.Errorf("Approval request didn't notify the first stage approvers once. Got %v", emails)
+	} else if !strings.Contains(bodies[0], "Name: old -> new") || !strings.Contains(bodies[0], "Count: 1 -> 5") {
+		t.Errorf("Approval request didn't notify all the changes of the request. Got %s", bodies[0])
+	}
+
+	// Only approvers of the current stage can decide
+	if err := req.Approve(fin, ""); err == nil {
+		t.Errorf("ApprovalRequest.Approve allowed a user who is not an approver of the stage")
+	}
+
+	// Approvals in a workflow cannot be decided on their own
+	a1.ApprovalAction = a1.ApprovalAction.Approved()
+	a1.Save()
+	mDB := TestApproval{}
+	Get(&mDB, "id = ?", m.ID)
+	if mDB.Name != "old" {
+		t.Errorf("Approval.Save applied an approval which is part of a request")
+	}
+	a1.ApprovalRequestID = 0
+	a1.ApprovalAction = a1.ApprovalAction.Approved()
+	a1.Save()
+	Get(&mDB, "id = ?", m.ID)
+	if mDB.Name != "old" || a1.ApprovalRequestID != req.ID {
+		t.Errorf("Approval.Save applied an approval after clearing its approval request")
+	}
+
+	if err := req.Approve(lead, "looks good"); err != nil {
+		t.Errorf("ApprovalRequest.Approve returned an error. %s", err)
+	}
+	Get(&req, "id = ?", req.ID)
+	if req.StageID != stage2.ID || req.DueDate == nil {
+		t.Errorf("ApprovalRequest.Approve didn't move the request to the next stage with a deadline")
+	}
+	if len(emails) != 2 || !strings.HasPrefix(emails[1], "fin@example.com") {
+		t.Errorf("ApprovalRequest.Approve didn't notify the next stage approvers. Got %v", emails)
+	}
+	Get(&mDB, "id = ?", m.ID)
+	if mDB.Name != "old" || mDB.Count != 1 {
+		t.Errorf("ApprovalRequest.Approve applied changes before the final stage")
+	}
+
+	// Overdue stages are notified once
+	if getRegisteredJob(approvalNotifyJob) == nil {
+		t.Errorf("The job that notifies overdue approvals isn't registered")
+	}
+	db.Model(&ApprovalRequest{}).Where("id = ?", req.ID).Update("due_date", time.Now().Add(-time.Hour))
+	notifyOverdueApprovals()
+	if len(emails) != 3 || !strings.HasPrefix(emails[2], "fin@example.com") || !strings.Contains(emails[2], "overdue") {
+		t.Errorf("notifyOverdueApprovals didn't notify the approvers of an overdue stage. Got %v", emails)
+	}
+	notifyOverdueApprovals()
+	if len(emails) != 3 {
+		t.Errorf("notifyOverdueApprovals notified an overdue stage twice. Got %v", emails)
+	}
+
+	// Approve the final stage from the form
+	req.Decision = req.Decision.Approved()
+	req.Comment = "approved"
+	req.UpdatedBy = fin.Username
+	req.Save()
+	Get(&mDB, "id = ?", m.ID)
+	if mDB.Name != "new" || mDB.Count != 5 {
+		t.Errorf("ApprovalRequest.Approve didn't apply the changes. Got %s, %d", mDB.Name, mDB.Count)
+	}
+	Get(&req, "id = ?", req.ID)
+	if req.Status != req.Status.Approved() {
+		t.Errorf("ApprovalRequest.Approve didn't approve the request. Got %d", req.Status)
+	}
+	if Count(&Approval{}, "approval_request_id = ? AND approval_action = ?", req.ID, ApprovalAction(0).Approved()) != 2 {
+		t.Errorf("ApprovalRequest.Approve didn't approve the approvals")
+	}
+	if comments := req.Comments(); len(comments) != 2 || comments[0].Comment != "looks good" || comments[1].StageName != "Finance" {
+		t.Errorf("ApprovalRequest didn't store the comments. Got %#v", comments)
+	}
+
+	// Reject a new request
+	a3 := Approval{ModelName: "testapproval", ModelPK: m.ID, ColumnName: "Name", OldValue: "new", NewValue: "rejected", ChangedBy: "admin"}
+	a3.Save()
+	if a3.ApprovalRequestID == 0 || a3.ApprovalRequestID == req.ID {
+		t.Errorf("Approval.Save didn't start a new request. Got %d", a3.ApprovalRequestID)
+	}
+	req = ApprovalRequest{}
+	Get(&req, "id = ?", a3.ApprovalRequestID)
+	if err := req.Reject(lead, "no"); err != nil {
+		t.Errorf("ApprovalRequest.Reject returned an error. %s", err)
+	}
+	Get(&mDB, "id = ?", m.ID)
+	if mDB.Name != "new" {
+		t.Errorf("ApprovalRequest.Reject applied the changes")
+	}
+	Get(&a3, "id = ?", a3.ID)
+	if a3.ApprovalAction != a3.ApprovalAction.Rejected() {
+		t.Errorf("ApprovalRequest.Reject didn't reject the approval")
+	}
+
+	DeleteList(&[]Approval{}, "model_name = ? AND model_pk = ?", "testapproval", m.ID)
+	DeleteList(&[]ApprovalComment{}, "id > 0")
+	DeleteList(&[]ApprovalRequest{}, "id > 0")
+	Delete(stage1)
+	Delete(stage2)
+	Delete(w)
+	Delete(m)
+	Delete(lead)
+	Delete(fin)
+	Delete(g1)
+	Delete(g2)
+}
@@ -274,7 +274,7 @@ func createJWT(r *http.Request, s *Session) string {
 			payload["groups"] = groups
 
 			entitlements := []map[string]interface{}{}
-			for k := range models {
+			for k := range registeredModels() {
 				perm := s.User.GetAccess(k)
 				entitlements = append(entitlements, map[string]interface{}{
 					"modelName": k,
@@ -283,6 +283,8 @@ func createJWT(r *http.Request, s *Session) string {
 					"edit":      perm.Edit,
 					"delete":    perm.Delete,
 					"approval":  perm.Approval,
+					"restore":   perm.Restore,
+					"purge":     perm.Purge,
 				})
 			}
 
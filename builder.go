@@ -0,0 +1,387 @@
+package uadmin
+
+import (
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/arbrix/uadmin/helper"
+	"github.com/jinzhu/inflection"
+	"gorm.io/gorm"
+)
+
+// builderModelTag is the tag of the embedded Model of models built by the
+// model builder. It has the name of the model because types built at
+// runtime don't have names.
+const builderModelTag = "uadmin_builder"
+
+// builderModels are the names of the models that are built by the model
+// builder
+var builderModels = map[string]bool{}
+
+// builderMutex makes the models of the model builder load one at a time.
+// The registered models and schemas are built in copies that replace them
+// under modelsMutex when they are ready.
+var builderMutex = sync.Mutex{}
+
+// builderReservedFields are the names of fields that are added by the model
+// builder
+var builderReservedFields = map[string]bool{
+	"ID":        true,
+	"Model":     true,
+	"DeletedAt": true,
+	"CreatedBy": true,
+	"UpdatedBy": true,
+}
+
+// Builder is a model that is defined in the admin and built at runtime.
+// Its table is created or migrated and it is served in the admin and dAPI
+// like a model registered in Go code.
+type Builder struct {
+	Model
+	Name           string `uadmin:"required;search"`
+	Category       string `uadmin:"help:Category of the model in the dashboard"`
+	CreatedByField bool   `uadmin:"list_exclude;help:Adds a CreatedBy field with the username who added the record"`
+	UpdatedByField bool   `uadmin:"list_exclude;help:Adds an UpdatedBy field with the username who edited the record"`
+	IncludeFormJS  string `uadmin:"list_exclude;help:Comma separated list of JS files for the form"`
+	IncludeListJS  string `uadmin:"list_exclude;help:Comma separated list of JS files for the list"`
+	FormTheme      string `uadmin:"list_exclude"`
+	ListTheme      string `uadmin:"list_exclude"`
+}
+
+func (b Builder) String() string {
+	return b.Name
+}
+
+// GetCodeName returns the name of the model type
+func (b *Builder) GetCodeName() string {
+	return helper.ToCamel(b.Name)
+}
+
+// getFields returns the fields of the model sorted by ID
+func (b *Builder) getFields() []BuilderField {
+	fields := []BuilderField{}
+	FilterSorted("id", true, &fields, "builder_id = ?", b.ID)
+	return fields
+}
+
+// buildModel returns the type of the model. types has the types of models
+// by model name for foreign keys and M2M fields.
+func (b *Builder) buildModel(fields []BuilderField, types map[string]reflect.Type) (reflect.Type, error) {
+	structFields := []reflect.StructField{{
+		Name:      "Model",
+		Type:      reflect.TypeOf(Model{}),
+		Tag:       reflect.StructTag(fmt.Sprintf("%s:%q", builderModelTag, b.GetCodeName())),
+		Anonymous: true,
+	}}
+	for i := range fields {
+		f, err := fields[i].getStructFields(types)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s %s", b.Name, fields[i].Name, err)
+		}
+		structFields = append(structFields, f...)
+	}
+	if b.CreatedByField {
+		structFields = append(structFields, reflect.StructField{Name: "CreatedBy", Type: reflect.TypeOf(""), Tag: `uadmin:"read_only"`})
+	}
+	if b.UpdatedByField {
+		structFields = append(structFields, reflect.StructField{Name: "UpdatedBy", Type: reflect.TypeOf(""), Tag: `uadmin:"read_only"`})
+	}
+
+	// reflect.StructOf panics if there are two fields with the same name
+	names := map[string]bool{}
+	for _, f := range structFields {
+		if names[f.Name] {
+			return nil, fmt.Errorf("%s has more than one field named %s", b.Name, f.Name)
+		}
+		names[f.Name] = true
+	}
+	return reflect.StructOf(structFields), nil
+}
+
+// GetCode returns the Go code of the model
+func (b *Builder) GetCode() string {
+	imports := []string{}
+	fCodes := []string{"\tuadmin.Model"}
+	for _, f := range b.getFields() {
+		fCode, fImport := f.GetCode()
+		fCodes = append(fCodes, "\t"+fCode)
+		if fImport != "" && !strings.Contains(strings.Join(imports, ","), fImport) {
+			imports = append(imports, fImport)
+		}
+	}
+	if b.CreatedByField {
+		fCodes = append(fCodes, "\tCreatedBy string `uadmin:\"read_only\"`")
+	}
+	if b.UpdatedByField {
+		fCodes = append(fCodes, "\tUpdatedBy string `uadmin:\"read_only\"`")
+	}
+	imports = append(imports, reflect.TypeOf(Model{}).PkgPath())
+
+	code := "package models\n\n"
+	code += "import (\n"
+	for _, imp := range imports {
+		code += "\t\"" + imp + "\"\n"
+	}
+	code += ")\n\n"
+	code += "// " + b.GetCodeName() + " model\n"
+	code += "type " + b.GetCodeName() + " struct {\n"
+	code += strings.Join(fCodes, "\n") + "\n"
+	code += "}\n"
+	if b.Category != "" {
+		code += "\n// SchemaCategory returns the category of the model in the dashboard\n"
+		code += "func (" + b.GetCodeName() + ") SchemaCategory() string {\n"
+		code += "\treturn " + strconv.Quote(b.Category) + "\n"
+		code += "}\n"
+	}
+
+	src, err := format.Source([]byte(code))
+	if err != nil {
+		Trail(ERROR, "Builder.GetCode unable to format the code of %s. %s", b.Name, err)
+		return code
+	}
+	return string(src)
+}
+
+// Validate the model when saving from uadmin
+func (b Builder) Validate() (ret map[string]string) {
+	ret = map[string]string{}
+	codeName := b.GetCodeName()
+	if codeName == "" || codeName[0] < 'A' || codeName[0] > 'Z' {
+		ret["Name"] = "Name should start with a letter"
+		return
+	}
+	modelsMutex.RLock()
+	_, exists := models[strings.ToLower(codeName)]
+	exists = exists && !builderModels[strings.ToLower(codeName)]
+	modelsMutex.RUnlock()
+	if exists {
+		ret["Name"] = "There is a registered model named " + codeName
+		return
+	}
+	builders := []Builder{}
+	Filter(&builders, "id <> ?", b.ID)
+	for _, other := range builders {
+		if other.GetCodeName() == codeName {
+			ret["Name"] = "There is another model named " + codeName
+		}
+	}
+	return
+}
+
+// Save the model and build it
+func (b *Builder) Save() {
+	Save(b)
+	if err := loadBuilderModels(); err != nil {
+		Trail(ERROR, "Builder.Save unable to build %s. %s", b.Name, err)
+	}
+}
+
+// Delete the models and their fields and unregister them. The tables of
+// the models stay in the database.
+func (Builder) Delete(a interface{}, query string, args ...interface{}) {
+	builders := []Builder{}
+	Filter(&builders, query, args...)
+	for _, b := range builders {
+		DeleteList(&BuilderField{}, "builder_id = ?", b.ID)
+	}
+	DeleteList(a, query, args...)
+	if err := loadBuilderModels(); err != nil {
+		Trail(ERROR, "Builder.Delete unable to build models. %s", err)
+	}
+}
+
+// loadBuilderModels builds the models of the model builder, migrates their
+// tables and registers them. All the models are rebuilt so foreign keys
+// between them use the same types.
+func loadBuilderModels() error {
+	builderMutex.Lock()
+	defer builderMutex.Unlock()
+
+	builders := []Builder{}
+	FilterSorted("id", true, &builders, "")
+
+	newModels := map[string]interface{}{}
+	for name, m := range registeredModels() {
+		newModels[name] = m
+	}
+	newSchema := map[string]ModelSchema{}
+	for name, s := range registeredSchema() {
+		newSchema[name] = s
+	}
+
+	types := map[string]reflect.Type{}
+	for name, m := range newModels {
+		if !builderModels[name] {
+			types[name] = reflect.TypeOf(m)
+		}
+	}
+
+	// Build models after the models of their foreign keys
+	fields := map[uint][]BuilderField{}
+	built := map[string]bool{}
+	var errs []string
+	for len(builders) != 0 {
+		pending := []Builder{}
+		for _, b := range builders {
+			if _, ok := fields[b.ID]; !ok {
+				fields[b.ID] = b.getFields()
+			}
+			ready := true
+			for _, f := range fields[b.ID] {
+				fkName := strings.ToLower(f.FKModel)
+				if (f.Type == f.Type.ForeignKey() || f.Type == f.Type.M2M()) && types[fkName] == nil {
+					ready = false
+				}
+			}
+			if !ready {
+				pending = append(pending, b)
+				continue
+			}
+			t, err := b.buildModel(fields[b.ID], types)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			types[strings.ToLower(b.GetCodeName())] = t
+			if err = registerBuilderModel(&b, t, newModels, newSchema); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			built[strings.ToLower(b.GetCodeName())] = true
+		}
+		if len(pending) == len(builders) {
+			for _, b := range pending {
+				errs = append(errs, fmt.Sprintf("%s refers to a model that cannot be built", b.Name))
+			}
+			break
+		}
+		builders = pending
+	}
+
+	// Unregister deleted models
+	for name := range builderModels {
+		if !built[name] {
+			delete(newModels, name)
+			delete(newSchema, name)
+			db.Model(&DashboardMenu{}).Where("url = ?", name).Update("hidden", true)
+		}
+	}
+	modelsMutex.Lock()
+	models = newModels
+	Schema = newSchema
+	builderModels = built
+	modelsMutex.Unlock()
+
+	if len(errs) != 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ". "))
+	}
+	return nil
+}
+
+// registerBuilderModel migrates the table of a model built by the model
+// builder and adds it to the registered models and schemas in newModels and
+// newSchema
+func registerBuilderModel(b *Builder, t reflect.Type, newModels map[string]interface{}, newSchema map[string]ModelSchema) error {
+	name := strings.ToLower(b.GetCodeName())
+	model := reflect.New(t).Elem().Interface()
+
+	// Types built at runtime don't have a name to get their table name
+	// from so the table name is set in the schema cached by gorm
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("unable to parse %s. %s", b.Name, err)
+	}
+	stmt.Schema.Table = db.Config.NamingStrategy.TableName(b.GetCodeName())
+
+	if err := db.AutoMigrate(reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("unable to migrate %s. %s", b.Name, err)
+	}
+	if err := customMigration(model); err != nil {
+		return fmt.Errorf("unable to migrate %s. %s", b.Name, err)
+	}
+	newModels[name] = model
+
+	menu := DashboardMenu{}
+	Get(&menu, "url = ?", name)
+	menu.URL = name
+	if menu.ID == 0 {
+		menu.MenuName = inflection.Plural(strings.Join(helper.SplitCamelCase(b.GetCodeName()), " "))
+	}
+	menu.Hidden = false
+	menu.Cat = b.Category
+	Save(&menu)
+
+	s, _ := buildSchema(model)
+	for _, js := range strings.Split(b.IncludeFormJS, ",") {
+		if js = strings.TrimSpace(js); js != "" {
+			s.IncludeFormJS = append(s.IncludeFormJS, js)
+		}
+	}
+	for _, js := range strings.Split(b.IncludeListJS, ",") {
+		if js = strings.TrimSpace(js); js != "" {
+			s.IncludeListJS = append(s.IncludeListJS, js)
+		}
+	}
+	s.FormTheme = b.FormTheme
+	s.ListTheme = b.ListTheme
+	newSchema[name] = s
+	return nil
+}
+
+// builderCommand runs the builder command:
+//
+//	builder export [MODEL...] [--output FOLDER]
+//
+// It writes the Go code of models defined using the model builder to the
+// folder (models by default).
+func builderCommand(args []string) error {
+	flags, params, err := parseCommandFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(params) == 0 || params[0] != "export" {
+		return fmt.Errorf("usage: builder export [MODEL...] [--output FOLDER]")
+	}
+	output := flags["output"]
+	if output == "" {
+		output = "models"
+	}
+
+	builders := []Builder{}
+	FilterSorted("id", true, &builders, "")
+	names := map[string]bool{}
+	for _, name := range params[1:] {
+		names[strings.ToLower(helper.ToCamel(name))] = true
+	}
+	sort.Slice(builders, func(i, j int) bool {
+		return builders[i].GetCodeName() < builders[j].GetCodeName()
+	})
+	if err = os.MkdirAll(output, 0755); err != nil {
+		return err
+	}
+	for _, b := range builders {
+		name := strings.ToLower(b.GetCodeName())
+		if len(names) != 0 && !names[name] {
+			continue
+		}
+		delete(names, name)
+		code := strings.Replace(b.GetCode(), "package models", "package "+filepath.Base(output), 1)
+		fileName := filepath.Join(output, name+".go")
+		if err = ioutil.WriteFile(fileName, []byte(code), 0644); err != nil {
+			return err
+		}
+		fmt.Println("Exported", b.Name, "to", fileName)
+	}
+	for name := range names {
+		return fmt.Errorf("model not found: %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package uadmin
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/arbrix/uadmin/helper"
+)
+
+// ReadOnly is the read only mode of a builder field
+type ReadOnly int
+
+// Always makes the field read only in new and edit forms
+func (ReadOnly) Always() ReadOnly {
+	return 1
+}
+
+// New makes the field read only in new forms
+func (ReadOnly) New() ReadOnly {
+	return 2
+}
+
+// Edit makes the field read only in edit forms
+func (ReadOnly) Edit() ReadOnly {
+	return 3
+}
+
+// GetTag returns the uadmin tag of the read only mode
+func (r *ReadOnly) GetTag() string {
+	if *r == r.Always() {
+		return "read_only"
+	}
+	if *r == r.Edit() {
+		return "read_only:true,edit"
+	}
+	if *r == r.New() {
+		return "read_only:true,new"
+	}
+	return ""
+}
+
+// BuilderField is a field of a model defined using the model builder
+type BuilderField struct {
+	Model
+	Builder           Builder `uadmin:"required;list_exclude"`
+	BuilderID         uint
+	Name              string    `uadmin:"required;list_exclude"`
+	CodeName          string    `uadmin:"read_only"`
+	Type              FieldType `uadmin:"required"`
+	FKModel           string    `uadmin:"list_exclude;help:Model name of foreign key and M2M fields"`
+	Required          bool
+	Help              string `uadmin:"list_exclude"`
+	Search            bool   `uadmin:"list_exclude"`
+	ReadOnly          ReadOnly
+	Max               string `uadmin:"list_exclude"`
+	Min               string `uadmin:"list_exclude"`
+	Hidden            bool
+	ListExclude       bool `uadmin:"list_exclude"`
+	Filter            bool
+	CategoricalFilter bool   `uadmin:"list_exclude"`
+	Encrypt           bool   `uadmin:"list_exclude"`
+	DefaultValue      string `uadmin:"list_exclude"`
+	Pattern           string `uadmin:"list_exclude"`
+	PatternMsg        string `uadmin:"list_exclude"`
+	DisplayName       string `uadmin:"list_exclude"`
+}
+
+func (b BuilderField) String() string {
+	return b.Name
+}
+
+// builderFieldTypes are the Go types of builder field types. Foreign
+// keys, M2M fields and static lists depend on other types.
+var builderFieldTypes = map[FieldType]reflect.Type{
+	FieldType(0).Boolean():      reflect.TypeOf(false),
+	FieldType(0).Code():         reflect.TypeOf(""),
+	FieldType(0).DateTime():     reflect.TypeOf(time.Time{}),
+	FieldType(0).DateTimePtr():  reflect.TypeOf(&time.Time{}),
+	FieldType(0).Email():        reflect.TypeOf(""),
+	FieldType(0).File():         reflect.TypeOf(""),
+	FieldType(0).Float():        reflect.TypeOf(float64(0)),
+	FieldType(0).HTML():         reflect.TypeOf(""),
+	FieldType(0).Image():        reflect.TypeOf(""),
+	FieldType(0).Int():          reflect.TypeOf(int(0)),
+	FieldType(0).Link():         reflect.TypeOf(""),
+	FieldType(0).Money():        reflect.TypeOf(float64(0)),
+	FieldType(0).Multilingual(): reflect.TypeOf(""),
+	FieldType(0).Password():     reflect.TypeOf(""),
+	FieldType(0).ProgressBar():  reflect.TypeOf(float64(0)),
+	FieldType(0).String():       reflect.TypeOf(""),
+}
+
+// builderFieldTags are the uadmin tags of builder field types
+var builderFieldTags = map[FieldType]string{
+	FieldType(0).Code():         "code",
+	FieldType(0).Email():        "email",
+	FieldType(0).File():         "file",
+	FieldType(0).HTML():         "html",
+	FieldType(0).Image():        "image",
+	FieldType(0).Link():         "link",
+	FieldType(0).Money():        "money",
+	FieldType(0).Multilingual(): "multilingual",
+	FieldType(0).Password():     "password",
+	FieldType(0).ProgressBar():  "progress_bar",
+}
+
+// GetTag returns the uadmin tag of the field
+func (b *BuilderField) GetTag() string {
+	fTags := []string{}
+	if val, ok := builderFieldTags[b.Type]; ok {
+		fTags = append(fTags, val)
+	}
+	if b.Required {
+		fTags = append(fTags, "required")
+	}
+	if b.Help != "" {
+		fTags = append(fTags, "help:"+b.Help)
+	}
+	if b.Search {
+		fTags = append(fTags, "search")
+	}
+	if b.ReadOnly != ReadOnly(0) {
+		fTags = append(fTags, b.ReadOnly.GetTag())
+	}
+	if b.Max != "" {
+		fTags = append(fTags, "max:"+b.Max)
+	}
+	if b.Min != "" {
+		fTags = append(fTags, "min:"+b.Min)
+	}
+	if b.Hidden {
+		fTags = append(fTags, "hidden")
+	}
+	if b.ListExclude {
+		fTags = append(fTags, "list_exclude")
+	}
+	if b.Filter {
+		fTags = append(fTags, "filter")
+	}
+	if b.CategoricalFilter {
+		fTags = append(fTags, "categorical_filter")
+	}
+	if b.Encrypt {
+		fTags = append(fTags, "encrypt")
+	}
+	if b.DefaultValue != "" {
+		fTags = append(fTags, "default_value:"+b.DefaultValue)
+	}
+	if b.Pattern != "" {
+		fTags = append(fTags, "pattern:"+b.Pattern)
+	}
+	if b.PatternMsg != "" {
+		fTags = append(fTags, "pattern_msg:"+b.PatternMsg)
+	}
+	if b.DisplayName != "" {
+		fTags = append(fTags, "display_name:"+b.DisplayName)
+	}
+	if len(fTags) == 0 {
+		return ""
+	}
+	return `uadmin:"` + strings.Join(fTags, ";") + `"`
+}
+
+// getStructFields returns the struct fields of the field. types has the
+// types of models by model name for foreign keys and M2M fields.
+func (b *BuilderField) getStructFields(types map[string]reflect.Type) ([]reflect.StructField, error) {
+	tag := reflect.StructTag(b.GetTag())
+	if fType, ok := builderFieldTypes[b.Type]; ok {
+		return []reflect.StructField{{Name: b.CodeName, Type: fType, Tag: tag}}, nil
+	}
+	switch b.Type {
+	case b.Type.ForeignKey(), b.Type.M2M():
+		fkType, ok := types[strings.ToLower(b.FKModel)]
+		if !ok {
+			return nil, fmt.Errorf("model not found: %s", b.FKModel)
+		}
+		if b.Type == b.Type.M2M() {
+			// M2M fields are stored in a join table by uadmin
+			tag = reflect.StructTag(strings.TrimSpace(`gorm:"-" ` + string(tag)))
+			return []reflect.StructField{{Name: b.CodeName, Type: reflect.SliceOf(fkType), Tag: tag}}, nil
+		}
+		return []reflect.StructField{
+			{Name: b.CodeName, Type: fkType, Tag: tag},
+			{Name: b.CodeName + "ID", Type: reflect.TypeOf(uint(0))},
+		}, nil
+	case b.Type.StaticList():
+		return nil, fmt.Errorf("static list fields need a type with choices in Go code")
+	}
+	return nil, fmt.Errorf("invalid field type: %d", b.Type)
+}
+
+// GetCode returns the Go code of the field and the package it imports
+func (b *BuilderField) GetCode() (code string, imports string) {
+	fkType := helper.ToCamel(b.FKModel)
+	if m, ok := registeredModels()[strings.ToLower(b.FKModel)]; ok {
+		fkType = getTypeName(reflect.TypeOf(m))
+		if reflect.TypeOf(m).PkgPath() == reflect.TypeOf(Model{}).PkgPath() {
+			fkType = "uadmin." + fkType
+		}
+	}
+
+	fType := ""
+	switch b.Type {
+	case b.Type.ForeignKey():
+		fType = fkType
+	case b.Type.M2M():
+		fType = "[]" + fkType
+	case b.Type.StaticList():
+		fType = b.CodeName
+	default:
+		if t, ok := builderFieldTypes[b.Type]; ok {
+			fType = t.String()
+		}
+	}
+	if strings.Contains(fType, "time.") {
+		imports = "time"
+	}
+
+	tag := b.GetTag()
+	if b.Type == b.Type.M2M() {
+		tag = strings.TrimSpace(`gorm:"-" ` + tag)
+	}
+	code = b.CodeName + " " + fType
+	if tag != "" {
+		code += " `" + tag + "`"
+	}
+	if b.Type == b.Type.ForeignKey() {
+		code += "\n\t" + b.CodeName + "ID uint"
+	}
+	return code, imports
+}
+
+// Validate the field when saving from uadmin
+func (b BuilderField) Validate() (ret map[string]string) {
+	ret = map[string]string{}
+	codeName := helper.ToCamel(b.Name)
+	if codeName == "" || codeName[0] < 'A' || codeName[0] > 'Z' {
+		ret["Name"] = "Name should start with a letter"
+	} else if builderReservedFields[codeName] {
+		ret["Name"] = codeName + " is a field added by the model builder"
+	} else if b.Type == b.Type.ForeignKey() && builderReservedFields[codeName+"ID"] {
+		ret["Name"] = codeName + "ID is a field added by the model builder"
+	} else {
+		fields := []BuilderField{}
+		Filter(&fields, "builder_id = ? AND id <> ?", b.BuilderID, b.ID)
+		for _, f := range fields {
+			// Foreign keys have a field with their name and ID
+			if f.CodeName == codeName || (f.Type == f.Type.ForeignKey() && f.CodeName+"ID" == codeName) {
+				ret["Name"] = "There is another field named " + codeName
+			} else if b.Type == b.Type.ForeignKey() && f.CodeName == codeName+"ID" {
+				ret["Name"] = "There is another field named " + codeName + "ID"
+			}
+		}
+	}
+	if b.Type == b.Type.ForeignKey() || b.Type == b.Type.M2M() {
+		builder := Builder{}
+		Get(&builder, "id = ?", b.BuilderID)
+		if _, ok := registeredModels()[strings.ToLower(b.FKModel)]; !ok {
+			ret["FKModel"] = "Model not found"
+		} else if strings.EqualFold(builder.GetCodeName(), helper.ToCamel(b.FKModel)) {
+			ret["FKModel"] = "A model cannot refer to itself"
+		}
+	} else if b.Type == b.Type.StaticList() {
+		ret["Type"] = "Static lists need a type with choices in Go code"
+	}
+	return
+}
+
+// Save the field and rebuild the models of the model builder
+func (b *BuilderField) Save() {
+	b.CodeName = helper.ToCamel(b.Name)
+	Save(b)
+	if err := loadBuilderModels(); err != nil {
+		Trail(ERROR, "BuilderField.Save unable to build %s. %s", b.Name, err)
+	}
+}
+
+// Delete the fields and rebuild the models of the model builder. The
+// columns of the fields stay in the database.
+func (BuilderField) Delete(a interface{}, query string, args ...interface{}) {
+	DeleteList(a, query, args...)
+	if err := loadBuilderModels(); err != nil {
+		Trail(ERROR, "BuilderField.Delete unable to build models. %s", err)
+	}
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (BuilderField) HideInDashboard() bool {
+	return true
+}
@@ -0,0 +1,168 @@
+package uadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TestBuilder is a unit testing function for models built at runtime
+func (t *UAdminTests) TestBuilder() {
+	customer := Builder{Name: "Test Customer", Category: "Builder", CreatedByField: true}
+	customer.Save()
+	fields := []BuilderField{
+		{BuilderID: customer.ID, Name: "Name", Type: FieldType(0).String(), Required: true, Search: true},
+		{BuilderID: customer.ID, Name: "Age", Type: FieldType(0).Int(), Filter: true, Min: "0"},
+		{BuilderID: customer.ID, Name: "Joined", Type: FieldType(0).DateTimePtr()},
+	}
+	for i := range fields {
+		if errs := fields[i].Validate(); len(errs) != 0 {
+			t.Errorf("BuilderField.Validate returned errors for %s. %v", fields[i].Name, errs)
+		}
+		fields[i].Save()
+	}
+	order := Builder{Name: "Test Order"}
+	order.Save()
+	orderFields := []BuilderField{
+		{BuilderID: order.ID, Name: "Customer", Type: FieldType(0).ForeignKey(), FKModel: "TestCustomer", Required: true},
+		{BuilderID: order.ID, Name: "Total", Type: FieldType(0).Money()},
+		{BuilderID: order.ID, Name: "Groups", Type: FieldType(0).M2M(), FKModel: "usergroup"},
+	}
+	for i := range orderFields {
+		orderFields[i].Save()
+	}
+	defer func() {
+		Builder{}.Delete(&Builder{}, "id IN (?)", []uint{customer.ID, order.ID})
+		if _, ok := models["testorder"]; ok {
+			t.Errorf("Builder.Delete didn't unregister testorder")
+		}
+		db.Exec("DROP TABLE IF EXISTS test_orders")
+		db.Exec("DROP TABLE IF EXISTS test_customers")
+		db.Exec("DROP TABLE IF EXISTS testorder_usergroup")
+	}()
+
+	// Validation
+	if errs := (Builder{Name: "User"}).Validate(); errs["Name"] == "" {
+		t.Errorf("Builder.Validate didn't return an error for the name of a registered model")
+	}
+	if errs := (BuilderField{BuilderID: customer.ID, Name: "Age", Type: FieldType(0).Int()}).Validate(); errs["Name"] == "" {
+		t.Errorf("BuilderField.Validate didn't return an error for a duplicate field name")
+	}
+	if errs := (BuilderField{BuilderID: customer.ID, Name: "Parent", Type: FieldType(0).ForeignKey(), FKModel: "testcustomer"}).Validate(); errs["FKModel"] == "" {
+		t.Errorf("BuilderField.Validate didn't return an error for a foreign key to the same model")
+	}
+	if errs := (BuilderField{BuilderID: customer.ID, Name: "Created By", Type: FieldType(0).String()}).Validate(); errs["Name"] == "" {
+		t.Errorf("BuilderField.Validate didn't return an error for a field added by the model builder")
+	}
+	if errs := (BuilderField{BuilderID: order.ID, Name: "Total ID", Type: FieldType(0).Int()}).Validate(); len(errs) != 0 {
+		t.Errorf("BuilderField.Validate returned errors for Total ID. %v", errs)
+	}
+	if errs := (BuilderField{BuilderID: customer.ID, Name: "Age", Type: FieldType(0).ForeignKey(), FKModel: "usergroup"}).Validate(); errs["Name"] == "" {
+		t.Errorf("BuilderField.Validate didn't return an error for a foreign key with the name of a field")
+	}
+	Save(&BuilderField{BuilderID: order.ID, Name: "Category ID", CodeName: "CategoryID", Type: FieldType(0).Int()})
+	if errs := (BuilderField{BuilderID: order.ID, Name: "Category", Type: FieldType(0).ForeignKey(), FKModel: "usergroup"}).Validate(); errs["Name"] == "" {
+		t.Errorf("BuilderField.Validate didn't return an error for a foreign key with the ID field of another field")
+	}
+	DeleteList(&BuilderField{}, "builder_id = ? AND code_name = ?", order.ID, "CategoryID")
+	dup := []BuilderField{{Name: "Created By", CodeName: "CreatedBy", Type: FieldType(0).String()}}
+	if _, err := customer.buildModel(dup, map[string]reflect.Type{}); err == nil {
+		t.Errorf("Builder.buildModel didn't return an error for duplicate fields")
+	}
+
+	// The models are registered
+	s, ok := getSchema("testorder")
+	if !ok || s.Name != "TestOrder" || s.TableName != "test_orders" {
+		t.Errorf("Builder didn't register testorder. Got %#v", s)
+		return
+	}
+	if f := s.FieldByName("Customer"); f.Type != cFK || f.TypeName != "TestCustomer" || !f.Required {
+		t.Errorf("Builder didn't build the foreign key of testorder. Got %#v", f)
+	}
+	if f := s.FieldByName("Groups"); f.Type != cM2M {
+		t.Errorf("Builder didn't build the M2M field of testorder. Got %#v", f)
+	}
+	if s, _ := getSchema("testcustomer"); s.Category != "Builder" || !s.FieldByName("Name").Searchable || !s.FieldByName("Age").Filter || s.FieldByName("CreatedBy").ReadOnly == "" {
+		t.Errorf("Builder didn't build the tags of testcustomer. Got %#v", s)
+	}
+	if !db.Migrator().HasTable("test_customers") || !db.Migrator().HasColumn("test_customers", "created_by") || !db.Migrator().HasTable("testorder_usergroup") {
+		t.Errorf("Builder didn't create the tables of the models")
+	}
+
+	// Records can be saved and loaded like any other model
+	c, _ := NewModel("testcustomer", true)
+	c.Elem().FieldByName("Name").SetString("John")
+	c.Elem().FieldByName("Age").SetInt(30)
+	now := time.Now()
+	c.Elem().FieldByName("Joined").Set(reflect.ValueOf(&now))
+	if err := Save(c.Interface()); err != nil || GetID(c) == 0 {
+		t.Errorf("Unable to save a record of testcustomer. %v", err)
+	}
+	o, _ := NewModel("testorder", true)
+	o.Elem().FieldByName("CustomerID").SetUint(uint64(GetID(c)))
+	o.Elem().FieldByName("Total").SetFloat(10.5)
+	Save(o.Interface())
+	o2, _ := NewModel("testorder", true)
+	Get(o2.Interface(), "id = ?", GetID(o))
+	Preload(o2.Interface())
+	if name := o2.Elem().FieldByName("Customer").FieldByName("Name").String(); name != "John" {
+		t.Errorf("Unable to preload the customer of testorder. Got %s", name)
+	}
+
+	// Adding a field migrates the table
+	f := BuilderField{BuilderID: customer.ID, Name: "Email", Type: FieldType(0).Email()}
+	f.Save()
+	if !db.Migrator().HasColumn("test_customers", "email") || Schema["testcustomer"].FieldByName("Email").Type != cEMAIL {
+		t.Errorf("BuilderField.Save didn't migrate the new field")
+	}
+
+	// The models are served by the dAPI
+	u1 := &User{Username: "builder1", Password: "builder1" + testPassword, Active: true, RemoteAccess: true, Admin: true}
+	u1.Save()
+	s1 := &Session{Active: true, UserID: u1.ID, LoginTime: time.Now()}
+	s1.GenerateKey()
+	s1.Save()
+	defer Delete(u1)
+	defer Delete(s1)
+	r := httptest.NewRequest("GET", "/api/d/testcustomer/read/?name=John", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	w := httptest.NewRecorder()
+	apiHandler(w, r)
+	buf, _ := io.ReadAll(w.Result().Body)
+	obj := map[string]interface{}{}
+	json.Unmarshal(buf, &obj)
+	if result, ok := obj["result"].([]interface{}); !ok || len(result) != 1 {
+		t.Errorf("dAPI didn't read testcustomer. Got %s", string(buf))
+	}
+
+	// The models are served in the admin
+	Preload(s1)
+	for _, url := range []string{"/testorder/", fmt.Sprintf("/testorder/%d", GetID(o)), "/testorder/new"} {
+		w = httptest.NewRecorder()
+		if strings.HasSuffix(url, "/") {
+			listHandler(w, httptest.NewRequest("GET", url, nil), s1)
+		} else {
+			formHandler(w, httptest.NewRequest("GET", url, nil), s1)
+		}
+		if w.Code != 200 {
+			t.Errorf("Admin didn't serve %s. Got %d", url, w.Code)
+		}
+	}
+
+	// Export as Go code
+	code := order.GetCode()
+	for _, e := range []string{"type TestOrder struct {", "\tCustomer   TestCustomer `uadmin:\"required\"`\n\tCustomerID uint\n", "Groups     []uadmin.UserGroup `gorm:\"-\"`", "`uadmin:\"money\"`"} {
+		if !strings.Contains(code, e) {
+			t.Errorf("Builder.GetCode didn't generate %q. Got\n%s", e, code)
+		}
+	}
+	quoted := Builder{Name: "Test Quoted", Category: `Sales "EU"\`}
+	if code = quoted.GetCode(); !strings.Contains(code, `return "Sales \"EU\"\\"`) {
+		t.Errorf("Builder.GetCode didn't quote the category. Got\n%s", code)
+	}
+}
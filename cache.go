@@ -0,0 +1,235 @@
+package uadmin
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QueryCacher is implemented by models whose query results are cached.
+// Results of Get, Filter, Count and dAPI read are kept in QueryCacheStore
+// for the returned duration and are removed when the table of the model is
+// written to. Cached records are decrypted so don't cache models with
+// encrypted fields in a shared store.
+type QueryCacher interface {
+	QueryCacheTTL() time.Duration
+}
+
+// CacheStore stores cached query results
+type CacheStore interface {
+	// Get returns the value of a key and true if the key exists and is not
+	// expired
+	Get(key string) ([]byte, bool)
+	// Set stores the value of a key for ttl
+	Set(key string, value []byte, ttl time.Duration)
+	// DeletePrefix deletes all keys that start with prefix
+	DeletePrefix(prefix string)
+}
+
+// memoryCacheStore is a CacheStore in memory that removes the least recently
+// used values when the size of the values is over QueryCacheMemoryLimit
+type memoryCacheStore struct {
+	lock  sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	size  int
+}
+
+// memoryCacheItem is a value in memoryCacheStore
+type memoryCacheItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCacheStore returns a CacheStore that keeps the values in memory
+func NewMemoryCacheStore() CacheStore {
+	return &memoryCacheStore{
+		items: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+}
+
+// Get returns the value of a key
+func (c *memoryCacheStore) Get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := e.Value.(*memoryCacheItem)
+	if time.Now().After(item.expires) {
+		c.remove(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return item.value, true
+}
+
+// Set stores the value of a key for ttl
+func (c *memoryCacheStore) Set(key string, value []byte, ttl time.Duration) {
+	if len(value) > QueryCacheMemoryLimit {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	c.items[key] = c.lru.PushFront(&memoryCacheItem{
+		key:     key,
+		value:   value,
+		expires: time.Now().Add(ttl),
+	})
+	c.size += len(value)
+	for c.size > QueryCacheMemoryLimit {
+		c.remove(c.lru.Back())
+	}
+}
+
+// DeletePrefix deletes all keys that start with prefix
+func (c *memoryCacheStore) DeletePrefix(prefix string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, e := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(e)
+		}
+	}
+}
+
+// remove removes an item from the store. The lock must be held.
+func (c *memoryCacheStore) remove(e *list.Element) {
+	item := e.Value.(*memoryCacheItem)
+	c.lru.Remove(e)
+	delete(c.items, item.key)
+	c.size -= len(item.value)
+}
+
+// cachedTables are the tables with cached query results. Writes to other
+// tables don't need to invalidate the cache.
+var cachedTables sync.Map
+
+// cacheTablesKey is the context key of the tables written in a transaction
+type cacheTablesKey struct{}
+
+// cachePrefix returns the prefix of the cache keys of a table
+func cachePrefix(dbName string, table string) string {
+	return "uadmin:query:" + dbName + ":" + table + ":"
+}
+
+// queryCacheKey returns the cache key of a query of a model, a pointer to a
+// model or a slice of models and the time to cache it. The key is empty if
+// the model is not cached.
+func queryCacheKey(a interface{}, op string, query interface{}, args []interface{}) (string, time.Duration) {
+	t := reflect.TypeOf(a)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return "", 0
+	}
+	m := reflect.New(t).Interface()
+	cacher, ok := m.(QueryCacher)
+	if !ok || cacher.QueryCacheTTL() <= 0 || QueryCacheStore == nil {
+		return "", 0
+	}
+	stmt := &gorm.Statement{DB: modelDB(m)}
+	if err := stmt.Parse(m); err != nil {
+		return "", 0
+	}
+	prefix := cachePrefix(getDBName(m), stmt.Schema.Table)
+	cachedTables.Store(prefix, true)
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%v|%#v", query, args)))
+	return prefix + op + ":" + hex.EncodeToString(hash[:]), cacher.QueryCacheTTL()
+}
+
+// getCache decodes the cached value of key into a and returns true if it
+// was found
+func getCache(key string, a interface{}) bool {
+	buf, ok := QueryCacheStore.Get(key)
+	if !ok {
+		return false
+	}
+	v := reflect.ValueOf(a).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(a); err != nil {
+		Trail(WARNING, "Unable to decode cached query of %s. %s", getModelName(a), err)
+		return false
+	}
+	return true
+}
+
+// setCache stores a in the cache for ttl
+func setCache(key string, ttl time.Duration, a interface{}) {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(a); err != nil {
+		Trail(WARNING, "Unable to cache query of %s. %s", getModelName(a), err)
+		return
+	}
+	QueryCacheStore.Set(key, buf.Bytes(), ttl)
+}
+
+// invalidateCache removes the cached query results of a model
+func invalidateCache(a interface{}) {
+	stmt := &gorm.Statement{DB: modelDB(a)}
+	if err := stmt.Parse(a); err != nil {
+		return
+	}
+	invalidateTable(getDBName(a), stmt.Schema.Table)
+}
+
+// invalidateTable removes the cached query results of a table
+func invalidateTable(dbName string, table string) {
+	prefix := cachePrefix(dbName, table)
+	if _, ok := cachedTables.Load(prefix); ok && QueryCacheStore != nil {
+		QueryCacheStore.DeletePrefix(prefix)
+	}
+}
+
+// registerCacheCallbacks invalidates the cached query results of tables
+// written to through a connection. Tables written to in a transaction are
+// invalidated again after the transaction ends so results read before the
+// commit are not kept.
+func registerCacheCallbacks(conn *gorm.DB, dbName string) {
+	if conn == nil || conn.Callback().Create().Get("uadmin:invalidate_cache") != nil {
+		return
+	}
+	invalidate := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Table == "" {
+			return
+		}
+		invalidateTable(dbName, tx.Statement.Table)
+		if tables, ok := tx.Statement.Context.Value(cacheTablesKey{}).(map[string]bool); ok {
+			tables[cachePrefix(dbName, tx.Statement.Table)] = true
+		}
+	}
+	conn.Callback().Create().After("gorm:create").Register("uadmin:invalidate_cache", invalidate)
+	conn.Callback().Update().After("gorm:update").Register("uadmin:invalidate_cache", invalidate)
+	conn.Callback().Delete().After("gorm:delete").Register("uadmin:invalidate_cache", invalidate)
+}
+
+// withCacheTables returns a context that records the tables written to in a
+// transaction and a function that invalidates them
+func withCacheTables(ctx context.Context) (context.Context, func()) {
+	tables := map[string]bool{}
+	return context.WithValue(ctx, cacheTablesKey{}, tables), func() {
+		for prefix := range tables {
+			if QueryCacheStore != nil {
+				QueryCacheStore.DeletePrefix(prefix)
+			}
+		}
+	}
+}
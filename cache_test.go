@@ -0,0 +1,121 @@
+package uadmin
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+type TestCachedItem struct {
+	Model
+	Name string
+}
+
+func (TestCachedItem) QueryCacheTTL() time.Duration {
+	return time.Minute
+}
+
+// TestCache is a unit testing function for the query cache
+func (t *UAdminTests) TestCache() {
+	initializeDB(TestCachedItem{})
+	defer db.Migrator().DropTable(&TestCachedItem{})
+
+	item := TestCachedItem{Name: "cached"}
+	Save(&item)
+
+	// Writes that skip the helpers don't invalidate the cache so they show
+	// whether results are read from the cache
+	r := TestCachedItem{}
+	Get(&r, "id = ?", item.ID)
+	db.Exec("UPDATE test_cached_items SET name = ? WHERE id = ?", "raw", item.ID)
+	r = TestCachedItem{}
+	Get(&r, "id = ?", item.ID)
+	if r.Name != "cached" {
+		t.Errorf("Get didn't read from the cache. Got %s", r.Name)
+	}
+
+	// Helpers invalidate the cache
+	Update(&item, "Name", "updated", "id = ?", item.ID)
+	r = TestCachedItem{}
+	Get(&r, "id = ?", item.ID)
+	if r.Name != "updated" {
+		t.Errorf("Update didn't invalidate the cache. Got %s", r.Name)
+	}
+
+	items := []TestCachedItem{}
+	Filter(&items, "")
+	if Count(&items, "") != 1 {
+		t.Errorf("Count returned the wrong count")
+	}
+	db.Exec("INSERT INTO test_cached_items (name) VALUES (?)", "raw")
+	items = []TestCachedItem{}
+	Filter(&items, "")
+	if len(items) != 1 || Count(&items, "") != 1 {
+		t.Errorf("Filter and Count didn't read from the cache. Got %d", len(items))
+	}
+	Save(&TestCachedItem{Name: "saved"})
+	Filter(&items, "")
+	if len(items) != 3 || Count(&items, "") != 3 {
+		t.Errorf("Save didn't invalidate the cache. Got %d", len(items))
+	}
+
+	// Writes in a transaction invalidate the cache
+	err := Transaction(context.Background(), func(tx *Tx) error {
+		return tx.Delete(&item)
+	})
+	if err != nil {
+		t.Errorf("Transaction returned an error. %s", err)
+	}
+	if Count(&items, "") != 2 {
+		t.Errorf("Transaction didn't invalidate the cache")
+	}
+
+	// dAPI reads with joins are not cached
+	models["testcacheditem"] = TestCachedItem{}
+	Schema["testcacheditem"], _ = getSchema(TestCachedItem{})
+	defer func() {
+		delete(models, "testcacheditem")
+		delete(Schema, "testcacheditem")
+	}()
+	session := &Session{User: User{Username: "cacheadmin", Admin: true}}
+	readJoin := func() string {
+		req := httptest.NewRequest("GET", "/?$join=users__left__id__id&$order=id", nil)
+		req.URL.Path = ""
+		req = req.WithContext(context.WithValue(req.Context(), CKey("modelName"), "testcacheditem"))
+		w := httptest.NewRecorder()
+		dAPIReadHandler(w, req, session)
+		return w.Body.String()
+	}
+	readJoin()
+	db.Exec("UPDATE test_cached_items SET name = ?", "joined")
+	if body := readJoin(); !strings.Contains(body, "joined") {
+		t.Errorf("dAPI read with a join was read from the cache. Got %s", body)
+	}
+
+	// The memory store removes expired and least recently used values
+	savedLimit := QueryCacheMemoryLimit
+	QueryCacheMemoryLimit = 10
+	defer func() {
+		QueryCacheMemoryLimit = savedLimit
+	}()
+	store := NewMemoryCacheStore()
+	store.Set("a", []byte("1234"), time.Minute)
+	store.Set("b", []byte("1234"), time.Minute)
+	store.Get("a")
+	store.Set("c", []byte("1234"), time.Minute)
+	if _, ok := store.Get("b"); ok {
+		t.Errorf("Memory cache store didn't remove the least recently used value")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Errorf("Memory cache store removed a recently used value")
+	}
+	store.Set("d", []byte("1"), -time.Second)
+	if _, ok := store.Get("d"); ok {
+		t.Errorf("Memory cache store returned an expired value")
+	}
+	store.DeletePrefix("a")
+	if _, ok := store.Get("a"); ok {
+		t.Errorf("Memory cache store didn't delete by prefix")
+	}
+}
@@ -0,0 +1,75 @@
+package uadmin
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// commands are the commands that can be passed to an application as
+// arguments to run instead of starting the server. The uadmin command line
+// tool runs them in the project folder:
+//
+//	uadmin datamigrate status
+var commands = map[string]func(args []string) error{
+	"datamigrate": migrateDataCommand,
+	"migrate":     migrateCommand,
+	"dumpdata":    dumpDataCommand,
+	"loaddata":    loadDataCommand,
+	"user":        userCommand,
+	"perm":        permCommand,
+	"inspectdb":   inspectDBCommand,
+	"builder":     builderCommand,
+	"backup":      backupCommand,
+}
+
+// runCommand runs a command passed as arguments to the application. It
+// returns false if the first argument is not a command.
+func runCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+	return true, command(args[1:])
+}
+
+// handleCommand runs the command in the arguments of the application and
+// exits after running it. Register calls it before migrating the database so
+// commands like migrate plan see the schema as it is, and the job scheduler
+// and the task workers don't start because the server doesn't start.
+func handleCommand(modelList []interface{}) {
+	args := os.Args[1:]
+	if len(args) == 0 || commands[args[0]] == nil {
+		return
+	}
+
+	// Open the database and register the models without migrating them
+	db = GetDB()
+	db.AllowGlobalUpdate = true
+	initializeKeys()
+	commandModels := map[string]interface{}{}
+	for _, m := range modelList {
+		commandModels[strings.ToLower(getTypeName(reflect.TypeOf(m)))] = m
+	}
+	modelsMutex.Lock()
+	models = commandModels
+	modelsMutex.Unlock()
+	commandSchema := map[string]ModelSchema{}
+	for k, v := range commandModels {
+		commandSchema[k], _ = getSchema(v)
+	}
+	modelsMutex.Lock()
+	Schema = commandSchema
+	modelsMutex.Unlock()
+
+	_, err := runCommand(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,399 @@
+package uadmin
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// cliActor is the username in the log of changes made using commands
+const cliActor = "cli"
+
+// permissionNames are the permissions that can be granted using the perm
+// command
+var permissionNames = []string{"read", "add", "edit", "delete", "approval", "restore", "purge"}
+
+// logCLIChange adds a log of a change made using a command
+func logCLIChange(a interface{}, modelName string, id uint, action Action) {
+	if log := newCLILog(a, modelName, id, action); log != nil {
+		log.Save()
+	}
+}
+
+// newCLILog returns a log of a change made by a command without saving it.
+// Modified logs store the record before the change so they are created
+// before changing the record and saved after it is saved.
+func newCLILog(a interface{}, modelName string, id uint, action Action) *Log {
+	r := &http.Request{RemoteAddr: cliActor, Header: http.Header{}}
+	log := &Log{}
+	if err := log.ParseRecord(reflect.ValueOf(a), modelName, id, &User{Username: cliActor}, action, r); err != nil {
+		return nil
+	}
+	return log
+}
+
+// parseCommandFlags splits command arguments into --name value flags and
+// positional arguments. Flags in boolFlags don't have values.
+func parseCommandFlags(args []string, boolFlags ...string) (map[string]string, []string, error) {
+	flags := map[string]string{}
+	params := []string{}
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			params = append(params, args[i])
+			continue
+		}
+		name := strings.TrimPrefix(args[i], "--")
+		if parts := strings.SplitN(name, "=", 2); len(parts) == 2 {
+			flags[parts[0]] = parts[1]
+			continue
+		}
+		isBool := false
+		for _, b := range boolFlags {
+			isBool = isBool || b == name
+		}
+		if isBool {
+			flags[name] = "true"
+			continue
+		}
+		if i+1 == len(args) {
+			return nil, nil, fmt.Errorf("missing value of --%s", name)
+		}
+		flags[name] = args[i+1]
+		i++
+	}
+	return flags, params, nil
+}
+
+// readCommandPassword returns the password from the --password flag or
+// reads it from the standard input
+func readCommandPassword(flags map[string]string) (string, error) {
+	if password, ok := flags["password"]; ok {
+		return password, nil
+	}
+	fmt.Print("Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("the password is empty. %v", err)
+	}
+	return password, nil
+}
+
+// getCommandUser returns a user by username
+func getCommandUser(username string) (*User, error) {
+	user := User{}
+	Get(&user, "username = ?", strings.ToLower(username))
+	if user.ID == 0 {
+		return nil, fmt.Errorf("user not found: %s", username)
+	}
+	return &user, nil
+}
+
+// getCommandGroup returns a user group by name
+func getCommandGroup(name string) (*UserGroup, error) {
+	group := UserGroup{}
+	Get(&group, "group_name = ?", name)
+	if group.ID == 0 {
+		return nil, fmt.Errorf("user group not found: %s", name)
+	}
+	return &group, nil
+}
+
+// userCommand runs the user command:
+//
+//	user create USERNAME [--password PASSWORD] [--email EMAIL] [--first-name NAME]
+//	                     [--last-name NAME] [--group GROUP] [--admin]
+//	user passwd USERNAME [--password PASSWORD]
+//	user disable USERNAME
+//	user otp-reset USERNAME
+//	user list
+func userCommand(args []string) error {
+	flags, params, err := parseCommandFlags(args, "admin")
+	if err != nil {
+		return err
+	}
+	if len(params) == 0 {
+		return fmt.Errorf("usage: user create|passwd|disable|otp-reset|list")
+	}
+	if params[0] == "list" {
+		users := []User{}
+		FilterSorted("username", true, &users, "")
+		fmt.Printf("%-6s %-20s %-30s %-30s %-6s %-6s %-4s %s\n", "ID", "Username", "Name", "Email", "Active", "Admin", "OTP", "Group")
+		for _, u := range users {
+			Preload(&u)
+			fmt.Printf("%-6d %-20s %-30s %-30s %-6v %-6v %-4v %s\n", u.ID, u.Username, strings.TrimSpace(u.String()), u.Email, u.Active, u.Admin, u.OTPRequired, u.UserGroup.GroupName)
+		}
+		return nil
+	}
+	if len(params) != 2 {
+		return fmt.Errorf("usage: user %s USERNAME", params[0])
+	}
+	username := params[1]
+
+	switch params[0] {
+	case "create":
+		user := User{
+			Username:     strings.ToLower(username),
+			FirstName:    flags["first-name"],
+			LastName:     flags["last-name"],
+			Email:        flags["email"],
+			Active:       true,
+			Admin:        flags["admin"] == "true",
+			RemoteAccess: true,
+		}
+		if user.FirstName == "" {
+			user.FirstName = username
+		}
+		if group, ok := flags["group"]; ok {
+			g, err := getCommandGroup(group)
+			if err != nil {
+				return err
+			}
+			user.UserGroupID = g.ID
+		}
+		if user.Password, err = readCommandPassword(flags); err != nil {
+			return err
+		}
+		if errs := user.Validate(); len(errs) != 0 {
+			for _, v := range errs {
+				return fmt.Errorf("unable to create %s. %s", username, v)
+			}
+		}
+		user.Save()
+		if user.ID == 0 {
+			return fmt.Errorf("unable to create %s", username)
+		}
+		logCLIChange(&user, "user", user.ID, Action(0).Added())
+		fmt.Println("Created user", user.Username)
+	case "passwd":
+		user, err := getCommandUser(username)
+		if err != nil {
+			return err
+		}
+		log := newCLILog(user, "user", user.ID, Action(0).Modified())
+		if user.Password, err = readCommandPassword(flags); err != nil {
+			return err
+		}
+		if msg := user.validatePass(); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		user.Password = hashPass(user.Password)
+		user.PasswordReset = nil
+		if err = Save(user); err != nil {
+			return err
+		}
+		loadSessions()
+		if log != nil {
+			log.Save()
+		}
+		fmt.Println("Changed the password of", user.Username)
+	case "disable":
+		user, err := getCommandUser(username)
+		if err != nil {
+			return err
+		}
+		log := newCLILog(user, "user", user.ID, Action(0).Modified())
+		user.Active = false
+		if err = Save(user); err != nil {
+			return err
+		}
+		// Log out the active sessions of the user
+		db.Model(&Session{}).Where("user_id = ?", user.ID).Update("active", false)
+		loadSessions()
+		if log != nil {
+			log.Save()
+		}
+		fmt.Println("Disabled user", user.Username)
+	case "otp-reset":
+		user, err := getCommandUser(username)
+		if err != nil {
+			return err
+		}
+		log := newCLILog(user, "user", user.ID, Action(0).Modified())
+		user.OTPRequired = false
+		user.OTPSeed, _ = generateOTPSeed(OTPDigits, OTPAlgorithm, OTPSkew, OTPPeriod, user)
+		if err = Save(user); err != nil {
+			return err
+		}
+		loadSessions()
+		if log != nil {
+			log.Save()
+		}
+		fmt.Println("Reset the OTP of", user.Username)
+	default:
+		return fmt.Errorf("unknown user command: %s", params[0])
+	}
+	return nil
+}
+
+// parsePermissions returns the permissions in a comma separated list. An
+// empty list or "all" is all the permissions.
+func parsePermissions(list string) (map[string]bool, error) {
+	perms := map[string]bool{}
+	if list == "" || list == "all" {
+		for _, name := range permissionNames {
+			perms[name] = true
+		}
+		return perms, nil
+	}
+	for _, name := range strings.Split(strings.ToLower(list), ",") {
+		name = strings.TrimSpace(name)
+		valid := false
+		for _, p := range permissionNames {
+			valid = valid || p == name
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid permission: %s. Valid permissions are %s", name, strings.Join(permissionNames, ","))
+		}
+		perms[name] = true
+	}
+	return perms, nil
+}
+
+// permissionField returns the field of a permission in a UserPermission or
+// a GroupPermission
+func permissionField(a interface{}, name string) reflect.Value {
+	return reflect.ValueOf(a).Elem().FieldByName(strings.ToUpper(name[:1]) + name[1:])
+}
+
+// permCommand runs the perm command:
+//
+//	perm grant --user USERNAME|--group GROUP MODEL [PERMISSIONS]
+//	perm revoke --user USERNAME|--group GROUP MODEL [PERMISSIONS]
+//	perm show --user USERNAME|--group GROUP
+//
+// PERMISSIONS is a comma separated list of read, add, edit, delete,
+// approval, restore and purge. The default is all of them. Revoking all
+// permissions removes the permission of the user or group.
+func permCommand(args []string) error {
+	flags, params, err := parseCommandFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(params) == 0 || (flags["user"] == "") == (flags["group"] == "") {
+		return fmt.Errorf("usage: perm grant|revoke|show --user USERNAME|--group GROUP [MODEL] [PERMISSIONS]")
+	}
+	var user *User
+	var group *UserGroup
+	if flags["user"] != "" {
+		if user, err = getCommandUser(flags["user"]); err != nil {
+			return err
+		}
+	} else if group, err = getCommandGroup(flags["group"]); err != nil {
+		return err
+	}
+
+	if params[0] == "show" {
+		menus := []DashboardMenu{}
+		FilterSorted("url", true, &menus, "")
+		fmt.Printf("%-30s %s\n", "Model", strings.Join(permissionNames, " "))
+		for _, m := range menus {
+			var perm interface{}
+			if user != nil {
+				p := user.GetAccess(m.URL)
+				perm = &p
+			} else {
+				p := group.hasAccess(m.URL)
+				perm = &p
+			}
+			values := []string{}
+			hasAccess := false
+			for _, name := range permissionNames {
+				value := "-"
+				if permissionField(perm, name).Bool() {
+					value = "x"
+					hasAccess = true
+				}
+				values = append(values, fmt.Sprintf("%-*s", len(name), value))
+			}
+			if hasAccess {
+				fmt.Printf("%-30s %s\n", m.URL, strings.Join(values, " "))
+			}
+		}
+		return nil
+	}
+
+	if len(params) < 2 || len(params) > 3 || (params[0] != "grant" && params[0] != "revoke") {
+		return fmt.Errorf("usage: perm grant|revoke --user USERNAME|--group GROUP MODEL [PERMISSIONS]")
+	}
+	modelName, ok := getInitialDataModelName(params[1])
+	if !ok {
+		return fmt.Errorf("model not found: %s", params[1])
+	}
+	menu := DashboardMenu{}
+	Get(&menu, "url = ?", modelName)
+	if menu.ID == 0 {
+		return fmt.Errorf("dashboard menu not found for %s", modelName)
+	}
+	list := ""
+	if len(params) == 3 {
+		list = params[2]
+	}
+	perms, err := parsePermissions(list)
+	if err != nil {
+		return err
+	}
+	grant := params[0] == "grant"
+
+	var perm interface{}
+	var permModel string
+	var id uint
+	if user != nil {
+		p := UserPermission{}
+		Get(&p, "user_id = ? AND dashboard_menu_id = ?", user.ID, menu.ID)
+		p.UserID = user.ID
+		p.DashboardMenuID = menu.ID
+		perm, permModel, id = &p, "userpermission", p.ID
+	} else {
+		p := GroupPermission{}
+		Get(&p, "user_group_id = ? AND dashboard_menu_id = ?", group.ID, menu.ID)
+		p.UserGroupID = group.ID
+		p.DashboardMenuID = menu.ID
+		perm, permModel, id = &p, "grouppermission", p.ID
+	}
+	if id == 0 && !grant {
+		fmt.Println("There is no permission to revoke")
+		return nil
+	}
+	// Take the snapshot of the permission before changing it
+	var log *Log
+	if id != 0 {
+		log = newCLILog(perm, permModel, id, Action(0).Modified())
+	}
+	for name := range perms {
+		permissionField(perm, name).SetBool(grant)
+	}
+
+	// Remove the permission if all of its permissions are revoked
+	hasAccess := false
+	for _, name := range permissionNames {
+		hasAccess = hasAccess || permissionField(perm, name).Bool()
+	}
+	if !hasAccess {
+		if err = db.Unscoped().Delete(perm).Error; err != nil {
+			return err
+		}
+		loadPermissions()
+		if log != nil {
+			log.Action = log.Action.Deleted()
+			log.Save()
+		}
+		fmt.Println("Removed the permission to", modelName)
+		return nil
+	}
+
+	if err = Save(perm); err != nil {
+		return err
+	}
+	loadPermissions()
+	if log != nil {
+		log.Save()
+	} else if id == 0 {
+		logCLIChange(perm, permModel, GetID(reflect.ValueOf(perm)), Action(0).Added())
+	}
+	fmt.Printf("Updated the permission to %s\n", modelName)
+	return nil
+}
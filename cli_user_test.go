@@ -0,0 +1,145 @@
+package uadmin
+
+import "encoding/json"
+
+// TestUserCommand is a unit testing function for the user and perm commands
+func (t *UAdminTests) TestUserCommand() {
+	logs := Count(&[]Log{}, "username = ?", cliActor)
+
+	// Create a user
+	if err := userCommand([]string{"create", "CLIUser", "--password", "Cl1-User-Passw0rd!", "--email", "cli@example.com", "--admin"}); err != nil {
+		t.Errorf("userCommand didn't create the user. %s", err)
+	}
+	user := User{}
+	Get(&user, "username = ?", "cliuser")
+	if user.ID == 0 || !user.Admin || !user.Active || user.Email != "cli@example.com" || verifyPassword(user.Password, "Cl1-User-Passw0rd!") != nil {
+		t.Errorf("userCommand created an invalid user. Got %#v", user)
+	}
+	if err := userCommand([]string{"create", "cliuser", "--password", "Cl1-User-Passw0rd!"}); err == nil {
+		t.Errorf("userCommand created a user with a username that is taken")
+	}
+
+	// Change the password
+	if err := userCommand([]string{"passwd", "cliuser", "--password=N3w-User-Passw0rd!"}); err != nil {
+		t.Errorf("userCommand didn't change the password. %s", err)
+	}
+	if err := userCommand([]string{"passwd", "cliuser", "--password", "123"}); err == nil {
+		t.Errorf("userCommand accepted an invalid password")
+	}
+	user = User{}
+	Get(&user, "username = ?", "cliuser")
+	if verifyPassword(user.Password, "N3w-User-Passw0rd!") != nil {
+		t.Errorf("userCommand didn't change the password")
+	}
+
+	// Reset OTP
+	seed := user.OTPSeed
+	db.Model(&User{}).Where("id = ?", user.ID).Update("otp_required", true)
+	if err := userCommand([]string{"otp-reset", "cliuser"}); err != nil {
+		t.Errorf("userCommand didn't reset OTP. %s", err)
+	}
+	user = User{}
+	Get(&user, "username = ?", "cliuser")
+	if user.OTPRequired || user.OTPSeed == seed || user.OTPSeed == "" {
+		t.Errorf("userCommand didn't reset OTP. Got %v %s", user.OTPRequired, user.OTPSeed)
+	}
+
+	// Disable the user and end their sessions
+	s := Session{UserID: user.ID, Active: true}
+	s.GenerateKey()
+	s.Save()
+	if err := userCommand([]string{"disable", "cliuser"}); err != nil {
+		t.Errorf("userCommand didn't disable the user. %s", err)
+	}
+	user = User{}
+	Get(&user, "username = ?", "cliuser")
+	s = Session{}
+	Get(&s, "user_id = ?", user.ID)
+	if user.Active || s.Active {
+		t.Errorf("userCommand didn't disable the user. Got %v %v", user.Active, s.Active)
+	}
+	// Modified logs store the user before the change
+	lastLog := func(modelName string, id uint, action Action) map[string]string {
+		logList := []Log{}
+		FilterSorted("id", false, &logList, "table_name = ? AND table_id = ? AND action = ?", modelName, id, action)
+		snapshot := map[string]string{}
+		if len(logList) != 0 {
+			json.Unmarshal([]byte(logList[0].Activity), &snapshot)
+		}
+		return snapshot
+	}
+	if snapshot := lastLog("user", user.ID, Action(0).Modified()); snapshot["Active"] != "true" {
+		t.Errorf("userCommand didn't log the user before disabling it. Got %v", snapshot)
+	}
+	if err := userCommand([]string{"disable", "unknown"}); err == nil {
+		t.Errorf("userCommand didn't return an error for an unknown user")
+	}
+	if err := userCommand([]string{"list"}); err != nil {
+		t.Errorf("userCommand didn't list the users. %s", err)
+	}
+
+	// Group permissions
+	group := UserGroup{GroupName: "CLI Group"}
+	Save(&group)
+	menu := DashboardMenu{}
+	Get(&menu, "url = ?", "language")
+	if err := permCommand([]string{"grant", "--group", "CLI Group", "Language", "read,edit"}); err != nil {
+		t.Errorf("permCommand didn't grant the permissions. %s", err)
+	}
+	gp := GroupPermission{}
+	Get(&gp, "user_group_id = ? AND dashboard_menu_id = ?", group.ID, menu.ID)
+	if gp.ID == 0 || !gp.Read || !gp.Edit || gp.Add || gp.Delete {
+		t.Errorf("permCommand granted invalid permissions. Got %#v", gp)
+	}
+	if err := permCommand([]string{"revoke", "--group", "CLI Group", "language", "edit"}); err != nil {
+		t.Errorf("permCommand didn't revoke the permissions. %s", err)
+	}
+	gp = GroupPermission{}
+	Get(&gp, "user_group_id = ? AND dashboard_menu_id = ?", group.ID, menu.ID)
+	if !gp.Read || gp.Edit {
+		t.Errorf("permCommand didn't revoke the permission. Got %#v", gp)
+	}
+	if err := permCommand([]string{"show", "--group", "CLI Group"}); err != nil {
+		t.Errorf("permCommand didn't show the permissions. %s", err)
+	}
+	if err := permCommand([]string{"revoke", "--group", "CLI Group", "language"}); err != nil {
+		t.Errorf("permCommand didn't revoke the permissions. %s", err)
+	}
+	if Count(&[]GroupPermission{}, "user_group_id = ?", group.ID) != 0 {
+		t.Errorf("permCommand didn't remove the permission")
+	}
+	if snapshot := lastLog("grouppermission", gp.ID, Action(0).Deleted()); snapshot["Read"] != "true" {
+		t.Errorf("permCommand didn't log the permission before removing it. Got %v", snapshot)
+	}
+
+	// User permissions
+	if err := permCommand([]string{"grant", "--user", "cliuser", "language"}); err != nil {
+		t.Errorf("permCommand didn't grant the permissions. %s", err)
+	}
+	up := UserPermission{}
+	Get(&up, "user_id = ? AND dashboard_menu_id = ?", user.ID, menu.ID)
+	if !up.Read || !up.Add || !up.Edit || !up.Delete || !up.Approval || !up.Restore || !up.Purge {
+		t.Errorf("permCommand didn't grant all the permissions. Got %#v", up)
+	}
+	examples := [][]string{
+		{"grant", "--user", "cliuser", "--group", "CLI Group", "language"},
+		{"grant", "--user", "cliuser", "unknown"},
+		{"grant", "--user", "cliuser", "language", "read,fly"},
+		{"grant", "--group", "unknown", "language"},
+	}
+	for _, e := range examples {
+		if err := permCommand(e); err == nil {
+			t.Errorf("permCommand didn't return an error for %v", e)
+		}
+	}
+
+	// Changes are logged as the CLI
+	if newLogs := Count(&[]Log{}, "username = ?", cliActor); newLogs != logs+8 {
+		t.Errorf("Commands didn't log the changes. Expected %d got %d", logs+8, newLogs)
+	}
+
+	DeleteList(&[]UserPermission{}, "user_id = ?", user.ID)
+	DeleteList(&[]Session{}, "user_id = ?", user.ID)
+	Delete(group)
+	Delete(user)
+}
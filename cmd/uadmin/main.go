@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -21,6 +22,41 @@ Commands:
   prepare         Generates folders and prepares static and templates
   version         Shows the version of uAdmin
 
+Project commands (run in the project folder):
+  migrate plan                Shows the schema changes of the models
+  migrate make [NAME]         Writes the schema changes to a SQL file
+  migrate [up] [--yes]        Applies pending schema migration files,
+                              --yes confirms destructive changes
+  migrate status              Shows the status of schema migrations
+  dumpdata [MODEL...]         Writes the records of models to a fixture
+    [--output FILE]           File name of the fixture (dumpdata.json)
+    [--format json|ndjson]    Format of the fixture
+    [--media]                 Includes the files of image and file fields
+  loaddata FILE               Loads the records of a fixture
+  user create USERNAME        Creates a user
+    [--password PASSWORD] [--email EMAIL] [--first-name NAME]
+    [--last-name NAME] [--group GROUP] [--admin]
+  user passwd USERNAME        Changes the password of a user
+  user disable USERNAME       Disables a user and ends their sessions
+  user otp-reset USERNAME     Turns off OTP for a user and resets its seed
+  user list                   Lists the users
+  perm grant|revoke --user USERNAME|--group GROUP MODEL [PERMISSIONS]
+                              Grants or revokes permissions to a model.
+                              PERMISSIONS is a comma separated list of
+                              read,add,edit,delete,approval,restore,purge
+  perm show --user USERNAME|--group GROUP
+                              Shows the permissions of a user or a group
+  inspectdb [TABLE...]        Generates models from tables in the database
+    [--output FILE]           File name of the models (inspectdb.go)
+    [--package NAME]          Package name of the models (models)
+  builder export [MODEL...]   Writes the Go code of models defined using
+    [--output FOLDER]         the model builder to a folder (models)
+  datamigrate [up]            Applies pending data migrations
+  datamigrate down [BATCHES]  Rolls back the last batches of data migrations
+  datamigrate status          Shows the status of data migrations
+  backup FILE                 Copies a SQLite database to a file while the
+                              application is running
+
 Arguments:
   --src           If you want to copy static files and templates from src folder
 
@@ -173,5 +209,40 @@ func main() {
 	} else if command == "version" {
 		uadmin.Trail(uadmin.INFO, uadmin.Version)
 		return
+	} else if projectCommands[command] {
+		os.Exit(runProjectCommand(args[1:]))
+	}
+	fmt.Println("ERROR: Unknown command " + command)
+	fmt.Print(Help)
+}
+
+// projectCommands are the commands that are run by the project in the
+// current folder because they need its models and database settings
+var projectCommands = map[string]bool{
+	"datamigrate": true,
+	"migrate":     true,
+	"dumpdata":    true,
+	"loaddata":    true,
+	"user":        true,
+	"perm":        true,
+	"inspectdb":   true,
+	"builder":     true,
+	"backup":      true,
+}
+
+// runProjectCommand runs a command using "go run ." in the current folder
+// and returns its exit code
+func runProjectCommand(args []string) int {
+	cmd := exec.Command("go", append([]string{"run", "."}, args...)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		uadmin.Trail(uadmin.ERROR, "Unable to run the project. %s", err)
+		return 1
 	}
+	return 0
 }
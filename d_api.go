@@ -24,6 +24,9 @@ URL                              Command
 /modelname/delete/?f=1           Delete Multiple
 /modelname/delete/1/             Delete One
 /modelname/method/METHOD_NAME/1/ Run method on model where id=1
+/modelname/history/1/            Version history of a record where id=1
+/modelname/history/1/?$diff=1,2  Compare version 1 and 2 of a record
+/modelname/history/1/?$restore=1 Restore a record to version 1
 /modelname/schema/               Schema
 /$allmodels/                     All Models
 
@@ -84,6 +87,9 @@ $next=/                Used in read method that redirects the user to the specif
                        after processing the request
                          $back: Send the user back
 $stat=1                Returns the query execution time in milliseconds
+$format=csv            Returns the result of a read request as CSV
+$delimiter=;           Sets the delimiter of CSV results. Use "tab" for tab separated values
+$encoding=utf-8-bom    Sets the encoding of CSV results (e.g. utf-8-bom, windows-1252, utf-16le)
 
 
 Aggregation Operators:
@@ -165,7 +171,7 @@ func dAPIHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	// check model name
 	modelExists := false
 	var model interface{}
-	for k, v := range models {
+	for k, v := range registeredModels() {
 		if urlParts[0] == k {
 			modelExists = true
 			model = v
@@ -200,7 +206,7 @@ func dAPIHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		}
 	}
 	if len(urlParts) > 1 && !secondPartIsANumber {
-		for _, i := range []string{"read", "add", "edit", "delete", "schema", "method"} {
+		for _, i := range []string{"read", "add", "edit", "delete", "schema", "method", "history"} {
 			if urlParts[1] == i {
 				commandExists = true
 				command = i
@@ -288,6 +294,10 @@ func dAPIHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		}
 		return
 	}
+	if command == "history" {
+		dAPIHistoryHandler(w, r, s)
+		return
+	}
 	if command == "method" {
 		dAPIMethodHandler(w, r, s)
 		if r.URL.Query().Get("$next") != "" {
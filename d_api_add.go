@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 func dAPIAddHandler(w http.ResponseWriter, r *http.Request, s *Session) {
@@ -17,6 +19,8 @@ func dAPIAddHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	model, _ := NewModel(modelName, false)
 	schema, _ := getSchema(modelName)
 	tableName := schema.TableName
+	ctx, cancel := queryContext(r, APIWriteTimeout)
+	defer cancel()
 
 	// Check CSRF
 	if CheckCSRF(r) {
@@ -84,73 +88,98 @@ func dAPIAddHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		if DebugDB {
 			Trail(DEBUG, "q: %s, v: %#v", q, args)
 		}
-		db := GetDB().Begin()
-
+		// Check unique fields before adding to return an error for each
+		// field instead of the error of the unique index
 		for i := range q {
-			// Build args place holder
-			argsPlaceHolder := []string{}
-			for range args[i] {
-				argsPlaceHolder = append(argsPlaceHolder, "?")
+			values := map[string]interface{}{}
+			for j, column := range strings.Split(q[i], ", ") {
+				if j < len(args[i]) {
+					values[strings.Trim(column, columnEnclosure())] = args[i][j]
+				}
+			}
+			if errMap := validateUnique(model.Interface(), &schema, values, 0); len(errMap) != 0 {
+				w.WriteHeader(400)
+				ReturnJSON(w, r, map[string]interface{}{
+					"status":  "error",
+					"err_msg": "Error in add. " + getUniqueErrMsg(errMap),
+					"fields":  errMap,
+				})
+				return
 			}
-
-			SQL := "INSERT INTO " + tableName + " (" + q[i] + ") VALUES (" + strings.Join(argsPlaceHolder, ",") + ")"
-			db = db.Exec(SQL, args[i]...)
-			rowsCount += db.RowsAffected
-		}
-		id := []int{}
-		if Database.Type == "sqlite" {
-			db = db.Raw("SELECT last_insert_rowid() AS lastid")
-		} else if Database.Type == "mysql" {
-			db = db.Raw("SELECT LAST_INSERT_ID() AS lastid")
-		} else if Database.Type == "postgres" {
-			db = db.Raw("SELECT lastval() AS lastid")
-		}
-		db.Table(tableName).Pluck("lastid", &id)
-		err := db.Commit().Error
-		if err != nil {
-			w.WriteHeader(400)
-			ReturnJSON(w, r, map[string]interface{}{
-				"status":  "error",
-				"err_msg": "Error in update query. " + err.Error(),
-			})
-			return
 		}
 
-		if db.Error != nil {
-			ReturnJSON(w, r, map[string]interface{}{
-				"status":  "error",
-				"err_msg": "Error in add. " + db.Error.Error(),
-			})
-			return
-		}
+		// Add the records and their M2M records in one transaction
+		err := modelDB(model.Interface()).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			for i := range q {
+				// Build args place holder
+				argsPlaceHolder := []string{}
+				for range args[i] {
+					argsPlaceHolder = append(argsPlaceHolder, "?")
+				}
 
-		intRowsCount := int(rowsCount)
-		for i := 1; i <= intRowsCount; i++ {
-			createdIDs = append(createdIDs, id[0]-(intRowsCount-i))
-		}
+				SQL := "INSERT INTO " + tableName + " (" + q[i] + ") VALUES (" + strings.Join(argsPlaceHolder, ",") + ")"
+				result := tx.Exec(SQL, args[i]...)
+				if result.Error != nil {
+					return result.Error
+				}
+				rowsCount += result.RowsAffected
+			}
+			id := []int{}
+			var lastID *gorm.DB
+			if getDBType(model.Interface()) == "sqlite" {
+				lastID = tx.Raw("SELECT last_insert_rowid() AS lastid")
+			} else if getDBType(model.Interface()) == "mysql" {
+				lastID = tx.Raw("SELECT LAST_INSERT_ID() AS lastid")
+			} else if getDBType(model.Interface()) == "postgres" {
+				lastID = tx.Raw("SELECT lastval() AS lastid")
+			}
+			if lastID != nil {
+				if err := lastID.Table(tableName).Pluck("lastid", &id).Error; err != nil {
+					return err
+				}
+			}
+			if rowsCount != 0 && len(id) == 0 {
+				return fmt.Errorf("unable to get the IDs of the new records")
+			}
 
-		// Add M2M records
-		// No need to delete existing m2m records because it
-		// is a new model
-		// Insert records
-		db = GetDB().Begin()
-		for i := range m2mFields {
-			table1 := schema.ModelName
-			for m2mModelName := range m2mFields[i] {
-				t2Schema, _ := getSchema(m2mModelName)
-				table2 := t2Schema.ModelName
-				for _, id := range strings.Split(m2mFields[i][m2mModelName], ",") {
-					if m2mFields[i][m2mModelName] == "" {
-						continue
+			intRowsCount := int(rowsCount)
+			for i := 1; i <= intRowsCount; i++ {
+				createdIDs = append(createdIDs, id[0]-(intRowsCount-i))
+			}
+
+			// Add M2M records
+			// No need to delete existing m2m records because it
+			// is a new model
+			// Insert records
+			for i := range m2mFields {
+				table1 := schema.ModelName
+				for m2mModelName := range m2mFields[i] {
+					t2Schema, _ := getSchema(m2mModelName)
+					table2 := t2Schema.ModelName
+					for _, id := range strings.Split(m2mFields[i][m2mModelName], ",") {
+						if m2mFields[i][m2mModelName] == "" {
+							continue
+						}
+						sql := sqlDialect[getDBType(model.Interface())]["insertM2M"]
+						sql = strings.Replace(sql, "{TABLE1}", table1, -1)
+						sql = strings.Replace(sql, "{TABLE2}", table2, -1)
+						if err := tx.Exec(sql, createdIDs[i], id).Error; err != nil {
+							return err
+						}
 					}
-					sql := sqlDialect[Database.Type]["insertM2M"]
-					sql = strings.Replace(sql, "{TABLE1}", table1, -1)
-					sql = strings.Replace(sql, "{TABLE2}", table2, -1)
-					db = db.Exec(sql, createdIDs[i], id)
 				}
 			}
+			return nil
+		})
+		if err != nil {
+			w.WriteHeader(400)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": "Error in add. " + err.Error(),
+			})
+			return
 		}
-		db.Commit()
+		invalidateCache(model.Interface())
 
 		returnDAPIJSON(w, r, map[string]interface{}{
 			"status":     "ok",
@@ -160,7 +189,7 @@ func dAPIAddHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 		if log {
 			for i := range createdIDs {
-				createAPIAddLog(q, args, GetDB().Config.NamingStrategy.ColumnName("", model.Type().Name()), createdIDs[i], s, r)
+				createAPIAddLog(q, args, modelName, createdIDs[i], s, r)
 			}
 		}
 		// Execute business logic
@@ -348,11 +377,11 @@ func getAddQueryArg(v string) interface{} {
 	return v
 }
 
-func createAPIAddLog(q []string, args [][]interface{}, tableName string, ID int, session *Session, r *http.Request) {
+func createAPIAddLog(q []string, args [][]interface{}, modelName string, ID int, session *Session, r *http.Request) {
 	// TODO: Fix mismatch field name and value assignment
 	// in JSON object for Activity field in Logs
 	nameMap := map[string]string{}
-	for _, f := range Schema[tableName].Fields {
+	for _, f := range registeredSchema()[modelName].Fields {
 		nameMap[f.ColumnName] = f.Name
 	}
 
@@ -388,7 +417,7 @@ func createAPIAddLog(q []string, args [][]interface{}, tableName string, ID int,
 		log := Log{
 			Username:  username,
 			Action:    Action(0).Added(),
-			TableName: tableName,
+			TableName: modelName,
 			TableID:   ID,
 			Activity:  string(b),
 		}
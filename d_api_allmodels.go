@@ -7,7 +7,7 @@ import (
 func dAPIAllModelsHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	response := []interface{}{}
 	for _, v := range modelList {
-		response = append(response, Schema[getModelName(v)])
+		response = append(response, registeredSchema()[getModelName(v)])
 	}
 	ReturnJSON(w, r, map[string]interface{}{
 		"status": "ok",
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 func dAPIDeleteHandler(w http.ResponseWriter, r *http.Request, s *Session) {
@@ -15,6 +18,8 @@ func dAPIDeleteHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	schema, _ := getSchema(modelName)
 	tableName := schema.TableName
 	params := getURLArgs(r)
+	ctx, cancel := queryContext(r, APIWriteTimeout)
+	defer cancel()
 
 	// Check CSRF
 	if CheckCSRF(r) {
@@ -75,51 +80,40 @@ func dAPIDeleteHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 			return
 		}
 
-		if Database.Type == "mysql" {
-			db := GetDB()
-
-			if log {
-				db.Model(model.Interface()).Where(q, args...).Scan(modelArray.Interface())
-			}
-
-			db = db.Where(q, args...).Delete(model.Addr().Interface())
-			if db.Error != nil {
-				ReturnJSON(w, r, map[string]interface{}{
-					"status":  "error",
-					"err_msg": "Unable to execute DELETE SQL. " + db.Error.Error(),
-				})
-				return
-			}
-			rowsCount = db.RowsAffected
-			if log {
-				for i := 0; i < modelArray.Elem().Len(); i++ {
-					createAPIDeleteLog(modelName, modelArray.Elem().Index(i).Interface(), &s.User, r)
-				}
-			}
-
-		} else if Database.Type == "sqlite" {
-			db := GetDB().Begin()
+		// Get the IDs of the records to apply the on delete rules
+		ids := []uint{}
+		withCaseSensitiveLike(modelDB(model.Interface()).WithContext(ctx), func(idDB *gorm.DB) error {
+			return idDB.Model(model.Interface()).Where(q, args...).Pluck("id", &ids).Error
+		})
+		impact, ok := dAPIDeleteImpact(w, r, params, modelName, ids)
+		if !ok {
+			return
+		}
 
-			if log {
-				db.Model(model.Interface()).Where(q, args...).Scan(modelArray.Interface())
-			}
+		db := modelDB(model.Interface()).WithContext(ctx)
+		if log {
+			db.Model(model.Interface()).Where("id IN (?)", ids).Scan(modelArray.Interface())
+		}
 
-			db = db.Exec("PRAGMA case_sensitive_like=ON;")
-			db = db.Where(q, args...).Delete(model.Addr().Interface())
-			db = db.Exec("PRAGMA case_sensitive_like=OFF;")
-			db.Commit()
-			if db.Error != nil {
-				ReturnJSON(w, r, map[string]interface{}{
-					"status":  "error",
-					"err_msg": "Unable to COMMIT SQL. " + db.Error.Error(),
-				})
-				return
-			}
-			rowsCount = db.RowsAffected
-			if log {
-				for i := 0; i < modelArray.Elem().Len(); i++ {
-					createAPIDeleteLog(modelName, modelArray.Elem().Index(i).Interface(), &s.User, r)
-				}
+		// Apply the on delete rules and delete the records in one transaction
+		err := deleteWithImpact(ctx, impact, dAPILogUser(log, s), r, func(tx *Tx) error {
+			res := impactDB(tx, model.Interface()).Where("id IN (?)", ids).Delete(model.Addr().Interface())
+			rowsCount = res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			w.WriteHeader(400)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": "Unable to execute DELETE SQL. " + err.Error(),
+			})
+			return
+		}
+		// Remove results cached from reads before the commit
+		invalidateCache(model.Interface())
+		if log {
+			for i := 0; i < modelArray.Elem().Len(); i++ {
+				createAPIDeleteLog(modelName, modelArray.Elem().Index(i).Interface(), &s.User, r)
 			}
 		}
 		returnDAPIJSON(w, r, map[string]interface{}{
@@ -128,20 +122,31 @@ func dAPIDeleteHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		}, params, "delete", model.Interface())
 	} else if len(urlParts) == 1 {
 		// Delete One
+		id, _ := strconv.ParseUint(urlParts[0], 10, 64)
+		impact, ok := dAPIDeleteImpact(w, r, params, modelName, []uint{uint(id)})
+		if !ok {
+			return
+		}
 		m, _ := NewModel(modelName, true)
 
-		db := GetDB()
+		db := modelDB(model.Interface()).WithContext(ctx)
 		if log {
-			db.Model(model.Interface()).Where("id = ?", urlParts[0]).Scan(m.Interface())
+			db.Model(model.Interface()).Where("id = ?", id).Scan(m.Interface())
 		}
-		db = db.Where("id = ?", urlParts[0]).Delete(model.Addr().Interface())
-		if db.Error != nil {
+		err := deleteWithImpact(ctx, impact, dAPILogUser(log, s), r, func(tx *Tx) error {
+			res := impactDB(tx, model.Interface()).Where("id = ?", id).Delete(model.Addr().Interface())
+			rowsCount = res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			w.WriteHeader(400)
 			ReturnJSON(w, r, map[string]interface{}{
 				"status":  "error",
-				"err_msg": "Unable to execute DELETE SQL. " + db.Error.Error(),
+				"err_msg": "Unable to execute DELETE SQL. " + err.Error(),
 			})
 			return
 		}
+		invalidateCache(model.Interface())
 
 		if log {
 			createAPIDeleteLog(modelName, m.Interface(), &s.User, r)
@@ -149,7 +154,7 @@ func dAPIDeleteHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 		returnDAPIJSON(w, r, map[string]interface{}{
 			"status":     "ok",
-			"rows_count": db.RowsAffected,
+			"rows_count": rowsCount,
 		}, params, "delete", model.Interface())
 	} else {
 		// Error: Unknown format
@@ -161,6 +166,47 @@ func dAPIDeleteHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	}
 }
 
+// dAPIDeleteImpact returns the delete impact of the records. It writes the
+// response and returns false for $dryrun and if the records should not be
+// deleted.
+func dAPIDeleteImpact(w http.ResponseWriter, r *http.Request, params map[string]string, modelName string, ids []uint) ([]DeleteImpact, bool) {
+	impact, err := GetDeleteImpact(modelName, ids...)
+	if err != nil {
+		w.WriteHeader(400)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": "Unable to get the related records. " + err.Error(),
+		})
+		return nil, false
+	}
+	if params["$dryrun"] == "1" || params["$dryrun"] == "true" {
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":    "ok",
+			"result":    impact,
+			"protected": IsDeleteProtected(impact),
+		})
+		return nil, false
+	}
+	if IsDeleteProtected(impact) {
+		w.WriteHeader(400)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": "Unable to delete records with protected related records",
+			"result":  impact,
+		})
+		return nil, false
+	}
+	return impact, true
+}
+
+// dAPILogUser returns the user to log the deletes of related records as
+func dAPILogUser(log bool, s *Session) *User {
+	if !log || s == nil {
+		return nil
+	}
+	return &s.User
+}
+
 func createAPIDeleteLog(modelName string, m interface{}, user *User, r *http.Request) {
 	b, _ := json.Marshal(m)
 	output := string(b[:len(b)-1]) + `,"_IP":"` + GetRemoteIP(r) + `"}`
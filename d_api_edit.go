@@ -13,6 +13,8 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	model, _ := NewModel(modelName, false)
 	schema, _ := getSchema(modelName)
 	tableName := schema.TableName
+	ctx, cancel := queryContext(r, APIWriteTimeout)
+	defer cancel()
 
 	// Check CSRF
 	if CheckCSRF(r) {
@@ -97,7 +99,7 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 	writeMap, m2mMap := getEditMap(params, &schema, &model)
 
-	db := GetDB()
+	db := modelDB(model.Interface()).WithContext(ctx)
 
 	if r.URL.Path == "" {
 		// Edit multiple
@@ -105,6 +107,23 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 		modelArray, _ := NewModelArray(modelName, true)
 		db.Model(model.Interface()).Where(q, args...).Scan(modelArray.Interface())
+		for i := 0; i < modelArray.Elem().Len(); i++ {
+			if errMap := validateEditUnique(model.Interface(), &schema, modelArray.Elem().Index(i), writeMap); len(errMap) != 0 {
+				w.WriteHeader(400)
+				ReturnJSON(w, r, map[string]interface{}{
+					"status":  "error",
+					"err_msg": "Unable to update database. " + getUniqueErrMsg(errMap),
+					"fields":  errMap,
+				})
+				return
+			}
+		}
+		if log {
+			// Load M2M fields to be included in the log
+			for i := 0; i < modelArray.Elem().Len(); i++ {
+				customGet(modelArray.Elem().Index(i).Addr().Interface())
+			}
+		}
 		db = db.Model(model.Interface()).Where(q, args...).Updates(writeMap)
 		if db.Error != nil {
 			w.WriteHeader(400)
@@ -117,14 +136,14 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		rowsAffected := db.RowsAffected
 
 		// Process M2M
-		db = GetDB().Begin()
+		db = modelDB(model.Interface()).WithContext(ctx).Begin()
 		table1 := schema.ModelName
 		for i := 0; i < modelArray.Elem().Len(); i++ {
 			for k, v := range m2mMap {
 				t2Schema, _ := getSchema(k)
 				table2 := t2Schema.ModelName
 				// First delete existing records
-				sql := sqlDialect[Database.Type]["deleteM2M"]
+				sql := sqlDialect[getDBType(model.Interface())]["deleteM2M"]
 				sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 				sql = strings.Replace(sql, "{TABLE2}", table2, -1)
 				db = db.Exec(sql, GetID(modelArray.Elem().Index(i)))
@@ -135,7 +154,7 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 				// Now add the records
 				for _, id := range strings.Split(v, ",") {
-					sql = sqlDialect[Database.Type]["insertM2M"]
+					sql = sqlDialect[getDBType(model.Interface())]["insertM2M"]
 					sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 					sql = strings.Replace(sql, "{TABLE2}", table2, -1)
 					db = db.Exec(sql, GetID(modelArray.Elem().Index(i)), id)
@@ -152,6 +171,7 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 			})
 			return
 		}
+		invalidateCache(model.Interface())
 
 		returnDAPIJSON(w, r, map[string]interface{}{
 			"status":     "ok",
@@ -176,6 +196,19 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		// Edit One
 		m, _ := NewModel(modelName, true)
 		db.Model(model.Interface()).Where("id = ?", urlParts[0]).Scan(m.Interface())
+		if errMap := validateEditUnique(model.Interface(), &schema, m, writeMap); len(errMap) != 0 {
+			w.WriteHeader(400)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": "Unable to update database. " + getUniqueErrMsg(errMap),
+				"fields":  errMap,
+			})
+			return
+		}
+		if log {
+			// Load M2M fields to be included in the log
+			customGet(m.Interface())
+		}
 		db = db.Model(model.Interface()).Where("id = ?", urlParts[0]).Updates(writeMap)
 		if db.Error != nil {
 			ReturnJSON(w, r, map[string]interface{}{
@@ -187,13 +220,13 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		rowsAffected := db.RowsAffected
 
 		// Process M2M
-		db = GetDB().Begin()
+		db = modelDB(model.Interface()).WithContext(ctx).Begin()
 		table1 := schema.ModelName
 		for k, v := range m2mMap {
 			t2Schema, _ := getSchema(k)
 			table2 := t2Schema.ModelName
 			// First delete existing records
-			sql := sqlDialect[Database.Type]["deleteM2M"]
+			sql := sqlDialect[getDBType(model.Interface())]["deleteM2M"]
 			sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 			sql = strings.Replace(sql, "{TABLE2}", table2, -1)
 			db = db.Exec(sql, urlParts[0])
@@ -204,13 +237,14 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 			// Now add the records
 			for _, id := range strings.Split(v, ",") {
-				sql = sqlDialect[Database.Type]["insertM2M"]
+				sql = sqlDialect[getDBType(model.Interface())]["insertM2M"]
 				sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 				sql = strings.Replace(sql, "{TABLE2}", table2, -1)
 				db = db.Exec(sql, urlParts[0], id)
 			}
 		}
 		db.Commit()
+		invalidateCache(model.Interface())
 
 		if log {
 			createAPIEditLog(modelName, m.Interface(), &s.User, r)
@@ -218,7 +252,7 @@ func dAPIEditHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 
 		// Execute business logic
 		if _, ok := m.Interface().(saver); ok {
-			db = GetDB()
+			db = modelDB(model.Interface()).WithContext(ctx)
 			m, _ = NewModel(modelName, true)
 			db.Model(model.Interface()).Where("id = ?", urlParts[0]).Scan(m.Interface())
 			m.Interface().(saver).Save()
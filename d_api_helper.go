@@ -165,10 +165,11 @@ func getM2MQueryOperator(v string, schema *ModelSchema) string {
 }
 
 func getM2MQueryArg(k, v string, schema *ModelSchema) []interface{} {
+	models := registeredModels()
 	f := schema.FieldByColumnName(k)
 	t1 := schema.ModelName
 	t2 := strings.ToLower(f.TypeName)
-	SQL := sqlDialect[Database.Type]["selectM2MT2"]
+	SQL := sqlDialect[getDBType(models[schema.ModelName])]["selectM2MT2"]
 	SQL = strings.ReplaceAll(SQL, "{TABLE1}", t1)
 	SQL = strings.ReplaceAll(SQL, "{TABLE2}", t2)
 
@@ -179,7 +180,7 @@ func getM2MQueryArg(k, v string, schema *ModelSchema) []interface{} {
 
 	values := []M2MTable{}
 
-	err := GetDB().Raw(SQL, strings.Split(v, ",")).Scan(&values).Error
+	err := readDB(models[schema.ModelName]).Raw(SQL, strings.Split(v, ",")).Scan(&values).Error
 	if err != nil {
 		Trail(ERROR, "Unable to get M2M args. %s", err)
 		return []interface{}{}
@@ -549,6 +550,7 @@ func getQueryJoin(r *http.Request, params map[string]string, tableName string) s
 }
 
 func getQueryM2M(params map[string]string, m interface{}, customSchema bool, modelName string) error {
+	schemas := registeredSchema()
 	// $m2m=0
 	// $m2m=$fill $m2m=1
 	// $m2m=$id
@@ -581,7 +583,7 @@ func getQueryM2M(params map[string]string, m interface{}, customSchema bool, mod
 	m2mStmt := map[string]string{}
 	m2mModelName := map[string]string{}
 
-	s := Schema[modelName]
+	s := schemas[modelName]
 
 	//table1 := s.TableName
 	table2 := ""
@@ -589,13 +591,13 @@ func getQueryM2M(params map[string]string, m interface{}, customSchema bool, mod
 	if m2m == "1" {
 		for _, f := range s.Fields {
 			if f.Type == cM2M {
-				table2 = Schema[strings.ToLower(f.TypeName)].TableName
-				m2mTable := s.ModelName + "_" + Schema[strings.ToLower(f.TypeName)].ModelName
+				table2 = schemas[strings.ToLower(f.TypeName)].TableName
+				m2mTable := s.ModelName + "_" + schemas[strings.ToLower(f.TypeName)].ModelName
 				m2mStmt[f.Name] = m2mTmpl
 				m2mStmt[f.Name] = strings.Replace(m2mStmt[f.Name], "{TABLE_NAME}", table2, -1)
 				m2mStmt[f.Name] = strings.Replace(m2mStmt[f.Name], "{FIELDS}", fillType, -1)
 				m2mStmt[f.Name] = strings.Replace(m2mStmt[f.Name], "{M2M_TABLE_NAME}", m2mTable, -1)
-				m2mModelName[f.Name] = Schema[strings.ToLower(f.TypeName)].ModelName
+				m2mModelName[f.Name] = schemas[strings.ToLower(f.TypeName)].ModelName
 			}
 		}
 	} else {
@@ -612,15 +614,15 @@ func getQueryM2M(params map[string]string, m interface{}, customSchema bool, mod
 				m2mParts[1] = "*"
 			}
 
-			f := Schema[modelName].FieldByName(m2mParts[0])
-			table2Schema := Schema[strings.ToLower(f.TypeName)]
+			f := schemas[modelName].FieldByName(m2mParts[0])
+			table2Schema := schemas[strings.ToLower(f.TypeName)]
 			table2 = table2Schema.TableName
 			m2mTable := s.ModelName + "_" + table2Schema.ModelName
 			m2mStmt[f.Name] = m2mTmpl
 			m2mStmt[f.Name] = strings.Replace(m2mStmt[f.Name], "{TABLE_NAME}", table2, -1)
 			m2mStmt[f.Name] = strings.Replace(m2mStmt[f.Name], "{FIELDS}", m2mParts[1], -1)
 			m2mStmt[f.Name] = strings.Replace(m2mStmt[f.Name], "{M2M_TABLE_NAME}", m2mTable, -1)
-			m2mModelName[f.Name] = Schema[strings.ToLower(f.TypeName)].ModelName
+			m2mModelName[f.Name] = schemas[strings.ToLower(f.TypeName)].ModelName
 		}
 	}
 
@@ -628,7 +630,7 @@ func getQueryM2M(params map[string]string, m interface{}, customSchema bool, mod
 	for i := 0; i < mValue.Elem().Len(); i++ {
 		for k, v := range m2mStmt {
 			tempList, _ := NewModelArray(m2mModelName[k], true)
-			GetDB().Raw(v, GetID(mValue.Elem().Index(i))).Scan(tempList.Interface())
+			readDB(m).Raw(v, GetID(mValue.Elem().Index(i))).Scan(tempList.Interface())
 			mValue.Elem().Index(i).FieldByName(k).Set(tempList.Elem())
 		}
 	}
@@ -636,6 +638,19 @@ func getQueryM2M(params map[string]string, m interface{}, customSchema bool, mod
 	return nil
 }
 
+// runDAPIPostQueryRead runs APIPostQueryReadHandler and the APIPostQueryRead
+// method of the model on the response of a read. It returns false if one of
+// them wrote the response.
+func runDAPIPostQueryRead(w http.ResponseWriter, r *http.Request, a map[string]interface{}, model interface{}) bool {
+	if APIPostQueryReadHandler != nil && !APIPostQueryReadHandler(w, r, a) {
+		return false
+	}
+	if postQuery, ok := model.(APIPostQueryReader); ok {
+		return postQuery.APIPostQueryRead(w, r, a)
+	}
+	return true
+}
+
 func returnDAPIJSON(w http.ResponseWriter, r *http.Request, a map[string]interface{}, params map[string]string, command string, model interface{}) error {
 	if params["$stat"] == "1" || params["$stat"] == "true" {
 		start := r.Context().Value(CKey("start"))
@@ -649,15 +664,8 @@ func returnDAPIJSON(w http.ResponseWriter, r *http.Request, a map[string]interfa
 	}
 
 	if model != nil {
-		if command == "read" {
-			if APIPostQueryReadHandler != nil && !APIPostQueryReadHandler(w, r, a) {
-				return nil
-			}
-			if postQuery, ok := model.(APIPostQueryReader); ok {
-				if !postQuery.APIPostQueryRead(w, r, a) {
-					return nil
-				}
-			}
+		if command == "read" && !runDAPIPostQueryRead(w, r, a, model) {
+			return nil
 		}
 		if command == "add" {
 			if APIPostQueryAddHandler != nil && !APIPostQueryAddHandler(w, r, a) {
@@ -0,0 +1,122 @@
+package uadmin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func dAPIHistoryHandler(w http.ResponseWriter, r *http.Request, s *Session) {
+	urlParts := strings.Split(r.URL.Path, "/")
+	modelName := r.Context().Value(CKey("modelName")).(string)
+
+	if s == nil {
+		w.WriteHeader(http.StatusForbidden)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": "Permission denied",
+		})
+		return
+	}
+
+	ID, err := strconv.ParseUint(urlParts[0], 10, 64)
+	if len(urlParts) != 1 || err != nil {
+		w.WriteHeader(400)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": "Bad request, URL format should be api/d/model/history/{ID}",
+		})
+		return
+	}
+
+	perm := s.User.GetAccess(modelName)
+	if !perm.Read {
+		w.WriteHeader(http.StatusForbidden)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": "Permission denied",
+		})
+		return
+	}
+
+	// Restore a version
+	if r.FormValue("$restore") != "" {
+		if CheckCSRF(r) {
+			w.WriteHeader(http.StatusForbidden)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": "Failed CSRF protection.",
+			})
+			return
+		}
+		// Restoring a version of a deleted record restores the record
+		m, _ := NewModel(modelName, true)
+		deleted, _ := getRecordUnscoped(m.Interface(), uint(ID))
+		if !perm.Edit || (deleted && !perm.Restore) {
+			w.WriteHeader(http.StatusForbidden)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": "Permission denied",
+			})
+			return
+		}
+		version, _ := strconv.Atoi(r.FormValue("$restore"))
+		log, err := RestoreRecordVersion(modelName, uint(ID), version, &s.User, r)
+		if err != nil {
+			w.WriteHeader(400)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": err.Error(),
+			})
+			return
+		}
+		ReturnJSON(w, r, map[string]interface{}{
+			"status": "ok",
+			"log_id": log.ID,
+		})
+		return
+	}
+
+	// Compare two versions
+	if r.FormValue("$diff") != "" {
+		versions := strings.Split(r.FormValue("$diff"), ",")
+		if len(versions) != 2 {
+			w.WriteHeader(400)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": "Bad request, $diff format should be $diff={FROM},{TO}",
+			})
+			return
+		}
+		from, _ := strconv.Atoi(versions[0])
+		to, _ := strconv.Atoi(versions[1])
+		changes, err := DiffRecordVersions(modelName, uint(ID), from, to)
+		if err != nil {
+			w.WriteHeader(400)
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "error",
+				"err_msg": err.Error(),
+			})
+			return
+		}
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "ok",
+			"changes": changes,
+		})
+		return
+	}
+
+	versions, err := GetRecordHistory(modelName, uint(ID))
+	if err != nil {
+		w.WriteHeader(400)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": err.Error(),
+		})
+		return
+	}
+	ReturnJSON(w, r, map[string]interface{}{
+		"status":   "ok",
+		"versions": versions,
+	})
+}
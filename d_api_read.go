@@ -2,9 +2,13 @@ package uadmin
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
@@ -16,6 +20,8 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	model, _ := NewModel(modelName, false)
 	params := getURLArgs(r)
 	schema, _ := getSchema(modelName)
+	ctx, cancel := queryContext(r, APIQueryTimeout)
+	defer cancel()
 
 	// Check permission
 	allow := false
@@ -133,8 +139,19 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		// 	m = []map[string]interface{}{}
 		// }
 
-		if Database.Type == "mysql" {
-			db := GetDB()
+		// Read from the query cache. Reads with joins are not cached because
+		// writes to the joined tables don't invalidate them.
+		cacheKey, cacheTTL := "", time.Duration(0)
+		cached := false
+		if !customSchema && join == "" {
+			cacheKey, cacheTTL = queryCacheKey(m, "dapi", SQL, args)
+			cached = cacheKey != "" && getCache(cacheKey, m)
+		}
+
+		if cached {
+			rowsCount = int64(reflect.ValueOf(m).Elem().Len())
+		} else if getDBType(model.Interface()) == "mysql" {
+			db := readDBContext(ctx, model.Interface())
 			if !customSchema {
 				err = db.Raw(SQL, args...).Scan(m).Error
 			} else {
@@ -147,25 +164,23 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 			} else {
 				rowsCount = int64(reflect.ValueOf(m).Elem().Len())
 			}
-		} else if Database.Type == "sqlite" {
-			db := GetDB().Begin()
-			db.Exec("PRAGMA case_sensitive_like=ON;")
-			if !customSchema {
-				err = db.Raw(SQL, args...).Scan(m).Error
-			} else {
+		} else if getDBType(model.Interface()) == "sqlite" {
+			err = withCaseSensitiveLike(readDBContext(ctx, model.Interface()), func(db *gorm.DB) error {
+				if !customSchema {
+					return db.Raw(SQL, args...).Scan(m).Error
+				}
 				var rec []map[string]interface{}
-				err = db.Raw(SQL, args...).Scan(&rec).Error
+				err := db.Raw(SQL, args...).Scan(&rec).Error
 				m = rec
-			}
-			db.Exec("PRAGMA case_sensitive_like=OFF;")
-			db.Commit()
+				return err
+			})
 			if a, ok := m.([]map[string]interface{}); ok {
 				rowsCount = int64(len(a))
 			} else {
 				rowsCount = int64(reflect.ValueOf(m).Elem().Len())
 			}
-		} else if Database.Type == "postgres" {
-			db := GetDB()
+		} else if getDBType(model.Interface()) == "postgres" {
+			db := readDBContext(ctx, model.Interface())
 			if !customSchema {
 				err = db.Raw(SQL, args...).Scan(m).Error
 			} else {
@@ -189,6 +204,9 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 			})
 			return
 		}
+		if !cached && cacheKey != "" {
+			setCache(cacheKey, cacheTTL, m)
+		}
 
 		// Preload
 		if !customSchema && (params["$preload"] == "1" || params["$preload"] == "true") {
@@ -221,10 +239,17 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 			}
 		}
 
-		returnDAPIJSON(w, r, map[string]interface{}{
-			"status": "ok",
-			"result": m,
-		}, params, "read", model.Interface())
+		if params["$format"] == "csv" {
+			returnDAPICSV(w, r, map[string]interface{}{
+				"status": "ok",
+				"result": m,
+			}, params, &schema, model.Interface())
+		} else {
+			returnDAPIJSON(w, r, map[string]interface{}{
+				"status": "ok",
+				"result": m,
+			}, params, "read", model.Interface())
+		}
 		go func() {
 			if log {
 				createAPIReadLog(modelName, 0, rowsCount, params, &s.User, r)
@@ -238,7 +263,7 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 		if r.Context().Value(CKey("WHERE")) != nil {
 			q += " AND " + r.Context().Value(CKey("WHERE")).(string)
 		}
-		Get(m.Interface(), q, urlParts[0])
+		GetContext(ctx, m.Interface(), q, urlParts[0])
 		rowsCount = 0
 
 		var i interface{}
@@ -269,10 +294,17 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 			}
 		}
 
-		returnDAPIJSON(w, r, map[string]interface{}{
-			"status": "ok",
-			"result": i,
-		}, params, "read", model.Interface())
+		if params["$format"] == "csv" && i != nil {
+			returnDAPICSV(w, r, map[string]interface{}{
+				"status": "ok",
+				"result": i,
+			}, params, &schema, model.Interface())
+		} else {
+			returnDAPIJSON(w, r, map[string]interface{}{
+				"status": "ok",
+				"result": i,
+			}, params, "read", model.Interface())
+		}
 		go func() {
 			if log {
 				createAPIReadLog(modelName, int(GetID(m)), rowsCount, map[string]string{"id": urlParts[0]}, &s.User, r)
@@ -289,6 +321,52 @@ func dAPIReadHandler(w http.ResponseWriter, r *http.Request, s *Session) {
 	}
 }
 
+// returnDAPICSV writes the result of a read request as CSV. The delimiter
+// and encoding can be set using $delimiter and $encoding.
+func returnDAPICSV(w http.ResponseWriter, r *http.Request, a map[string]interface{}, params map[string]string, schema *ModelSchema, model interface{}) {
+	delimiter, err := getExportDelimiter(params["$delimiter"])
+	if err == nil {
+		_, _, err = getExportEncoding(params["$encoding"])
+	}
+	if err != nil {
+		w.WriteHeader(400)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": err.Error(),
+		})
+		return
+	}
+
+	// Run the post query handlers of reads on the result before writing it
+	if !runDAPIPostQueryRead(w, r, a, model) {
+		return
+	}
+	m := reflect.Indirect(reflect.ValueOf(a["result"]))
+	if m.Kind() == reflect.Struct {
+		records := reflect.New(reflect.SliceOf(m.Type())).Elem()
+		m = reflect.Append(records, m)
+	}
+	if m.Kind() != reflect.Slice {
+		ReturnJSON(w, r, a)
+		return
+	}
+
+	var cols []exportColumn
+	if records, ok := m.Interface().([]map[string]interface{}); ok {
+		cols = getMapExportColumns(records)
+	} else {
+		cols = getExportColumns(schema, m.Type().Elem(), true)
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", schema.ModelName))
+	writer, _ := newExportWriter(w, params["$encoding"])
+	if err = writeExport(writer, "csv", delimiter, m, cols, false, nil); err != nil {
+		Trail(ERROR, "dAPI unable to write CSV for %s. %s", schema.ModelName, err)
+	}
+	writer.Close()
+}
+
 func createAPIReadLog(modelName string, ID int, rowsCount int64, params map[string]string, user *User, r *http.Request) {
 	vals := map[string]interface{}{
 		"params":     params,
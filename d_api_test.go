@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"time"
 )
 
@@ -50,8 +51,8 @@ func (t *UAdminTests) TestDAPI() {
 				json.Unmarshal([]byte(v), &obj)
 				if result, ok := obj["result"].([]interface{}); !ok {
 					return fmt.Sprintf("Invalid return for dAPI url=%%s. No 'result' in response")
-				} else if len(result) != 17 {
-					return fmt.Sprintf("Invalid length of 'result' dAPI url=%%s. Expected %d got %d", 17, len(result))
+				} else if len(result) != 30 {
+					return fmt.Sprintf("Invalid length of 'result' dAPI url=%%s. Expected %d got %d", 30, len(result))
 				}
 				return ""
 			},
@@ -70,6 +71,19 @@ func (t *UAdminTests) TestDAPI() {
 				return ""
 			},
 		},
+		{
+			"/api/d/user/read/?$format=csv&$delimiter=;&$order=id",
+			s1.Key,
+			func(v string) string {
+				lines := strings.Split(strings.TrimSpace(v), "\n")
+				if len(lines) != 3 {
+					return fmt.Sprintf("Invalid number of CSV lines dAPI url=%%s. Expected %d got %d", 3, len(lines))
+				} else if !strings.HasPrefix(lines[0], "ID;Username;") || !strings.HasPrefix(lines[2], fmt.Sprintf("%d;u1;", u1.ID)) {
+					return fmt.Sprintf("Invalid CSV dAPI url=%%s")
+				}
+				return ""
+			},
+		},
 		{
 			"/api/d/user/read/" + fmt.Sprint(u1.ID),
 			s1.Key,
@@ -209,6 +223,20 @@ func (t *UAdminTests) TestDAPI() {
 			t.Errorf("test case #%d failed: '"+msg+"', return: '%s'", i, e[i].url, string(buf))
 		}
 	}
+
+	// CSV reads run the post query handler
+	APIPostQueryReadHandler = func(w http.ResponseWriter, r *http.Request, a map[string]interface{}) bool {
+		a["result"] = []map[string]interface{}{{"username": "hooked"}}
+		return true
+	}
+	r := httptest.NewRequest("GET", "/api/d/user/read/?$format=csv", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	w := httptest.NewRecorder()
+	apiHandler(w, r)
+	APIPostQueryReadHandler = nil
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 2 || lines[1] != "hooked" {
+		t.Errorf("dAPI CSV read didn't run APIPostQueryReadHandler. Got %q", w.Body.String())
+	}
 	Delete(s1)
 	Delete(u1)
 }
@@ -0,0 +1,251 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ExportStatus is the status of a data export
+type ExportStatus int
+
+// Queued is an export waiting for a worker
+func (ExportStatus) Queued() ExportStatus {
+	return 1
+}
+
+// Running is an export being created
+func (ExportStatus) Running() ExportStatus {
+	return 2
+}
+
+// Finished is an export that is ready to download
+func (ExportStatus) Finished() ExportStatus {
+	return 3
+}
+
+// Failed is an export that could not be created
+func (ExportStatus) Failed() ExportStatus {
+	return 4
+}
+
+// Expired is an export whose file was removed
+func (ExportStatus) Expired() ExportStatus {
+	return 5
+}
+
+// DataExport is a model that stores an export of a model's records to excel.
+// Exports are created in the background task queue.
+type DataExport struct {
+	Model
+	ModelName   string       `uadmin:"read_only;filter"`
+	Format      string       `uadmin:"read_only;filter"`
+	Filters     string       `uadmin:"read_only;list_exclude"`
+	User        User         `uadmin:"read_only;filter"`
+	UserID      uint         `uadmin:"read_only"`
+	Status      ExportStatus `uadmin:"read_only;filter"`
+	Progress    int          `uadmin:"read_only;progress_bar"`
+	Records     int          `uadmin:"read_only"`
+	Download    string       `uadmin:"read_only;link"`
+	ExpiresAt   *time.Time   `uadmin:"read_only"`
+	FinishedAt  *time.Time   `uadmin:"read_only"`
+	Error       string       `uadmin:"read_only;list_exclude"`
+	FileName    string       `uadmin:"read_only;hidden;list_exclude"`
+	DownloadKey string       `uadmin:"read_only;hidden;list_exclude"`
+}
+
+func (d DataExport) String() string {
+	return fmt.Sprintf("%s %d", d.ModelName, d.ID)
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (DataExport) HideInDashboard() bool {
+	return true
+}
+
+// exportTaskName is the name of the task that creates data exports
+const exportTaskName = "uadmin/export"
+
+// exportTask is the payload of the export task
+type exportTask struct {
+	ExportID uint
+}
+
+func init() {
+	RegisterTask(exportTaskName, runExportTask)
+	RegisterJob("uadmin/export-cleanup", "@every 1h", func(ctx context.Context) error {
+		removeExpiredExports()
+		return nil
+	})
+}
+
+// startExport creates a data export in a format for the records of a model
+// matching the filters in the request. It returns an error if the user
+// reached the limit of concurrent exports.
+func startExport(r *http.Request, session *Session, format string) (*DataExport, error) {
+	export := &DataExport{
+		ModelName: r.URL.Query().Get("m"),
+		Format:    format,
+		Filters:   r.URL.RawQuery,
+		UserID:    session.UserID,
+		Status:    ExportStatus(0).Queued(),
+	}
+	// The user is locked while their active exports are counted so
+	// concurrent requests can't pass the limit together
+	err := Transaction(r.Context(), func(tx *Tx) error {
+		if err := tx.DB().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", session.UserID).First(&User{}).Error; err != nil {
+			return err
+		}
+		active := tx.Count(&DataExport{}, "user_id = ? AND status IN (?)", session.UserID, []ExportStatus{ExportStatus(0).Queued(), ExportStatus(0).Running()})
+		if ExportMaxConcurrent > 0 && active >= ExportMaxConcurrent {
+			return fmt.Errorf("you can only run %d exports at the same time", ExportMaxConcurrent)
+		}
+		return tx.Save(export)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := EnqueueTask(exportTaskName, exportTask{ExportID: export.ID}); err != nil {
+		updateExport(export.ID, map[string]interface{}{
+			"status": export.Status.Failed(),
+			"error":  err.Error(),
+		})
+		return nil, err
+	}
+	return export, nil
+}
+
+// runExportTask creates the file of a data export and notifies its user
+func runExportTask(ctx context.Context, task *Task) error {
+	e := exportTask{}
+	if err := task.UnmarshalPayload(&e); err != nil {
+		return err
+	}
+	export := DataExport{}
+	Get(&export, "id = ?", e.ExportID)
+	if export.ID == 0 {
+		return fmt.Errorf("data export %d was not found", e.ExportID)
+	}
+	updateExport(export.ID, map[string]interface{}{"status": export.Status.Running()})
+
+	r, err := http.NewRequest("GET", "/export/?"+export.Filters, nil)
+	if err != nil {
+		return err
+	}
+	session := &Session{UserID: export.UserID}
+	Get(&session.User, "id = ?", export.UserID)
+
+	progress := 0
+	format, _ := getExportFormat(export.Format)
+	fileName, err := exportData(r, session, format, func(done int, total int) {
+		if total == 0 || done*100/total == progress {
+			return
+		}
+		progress = done * 100 / total
+		updateExport(export.ID, map[string]interface{}{"progress": progress, "records": total})
+	})
+	if err != nil {
+		updates := map[string]interface{}{"status": export.Status.Queued(), "error": err.Error()}
+		if task.Attempts >= task.MaxAttempts {
+			updates["status"] = export.Status.Failed()
+		}
+		updateExport(export.ID, updates)
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ExportExpiry)
+	export.DownloadKey = GenerateBase64(24)
+	export.Download = RootURL + "export/?download=" + export.DownloadKey
+	updateExport(export.ID, map[string]interface{}{
+		"status":       export.Status.Finished(),
+		"progress":     100,
+		"file_name":    fileName,
+		"download_key": export.DownloadKey,
+		"download":     export.Download,
+		"expires_at":   expiresAt,
+		"finished_at":  now,
+		"error":        "",
+	})
+	task.Result = export.Download
+
+	// Notify the user by email
+	if ExportNotifyEmail && session.User.Email != "" {
+		subject := fmt.Sprintf("Your export of %s is ready", export.ModelName)
+		body := fmt.Sprintf("Your export of %s is ready to download until %s.\n\n%s",
+			export.ModelName, expiresAt.Format("2006-01-02 15:04"), export.Download)
+		SendEmail([]string{session.User.Email}, []string{}, []string{}, subject, body)
+	}
+	return nil
+}
+
+func updateExport(ID uint, updates map[string]interface{}) {
+	if err := db.Model(&DataExport{}).Where("id = ?", ID).Updates(updates).Error; err != nil {
+		Trail(ERROR, "Unable to update data export %d. %s", ID, err)
+	}
+}
+
+// removeExpiredExports removes the files of expired exports
+func removeExpiredExports() {
+	exports := []DataExport{}
+	Filter(&exports, "status = ? AND expires_at < ?", ExportStatus(0).Finished(), time.Now())
+	for _, export := range exports {
+		if strings.HasPrefix(export.FileName, "/media/export/") {
+			if err := os.Remove("." + export.FileName); err != nil && !os.IsNotExist(err) {
+				Trail(WARNING, "Unable to remove expired export %s. %s", export.FileName, err)
+			}
+		}
+		updateExport(export.ID, map[string]interface{}{
+			"status":       export.Status.Expired(),
+			"download":     "",
+			"download_key": "",
+		})
+	}
+}
+
+// exportStatusHandler returns the status of a data export as JSON
+func exportStatusHandler(w http.ResponseWriter, r *http.Request, session *Session) {
+	export := DataExport{}
+	Get(&export, "id = ?", r.URL.Query().Get("id"))
+	if export.ID == 0 || (export.UserID != session.UserID && !session.User.Admin) {
+		w.WriteHeader(http.StatusNotFound)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": "export not found",
+		})
+		return
+	}
+	ReturnJSON(w, r, map[string]interface{}{
+		"status":        "ok",
+		"id":            export.ID,
+		"export_status": GetString(export.Status),
+		"progress":      export.Progress,
+		"records":       export.Records,
+		"download":      export.Download,
+		"expires_at":    export.ExpiresAt,
+		"err_msg":       export.Error,
+	})
+}
+
+// exportDownloadHandler serves the file of a finished data export to its user
+func exportDownloadHandler(w http.ResponseWriter, r *http.Request, session *Session) {
+	export := DataExport{}
+	key := r.URL.Query().Get("download")
+	if key != "" {
+		Get(&export, "download_key = ? AND status = ?", key, export.Status.Finished())
+	}
+	if export.ID == 0 || export.UserID != session.UserID || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		pageErrorHandler(w, r, session)
+		return
+	}
+	format, _ := getExportFormat(export.Format)
+	w.Header().Set("Content-Type", exportFormats[format][1])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_%s%s\"", export.ModelName, export.FinishedAt.Format("20060102150405"), exportFormats[format][0]))
+	http.ServeFile(w, r, "."+export.FileName)
+}
@@ -0,0 +1,637 @@
+package uadmin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func init() {
+	RegisterJob("uadmin/import-cleanup", "@every 1h", func(ctx context.Context) error {
+		removeExpiredImportFiles()
+		return nil
+	})
+}
+
+// ImportStatus is the status of a data import
+type ImportStatus int
+
+// Uploaded is an import whose file was uploaded and is waiting to be mapped
+func (ImportStatus) Uploaded() ImportStatus {
+	return 1
+}
+
+// Validated is an import that passed a dry run without errors
+func (ImportStatus) Validated() ImportStatus {
+	return 2
+}
+
+// Imported is an import whose records were saved
+func (ImportStatus) Imported() ImportStatus {
+	return 3
+}
+
+// Failed is an import that has errors in some rows. No records are saved
+// when any row fails.
+func (ImportStatus) Failed() ImportStatus {
+	return 4
+}
+
+// DataImport is a model that stores an import of records from an excel or
+// CSV file and the summary of its last run
+type DataImport struct {
+	Model
+	ModelName  string       `uadmin:"read_only;filter"`
+	FileName   string       `uadmin:"read_only;list_exclude"`
+	Delimiter  string       `uadmin:"read_only;list_exclude"`
+	Encoding   string       `uadmin:"read_only;list_exclude"`
+	User       User         `uadmin:"read_only;filter"`
+	UserID     uint         `uadmin:"read_only"`
+	Mapping    string       `uadmin:"read_only;code;list_exclude"`
+	DryRun     bool         `uadmin:"read_only;filter"`
+	Status     ImportStatus `uadmin:"read_only;filter"`
+	Rows       int          `uadmin:"read_only"`
+	Created    int          `uadmin:"read_only"`
+	Updated    int          `uadmin:"read_only"`
+	Failed     int          `uadmin:"read_only"`
+	Errors     string       `uadmin:"read_only;code;list_exclude"`
+	FinishedAt *time.Time   `uadmin:"read_only"`
+}
+
+func (d DataImport) String() string {
+	return fmt.Sprintf("%s %d", d.ModelName, d.ID)
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (DataImport) HideInDashboard() bool {
+	return true
+}
+
+// importError is a validation error in a row of an import
+type importError struct {
+	Row    int
+	Column string
+	Error  string
+}
+
+func (e importError) String() string {
+	if e.Column == "" {
+		return fmt.Sprintf("Row %d: %s", e.Row, e.Error)
+	}
+	return fmt.Sprintf("Row %d: %s: %s", e.Row, e.Column, e.Error)
+}
+
+// importDateFormats are the formats of dates accepted in imports
+var importDateFormats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC3339,
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+	"1/2/06 15:04",
+	"1/2/06",
+}
+
+// readImportFile reads the rows of an excel or CSV file of an import. The
+// first row is the header.
+func readImportFile(d *DataImport) ([][]string, error) {
+	if strings.HasSuffix(strings.ToLower(d.FileName), ".xlsx") {
+		f, err := excelize.OpenFile("." + d.FileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		rows, err := f.GetRows(f.GetSheetName(0))
+		if err != nil {
+			return nil, err
+		}
+		return trimImportRows(rows), nil
+	}
+
+	f, err := os.Open("." + d.FileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	enc, _, err := getExportEncoding(d.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader = f
+	if enc != nil {
+		reader = enc.NewDecoder().Reader(f)
+	}
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimPrefix(string(buf), "\xef\xbb\xbf")
+
+	delimiter := ','
+	if d.Delimiter == "" {
+		delimiter = detectImportDelimiter(content)
+	} else if delimiter, err = getExportDelimiter(d.Delimiter); err != nil {
+		return nil, err
+	}
+	csvReader := csv.NewReader(strings.NewReader(content))
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	rows, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return trimImportRows(rows), nil
+}
+
+// detectImportDelimiter returns the most common delimiter in the header of
+// a CSV file
+func detectImportDelimiter(content string) rune {
+	header := strings.SplitN(content, "\n", 2)[0]
+	delimiter := ','
+	count := strings.Count(header, ",")
+	for _, d := range []rune{';', '\t', '|'} {
+		if c := strings.Count(header, string(d)); c > count {
+			delimiter = d
+			count = c
+		}
+	}
+	return delimiter
+}
+
+// trimImportRows removes empty rows at the end of a file
+func trimImportRows(rows [][]string) [][]string {
+	for len(rows) > 0 && strings.TrimSpace(strings.Join(rows[len(rows)-1], "")) == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+// getImportFields returns the fields of a schema that can be mapped to
+// columns of an import. Read only fields are not imported except the ID
+// that is used to update records.
+func getImportFields(schema *ModelSchema) []F {
+	fields := []F{}
+	for _, f := range schema.Fields {
+		if f.IsMethod || f.Type == cM2M || f.Type == cPASSWORD || f.Type == cIMAGE || f.Type == cFILE || f.Type == cLINK || f.Type == cPROGRESSBAR {
+			continue
+		}
+		if f.ReadOnly != "" && f.Type != cID {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// guessImportMapping maps the columns of an import to fields with the same
+// name, display name or column name
+func guessImportMapping(schema *ModelSchema, header []string) []string {
+	normalize := func(v string) string {
+		return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.TrimSpace(v)))
+	}
+	fields := getImportFields(schema)
+	mapping := make([]string, len(header))
+	for i := range header {
+		h := normalize(header[i])
+		for _, f := range fields {
+			names := []string{f.Name, f.DisplayName, f.ColumnName}
+			if f.Type == cFK {
+				names = append(names, f.Name+"ID")
+			}
+			for _, name := range names {
+				if h != "" && normalize(name) == h {
+					mapping[i] = f.Name
+				}
+			}
+			if mapping[i] != "" {
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// importRecord is a validated row of an import of a model with a Save method
+type importRecord struct {
+	m      reflect.Value
+	log    Log
+	action Action
+}
+
+// runImport creates or updates the records in the rows of an import in a
+// transaction. Rows with an ID column update the record with that ID. In a
+// dry run or when any row has an error, the transaction is rolled back. A
+// Log is created for every saved record and the summary of the run is stored
+// in the import. Models with a Save method are saved with it after the rows
+// are validated because the method doesn't run in the transaction.
+func runImport(d *DataImport, rows [][]string, mapping []string, dryRun bool, session *Session, r *http.Request) []importError {
+	errs := []importError{}
+	schema, ok := getSchema(d.ModelName)
+	if !ok {
+		return []importError{{Error: "invalid model name: " + d.ModelName}}
+	}
+	if len(rows) == 0 {
+		return []importError{{Error: "the file has no header"}}
+	}
+	perm := session.User.GetAccess(d.ModelName)
+
+	// Records that are not in the list of the user cannot be updated
+	lmQuery := ""
+	lmArgs := []interface{}{}
+	if schema.ListModifier != nil {
+		lmQuery, lmArgs = schema.ListModifier(&schema, &session.User)
+	}
+
+	// Resolve the fields of the columns
+	header := rows[0]
+	fields := make([]*F, len(header))
+	mapped := map[string]bool{}
+	for i := range header {
+		if i >= len(mapping) || mapping[i] == "" {
+			continue
+		}
+		for _, f := range getImportFields(&schema) {
+			if f.Name == mapping[i] {
+				f := f
+				if mapped[f.Name] {
+					return []importError{{Column: header[i], Error: f.DisplayName + " is mapped to more than one column"}}
+				}
+				fields[i] = &f
+				mapped[f.Name] = true
+			}
+		}
+	}
+
+	// Load the choices of foreign keys before starting the transaction
+	fkChoices := map[string][]Choice{}
+	for _, f := range fields {
+		if f != nil && f.Type == cFK {
+			fkChoices[f.Name] = getChoices(f.TypeName)
+		}
+	}
+
+	d.Rows = len(rows) - 1
+	d.Created = 0
+	d.Updated = 0
+	d.Failed = 0
+	model := registeredModels()[d.ModelName]
+	_, isSaver := reflect.New(reflect.TypeOf(model)).Interface().(saver)
+	records := []importRecord{}
+	tx := modelDB(model).Begin()
+	// Logs of models in named databases are saved in the default database
+	// after the commit
+	logs := []Log{}
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+		rowErrors := []importError{}
+		m, _ := NewModel(d.ModelName, true)
+
+		// Load the record when the row has an ID
+		action := Action(0).Added()
+		log := Log{}
+		for c, f := range fields {
+			if f == nil || f.Type != cID || c >= len(row) || strings.TrimSpace(row[c]) == "" {
+				continue
+			}
+			q := tx.Where("id = ?", strings.TrimSpace(row[c]))
+			if lmQuery != "" {
+				q = q.Where(fixQueryEnclosure(lmQuery), lmArgs...)
+			}
+			if err := q.First(m.Interface()).Error; err != nil {
+				rowErrors = append(rowErrors, importError{rowNum, header[c], fmt.Sprintf("no record with ID %s", row[c])})
+			} else {
+				// Encrypted fields are encrypted again when the record is saved
+				decryptRecord(m.Interface())
+				// Modified logs store the record before the change with its
+				// M2M fields
+				before, _ := NewModel(d.ModelName, true)
+				before.Elem().Set(m.Elem())
+				customGet(before.Interface())
+				log.ParseRecord(before, d.ModelName, GetID(m), &session.User, Action(0).Modified(), r)
+			}
+			action = Action(0).Modified()
+		}
+		if action == action.Added() && !perm.Add {
+			rowErrors = append(rowErrors, importError{rowNum, "", "you don't have permission to add records"})
+		}
+		if action == action.Modified() && !perm.Edit {
+			rowErrors = append(rowErrors, importError{rowNum, "", "you don't have permission to edit records"})
+		}
+
+		for c, f := range fields {
+			if f == nil || f.Type == cID {
+				continue
+			}
+			value := ""
+			if c < len(row) {
+				value = strings.TrimSpace(row[c])
+			}
+			if err := setImportValue(m.Elem(), f, value, fkChoices[f.Name]); err != nil {
+				rowErrors = append(rowErrors, importError{rowNum, header[c], err.Error()})
+			}
+		}
+
+		// Check required fields of new records that are not in the file
+		if action == action.Added() {
+			for _, f := range getImportFields(&schema) {
+				if f.Required && !mapped[f.Name] && f.Type != cID {
+					rowErrors = append(rowErrors, importError{rowNum, f.DisplayName, "is required"})
+				}
+			}
+		}
+
+		// Call the Validate method of the model
+		if validate := m.MethodByName("Validate"); validate.IsValid() && validate.Type().NumIn() == 0 && validate.Type().NumOut() == 1 {
+			if errMap, ok := validate.Call([]reflect.Value{})[0].Interface().(map[string]string); ok {
+				for k, v := range errMap {
+					rowErrors = append(rowErrors, importError{rowNum, schema.FieldByName(k).DisplayName, v})
+				}
+			}
+		}
+
+		// Check unique fields against the saved records and the previous rows
+		errMap := validateUniqueWith(tx, m.Interface(), &schema, getUniqueValues(m.Interface(), m), GetID(m))
+		for k, v := range errMap {
+			rowErrors = append(rowErrors, importError{rowNum, schema.FieldByName(k).DisplayName, v})
+		}
+
+		if len(rowErrors) == 0 && isSaver {
+			record, _ := NewModel(d.ModelName, true)
+			record.Elem().Set(m.Elem())
+			if action == action.Modified() {
+				// Keep the M2M fields which are not imported
+				customGet(record.Interface())
+			}
+			records = append(records, importRecord{m: record, log: log, action: action})
+		}
+		if len(rowErrors) == 0 {
+			encryptRecord(m.Interface())
+			a := m.Interface()
+			if getDBType(model) == "mysql" {
+				a = fixDates(a)
+			}
+			if err := tx.Save(a).Error; err != nil {
+				rowErrors = append(rowErrors, importError{rowNum, "", err.Error()})
+			}
+		}
+		if len(rowErrors) != 0 {
+			d.Failed++
+			errs = append(errs, rowErrors...)
+			continue
+		}
+
+		if action == action.Added() {
+			d.Created++
+		} else {
+			d.Updated++
+		}
+		if isSaver {
+			continue
+		}
+		if action == action.Added() {
+			log.ParseRecord(m, d.ModelName, GetID(m), &session.User, action, r)
+		}
+		if getDBName(model) != "" {
+			logs = append(logs, log)
+		} else if err := tx.Create(&log).Error; err != nil {
+			Trail(ERROR, "runImport unable to create log for %s. %s", d.ModelName, err)
+		}
+	}
+
+	if dryRun || len(errs) != 0 {
+		tx.Rollback()
+	} else if isSaver {
+		tx.Rollback()
+		for i := range records {
+			records[i].m.Interface().(saver).Save()
+			if records[i].action == records[i].action.Added() {
+				records[i].log.ParseRecord(records[i].m, d.ModelName, GetID(records[i].m), &session.User, records[i].action, r)
+			}
+			records[i].log.Save()
+		}
+	} else if err := tx.Commit().Error; err != nil {
+		errs = append(errs, importError{Error: err.Error()})
+	} else {
+		for i := range logs {
+			if err := db.Create(&logs[i]).Error; err != nil {
+				Trail(ERROR, "runImport unable to create log for %s. %s", d.ModelName, err)
+			}
+		}
+	}
+
+	// Store the summary of the run
+	buf, _ := json.Marshal(mapping)
+	errMsgs := make([]string, len(errs))
+	for i := range errs {
+		errMsgs[i] = errs[i].String()
+	}
+	now := time.Now()
+	d.Mapping = string(buf)
+	d.DryRun = dryRun
+	d.Errors = strings.Join(errMsgs, "\n")
+	d.FinishedAt = &now
+	d.Status = d.Status.Imported()
+	if len(errs) != 0 {
+		d.Status = d.Status.Failed()
+	} else if dryRun {
+		d.Status = d.Status.Validated()
+	}
+	if !dryRun && len(errs) == 0 {
+		removeImportFile(d)
+	}
+	Save(d)
+	if !dryRun && len(errs) == 0 {
+		Trail(INFO, "%s imported %d new and %d updated %s records from %s", session.User.Username, d.Created, d.Updated, d.ModelName, d.FileName)
+	}
+	return errs
+}
+
+// removeImportFile removes the uploaded file of an import
+func removeImportFile(d *DataImport) {
+	if !strings.HasPrefix(d.FileName, "/media/import/") || strings.Contains(d.FileName, "..") {
+		return
+	}
+	if err := os.Remove("." + d.FileName); err != nil && !os.IsNotExist(err) {
+		Trail(WARNING, "Unable to remove import file %s. %s", d.FileName, err)
+	}
+}
+
+// removeExpiredImportFiles removes uploaded import files older than
+// ImportExpiry that were not imported
+func removeExpiredImportFiles() {
+	files, err := os.ReadDir("./media/import")
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || file.IsDir() || file.Name() == "index.html" || time.Since(info.ModTime()) < ImportExpiry {
+			continue
+		}
+		if err = os.Remove("./media/import/" + file.Name()); err != nil && !os.IsNotExist(err) {
+			Trail(WARNING, "Unable to remove expired import file %s. %s", file.Name(), err)
+		}
+	}
+}
+
+// setImportValue sets the value of a field in a record from a cell of an
+// import. Foreign keys are matched by ID or by their string value and static
+// lists by their value or display name.
+func setImportValue(record reflect.Value, f *F, value string, choices []Choice) error {
+	if f.Required && value == "" {
+		return fmt.Errorf("is required")
+	}
+	if f.Pattern != "" && value != "" {
+		if re, err := regexp.Compile(f.Pattern); err == nil && !re.MatchString(value) {
+			if f.PatternMsg != "" {
+				return fmt.Errorf("%s", f.PatternMsg)
+			}
+			return fmt.Errorf("invalid format %q", value)
+		}
+	}
+
+	switch f.Type {
+	case cFK:
+		field := record.FieldByName(f.Name + "ID")
+		if value == "" {
+			field.SetUint(0)
+			return nil
+		}
+		ID, err := matchImportChoice(choices, value)
+		if err != nil {
+			return err
+		}
+		field.SetUint(uint64(ID))
+		return nil
+	case cLIST:
+		field := record.FieldByName(f.Name)
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		ID, err := matchImportChoice(f.Choices, value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(ID))
+		return nil
+	case cDATE:
+		field := record.FieldByName(f.Name)
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		d, err := parseImportDate(value)
+		if err != nil {
+			return err
+		}
+		if field.Kind() == reflect.Ptr {
+			field.Set(reflect.ValueOf(&d))
+		} else {
+			field.Set(reflect.ValueOf(d))
+		}
+		return nil
+	}
+
+	field := record.FieldByName(f.Name)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		switch strings.ToLower(value) {
+		case "1", "true", "yes", "y", "on":
+			field.SetBool(true)
+		case "", "0", "false", "no", "n", "off":
+			field.SetBool(false)
+		default:
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			value = "0"
+		}
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || field.OverflowInt(v) {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			value = "0"
+		}
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || field.OverflowUint(v) {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			value = "0"
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// matchImportChoice returns the key of the choice matching a value by its
+// key or its string value
+func matchImportChoice(choices []Choice, value string) (uint, error) {
+	if ID, err := strconv.ParseUint(value, 10, 64); err == nil {
+		for _, c := range choices {
+			if c.K == uint(ID) && c.K != 0 {
+				return c.K, nil
+			}
+		}
+	}
+	matches := []uint{}
+	for _, c := range choices {
+		if c.K != 0 && strings.EqualFold(strings.TrimSpace(c.V), value) {
+			matches = append(matches, c.K)
+		}
+	}
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no match for %q", value)
+	}
+	if len(matches) > 1 {
+		return 0, fmt.Errorf("%q matches %d records", value, len(matches))
+	}
+	return matches[0], nil
+}
+
+// parseImportDate parses a date in an import. Excel serial dates are also
+// accepted.
+func parseImportDate(value string) (time.Time, error) {
+	for _, format := range importDateFormats {
+		if d, err := time.ParseInLocation(format, value, getTZ()); err == nil {
+			return d, nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil {
+		d, err := excelize.ExcelDateToTime(serial, false)
+		if err == nil {
+			return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), 0, getTZ()), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
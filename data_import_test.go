@@ -0,0 +1,235 @@
+package uadmin
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+)
+
+type TestImportItem struct {
+	Model
+	Code string `uadmin:"unique"`
+	Name string
+}
+
+// Save saves the code in upper case
+func (m *TestImportItem) Save() {
+	m.Code = strings.ToUpper(m.Code)
+	Save(m)
+}
+
+// TestDataImport is a unit testing function for importing records from
+// excel and CSV files
+func (t *UAdminTests) TestDataImport() {
+	s1 := Session{
+		Active:    true,
+		UserID:    1,
+		LoginTime: time.Now(),
+	}
+	s1.GenerateKey()
+	s1.Save()
+	Preload(&s1)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	om := TestStruct1{Name: "Other Model"}
+	Save(&om)
+	om2 := TestStruct1{Name: "Second Model"}
+	Save(&om2)
+
+	os.MkdirAll("./media/import/", 0700)
+	newImport := func(content string, delimiter string, encoding string) (*DataImport, [][]string) {
+		fileName := "/media/import/" + GenerateBase64(24) + ".csv"
+		os.WriteFile("."+fileName, []byte(content), 0600)
+		d := &DataImport{ModelName: "teststruct2", FileName: fileName, Delimiter: delimiter, Encoding: encoding, UserID: s1.UserID}
+		Save(d)
+		rows, err := readImportFile(d)
+		if err != nil {
+			t.Errorf("readImportFile returned an error. %s", err)
+		}
+		return d, rows
+	}
+
+	// Column mapping
+	schema, _ := getSchema("teststruct2")
+	mapping := guessImportMapping(&schema, []string{"name", "Count", "Other Model", "another_model_id", "Unknown", "Start", "Type"})
+	expected := []string{"Name", "Count", "OtherModel", "AnotherModel", "", "Start", "Type"}
+	if strings.Join(mapping, ",") != strings.Join(expected, ",") {
+		t.Errorf("guessImportMapping returned invalid mapping. Got %v expected %v", mapping, expected)
+	}
+
+	// Dry run with errors
+	d, rows := newImport("Name;Count;Other Model;Type;Start;Active\n"+
+		"Row 1;1;Other Model;Active;2024-01-02;yes\n"+
+		"Row 2;x;Unknown Model;Wrong;2024-13-45;maybe\n", "", "")
+	mapping = guessImportMapping(&schema, rows[0])
+	errs := runImport(d, rows, mapping, true, &s1, r)
+	if len(errs) != 5 || d.Status != d.Status.Failed() || d.Failed != 1 || d.Rows != 2 {
+		t.Errorf("runImport didn't report errors in the dry run. Got %v %#v", errs, d)
+	}
+	if Count(&[]TestStruct2{}, "") != 0 {
+		t.Errorf("runImport saved records in a dry run")
+	}
+
+	// Dry run and import
+	d, rows = newImport("Name,Count,Other Model,Another Model,Type,Start,End,Active\n"+
+		"Caf\xe9 1,1,Other Model,,Active,2024-01-02 03:04:05,,yes\n"+
+		fmt.Sprintf("Caf\xe9 2,2,%d,second model,%d,2024-01-03,2024-01-04,0\n", om.ID, TestType(0).Inactive()), "", "windows-1252")
+	mapping = guessImportMapping(&schema, rows[0])
+	if errs = runImport(d, rows, mapping, true, &s1, r); len(errs) != 0 || d.Status != d.Status.Validated() || d.Created != 2 {
+		t.Errorf("runImport didn't validate the dry run. Got %v %#v", errs, d)
+	}
+	if Count(&[]TestStruct2{}, "") != 0 {
+		t.Errorf("runImport saved records in a dry run")
+	}
+	logs := Count(&[]Log{}, "table_name = ? AND action = ?", "teststruct2", Action(0).Added())
+	if errs = runImport(d, rows, mapping, false, &s1, r); len(errs) != 0 || d.Status != d.Status.Imported() || d.Created != 2 {
+		t.Errorf("runImport didn't import the records. Got %v %#v", errs, d)
+	}
+	if _, err := os.Stat("." + d.FileName); !os.IsNotExist(err) {
+		t.Errorf("runImport didn't remove the imported file %s", d.FileName)
+	}
+	records := []TestStruct2{}
+	FilterSorted("id", true, &records, "")
+	if len(records) != 2 {
+		t.Errorf("runImport didn't create the records. Got %d", len(records))
+	} else {
+		if records[0].Name != "Café 1" || records[0].Count != 1 || records[0].OtherModelID != om.ID || records[0].Type != TestType(0).Active() ||
+			!records[0].Active || records[0].End != nil || records[0].Start.Format("2006-01-02 15:04:05") != "2024-01-02 03:04:05" {
+			t.Errorf("runImport created an invalid record. Got %#v", records[0])
+		}
+		if records[1].OtherModelID != om.ID || records[1].AnotherModelID != om2.ID || records[1].Type != TestType(0).Inactive() ||
+			records[1].Active || records[1].End == nil {
+			t.Errorf("runImport created an invalid record. Got %#v", records[1])
+		}
+	}
+	if newLogs := Count(&[]Log{}, "table_name = ? AND action = ?", "teststruct2", Action(0).Added()); newLogs != logs+2 {
+		t.Errorf("runImport didn't create a log for each record. Expected %d got %d", logs+2, newLogs)
+	}
+
+	// Update records by ID and roll back when a row fails
+	if len(records) == 2 {
+		d, rows = newImport(fmt.Sprintf("ID\tCount\n%d\t10\n%d\t20\n", records[0].ID, records[1].ID), "tab", "")
+		if errs = runImport(d, rows, []string{"ID", "Count"}, false, &s1, r); len(errs) != 0 || d.Updated != 2 || d.Created != 0 {
+			t.Errorf("runImport didn't update the records. Got %v %#v", errs, d)
+		}
+		Get(&records[0], "id = ?", records[0].ID)
+		if records[0].Count != 10 || records[0].Name != "Café 1" {
+			t.Errorf("runImport didn't update the record. Got %#v", records[0])
+		}
+
+		d, rows = newImport(fmt.Sprintf("ID,Count\n%d,30\n999999,40\n", records[0].ID), "", "")
+		if errs = runImport(d, rows, []string{"ID", "Count"}, false, &s1, r); len(errs) != 1 || d.Status != d.Status.Failed() {
+			t.Errorf("runImport didn't fail for an unknown ID. Got %v %#v", errs, d)
+		}
+		Get(&records[0], "id = ?", records[0].ID)
+		if records[0].Count != 10 {
+			t.Errorf("runImport didn't roll back the failed import. Got %d", records[0].Count)
+		}
+	}
+
+	// Read only fields are not imported and updates keep encrypted fields
+	// and only change records in the list of the user
+	schemaB, _ := getSchema("testmodelb")
+	for _, f := range getImportFields(&schemaB) {
+		if f.Name == "ItemCount" || f.Name == "Active" {
+			t.Errorf("getImportFields returned the read only field %s", f.Name)
+		}
+	}
+	b1 := TestModelB{Name: "import_b1", Phone: "123456789", ItemCount: 1}
+	b2 := TestModelB{Name: "import_b2", Phone: "123456789", ItemCount: 1}
+	Save(&b1)
+	Save(&b2)
+	bImport := &DataImport{ModelName: "testmodelb", UserID: s1.UserID}
+	rows = [][]string{{"ID", "Name"}, {fmt.Sprint(b1.ID), "import_b3"}}
+	if errs = runImport(bImport, rows, []string{"ID", "Name"}, false, &s1, r); len(errs) != 0 || bImport.Updated != 1 {
+		t.Errorf("runImport didn't update testmodelb. Got %v %#v", errs, bImport)
+	}
+	Get(&b1, "id = ?", b1.ID)
+	if b1.Name != "import_b3" || b1.Phone != "123456789" {
+		t.Errorf("runImport didn't keep the encrypted field. Got %#v", b1)
+	}
+	schemaB = Schema["testmodelb"]
+	listModifier := schemaB.ListModifier
+	schemaB.ListModifier = func(*ModelSchema, *User) (string, []interface{}) {
+		return "id <> ?", []interface{}{b2.ID}
+	}
+	Schema["testmodelb"] = schemaB
+	rows = [][]string{{"ID", "Name"}, {fmt.Sprint(b2.ID), "import_b4"}}
+	if errs = runImport(bImport, rows, []string{"ID", "Name"}, false, &s1, r); len(errs) != 1 {
+		t.Errorf("runImport didn't return an error for a record that is not in the list. Got %v", errs)
+	}
+	schemaB.ListModifier = listModifier
+	Schema["testmodelb"] = schemaB
+	DeleteList(&TestModelB{}, "id IN (?)", []uint{b1.ID, b2.ID})
+	Delete(bImport)
+
+	// Unique fields are checked against the previous rows and models with a
+	// Save method are saved with it
+	initializeDB(TestImportItem{})
+	models["testimportitem"] = TestImportItem{}
+	Schema["testimportitem"], _ = getSchema(TestImportItem{})
+	defer func() {
+		delete(models, "testimportitem")
+		delete(Schema, "testimportitem")
+		db.Migrator().DropTable(&TestImportItem{})
+		db.Where("table_name = ?", "testimportitem").Delete(&Log{})
+	}()
+	itemImport := &DataImport{ModelName: "testimportitem", UserID: s1.UserID}
+	rows = [][]string{{"Code", "Name"}, {"a1", "First"}, {"a1", "Second"}}
+	if errs = runImport(itemImport, rows, []string{"Code", "Name"}, true, &s1, r); len(errs) != 1 || errs[0].Row != 3 {
+		t.Errorf("runImport didn't return an error for a duplicate unique value. Got %v", errs)
+	}
+	rows = [][]string{{"Code", "Name"}, {"a1", "First"}, {"a2", "Second"}}
+	if errs = runImport(itemImport, rows, []string{"Code", "Name"}, false, &s1, r); len(errs) != 0 || itemImport.Created != 2 {
+		t.Errorf("runImport didn't import the records of a model with a Save method. Got %v %#v", errs, itemImport)
+	}
+	if Count(&[]TestImportItem{}, "code IN (?)", []string{"A1", "A2"}) != 2 {
+		t.Errorf("runImport didn't save the records with the Save method of the model")
+	}
+	if n := Count(&[]Log{}, "table_name = ? AND action = ?", "testimportitem", Action(0).Added()); n != 2 {
+		t.Errorf("runImport didn't log the records saved with the Save method. Expected 2 got %d", n)
+	}
+	Delete(itemImport)
+
+	// Uploaded files that are not imported expire
+	expired := "./media/import/" + GenerateBase64(24) + ".csv"
+	os.WriteFile(expired, []byte("Name\n"), 0600)
+	os.Chtimes(expired, time.Now().Add(-ImportExpiry-time.Hour), time.Now().Add(-ImportExpiry-time.Hour))
+	removeExpiredImportFiles()
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("removeExpiredImportFiles didn't remove an expired file")
+	}
+
+	// Upload a file using the import page
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("x-csrf-token", s1.Key)
+	part, _ := writer.CreateFormFile("file", "records.csv")
+	part.Write([]byte("Name,Count\nUploaded,5\n"))
+	writer.Close()
+	req := httptest.NewRequest("POST", "/import/?m=teststruct2", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	w := httptest.NewRecorder()
+	importHandler(w, req, &s1)
+	uploaded := DataImport{}
+	Get(&uploaded, "model_name = ? AND status = ?", "teststruct2", uploaded.Status.Uploaded())
+	if w.Code != http.StatusOK || uploaded.ID == 0 || uploaded.Mapping != `["Name","Count"]` {
+		t.Errorf("importHandler didn't upload the file. Got %d %#v", w.Code, uploaded)
+	}
+
+	imports := []DataImport{}
+	Filter(&imports, "model_name = ?", "teststruct2")
+	for _, d := range imports {
+		os.Remove("." + d.FileName)
+	}
+	DeleteList(&[]DataImport{}, "model_name = ?", "teststruct2")
+	DeleteList(&TestStruct2{}, "")
+	DeleteList(&TestStruct1{}, "")
+	Delete(s1)
+}
@@ -1,6 +1,7 @@
 package uadmin
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -38,6 +39,10 @@ var sqlDialect = map[string]map[string]string{
 		"deleteM2M":      "DELETE FROM `{TABLE1}_{TABLE2}` WHERE `table1_id`=?;",
 		"insertM2M":      "INSERT INTO `{TABLE1}_{TABLE2}` VALUES (?, ?);",
 		"selectM2MT2":    "SELECT DISTINCT `table1_id` FROM `{TABLE1}_{TABLE2}` WHERE table2_id IN (?);",
+		"foreignKeys":    "SELECT `COLUMN_NAME` AS `column_name`, `REFERENCED_TABLE_NAME` AS `referenced_table` FROM `information_schema`.`KEY_COLUMN_USAGE` WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? AND `REFERENCED_TABLE_NAME` IS NOT NULL;",
+		"truncDay":       "DATE_FORMAT({COLUMN}, '%Y-%m-%d')",
+		"truncMonth":     "DATE_FORMAT({COLUMN}, '%Y-%m-01')",
+		"truncYear":      "DATE_FORMAT({COLUMN}, '%Y-01-01')",
 	},
 	"postgres": {
 		"createM2MTable": `CREATE TABLE "{TABLE1}_{TABLE2}" ("table1_id" BIGINT NOT NULL, "table2_id" BIGINT NOT NULL, PRIMARY KEY ("table1_id","table2_id"))`,
@@ -45,6 +50,10 @@ var sqlDialect = map[string]map[string]string{
 		"deleteM2M":      `DELETE FROM "{TABLE1}_{TABLE2}" WHERE "table1_id"=?;`,
 		"insertM2M":      `INSERT INTO "{TABLE1}_{TABLE2}" VALUES (?, ?);`,
 		"selectM2MT2":    "SELECT DISTINCT `table1_id` FROM `{TABLE1}_{TABLE2}` WHERE table2_id IN (?);",
+		"foreignKeys":    `SELECT kcu.column_name AS column_name, ccu.table_name AS referenced_table FROM information_schema.table_constraints tc JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_name = tc.constraint_name AND ccu.table_schema = tc.table_schema WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = ? AND tc.table_schema = current_schema();`,
+		"truncDay":       "TO_CHAR({COLUMN}, 'YYYY-MM-DD')",
+		"truncMonth":     "TO_CHAR({COLUMN}, 'YYYY-MM-01')",
+		"truncYear":      "TO_CHAR({COLUMN}, 'YYYY-01-01')",
 	},
 	"sqlite": {
 		//"createM2MTable": "CREATE TABLE `{TABLE1}_{TABLE2}` (`{TABLE1}_id`	INTEGER NOT NULL,`{TABLE2}_id` INTEGER NOT NULL, PRIMARY KEY(`{TABLE1}_id`,`{TABLE2}_id`));",
@@ -53,11 +62,21 @@ var sqlDialect = map[string]map[string]string{
 		"deleteM2M":      "DELETE FROM `{TABLE1}_{TABLE2}` WHERE `table1_id`=?;",
 		"insertM2M":      "INSERT INTO `{TABLE1}_{TABLE2}` VALUES (?, ?);",
 		"selectM2MT2":    "SELECT DISTINCT `table1_id` FROM `{TABLE1}_{TABLE2}` WHERE table2_id IN (?);",
+		"foreignKeys":    "SELECT `from` AS `column_name`, `table` AS `referenced_table` FROM pragma_foreign_key_list(?);",
+		"truncDay":       "strftime('%Y-%m-%d', {COLUMN})",
+		"truncMonth":     "strftime('%Y-%m-01', {COLUMN})",
+		"truncYear":      "strftime('%Y-01-01', {COLUMN})",
 	},
 }
 
 // Database is the active Database settings
 var Database *DBSettings
+
+// Databases are the settings of named databases by name. Models that
+// implement DBRouter are stored in the named database instead of the
+// default database. The dAPI builds SQL for the type of the default
+// database.
+var Databases map[string]*DBSettings
 var dbOK = false
 
 // DBSettings !
@@ -70,6 +89,19 @@ type DBSettings struct {
 	Port     int    `json:"port"`
 	Timezone string `json:"timezone"`
 	SSLMode  string `json:"sslmode"`
+	// JournalMode is the journal mode of a SQLite database. The default is
+	// WAL.
+	JournalMode string `json:"journal_mode"`
+	// BusyTimeout is how long in milliseconds a write to a SQLite database
+	// waits for another write to finish. The default is 5000.
+	BusyTimeout int `json:"busy_timeout"`
+	// VacuumSchedule and AnalyzeSchedule are cron specs of jobs that run
+	// VACUUM and ANALYZE on a SQLite database
+	VacuumSchedule  string `json:"vacuum_schedule"`
+	AnalyzeSchedule string `json:"analyze_schedule"`
+	// Replicas are read replicas of the database. Empty settings of a
+	// replica are the same as the settings of the database.
+	Replicas []DBSettings `json:"replicas"`
 }
 
 type AutoMigrater interface {
@@ -89,16 +121,32 @@ func initializeDB(a ...interface{}) {
 			}
 		}
 		Trail(INFO, "Initializing DB: [%s%d/%d%s]", colors.FGGreenB, i+1, len(a), colors.FGNormal)
-		err := db.AutoMigrate(model)
+		if !AutoMigrateSchema && getDBName(model) == "" && modelDB(model).Migrator().HasTable(model) {
+			continue
+		}
+		err := renameSchemaColumns(model)
+		if err != nil {
+			Trail(ERROR, "Unable to rename columns of %s. %s", reflect.TypeOf(model).Name(), err)
+		}
+		err = modelDB(model).AutoMigrate(model)
 		if err != nil {
 			Trail(ERROR, "Unable to migrate schema of %s. %s", reflect.TypeOf(model).Name(), err)
 		}
 		err = customMigration(model)
-		if err != nil {
+		if _, ok := err.(indexConflictError); ok {
+			// Fail instead of starting without the unique index
+			Trail(ERROR, "Unable to migrate schema of %s. %s. Fix the records and start again", reflect.TypeOf(model).Name(), err)
+			os.Exit(2)
+		} else if err != nil {
 			Trail(ERROR, "Unable to custom migrate schema of %s. %s", reflect.TypeOf(model).Name(), err)
 		}
 	}
 	Trail(OK, "Initializing DB: [%s%d/%d%s]", colors.FGGreenB, len(a), len(a), colors.FGNormal)
+	if !AutoMigrateSchema {
+		if changes, err := planSchemaChanges(a...); err == nil && len(changes) != 0 {
+			Trail(WARNING, "The database schema has %d changes that are not applied. Run \"uadmin migrate make\" and \"uadmin migrate\"", len(changes))
+		}
+	}
 	db.AllowGlobalUpdate = true
 }
 
@@ -108,15 +156,15 @@ func customMigration(a interface{}) (err error) {
 		// Check if there is any m2m fields
 		if !strings.Contains(t.Field(i).Tag.Get("uadmin"), "disable_m2m") &&
 			t.Field(i).Type.Kind() == reflect.Slice && t.Field(i).Type.Elem().Kind() == reflect.Struct {
-			table1 := strings.ToLower(t.Name())
-			table2 := strings.ToLower(t.Field(i).Type.Elem().Name())
+			table1 := strings.ToLower(getTypeName(t))
+			table2 := strings.ToLower(getTypeName(t.Field(i).Type.Elem()))
 
 			//Check if the table is created for the m2m field
-			if !db.Migrator().HasTable(table1 + "_" + table2) {
-				sql := sqlDialect[Database.Type]["createM2MTable"]
+			if !modelDB(a).Migrator().HasTable(table1 + "_" + table2) {
+				sql := sqlDialect[getDBType(a)]["createM2MTable"]
 				sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 				sql = strings.Replace(sql, "{TABLE2}", table2, -1)
-				err = db.Exec(sql).Error
+				err = modelDB(a).Exec(sql).Error
 				if err != nil {
 					Trail(ERROR, "Unable to create M2M table. %s", err)
 					Trail(ERROR, sql)
@@ -125,6 +173,12 @@ func customMigration(a interface{}) (err error) {
 			}
 		}
 	}
+
+	// Create the indexes of unique and indexed fields
+	err = createIndexes(a)
+	if err != nil {
+		Trail(ERROR, "Unable to create indexes. %s", err)
+	}
 	return err
 }
 
@@ -202,11 +256,7 @@ func GetDB() *gorm.DB {
 	}
 
 	if strings.ToLower(Database.Type) == "sqlite" {
-		dbName := Database.Name
-		if dbName == "" {
-			dbName = "uadmin.db"
-		}
-		db, err = gorm.Open(sqlite.Open(dbName), &gorm.Config{
+		db, err = gorm.Open(sqlite.Open(getSQLiteDSN(Database)), &gorm.Config{
 			Logger: func() logger.Interface {
 				if DebugDB {
 					return logger.Default.LogMode(logger.Info)
@@ -214,6 +264,16 @@ func GetDB() *gorm.DB {
 				return logger.Default.LogMode(logger.Silent)
 			}(),
 		})
+		if err == nil {
+			registerSQLiteWriteQueue(db, Database)
+			if Database.VacuumSchedule != "" {
+				SQLiteVacuumSchedule = Database.VacuumSchedule
+			}
+			if Database.AnalyzeSchedule != "" {
+				SQLiteAnalyzeSchedule = Database.AnalyzeSchedule
+			}
+			scheduleSQLiteMaintenance()
+		}
 	} else if strings.ToLower(Database.Type) == "mysql" {
 		if Database.Host == "" || Database.Host == "localhost" {
 			Database.Host = "127.0.0.1"
@@ -226,22 +286,7 @@ func GetDB() *gorm.DB {
 			Database.User = "root"
 		}
 
-		credential := Database.User
-
-		if Database.Password != "" {
-			credential = fmt.Sprintf("%s:%s", Database.User, Database.Password)
-		}
-		tz := "Local"
-		if Database.Timezone != "" {
-			tz = Database.Timezone
-		}
-		dsn := fmt.Sprintf("%s@(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=%s",
-			credential,
-			Database.Host,
-			Database.Port,
-			Database.Name,
-			tz,
-		)
+		dsn := getMySQLDSN(Database)
 		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
 			Logger: func() logger.Interface {
 				if DebugDB {
@@ -298,19 +343,7 @@ func GetDB() *gorm.DB {
 		if Database.User == "" {
 			Database.User = "postgres"
 		}
-		tz, _ := tzlocal.RuntimeTZ()
-		if Database.Timezone != "" {
-			tz = Database.Timezone
-		}
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
-			Database.Host,
-			Database.User,
-			Database.Password,
-			Database.Name,
-			Database.Port,
-			Database.SSLMode,
-			tz,
-		)
+		dsn := getPostgresDSN(Database)
 		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
 			Logger: func() logger.Interface {
 				if DebugDB {
@@ -348,9 +381,49 @@ func GetDB() *gorm.DB {
 		Trail(ERROR, "unable to connect to DB. %s", err)
 		db.Error = fmt.Errorf("unable to connect to DB. %s", err)
 	}
+
+	registerCacheCallbacks(db, "")
+	openReplicas()
+	openDatabases()
 	return db
 }
 
+// getMySQLDSN returns the data source name of a MySQL database
+func getMySQLDSN(s *DBSettings) string {
+	credential := s.User
+	if s.Password != "" {
+		credential = fmt.Sprintf("%s:%s", s.User, s.Password)
+	}
+	tz := "Local"
+	if s.Timezone != "" {
+		tz = s.Timezone
+	}
+	return fmt.Sprintf("%s@(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=%s",
+		credential,
+		s.Host,
+		s.Port,
+		s.Name,
+		tz,
+	)
+}
+
+// getPostgresDSN returns the data source name of a PostgreSQL database
+func getPostgresDSN(s *DBSettings) string {
+	tz, _ := tzlocal.RuntimeTZ()
+	if s.Timezone != "" {
+		tz = s.Timezone
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
+		s.Host,
+		s.User,
+		s.Password,
+		s.Name,
+		s.Port,
+		s.SSLMode,
+		tz,
+	)
+}
+
 func createDB() error {
 	if Database.Type == "mysql" {
 		credential := Database.User
@@ -435,6 +508,8 @@ func createDB() error {
 // ClearDB clears the db object
 func ClearDB() {
 	db = nil
+	replicas = nil
+	databases = map[string]*gorm.DB{}
 }
 
 // Set mockDB for testing purpose
@@ -445,10 +520,10 @@ func SetDB(mockDB *gorm.DB) {
 // All fetches all object in the database
 func All(a interface{}) (err error) {
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Find(a).Error
+		err = readDB(a).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Find(a).Error
+			err = readDB(a).Find(a).Error
 		}
 	})
 	if err != nil {
@@ -461,22 +536,27 @@ func All(a interface{}) (err error) {
 
 // Save saves the object in the database
 func Save(a interface{}) (err error) {
+	return saveWith(modelDB(a), a)
+}
+
+// saveWith saves the object using a connection
+func saveWith(conn *gorm.DB, a interface{}) (err error) {
 	encryptRecord(a)
 	if Database.Type == "mysql" {
 		a = fixDates(a)
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Save(a).Error
+		err = conn.Save(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Save(a).Error
+			err = conn.Save(a).Error
 		}
 	})
 	if err != nil {
 		Trail(ERROR, "DB error in Save(%v). %s", getModelName(a), err.Error())
 		return err
 	}
-	err = customSave(a)
+	err = customSaveWith(conn, a)
 	if err != nil {
 		Trail(ERROR, "DB error in customSave(%v). %s", getModelName(a), err.Error())
 		return err
@@ -509,6 +589,11 @@ func fixDates(a interface{}) interface{} {
 }
 
 func customSave(m interface{}) (err error) {
+	return customSaveWith(modelDB(m), m)
+}
+
+// customSaveWith saves the M2M fields of the object using a connection
+func customSaveWith(conn *gorm.DB, m interface{}) (err error) {
 	a := m
 	t := reflect.TypeOf(a)
 	if t.Kind() == reflect.Ptr {
@@ -519,19 +604,19 @@ func customSave(m interface{}) (err error) {
 	for i := 0; i < t.NumField(); i++ {
 		// Check if there is any m2m fields
 		if t.Field(i).Type.Kind() == reflect.Slice && t.Field(i).Type.Elem().Kind() == reflect.Struct {
-			table1 := strings.ToLower(t.Name())
-			table2 := strings.ToLower(t.Field(i).Type.Elem().Name())
+			table1 := strings.ToLower(getTypeName(t))
+			table2 := strings.ToLower(getTypeName(t.Field(i).Type.Elem()))
 
 			// Delete existing records
-			sql := sqlDialect[Database.Type]["deleteM2M"]
+			sql := sqlDialect[getDBType(a)]["deleteM2M"]
 			sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 			sql = strings.Replace(sql, "{TABLE2}", table2, -1)
 
 			TimeMetric("uadmin/db/duration", 1000, func() {
-				err = db.Exec(sql, GetID(value)).Error
+				err = conn.Exec(sql, GetID(value)).Error
 				for fmt.Sprint(err) == "database is locked" {
 					time.Sleep(time.Millisecond * 100)
-					err = db.Exec(sql, GetID(value)).Error
+					err = conn.Exec(sql, GetID(value)).Error
 				}
 			})
 			if err != nil {
@@ -541,15 +626,15 @@ func customSave(m interface{}) (err error) {
 			}
 			// Insert records
 			for index := 0; index < value.Field(i).Len(); index++ {
-				sql := sqlDialect[Database.Type]["insertM2M"]
+				sql := sqlDialect[getDBType(a)]["insertM2M"]
 				sql = strings.Replace(sql, "{TABLE1}", table1, -1)
 				sql = strings.Replace(sql, "{TABLE2}", table2, -1)
 
 				TimeMetric("uadmin/db/duration", 1000, func() {
-					err = db.Exec(sql, GetID(value), GetID(value.Field(i).Index(index))).Error
+					err = conn.Exec(sql, GetID(value), GetID(value.Field(i).Index(index))).Error
 					for fmt.Sprint(err) == "database is locked" {
 						time.Sleep(time.Millisecond * 100)
-						err = db.Exec(sql, GetID(value), GetID(value.Field(i).Index(index))).Error
+						err = conn.Exec(sql, GetID(value), GetID(value.Field(i).Index(index))).Error
 					}
 				})
 				if err != nil {
@@ -566,14 +651,33 @@ func customSave(m interface{}) (err error) {
 
 // Get fetches the first record from the database matching query and args
 func Get(a interface{}, query interface{}, args ...interface{}) (err error) {
+	return GetContext(context.Background(), a, query, args...)
+}
+
+// GetContext fetches the first record from the database matching query and
+// args. The query is cancelled when ctx is done.
+func GetContext(ctx context.Context, a interface{}, query interface{}, args ...interface{}) (err error) {
+	key, ttl := queryCacheKey(a, "get", query, args)
+	if key != "" && getCache(key, a) {
+		return nil
+	}
+	err = getWith(readDBContext(ctx, a), a, query, args...)
+	if err == nil && key != "" {
+		setCache(key, ttl, a)
+	}
+	return err
+}
+
+// getWith fetches the first record using a connection
+func getWith(conn *gorm.DB, a interface{}, query interface{}, args ...interface{}) (err error) {
 	if val, ok := query.(string); ok {
 		query = fixQueryEnclosure(val)
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Where(query, args...).First(a).Error
+		err = conn.Where(query, args...).First(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Where(query, args...).First(a).Error
+			err = conn.Where(query, args...).First(a).Error
 		}
 	})
 
@@ -584,7 +688,7 @@ func Get(a interface{}, query interface{}, args ...interface{}) (err error) {
 		return err
 	}
 
-	err = customGet(a)
+	err = customGetWith(conn, a)
 	if err != nil {
 		Trail(ERROR, "DB error in customGet(%v). %s", getModelName(a), err.Error())
 		return err
@@ -599,10 +703,10 @@ func GetTable(table string, a interface{}, query interface{}, args ...interface{
 		query = fixQueryEnclosure(val)
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).First(a).Error
+		err = readDB(a).Table(table).Where(query, args...).First(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).First(a).Error
+			err = readDB(a).Table(table).Where(query, args...).First(a).Error
 		}
 	})
 
@@ -639,10 +743,10 @@ func GetSorted(order string, asc bool, a interface{}, query interface{}, args ..
 		order = "id desc"
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Where(query, args...).Order(order).First(a).Error
+		err = readDB(a).Where(query, args...).Order(order).First(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Where(query, args...).Order(order).First(a).Error
+			err = readDB(a).Where(query, args...).Order(order).First(a).Error
 		}
 	})
 
@@ -679,10 +783,10 @@ func GetSortedTable(table string, order string, asc bool, a interface{}, query i
 		order = "id desc"
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Order(order).First(a).Error
+		err = readDB(a).Table(table).Where(query, args...).Order(order).First(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Order(order).First(a).Error
+			err = readDB(a).Table(table).Where(query, args...).Order(order).First(a).Error
 		}
 	})
 
@@ -719,10 +823,10 @@ func GetValueSorted(table string, column string, order string, asc bool, a inter
 		order = "id desc"
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Order(order).Limit(1).Pluck(column, a).Error
+		err = readDB(a).Table(table).Where(query, args...).Order(order).Limit(1).Pluck(column, a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Order(order).Limit(1).Pluck(column, a).Error
+			err = readDB(a).Table(table).Where(query, args...).Order(order).Limit(1).Pluck(column, a).Error
 		}
 	})
 
@@ -737,6 +841,7 @@ func GetValueSorted(table string, column string, order string, asc bool, a inter
 
 // GetABTest is like Get function but implements AB testing for the results
 func GetABTest(r *http.Request, a interface{}, query interface{}, args ...interface{}) (err error) {
+	schemas := registeredSchema()
 	if val, ok := query.(string); ok {
 		query = fixQueryEnclosure(val)
 	}
@@ -752,10 +857,10 @@ func GetABTest(r *http.Request, a interface{}, query interface{}, args ...interf
 		if strings.HasPrefix(k, modelName+"__") && strings.HasSuffix(k, "__"+fmt.Sprint(GetID(reflect.ValueOf(a)))) {
 			if len(v) != 0 {
 				index := abt % len(v)
-				fName := Schema[modelName].Fields[v[index].fname].Name
+				fName := schemas[modelName].Fields[v[index].fname].Name
 
 				// TODO: Support more data types
-				switch Schema[modelName].Fields[v[index].fname].Type {
+				switch schemas[modelName].Fields[v[index].fname].Type {
 				case cSTRING:
 					reflect.ValueOf(a).Elem().FieldByName(fName).SetString(v[index].v)
 				case cIMAGE:
@@ -781,23 +886,23 @@ func GetStringer(a interface{}, query interface{}, args ...interface{}) (err err
 	}
 	stringers := []string{}
 	modelName := getModelName(a)
-	for _, f := range Schema[modelName].Fields {
+	for _, f := range registeredSchema()[modelName].Fields {
 		if f.Stringer {
 			stringers = append(stringers, GetDB().Config.NamingStrategy.ColumnName("", f.Name))
 		}
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
 		if len(stringers) == 0 {
-			err = db.Where(query, args...).First(a).Error
+			err = readDB(a).Where(query, args...).First(a).Error
 			for fmt.Sprint(err) == "database is locked" {
 				time.Sleep(time.Millisecond * 100)
-				err = db.Where(query, args...).First(a).Error
+				err = readDB(a).Where(query, args...).First(a).Error
 			}
 		} else {
-			err = db.Select(stringers).Where(query, args...).First(a).Error
+			err = readDB(a).Select(stringers).Where(query, args...).First(a).Error
 			for fmt.Sprint(err) == "database is locked" {
 				time.Sleep(time.Millisecond * 100)
-				err = db.Select(stringers).Where(query, args...).First(a).Error
+				err = readDB(a).Select(stringers).Where(query, args...).First(a).Error
 			}
 		}
 	})
@@ -820,6 +925,12 @@ func GetStringer(a interface{}, query interface{}, args ...interface{}) (err err
 // GetForm fetches the first record from the database matching query and args
 // where it selects only visible fields in the form based on given schema
 func GetForm(a interface{}, s *ModelSchema, query interface{}, args ...interface{}) (err error) {
+	return GetFormContext(context.Background(), a, s, query, args...)
+}
+
+// GetFormContext fetches the first record from the database matching query
+// and args for the form. The query is cancelled when ctx is done.
+func GetFormContext(ctx context.Context, a interface{}, s *ModelSchema, query interface{}, args ...interface{}) (err error) {
 	if val, ok := query.(string); ok {
 		query = fixQueryEnclosure(val)
 	}
@@ -840,10 +951,10 @@ func GetForm(a interface{}, s *ModelSchema, query interface{}, args ...interface
 	}
 
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Select(columnList).Where(query, args...).First(a).Error
+		err = readDBContext(ctx, a).Select(columnList).Where(query, args...).First(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Select(columnList).Where(query, args...).First(a).Error
+			err = readDBContext(ctx, a).Select(columnList).Where(query, args...).First(a).Error
 		}
 	})
 
@@ -854,7 +965,7 @@ func GetForm(a interface{}, s *ModelSchema, query interface{}, args ...interface
 		return err
 	}
 
-	err = customGet(a, m2mList...)
+	err = customGetWith(readDBContext(ctx, a), a, m2mList...)
 	if err != nil {
 		Trail(ERROR, "DB error in customGet(%v). %s", getModelName(a), err.Error())
 		return err
@@ -864,6 +975,11 @@ func GetForm(a interface{}, s *ModelSchema, query interface{}, args ...interface
 }
 
 func customGet(m interface{}, m2m ...string) (err error) {
+	return customGetWith(readDB(m), m, m2m...)
+}
+
+// customGetWith fetches the M2M fields of the object using a connection
+func customGetWith(conn *gorm.DB, m interface{}, m2m ...string) (err error) {
 	a := m
 	t := reflect.TypeOf(a)
 	var ignore bool
@@ -894,15 +1010,15 @@ func customGet(m interface{}, m2m ...string) (err error) {
 
 		// Check if there is any m2m fields
 		if t.Field(i).Type.Kind() == reflect.Slice && t.Field(i).Type.Elem().Kind() == reflect.Struct {
-			table1 := strings.ToLower(t.Name())
-			table2 := strings.ToLower(t.Field(i).Type.Elem().Name())
+			table1 := strings.ToLower(getTypeName(t))
+			table2 := strings.ToLower(getTypeName(t.Field(i).Type.Elem()))
 
-			sqlSelect := sqlDialect[Database.Type]["selectM2M"]
+			sqlSelect := sqlDialect[getDBType(m)]["selectM2M"]
 			sqlSelect = strings.Replace(sqlSelect, "{TABLE1}", table1, -1)
 			sqlSelect = strings.Replace(sqlSelect, "{TABLE2}", table2, -1)
 
 			var rows *sql.Rows
-			rows, err = db.Raw(sqlSelect, GetID(value)).Rows()
+			rows, err = conn.Raw(sqlSelect, GetID(value)).Rows()
 			if err != nil {
 				Trail(ERROR, "Unable to get m2m records. %s", err)
 				Trail(ERROR, sqlSelect)
@@ -915,7 +1031,12 @@ func customGet(m interface{}, m2m ...string) (err error) {
 				rows.Scan(&fkID)
 				tempModel := reflect.New(t.Field(i).Type.Elem()).Elem()
 
-				Get(tempModel.Addr().Interface(), "id = ?", fkID)
+				// Records in another database are read from their database
+				if getDBName(tempModel.Interface()) != getDBName(m) {
+					Get(tempModel.Addr().Interface(), "id = ?", fkID)
+				} else {
+					getWith(conn, tempModel.Addr().Interface(), "id = ?", fkID)
+				}
 				tmpDst = reflect.Append(tmpDst, tempModel)
 			}
 			reflect.ValueOf(m).Elem().Field(i).Set(tmpDst)
@@ -926,14 +1047,33 @@ func customGet(m interface{}, m2m ...string) (err error) {
 
 // Filter fetches records from the database
 func Filter(a interface{}, query interface{}, args ...interface{}) (err error) {
+	return FilterContext(context.Background(), a, query, args...)
+}
+
+// FilterContext fetches records from the database. The query is cancelled
+// when ctx is done.
+func FilterContext(ctx context.Context, a interface{}, query interface{}, args ...interface{}) (err error) {
+	key, ttl := queryCacheKey(a, "filter", query, args)
+	if key != "" && getCache(key, a) {
+		return nil
+	}
+	err = filterWith(readDBContext(ctx, a), a, query, args...)
+	if err == nil && key != "" {
+		setCache(key, ttl, a)
+	}
+	return err
+}
+
+// filterWith fetches records using a connection
+func filterWith(conn *gorm.DB, a interface{}, query interface{}, args ...interface{}) (err error) {
 	if val, ok := query.(string); ok {
 		query = fixQueryEnclosure(val)
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Where(query, args...).Find(a).Error
+		err = conn.Where(query, args...).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Where(query, args...).Find(a).Error
+			err = conn.Where(query, args...).Find(a).Error
 		}
 	})
 
@@ -962,10 +1102,10 @@ func FilterSorted(order string, asc bool, a interface{}, query interface{}, args
 		order = "id desc"
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Where(query, args...).Order(order).Find(a).Error
+		err = readDB(a).Where(query, args...).Order(order).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Where(query, args...).Order(order).Find(a).Error
+			err = readDB(a).Where(query, args...).Order(order).Find(a).Error
 		}
 	})
 
@@ -983,10 +1123,10 @@ func FilterTable(table string, a interface{}, query interface{}, args ...interfa
 		query = fixQueryEnclosure(val)
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Find(a).Error
+		err = readDB(a).Table(table).Where(query, args...).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Find(a).Error
+			err = readDB(a).Table(table).Where(query, args...).Find(a).Error
 		}
 	})
 
@@ -1015,10 +1155,10 @@ func FilterSortedTable(table string, order string, asc bool, a interface{}, quer
 		order = "id desc"
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Order(order).Find(a).Error
+		err = readDB(a).Table(table).Where(query, args...).Order(order).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Order(order).Find(a).Error
+			err = readDB(a).Table(table).Where(query, args...).Order(order).Find(a).Error
 		}
 	})
 
@@ -1047,10 +1187,10 @@ func FilterSortedValue(table string, column string, order string, asc bool, a in
 		order = "id desc"
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Order(order).Pluck(column, a).Error
+		err = readDB(a).Table(table).Where(query, args...).Order(order).Pluck(column, a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Order(order).Pluck(column, a).Error
+			err = readDB(a).Table(table).Where(query, args...).Order(order).Pluck(column, a).Error
 		}
 	})
 
@@ -1065,7 +1205,7 @@ func FilterSortedValue(table string, column string, order string, asc bool, a in
 // Preload fills the data from foreign keys into structs. You can pass in preload alist of fields
 // to be preloaded. If nothing is passed, every foreign key is preloaded
 func Preload(a interface{}, preload ...string) (err error) {
-	modelName := strings.ToLower(reflect.TypeOf(a).Elem().Name())
+	modelName := strings.ToLower(getTypeName(reflect.TypeOf(a).Elem()))
 	if len(preload) == 0 {
 		if schema, ok := getSchema(modelName); ok {
 			for _, f := range schema.Fields {
@@ -1080,16 +1220,16 @@ func Preload(a interface{}, preload ...string) (err error) {
 	}
 	value := reflect.ValueOf(a).Elem()
 	for _, p := range preload {
-		fkType := value.FieldByName(p).Type().Name()
+		fkType := getTypeName(value.FieldByName(p).Type())
 		if value.FieldByName(p).Type().Kind() == reflect.Ptr {
-			fkType = value.FieldByName(p).Type().Elem().Name()
+			fkType = getTypeName(value.FieldByName(p).Type().Elem())
 		}
 		fieldStruct, _ := NewModel(strings.ToLower(fkType), true)
 		TimeMetric("uadmin/db/duration", 1000, func() {
-			err = db.Where("id = ?", value.FieldByName(p+"ID").Interface()).First(fieldStruct.Interface()).Error
+			err = readDB(fieldStruct.Interface()).Where("id = ?", value.FieldByName(p+"ID").Interface()).First(fieldStruct.Interface()).Error
 			for fmt.Sprint(err) == "database is locked" {
 				time.Sleep(time.Millisecond * 100)
-				err = db.Where("id = ?", value.FieldByName(p+"ID").Interface()).First(fieldStruct.Interface()).Error
+				err = readDB(fieldStruct.Interface()).Where("id = ?", value.FieldByName(p+"ID").Interface()).First(fieldStruct.Interface()).Error
 			}
 		})
 
@@ -1111,6 +1251,11 @@ func Preload(a interface{}, preload ...string) (err error) {
 
 // Delete records from database
 func Delete(a interface{}) (err error) {
+	return deleteWith(modelDB(a), a)
+}
+
+// deleteWith deletes records using a connection
+func deleteWith(conn *gorm.DB, a interface{}) (err error) {
 	v := reflect.ValueOf(a)
 	t := reflect.TypeOf(a)
 
@@ -1120,16 +1265,16 @@ func Delete(a interface{}) (err error) {
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
 		if t.Kind() == reflect.Ptr {
-			err = db.Delete(a).Error
+			err = conn.Delete(a).Error
 		} else {
 			vp := reflect.New(t)
 			vp.Elem().Set(v)
-			err = db.Delete(vp.Interface()).Error
+			err = conn.Delete(vp.Interface()).Error
 		}
 
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Delete(a).Error
+			err = conn.Delete(a).Error
 		}
 	})
 
@@ -1142,6 +1287,11 @@ func Delete(a interface{}) (err error) {
 
 // DeleteList deletes multiple records from database
 func DeleteList(a interface{}, query interface{}, args ...interface{}) (err error) {
+	return deleteListWith(modelDB(a), a, query, args...)
+}
+
+// deleteListWith deletes multiple records using a connection
+func deleteListWith(conn *gorm.DB, a interface{}, query interface{}, args ...interface{}) (err error) {
 	if val, ok := query.(string); ok {
 		query = fixQueryEnclosure(val)
 	}
@@ -1150,20 +1300,20 @@ func DeleteList(a interface{}, query interface{}, args ...interface{}) (err erro
 
 	TimeMetric("uadmin/db/duration", 1000, func() {
 		if t.Kind() == reflect.Ptr {
-			err = db.Where(query, args...).Delete(a).Error
+			err = conn.Where(query, args...).Delete(a).Error
 		} else {
 			vp := reflect.New(t)
 			vp.Elem().Set(v)
-			err = db.Where(query, args...).Delete(vp.Interface()).Error
+			err = conn.Where(query, args...).Delete(vp.Interface()).Error
 		}
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
 			if t.Kind() == reflect.Ptr {
-				err = db.Where(query, args...).Delete(a).Error
+				err = conn.Where(query, args...).Delete(a).Error
 			} else {
 				vp := reflect.New(t)
 				vp.Elem().Set(v)
-				err = db.Where(query, args...).Delete(vp.Interface()).Error
+				err = conn.Where(query, args...).Delete(vp.Interface()).Error
 			}
 		}
 	})
@@ -1188,6 +1338,12 @@ func FilterBuilder(params map[string]interface{}) (query string, args []interfac
 
 // AdminPage !
 func AdminPage(order string, asc bool, offset int, limit int, a interface{}, query interface{}, args ...interface{}) (err error) {
+	return AdminPageContext(context.Background(), order, asc, offset, limit, a, query, args...)
+}
+
+// AdminPageContext fetches a page of records for the list view. The query is
+// cancelled when ctx is done.
+func AdminPageContext(ctx context.Context, order string, asc bool, offset int, limit int, a interface{}, query interface{}, args ...interface{}) (err error) {
 	if order != "" {
 		order = strings.ToLower(order)
 		orderby := " desc"
@@ -1202,10 +1358,10 @@ func AdminPage(order string, asc bool, offset int, limit int, a interface{}, que
 	}
 	if limit > 0 {
 		TimeMetric("uadmin/db/duration", 1000, func() {
-			err = db.Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
+			err = readDBContext(ctx, a).Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
 			for fmt.Sprint(err) == "database is locked" {
 				time.Sleep(time.Millisecond * 100)
-				err = db.Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
+				err = readDBContext(ctx, a).Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
 			}
 		})
 
@@ -1217,10 +1373,10 @@ func AdminPage(order string, asc bool, offset int, limit int, a interface{}, que
 		return nil
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Where(query, args...).Order(order).Find(a).Error
+		err = readDBContext(ctx, a).Where(query, args...).Order(order).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Where(query, args...).Order(order).Find(a).Error
+			err = readDBContext(ctx, a).Where(query, args...).Order(order).Find(a).Error
 		}
 	})
 
@@ -1235,6 +1391,12 @@ func AdminPage(order string, asc bool, offset int, limit int, a interface{}, que
 // FilterList fetches the all record from the database matching query and args
 // where it selects only visible fields in the form based on given schema
 func FilterList(s *ModelSchema, order string, asc bool, offset int, limit int, a interface{}, query interface{}, args ...interface{}) (err error) {
+	return FilterListContext(context.Background(), s, order, asc, offset, limit, a, query, args...)
+}
+
+// FilterListContext fetches a page of records with the columns of the list
+// view. The query is cancelled when ctx is done.
+func FilterListContext(ctx context.Context, s *ModelSchema, order string, asc bool, offset int, limit int, a interface{}, query interface{}, args ...interface{}) (err error) {
 	// get a list of visible fields
 	columnList := []string{}
 	for _, f := range s.Fields {
@@ -1261,10 +1423,10 @@ func FilterList(s *ModelSchema, order string, asc bool, offset int, limit int, a
 	}
 	if limit > 0 {
 		TimeMetric("uadmin/db/duration", 1000, func() {
-			err = db.Select(columnList).Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
+			err = readDBContext(ctx, a).Select(columnList).Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
 			for fmt.Sprint(err) == "database is locked" {
 				time.Sleep(time.Millisecond * 100)
-				err = db.Select(columnList).Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
+				err = readDBContext(ctx, a).Select(columnList).Where(query, args...).Order(order).Offset(offset).Limit(limit).Find(a).Error
 			}
 		})
 
@@ -1276,10 +1438,10 @@ func FilterList(s *ModelSchema, order string, asc bool, offset int, limit int, a
 		return nil
 	}
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Select(columnList).Where(query, args...).Order(order).Find(a).Error
+		err = readDBContext(ctx, a).Select(columnList).Where(query, args...).Order(order).Find(a).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Select(columnList).Where(query, args...).Order(order).Find(a).Error
+			err = readDBContext(ctx, a).Select(columnList).Where(query, args...).Order(order).Find(a).Error
 		}
 	})
 
@@ -1293,16 +1455,43 @@ func FilterList(s *ModelSchema, order string, asc bool, offset int, limit int, a
 
 // Count return the count of records in a table based on a filter
 func Count(a interface{}, query interface{}, args ...interface{}) int {
+	return CountContext(context.Background(), a, query, args...)
+}
+
+// CountContext return the count of records in a table based on a filter. The
+// query is cancelled when ctx is done.
+func CountContext(ctx context.Context, a interface{}, query interface{}, args ...interface{}) int {
+	key, ttl := queryCacheKey(a, "count", query, args)
+	count := 0
+	if key != "" && getCache(key, &count) {
+		return count
+	}
+	count, err := countErrWith(readDBContext(ctx, a), a, query, args...)
+	if err == nil && key != "" {
+		setCache(key, ttl, &count)
+	}
+	return count
+}
+
+// countWith returns the count of records using a connection
+func countWith(conn *gorm.DB, a interface{}, query interface{}, args ...interface{}) int {
+	count, _ := countErrWith(conn, a, query, args...)
+	return count
+}
+
+// countErrWith returns the count of records using a connection and the
+// error of the query
+func countErrWith(conn *gorm.DB, a interface{}, query interface{}, args ...interface{}) (int, error) {
 	if val, ok := query.(string); ok {
 		query = fixQueryEnclosure(val)
 	}
 	var count int64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Model(a).Where(query, args...).Count(&count).Error
+		err = conn.Model(a).Where(query, args...).Count(&count).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Model(a).Where(query, args...).Count(&count).Error
+			err = conn.Model(a).Where(query, args...).Count(&count).Error
 		}
 	})
 
@@ -1313,7 +1502,7 @@ func Count(a interface{}, query interface{}, args ...interface{}) int {
 	if err != nil {
 		Trail(ERROR, "DB error in Count(%v). %s\n", getModelName(a), err.Error())
 	}
-	return int(count)
+	return int(count), err
 }
 
 // Sum return the sum of a column in a table based on a filter
@@ -1324,10 +1513,10 @@ func Sum(a interface{}, column string, query interface{}, args ...interface{}) f
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Model(a).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
+		err = readDB(a).Model(a).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Model(a).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
+			err = readDB(a).Model(a).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
 		}
 	})
 
@@ -1352,10 +1541,10 @@ func Avg(a interface{}, column string, query interface{}, args ...interface{}) f
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Model(a).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
+		err = readDB(a).Model(a).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Model(a).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
+			err = readDB(a).Model(a).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
 		}
 	})
 
@@ -1380,10 +1569,10 @@ func Max(a interface{}, column string, query interface{}, args ...interface{}) f
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Model(a).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
+		err = readDB(a).Model(a).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Model(a).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
+			err = readDB(a).Model(a).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
 		}
 	})
 
@@ -1408,10 +1597,10 @@ func Min(a interface{}, column string, query interface{}, args ...interface{}) f
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Model(a).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
+		err = readDB(a).Model(a).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Model(a).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
+			err = readDB(a).Model(a).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
 		}
 	})
 
@@ -1436,10 +1625,10 @@ func Std(a interface{}, column string, query interface{}, args ...interface{}) f
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Model(a).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
+		err = readDB(a).Model(a).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Model(a).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
+			err = readDB(a).Model(a).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
 		}
 	})
 
@@ -1464,10 +1653,10 @@ func CountTable(table string, query interface{}, args ...interface{}) int {
 	var count int64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Count(&count).Error
+		err = readDB(nil).Table(table).Where(query, args...).Count(&count).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Count(&count).Error
+			err = readDB(nil).Table(table).Where(query, args...).Count(&count).Error
 		}
 	})
 
@@ -1489,10 +1678,10 @@ func SumTable(table string, column string, query interface{}, args ...interface{
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
+		err = readDB(nil).Table(table).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
+			err = readDB(nil).Table(table).Where(query, args...).Select("SUM("+column+")").Pluck("SUM("+column+")", &vals).Error
 		}
 	})
 
@@ -1517,10 +1706,10 @@ func AvgTable(table string, column string, query interface{}, args ...interface{
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
+		err = readDB(nil).Table(table).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
+			err = readDB(nil).Table(table).Where(query, args...).Select("AVG("+column+")").Pluck("AVG("+column+")", &vals).Error
 		}
 	})
 
@@ -1545,10 +1734,10 @@ func MaxTable(table string, column string, query interface{}, args ...interface{
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
+		err = readDB(nil).Table(table).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
+			err = readDB(nil).Table(table).Where(query, args...).Select("MAX("+column+")").Pluck("MAX("+column+")", &vals).Error
 		}
 	})
 
@@ -1573,10 +1762,10 @@ func MinTable(table string, column string, query interface{}, args ...interface{
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
+		err = readDB(nil).Table(table).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
+			err = readDB(nil).Table(table).Where(query, args...).Select("MIN("+column+")").Pluck("MIN("+column+")", &vals).Error
 		}
 	})
 
@@ -1601,10 +1790,10 @@ func StdTable(table string, column string, query interface{}, args ...interface{
 	var vals []float64
 	var err error
 	TimeMetric("uadmin/db/duration", 1000, func() {
-		err = db.Table(table).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
+		err = readDB(nil).Table(table).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
-			err = db.Table(table).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
+			err = readDB(nil).Table(table).Where(query, args...).Select("STD("+column+")").Pluck("STD("+column+")", &vals).Error
 		}
 	})
 
@@ -1621,8 +1810,23 @@ func StdTable(table string, column string, query interface{}, args ...interface{
 	return vals[0]
 }
 
+// queryContext returns the context of the database queries of a request. It
+// is cancelled when the request is cancelled or after timeout if it is not
+// zero.
+func queryContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(r.Context(), timeout)
+	}
+	return context.WithCancel(r.Context())
+}
+
 // Update !
 func Update(a interface{}, fieldName string, value interface{}, query string, args ...interface{}) (err error) {
+	return updateWith(modelDB(a), a, fieldName, value, query, args...)
+}
+
+// updateWith updates a field using a connection
+func updateWith(conn *gorm.DB, a interface{}, fieldName string, value interface{}, query string, args ...interface{}) (err error) {
 	query = fixQueryEnclosure(query)
 	TimeMetric("uadmin/db/duration", 1000, func() {
 		// There seems to be a bug in gorm stopping updates using model but it works when we use table
@@ -1631,11 +1835,11 @@ func Update(a interface{}, fieldName string, value interface{}, query string, ar
 		tableName := getModelNameNorm(a)
 		tableName = db.Config.NamingStrategy.TableName(tableName)
 
-		err = db.Table(tableName).Where(query, args...).Update(fieldName, value).Error
+		err = conn.Table(tableName).Where(query, args...).Update(fieldName, value).Error
 		for fmt.Sprint(err) == "database is locked" {
 			time.Sleep(time.Millisecond * 100)
 			//err = db.Model(a).Where(query, args...).Update(fieldName, value).Error
-			err = db.Table(tableName).Where(query, args...).Update(fieldName, value).Error
+			err = conn.Table(tableName).Where(query, args...).Update(fieldName, value).Error
 		}
 	})
 
@@ -0,0 +1,184 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// replicas are the connections to the read replicas of the database
+var replicas []*gorm.DB
+
+// replicaIndex is the index of the last replica used to read
+var replicaIndex uint32
+
+// sessionWrites are the times of the last writes of sessions to the primary
+// database in unix nanoseconds
+var sessionWrites sync.Map
+
+// readSessionKey is the context key of the session that reads and writes
+type readSessionKey struct{}
+
+// withReadSession returns a context with the session key used to route the
+// reads of the session to the primary database after it writes
+func withReadSession(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, readSessionKey{}, key)
+}
+
+// getReadSession returns the session key of a context
+func getReadSession(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	key, _ := ctx.Value(readSessionKey{}).(string)
+	return key
+}
+
+// openReplicas opens the connections to the read replicas in the database
+// settings. Replicas that cannot be opened are skipped.
+func openReplicas() {
+	replicas = nil
+	if Database == nil || db == nil || len(Database.Replicas) == 0 {
+		return
+	}
+	for i := range Database.Replicas {
+		r, err := openDB(getDBSettings(Database.Replicas[i]))
+		if err != nil {
+			Trail(WARNING, "Unable to connect to read replica %d. %s", i+1, err)
+			continue
+		}
+		replicas = append(replicas, r)
+	}
+	if len(replicas) == 0 {
+		return
+	}
+
+	// Mark writes of sessions to the primary database so they read their own
+	// writes from it for ReadYourWritesWindow
+	if db.Callback().Create().Get("uadmin:mark_write") == nil {
+		db.Callback().Create().After("gorm:create").Register("uadmin:mark_write", markWrite)
+		db.Callback().Update().After("gorm:update").Register("uadmin:mark_write", markWrite)
+		db.Callback().Delete().After("gorm:delete").Register("uadmin:mark_write", markWrite)
+		db.Callback().Raw().After("gorm:raw").Register("uadmin:mark_write", markWrite)
+	}
+}
+
+// getDBSettings returns the settings of a replica or a named database where
+// empty settings are the same as the settings of the default database
+func getDBSettings(s DBSettings) *DBSettings {
+	if s.Type == "" {
+		s.Type = Database.Type
+	}
+	if s.Name == "" {
+		s.Name = Database.Name
+	}
+	if s.User == "" {
+		s.User = Database.User
+	}
+	if s.Password == "" {
+		s.Password = Database.Password
+	}
+	if s.Host == "" {
+		s.Host = Database.Host
+	}
+	if s.Port == 0 {
+		s.Port = Database.Port
+	}
+	if s.Timezone == "" {
+		s.Timezone = Database.Timezone
+	}
+	if s.SSLMode == "" {
+		s.SSLMode = Database.SSLMode
+	}
+	s.Replicas = nil
+	return &s
+}
+
+// openDB opens a connection to a read replica or a named database
+func openDB(s *DBSettings) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch strings.ToLower(s.Type) {
+	case "sqlite":
+		if s.Name == "" {
+			return nil, fmt.Errorf("sqlite databases need a name")
+		}
+		dialector = sqlite.Open(getSQLiteDSN(s))
+	case "mysql":
+		dialector = mysql.Open(getMySQLDSN(s))
+	case "postgres":
+		dialector = postgres.Open(getPostgresDSN(s))
+	default:
+		return nil, fmt.Errorf("unknown database type: %s", s.Type)
+	}
+	conn, err := gorm.Open(dialector, &gorm.Config{
+		Logger: func() logger.Interface {
+			if DebugDB {
+				return logger.Default.LogMode(logger.Info)
+			}
+			return logger.Default.LogMode(logger.Silent)
+		}(),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err == nil && strings.ToLower(s.Type) == "sqlite" {
+		registerSQLiteWriteQueue(conn, s)
+	}
+	return conn, err
+}
+
+// markWrite records the time of a write to the primary database for the
+// session in the context of the write
+func markWrite(tx *gorm.DB) {
+	if tx.Error == nil && tx.Statement.RowsAffected != 0 {
+		markSessionWrite(getReadSession(tx.Statement.Context))
+	}
+}
+
+// markSessionWrite records the time of a write of a session to the primary
+// database
+func markSessionWrite(key string) {
+	if key == "" || len(replicas) == 0 {
+		return
+	}
+	sessionWrites.Store(key, time.Now().UnixNano())
+}
+
+// readDB returns the connection used to read a model. Reads of models in
+// the default database go to the read replicas in turn.
+func readDB(a interface{}) *gorm.DB {
+	return readDBContext(context.Background(), a)
+}
+
+// readDBContext returns the connection used to read a model in a context.
+// Reads of models in the default database go to the read replicas in turn
+// except within ReadYourWritesWindow after the session in the context wrote
+// where they go to the primary database.
+func readDBContext(ctx context.Context, a interface{}) *gorm.DB {
+	if getDBName(a) != "" {
+		return modelDB(a).WithContext(ctx)
+	}
+	if len(replicas) == 0 {
+		return db.WithContext(ctx)
+	}
+	if key := getReadSession(ctx); key != "" {
+		if t, ok := sessionWrites.Load(key); ok {
+			if time.Since(time.Unix(0, t.(int64))) < ReadYourWritesWindow {
+				return db.WithContext(ctx)
+			}
+			sessionWrites.Delete(key)
+		}
+	}
+	i := atomic.AddUint32(&replicaIndex, 1)
+	return replicas[int(i)%len(replicas)].WithContext(ctx)
+}
@@ -0,0 +1,80 @@
+package uadmin
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// DBRouter is implemented by models that are stored in a named database in
+// Databases instead of the default database
+type DBRouter interface {
+	DBName() string
+}
+
+// databases are the connections to the named databases in Databases
+var databases = map[string]*gorm.DB{}
+
+// openDatabases opens the connections to the named databases. Settings of
+// a named database that are empty are the same as the settings of the
+// default database.
+func openDatabases() {
+	databases = map[string]*gorm.DB{}
+	for name, s := range Databases {
+		if s == nil {
+			continue
+		}
+		conn, err := openDB(getDBSettings(*s))
+		if err != nil {
+			Trail(ERROR, "Unable to connect to database %s. %s", name, err)
+			continue
+		}
+		registerCacheCallbacks(conn, name)
+		databases[name] = conn
+	}
+}
+
+// getDBName returns the name of the database of a model, a pointer to a
+// model or a slice of models. The default database has an empty name.
+func getDBName(a interface{}) string {
+	t := reflect.TypeOf(a)
+	if v, ok := a.(reflect.Value); ok {
+		t = v.Type()
+	}
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ""
+	}
+	if router, ok := reflect.New(t).Interface().(DBRouter); ok {
+		return router.DBName()
+	}
+	return ""
+}
+
+// modelDB returns the connection to the database of a model
+func modelDB(a interface{}) *gorm.DB {
+	name := getDBName(a)
+	if name == "" {
+		return db
+	}
+	if conn, ok := databases[name]; ok {
+		return conn
+	}
+	// Don't write to the default database when the database of the model
+	// is not connected
+	tx := db.Session(&gorm.Session{NewDB: true})
+	tx.AddError(fmt.Errorf("database %s is not connected", name))
+	return tx
+}
+
+// getDBType returns the type of the database of a model
+func getDBType(a interface{}) string {
+	name := getDBName(a)
+	if name == "" || Databases[name] == nil || Databases[name].Type == "" {
+		return Database.Type
+	}
+	return Databases[name].Type
+}
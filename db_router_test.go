@@ -0,0 +1,124 @@
+package uadmin
+
+import (
+	"os"
+
+	"gorm.io/gorm"
+)
+
+type TestReport struct {
+	Model
+	Name string
+	Tags []TestReportTag `gorm:"-"`
+}
+
+func (TestReport) DBName() string {
+	return "reporting"
+}
+
+type TestReportTag struct {
+	Model
+	Name string
+}
+
+func (TestReportTag) DBName() string {
+	return "reporting"
+}
+
+// TestDBRouter is a unit testing function for models in named databases
+func (t *UAdminTests) TestDBRouter() {
+	reportingFile := "uadmin_reporting_test.db"
+	savedDatabases := Databases
+	Databases = map[string]*DBSettings{
+		"reporting": {Type: "sqlite", Name: reportingFile},
+	}
+	openDatabases()
+	defer func() {
+		for _, conn := range databases {
+			if sqlDB, err := conn.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}
+		Databases = savedDatabases
+		databases = map[string]*gorm.DB{}
+		os.Remove(reportingFile)
+	}()
+
+	reporting, ok := databases["reporting"]
+	if !ok {
+		t.Errorf("openDatabases didn't connect to the reporting database")
+		return
+	}
+
+	// Tables are migrated in the database of the model
+	initializeDB(TestReportTag{}, TestReport{})
+	if !reporting.Migrator().HasTable(&TestReport{}) || !reporting.Migrator().HasTable("testreport_testreporttag") {
+		t.Errorf("initializeDB didn't migrate the tables in the reporting database")
+	}
+	if db.Migrator().HasTable(&TestReport{}) {
+		t.Errorf("initializeDB migrated a table of the reporting database in the default database")
+	}
+
+	// Helpers use the database of the model
+	tag := TestReportTag{Name: "Monthly"}
+	if err := Save(&tag); err != nil {
+		t.Errorf("Save returned an error for a model in the reporting database. %s", err)
+	}
+	report := TestReport{Name: "Sales", Tags: []TestReportTag{tag}}
+	Save(&report)
+	r := TestReport{}
+	Get(&r, "id = ?", report.ID)
+	if r.Name != "Sales" || len(r.Tags) != 1 || r.Tags[0].Name != "Monthly" {
+		t.Errorf("Get didn't read a model and its M2M field from the reporting database. Got %#v", r)
+	}
+	reports := []TestReport{}
+	Filter(&reports, "name = ?", "Sales")
+	if len(reports) != 1 || Count(&[]TestReport{}, "") != 1 {
+		t.Errorf("Filter didn't read from the reporting database. Got %d", len(reports))
+	}
+	var count int64
+	reporting.Table("testreport_testreporttag").Count(&count)
+	if count != 1 {
+		t.Errorf("Save didn't save M2M records in the reporting database. Got %d", count)
+	}
+	Delete(&report)
+	if Count(&[]TestReport{}, "") != 0 {
+		t.Errorf("Delete didn't delete from the reporting database")
+	}
+
+	// Approved changes are written to the database of the model
+	models["testreport"] = TestReport{}
+	Schema["testreport"], _ = getSchema(TestReport{})
+	defer func() {
+		delete(models, "testreport")
+		delete(Schema, "testreport")
+	}()
+	report = TestReport{Name: "Old"}
+	Save(&report)
+	req := ApprovalRequest{ModelName: "testreport", ModelPK: report.ID, Status: ApprovalRequestStatus(0).Pending()}
+	db.Create(&req)
+	defer db.Unscoped().Where("id = ?", req.ID).Delete(&ApprovalRequest{})
+	approval := Approval{ApprovalRequestID: req.ID, ModelName: "testreport", ModelPK: report.ID, ColumnName: "Name", OldValue: "Old", NewValue: "New"}
+	db.Create(&approval)
+	defer db.Unscoped().Where("id = ?", approval.ID).Delete(&Approval{})
+	if err := req.apply(&User{Username: "admin"}); err != nil {
+		t.Errorf("ApprovalRequest.apply returned an error for a model in the reporting database. %s", err)
+	}
+	r = TestReport{}
+	Get(&r, "id = ?", report.ID)
+	if r.Name != "New" {
+		t.Errorf("ApprovalRequest.apply didn't write the change to the reporting database. Got %q", r.Name)
+	}
+	Get(&req, "id = ?", req.ID)
+	if req.Status != req.Status.Approved() {
+		t.Errorf("ApprovalRequest.apply didn't approve the request. Got %d", req.Status)
+	}
+
+	// Models in databases that are not connected are not written to the
+	// default database
+	delete(databases, "reporting")
+	if err := Save(&TestReportTag{Name: "Weekly"}); err == nil {
+		t.Errorf("Save didn't return an error for a database that is not connected")
+	}
+	databases["reporting"] = reporting
+}
@@ -0,0 +1,211 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// sqliteBackupPages is the number of pages copied in a step of a backup.
+// Writes to the database can run between the steps.
+const sqliteBackupPages = 1024
+
+// Names of the jobs that maintain SQLite databases
+const (
+	sqliteVacuumJob  = "SQLite VACUUM"
+	sqliteAnalyzeJob = "SQLite ANALYZE"
+)
+
+// getSQLiteDSN returns the data source name of a SQLite database with its
+// journal mode and busy timeout. The default is WAL journal mode where
+// readers don't block the writer and a busy timeout of 5 seconds.
+// Transactions take the write lock when they begin so they wait for the
+// busy timeout there. A transaction that reads and then writes can't wait
+// for the lock and fails with "database is locked" if another transaction
+// wrote in between. Reads don't use transactions so they don't wait for
+// the write lock.
+func getSQLiteDSN(s *DBSettings) string {
+	name := s.Name
+	if name == "" {
+		name = "uadmin.db"
+	}
+	journalMode := s.JournalMode
+	if journalMode == "" {
+		journalMode = "WAL"
+	}
+	busyTimeout := s.BusyTimeout
+	if busyTimeout == 0 {
+		busyTimeout = 5000
+	}
+	sep := "?"
+	if strings.Contains(name, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_journal_mode=%s&_busy_timeout=%d&_txlock=immediate", name, sep, journalMode, busyTimeout)
+}
+
+// registerSQLiteWriteQueue makes the writes to a SQLite database that are
+// not in a transaction wait for each other instead of failing with
+// "database is locked". Writes that wait longer than the busy timeout run
+// without waiting so a stuck write doesn't stop all writes. Writes in
+// transactions wait for the write lock when the transaction begins.
+func registerSQLiteWriteQueue(conn *gorm.DB, s *DBSettings) {
+	if conn == nil || conn.Callback().Create().Get("uadmin:write_queue") != nil {
+		return
+	}
+	timeout := time.Duration(s.BusyTimeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	queue := make(chan struct{}, 1)
+	wait := func(tx *gorm.DB) {
+		// The write lock of a transaction is held by SQLite until it ends
+		if _, ok := tx.Statement.ConnPool.(gorm.TxCommitter); ok {
+			return
+		}
+		select {
+		case queue <- struct{}{}:
+			tx.InstanceSet("uadmin:write_queue", true)
+		case <-time.After(timeout):
+		case <-tx.Statement.Context.Done():
+		}
+	}
+	done := func(tx *gorm.DB) {
+		if _, ok := tx.InstanceGet("uadmin:write_queue"); ok {
+			<-queue
+		}
+	}
+	conn.Callback().Create().Before("gorm:begin_transaction").Register("uadmin:write_queue", wait)
+	conn.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("uadmin:write_queue_done", done)
+	conn.Callback().Update().Before("gorm:begin_transaction").Register("uadmin:write_queue", wait)
+	conn.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("uadmin:write_queue_done", done)
+	conn.Callback().Delete().Before("gorm:begin_transaction").Register("uadmin:write_queue", wait)
+	conn.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("uadmin:write_queue_done", done)
+	conn.Callback().Raw().Before("gorm:raw").Register("uadmin:write_queue", wait)
+	conn.Callback().Raw().After("gorm:raw").Register("uadmin:write_queue_done", done)
+}
+
+// withCaseSensitiveLike runs fn on one connection of conn with LIKE matching
+// the case for SQLite databases like the other databases do. The pragma is
+// set on the connection instead of in a transaction because transactions
+// take the write lock when they begin.
+func withCaseSensitiveLike(conn *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if conn.Dialector.Name() != "sqlite" {
+		return fn(conn)
+	}
+	return conn.Connection(func(c *gorm.DB) error {
+		if _, err := c.Statement.ConnPool.ExecContext(c.Statement.Context, "PRAGMA case_sensitive_like=ON;"); err != nil {
+			return err
+		}
+		defer c.Statement.ConnPool.ExecContext(context.Background(), "PRAGMA case_sensitive_like=OFF;")
+		return fn(c)
+	})
+}
+
+// BackupSQLite copies the SQLite database to a file using the SQLite online
+// backup API. The database can be read and written while it is copied.
+func BackupSQLite(ctx context.Context, fileName string) error {
+	if Database == nil || strings.ToLower(Database.Type) != "sqlite" {
+		return fmt.Errorf("backup is only supported for sqlite databases")
+	}
+	dbName := Database.Name
+	if dbName == "" {
+		dbName = "uadmin.db"
+	}
+	src, _ := filepath.Abs(strings.SplitN(dbName, "?", 2)[0])
+	dst, _ := filepath.Abs(fileName)
+	if src == dst {
+		return fmt.Errorf("backup file is the database file")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sqlDB, err := GetDB().DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		srcConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("database connection is not a sqlite connection")
+		}
+		c, err := (&sqlite3.SQLiteDriver{}).Open(fileName)
+		if err != nil {
+			return err
+		}
+		dstConn := c.(*sqlite3.SQLiteConn)
+		defer dstConn.Close()
+
+		backup, err := dstConn.Backup("main", srcConn, "main")
+		if err != nil {
+			return err
+		}
+		for {
+			done, err := backup.Step(sqliteBackupPages)
+			if err != nil {
+				backup.Finish()
+				return err
+			}
+			if done {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				backup.Finish()
+				return ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		return backup.Finish()
+	})
+}
+
+// scheduleSQLiteMaintenance registers the jobs that run VACUUM and ANALYZE
+// on the SQLite database on SQLiteVacuumSchedule and SQLiteAnalyzeSchedule
+func scheduleSQLiteMaintenance() {
+	if Database == nil || strings.ToLower(Database.Type) != "sqlite" {
+		return
+	}
+	jobs := map[string]string{
+		sqliteVacuumJob:  SQLiteVacuumSchedule,
+		sqliteAnalyzeJob: SQLiteAnalyzeSchedule,
+	}
+	for name, spec := range jobs {
+		if spec == "" {
+			unregisterJob(name)
+			continue
+		}
+		if rj := getRegisteredJob(name); rj != nil && rj.spec == spec {
+			continue
+		}
+		sql := strings.TrimPrefix(name, "SQLite ")
+		RegisterJob(name, spec, func(ctx context.Context) error {
+			return db.WithContext(ctx).Exec(sql).Error
+		})
+	}
+}
+
+// backupCommand runs the backup command:
+//
+//	backup FILE
+func backupCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: backup FILE")
+	}
+	if err := BackupSQLite(context.Background(), args[0]); err != nil {
+		return err
+	}
+	fmt.Println("Backed up the database to", args[0])
+	return nil
+}
@@ -0,0 +1,165 @@
+package uadmin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestSQLite is a unit testing function for the SQLite mode
+func (t *UAdminTests) TestSQLite() {
+	examples := []struct {
+		s   DBSettings
+		dsn string
+	}{
+		{DBSettings{}, "uadmin.db?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"},
+		{DBSettings{Name: "test.db", JournalMode: "DELETE", BusyTimeout: 100}, "test.db?_journal_mode=DELETE&_busy_timeout=100&_txlock=immediate"},
+		{DBSettings{Name: "test.db?cache=shared"}, "test.db?cache=shared&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"},
+	}
+	for i, e := range examples {
+		if dsn := getSQLiteDSN(&e.s); dsn != e.dsn {
+			t.Errorf("getSQLiteDSN invalid DSN in example %d. Expected %s got %s", i, e.dsn, dsn)
+		}
+	}
+
+	if strings.ToLower(Database.Type) != "sqlite" {
+		if err := BackupSQLite(context.Background(), "backup.db"); err == nil {
+			t.Errorf("BackupSQLite didn't return an error for a %s database", Database.Type)
+		}
+		return
+	}
+	defer DeleteList(&TestModelA{}, "name LIKE ?", "sqlite_%")
+
+	var journalMode string
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+	if strings.ToLower(journalMode) != "wal" {
+		t.Errorf("Invalid journal mode. Expected wal got %s", journalMode)
+	}
+
+	// Writes from many goroutines wait for each other
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.Create(&TestModelA{Name: "sqlite_write"}).Error
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent write returned an error. %s", err)
+		}
+	}
+	if n := Count(&[]TestModelA{}, "name = ?", "sqlite_write"); n != 20 {
+		t.Errorf("Invalid number of concurrent writes. Expected 20 got %d", n)
+	}
+
+	// Transactions that read and then write like the dAPI handlers wait
+	// for each other
+	errs = make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Transaction(context.Background(), func(tx *Tx) error {
+				tx.Count(&[]TestModelA{}, "name = ?", "sqlite_write")
+				time.Sleep(10 * time.Millisecond)
+				return tx.Save(&TestModelA{Name: "sqlite_tx"})
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent transaction returned an error. %s", err)
+		}
+	}
+	if n := Count(&[]TestModelA{}, "name = ?", "sqlite_tx"); n != 20 {
+		t.Errorf("Invalid number of concurrent transactions. Expected 20 got %d", n)
+	}
+
+	// Reads with case sensitive LIKE don't wait for a transaction that
+	// holds the write lock
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	go Transaction(context.Background(), func(tx *Tx) error {
+		close(locked)
+		<-release
+		return nil
+	})
+	<-locked
+	ids := []uint{}
+	start := time.Now()
+	err := withCaseSensitiveLike(db, func(conn *gorm.DB) error {
+		return conn.Model(&TestModelA{}).Where("name LIKE ?", "SQLITE_%").Pluck("id", &ids).Error
+	})
+	close(release)
+	if err != nil || len(ids) != 0 || time.Since(start) > time.Second {
+		t.Errorf("withCaseSensitiveLike didn't read while the write lock was held. Got %v %s after %s", ids, err, time.Since(start))
+	}
+	if n := Count(&[]TestModelA{}, "name LIKE ?", "SQLITE_%"); n != 40 {
+		t.Errorf("withCaseSensitiveLike didn't turn off case sensitive LIKE. Expected 40 got %d", n)
+	}
+
+	// Backup
+	fileName := filepath.Join(os.TempDir(), "uadmin_backup_test.db")
+	os.Remove(fileName)
+	defer os.Remove(fileName)
+	if err := BackupSQLite(context.Background(), fileName); err != nil {
+		t.Errorf("BackupSQLite returned an error. %s", err)
+	}
+	backup, err := gorm.Open(sqlite.Open(fileName), &gorm.Config{})
+	if err != nil {
+		t.Errorf("Unable to open the backup. %s", err)
+	} else {
+		var n int64
+		backup.Model(&TestModelA{}).Where("name = ?", "sqlite_write").Count(&n)
+		if n != 20 {
+			t.Errorf("Invalid number of records in the backup. Expected 20 got %d", n)
+		}
+		if sqlDB, err := backup.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	if err := BackupSQLite(context.Background(), "uadmin.db"); err == nil {
+		t.Errorf("BackupSQLite didn't return an error for the database file")
+	}
+
+	// Maintenance jobs
+	defer func() {
+		SQLiteVacuumSchedule = ""
+		SQLiteAnalyzeSchedule = ""
+		scheduleSQLiteMaintenance()
+	}()
+	SQLiteVacuumSchedule = "0 3 * * 0"
+	SQLiteAnalyzeSchedule = "@daily"
+	scheduleSQLiteMaintenance()
+	for name, spec := range map[string]string{sqliteVacuumJob: "0 3 * * 0", sqliteAnalyzeJob: "@daily"} {
+		rj := getRegisteredJob(name)
+		if rj == nil {
+			t.Errorf("scheduleSQLiteMaintenance didn't register %s", name)
+			continue
+		}
+		if rj.spec != spec {
+			t.Errorf("Invalid schedule of %s. Expected %s got %s", name, spec, rj.spec)
+		}
+		if err := rj.f(context.Background()); err != nil {
+			t.Errorf("%s returned an error. %s", name, err)
+		}
+	}
+	SQLiteVacuumSchedule = ""
+	scheduleSQLiteMaintenance()
+	if getRegisteredJob(sqliteVacuumJob) != nil {
+		t.Errorf("scheduleSQLiteMaintenance didn't remove %s", sqliteVacuumJob)
+	}
+}
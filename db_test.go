@@ -1,6 +1,11 @@
 package uadmin
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"time"
 )
 
@@ -248,3 +253,83 @@ func (t *UAdminTests) TestSave() {
 		t.Errorf("Count is invalid after DeleteList. Got %d expected %d", Count(TestStruct{}, ""), 0)
 	}
 }
+
+// TestDBContext is a unit testing function for the DB helpers that take a
+// context
+func (t *UAdminTests) TestDBContext() {
+	Save(&TestModelA{Name: "ctx_record"})
+	defer DeleteList(&TestModelA{}, "name = ?", "ctx_record")
+
+	ctx := context.Background()
+	m := TestModelA{}
+	if err := GetContext(ctx, &m, "name = ?", "ctx_record"); err != nil || m.ID == 0 {
+		t.Errorf("GetContext didn't get the record. %v", err)
+	}
+	if n := CountContext(ctx, &[]TestModelA{}, "name = ?", "ctx_record"); n != 1 {
+		t.Errorf("CountContext didn't count the record. Expected 1 got %d", n)
+	}
+
+	// Queries of cancelled contexts are not run
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := GetContext(cancelled, &TestModelA{}, "name = ?", "ctx_record"); err == nil {
+		t.Errorf("GetContext didn't return an error for a cancelled context")
+	}
+	list := []TestModelA{}
+	if err := FilterContext(cancelled, &list, "name = ?", "ctx_record"); err == nil || len(list) != 0 {
+		t.Errorf("FilterContext didn't return an error for a cancelled context")
+	}
+	if err := AdminPageContext(cancelled, "", true, 0, 10, &list, "name = ?", "ctx_record"); err == nil {
+		t.Errorf("AdminPageContext didn't return an error for a cancelled context")
+	}
+
+	// Queries of requests time out
+	r := httptest.NewRequest("GET", "/", nil)
+	qCtx, qCancel := queryContext(r, time.Nanosecond)
+	defer qCancel()
+	time.Sleep(time.Millisecond)
+	if qCtx.Err() != context.DeadlineExceeded {
+		t.Errorf("queryContext didn't time out. Got %v", qCtx.Err())
+	}
+
+	// dAPI read uses APIQueryTimeout
+	u1 := &User{Username: "ctxuser", Password: "ctxuser" + testPassword, Active: true, RemoteAccess: true, Admin: true}
+	u1.Save()
+	s1 := &Session{Active: true, UserID: u1.ID, LoginTime: time.Now()}
+	s1.GenerateKey()
+	s1.Save()
+	defer Delete(u1)
+	defer Delete(s1)
+	savedTimeout := APIQueryTimeout
+	APIQueryTimeout = time.Nanosecond
+	defer func() {
+		APIQueryTimeout = savedTimeout
+	}()
+	r = httptest.NewRequest("GET", "/api/d/testmodela/read/?name=ctx_record", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	w := httptest.NewRecorder()
+	apiHandler(w, r)
+	buf, _ := io.ReadAll(w.Result().Body)
+	obj := map[string]interface{}{}
+	json.Unmarshal(buf, &obj)
+	if obj["status"] != "error" {
+		t.Errorf("dAPI read didn't time out with APIQueryTimeout. Got %s", string(buf))
+	}
+
+	// dAPI writes use APIWriteTimeout
+	savedWriteTimeout := APIWriteTimeout
+	APIWriteTimeout = time.Nanosecond
+	defer func() {
+		APIWriteTimeout = savedWriteTimeout
+	}()
+	r = httptest.NewRequest("POST", "/api/d/testmodela/add/?_name=ctx_timeout&x-csrf-token="+s1.Key, nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	w = httptest.NewRecorder()
+	apiHandler(w, r)
+	buf, _ = io.ReadAll(w.Result().Body)
+	obj = map[string]interface{}{}
+	json.Unmarshal(buf, &obj)
+	if obj["status"] != "error" || Count(&TestModelA{}, "name = ?", "ctx_timeout") != 0 {
+		t.Errorf("dAPI add didn't time out with APIWriteTimeout. Got %s", string(buf))
+	}
+}
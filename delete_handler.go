@@ -8,11 +8,12 @@ import (
 	"strings"
 )
 
-// processDelete is a handler for processing deleting records from a table
-func processDelete(a interface{}, w http.ResponseWriter, r *http.Request, session *Session, user *User) {
+// processDelete is a handler for processing deleting records from a table.
+// It returns an error if the records cannot be deleted.
+func processDelete(a interface{}, w http.ResponseWriter, r *http.Request, session *Session, user *User) error {
 
 	if r.FormValue("listID") == "" || r.FormValue("listID") == "," {
-		return
+		return nil
 	}
 	tempID := strings.Split(r.FormValue("listID"), ",")
 	var tempIDs []uint
@@ -20,17 +21,17 @@ func processDelete(a interface{}, w http.ResponseWriter, r *http.Request, sessio
 
 	if !ok {
 		pageErrorHandler(w, r, session)
-		return
+		return nil
 	}
 
 	if !user.GetAccess(modelName).Delete {
-		return
+		return nil
 	}
 
 	// Check CSRF
 	if CheckCSRF(r) {
 		pageErrorHandler(w, r, session)
-		return
+		return nil
 	}
 
 	for _, v := range tempID {
@@ -41,9 +42,20 @@ func processDelete(a interface{}, w http.ResponseWriter, r *http.Request, sessio
 	m, ok := NewModel(modelName, false)
 	if !ok {
 		pageErrorHandler(w, r, session)
-		return
+		return nil
 	}
 
+	impact, err := GetDeleteImpact(modelName, tempIDs...)
+	if err != nil {
+		Trail(WARNING, "processDelete unable to delete %s. %s", modelName, err)
+		return err
+	}
+	if IsDeleteProtected(impact) {
+		return errDeleteProtected
+	}
+
+	// Take the snapshots of the records for their logs before deleting them
+	logs := []Log{}
 	if LogDelete {
 		for _, v := range tempIDs {
 			s, _ := getSchema(modelName)
@@ -67,19 +79,37 @@ func processDelete(a interface{}, w http.ResponseWriter, r *http.Request, sessio
 
 			json, _ := json.Marshal(jsonifyValue)
 			log.Activity = string(json)
-
-			log.Save()
+			logs = append(logs, log)
 		}
 	}
+	var logUser *User
+	if LogDelete {
+		logUser = user
+	}
 
 	type Deleter interface {
 		Delete(interface{}, string, ...interface{})
 	}
 
-	deleter, ok := m.Interface().(Deleter)
-	if ok {
+	// Apply the on delete rules of the related records and delete the
+	// records in one transaction. Models with a Delete method delete their
+	// records after the transaction because the method doesn't run in it.
+	deleter, isDeleter := m.Interface().(Deleter)
+	err = deleteWithImpact(r.Context(), impact, logUser, r, func(tx *Tx) error {
+		if isDeleter {
+			return nil
+		}
+		return deleteListWith(impactDB(tx, m.Interface()), m.Interface(), "id IN (?)", tempIDs)
+	})
+	if err != nil {
+		Trail(WARNING, "processDelete unable to delete %s. %s", modelName, err)
+		return err
+	}
+	if isDeleter {
 		deleter.Delete(m.Interface(), "id IN (?)", tempIDs)
-	} else {
-		DeleteList(m.Interface(), "id IN (?)", tempIDs)
 	}
+	for i := range logs {
+		logs[i].Save()
+	}
+	return nil
 }
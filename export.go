@@ -25,7 +25,7 @@ func getFilter(r *http.Request, session *Session, schema *ModelSchema) (interfac
 	var dateRe = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}$`)
 	for k, v := range r.URL.Query() {
 
-		if k == "m" || k == "o" || k == "p" || k == "return_url" {
+		if k == "m" || k == "o" || k == "p" || k == "return_url" || k == "trash" || k == "format" || k == "delimiter" || k == "encoding" {
 			continue
 		}
 
@@ -129,40 +129,221 @@ func getFilter(r *http.Request, session *Session, schema *ModelSchema) (interfac
 	return strings.Join(queryList, " AND "), args
 }
 
-// exportHandler handles http request for exporting data
+// exportHandler handles http request for exporting data. Exports are
+// created in the background task queue and the request is redirected to the
+// status of the export which includes its download link when it is finished.
 func exportHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	//http://hostname/admin/export/?m=orders&date__gte=2016-02-01&date__lte=2016-03-01
-	var err error
+	//http://hostname/admin/export/?m=orders&format=csv&delimiter=%3B&encoding=windows-1252
+	if r.URL.Query().Get("id") != "" {
+		exportStatusHandler(w, r, session)
+		return
+	}
+	if r.URL.Query().Get("download") != "" {
+		exportDownloadHandler(w, r, session)
+		return
+	}
+
+	modelName := r.URL.Query().Get("m")
+	if _, ok := getSchema(modelName); !ok || !session.User.GetAccess(modelName).Read {
+		pageErrorHandler(w, r, session)
+		return
+	}
+
+	// Validate the format of the export
+	format, err := getExportFormat(r.URL.Query().Get("format"))
+	if err == nil {
+		_, err = getExportDelimiter(r.URL.Query().Get("delimiter"))
+	}
+	if err == nil {
+		_, _, err = getExportEncoding(r.URL.Query().Get("encoding"))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": err.Error(),
+		})
+		return
+	}
+
+	export, err := startExport(r, session, format)
+	if err != nil {
+		w.WriteHeader(http.StatusTooManyRequests)
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "error",
+			"err_msg": err.Error(),
+		})
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("%sexport/?id=%d", RootURL, export.ID), http.StatusSeeOther)
+}
+
+// exportData exports the records of a model matching the filters in the
+// request to a file in the format of the export and returns the URL of the
+// file. progress is called after each exported record.
+func exportData(r *http.Request, session *Session, format string, progress func(done int, total int)) (string, error) {
+	if format == "xlsx" {
+		return exportExcel(r, session, progress)
+	}
+	return exportText(r, session, format, progress)
+}
 
+// exportPageSize is the number of records that exports read at a time
+var exportPageSize = 1000
+
+// getExportQuery returns the schema, an empty model and the query of the
+// records of a model matching the filters in the request
+func getExportQuery(r *http.Request, session *Session) (ModelSchema, reflect.Value, string, []interface{}, error) {
 	// TODO: Call ListSchemaModifier of the schema and use the modified one
 
 	modelName := r.URL.Query().Get("m")
 	schema, ok := getSchema(modelName)
 	if !ok {
-		pageErrorHandler(w, r, session)
-		return
+		return schema, reflect.Value{}, "", nil, fmt.Errorf("invalid model name: %s", modelName)
 	}
 
-	a, ok := NewModelArray(modelName, false)
-	if !ok {
-		pageErrorHandler(w, r, session)
-		return
+	m, _ := NewModel(modelName, false)
+
+	query := ""
+	args := []interface{}{}
+	if schema.ListModifier != nil {
+		query, args = schema.ListModifier(&schema, &session.User)
+	}
+	fQuery, fArgs := getFilter(r, session, &schema)
+	if fQuery.(string) != "" {
+		if query == "" {
+			query = fQuery.(string)
+		} else {
+			query += " AND " + fQuery.(string)
+		}
+		args = append(args, fArgs...)
 	}
+	return schema, m, query, args, nil
+}
+
+// readExportPages reads the records of a model matching a query in pages of
+// exportPageSize records sorted by ID and calls fn with each page and the
+// total number of records. Pages start after the last ID of the previous page
+// so records added or deleted during the export don't shift the pages.
+func readExportPages(modelName string, query string, args []interface{}, fn func(page reflect.Value, total int) error) error {
 	m, _ := NewModel(modelName, false)
+	a, _ := NewModelArray(modelName, false)
+	var total int
+	if c, ok := m.Interface().(counter); ok {
+		total = c.Count(a.Addr().Interface(), query, args...)
+	} else {
+		total = Count(a.Addr().Interface(), query, args...)
+	}
 
-	query, args := getFilter(r, session, &schema)
+	ap, isPager := m.Interface().(adminPager)
+	var lastID uint
+	for {
+		page, _ := NewModelArray(modelName, false)
+		pageQuery := "id > ?"
+		if query != "" {
+			pageQuery = "(" + query + ") AND id > ?"
+		}
+		pageArgs := append(append([]interface{}{}, args...), lastID)
+		var err error
+		if isPager {
+			err = ap.AdminPage("id", true, 0, exportPageSize, page.Addr().Interface(), pageQuery, pageArgs...)
+		} else {
+			err = AdminPage("id", true, 0, exportPageSize, page.Addr().Interface(), pageQuery, pageArgs...)
+		}
+		if err != nil {
+			return err
+		}
+		if page.Len() == 0 {
+			return nil
+		}
+		if err = fn(page, total); err != nil {
+			return err
+		}
+		if page.Len() < exportPageSize {
+			return nil
+		}
+		lastID = GetID(page.Index(page.Len() - 1))
+	}
+}
 
-	ap, ok := m.Interface().(adminPager)
+// exportText exports the records of a model matching the filters in the
+// request to a CSV, JSON or NDJSON file and returns the URL of the file
+func exportText(r *http.Request, session *Session, format string, progress func(done int, total int)) (string, error) {
+	delimiter, err := getExportDelimiter(r.URL.Query().Get("delimiter"))
+	if err != nil {
+		return "", err
+	}
+	schema, m, query, args, err := getExportQuery(r, session)
+	if err != nil {
+		return "", err
+	}
 
-	if ok {
-		err = ap.AdminPage("id", true, 0, -1, a.Addr().Interface(), query, args...)
-	} else {
-		err = AdminPage("id", true, 0, -1, a.Addr().Interface(), query, args...)
+	fileName := getExportFileName(exportFormats[format][0])
+	f, err := os.Create("." + fileName)
+	if err != nil {
+		Trail(ERROR, "exportText unable to create file %s. %s", fileName, err)
+		return "", err
+	}
+	defer f.Close()
+	w, err := newExportWriter(f, r.URL.Query().Get("encoding"))
+	if err != nil {
+		return "", err
 	}
 
+	cols := getExportColumns(&schema, m.Type(), false)
+	ew, err := newExportRecordWriter(w, format, delimiter, cols)
 	if err != nil {
-		pageErrorHandler(w, r, session)
-		return
+		return "", err
+	}
+	done := 0
+	err = readExportPages(r.URL.Query().Get("m"), query, args, func(page reflect.Value, total int) error {
+		for i := 0; i < page.Len(); i++ {
+			if err := ew.write(page.Index(i), true); err != nil {
+				return err
+			}
+			done++
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = ew.close()
+	}
+	if err != nil {
+		Trail(ERROR, "exportText unable to write file %s. %s", fileName, err)
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// getExportFileName returns a new URL for an export file with an extension
+func getExportFileName(ext string) string {
+	exportRoot := "./media/export/"
+	if _, err := os.Stat(exportRoot); os.IsNotExist(err) {
+		os.MkdirAll(exportRoot, 0700)
+		os.Create(exportRoot + "index.html")
+	}
+
+	fileName := GenerateBase64(24)
+	for _, err := os.Stat(exportRoot + fileName + ext); !os.IsNotExist(err); _, err = os.Stat(exportRoot + fileName + ext) {
+		fileName = GenerateBase64(24)
+	}
+	return "/media/export/" + fileName + ext
+}
+
+// exportExcel exports the records of a model matching the filters in the
+// request to an excel file and returns the URL of the file. progress is
+// called after each exported record.
+func exportExcel(r *http.Request, session *Session, progress func(done int, total int)) (string, error) {
+	schema, m, query, args, err := getExportQuery(r, session)
+	if err != nil {
+		return "", err
 	}
 
 	file := excelize.NewFile()
@@ -247,101 +428,103 @@ func exportHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	}
 
 	// Add body data
-	for i := 0; i < a.Len(); i++ {
-		colIndex = 0
-		preloaded = false
-		for c := 0; c < m.NumField(); c++ {
-			if !schema.FieldByName(t.Field(c).Name).ListDisplay || m.Field(c).Type().Name() == "Model" || (m.Field(c).Type().Kind() == reflect.Uint && strings.HasSuffix(t.Field(c).Name, "ID")) || schema.FieldByName(t.Field(c).Name).Type == cLINK {
-				continue
-			}
-			colIndex++
-			colName, _ = excelize.ColumnNumberToName(colIndex)
-			cellName := fmt.Sprintf(colName+"%d", i+2)
-
-			// Determine the data type
-			if schema.FieldByName(t.Field(c).Name).Type == cDATE {
-				// Process Date/Time
-				var cDate time.Time
-				if t.Field(c).Type.Kind() == reflect.Ptr {
-					if a.Index(i).Field(c).IsNil() {
-						continue
-					}
-					cDate = a.Index(i).Field(c).Elem().Interface().(time.Time)
-				} else {
-					cDate = a.Index(i).Field(c).Interface().(time.Time)
+	i := 0
+	err = readExportPages(r.URL.Query().Get("m"), query, args, func(page reflect.Value, total int) error {
+		for p := 0; p < page.Len(); p, i = p+1, i+1 {
+			record := page.Index(p)
+			colIndex = 0
+			preloaded = false
+			for c := 0; c < m.NumField(); c++ {
+				if !schema.FieldByName(t.Field(c).Name).ListDisplay || m.Field(c).Type().Name() == "Model" || (m.Field(c).Type().Kind() == reflect.Uint && strings.HasSuffix(t.Field(c).Name, "ID")) || schema.FieldByName(t.Field(c).Name).Type == cLINK {
+					continue
 				}
-				startDate := time.Date(1900, 1, 1, 0, 0, 0, 0, time.Now().Location())
-				file.SetCellValue(sheetName, cellName, cDate.Sub(startDate).Hours()/24)
-				file.SetColWidth(sheetName, colName, colName, 20)
+				colIndex++
+				colName, _ = excelize.ColumnNumberToName(colIndex)
+				cellName := fmt.Sprintf(colName+"%d", i+2)
 
-				file.SetCellStyle(sheetName, cellName, cellName, dateStyle)
-				cWidth, _ := file.GetColWidth(sheetName, colName)
-				if cWidth < 20 {
+				// Determine the data type
+				if schema.FieldByName(t.Field(c).Name).Type == cDATE {
+					// Process Date/Time
+					var cDate time.Time
+					if t.Field(c).Type.Kind() == reflect.Ptr {
+						if record.Field(c).IsNil() {
+							continue
+						}
+						cDate = record.Field(c).Elem().Interface().(time.Time)
+					} else {
+						cDate = record.Field(c).Interface().(time.Time)
+					}
+					startDate := time.Date(1900, 1, 1, 0, 0, 0, 0, time.Now().Location())
+					file.SetCellValue(sheetName, cellName, cDate.Sub(startDate).Hours()/24)
 					file.SetColWidth(sheetName, colName, colName, 20)
+
+					file.SetCellStyle(sheetName, cellName, cellName, dateStyle)
+					cWidth, _ := file.GetColWidth(sheetName, colName)
+					if cWidth < 20 {
+						file.SetColWidth(sheetName, colName, colName, 20)
+					}
+				} else if t.Field(c).Type.Kind() == reflect.Struct || (t.Field(c).Type.Kind() == reflect.Ptr && t.Field(c).Type.Elem().Kind() == reflect.Struct) {
+					// Process forign keys
+					if !preloaded {
+						Preload(record.Addr().Interface())
+					}
+					file.SetCellValue(sheetName, cellName, GetString(record.Field(c).Interface()))
+					file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
+					excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, GetString(record.Field(c).Interface()))
+				} else if t.Field(c).Type.Kind() == reflect.Int && t.Field(c).Type != reflect.TypeOf(0) {
+					// Process static list type
+					value := record.Field(c).Interface()
+					file.SetCellValue(sheetName, cellName, GetString(value))
+					file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
+					excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, GetString(value))
+				} else if schema.FieldByName(t.Field(c).Name).Type == cIMAGE {
+					// Process images
+					if record.Field(c).String() == "" {
+						continue
+					}
+					file.SetRowHeight(sheetName, i+2, 100)
+					file.SetColWidth(sheetName, colName, colName, 25)
+					True := true
+					False := false
+					graphicsOptions := excelize.GraphicOptions{
+						AutoFit:         true,
+						PrintObject:     &True,
+						LockAspectRatio: true,
+						Locked:          &False,
+						Positioning:     "oneCell",
+						ScaleX:          5.0,
+						ScaleY:          5.0,
+					}
+					file.AddPicture(sheetName, cellName, record.Field(c).String()[1:], &graphicsOptions)
+					file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
+				} else if schema.FieldByName(t.Field(c).Name).Type == cCODE {
+					file.SetCellValue(sheetName, cellName, record.Field(c).Interface())
+					file.SetCellStyle(sheetName, cellName, cellName, codeStyle)
+					excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, fmt.Sprint(record.Field(c).Interface()))
+				} else {
+					// All other data
+					file.SetCellValue(sheetName, cellName, record.Field(c).Interface())
+					file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
+					excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, fmt.Sprint(record.Field(c).Interface()))
 				}
-			} else if t.Field(c).Type.Kind() == reflect.Struct || (t.Field(c).Type.Kind() == reflect.Ptr && t.Field(c).Type.Elem().Kind() == reflect.Struct) {
-				// Process forign keys
-				if !preloaded {
-					Preload(a.Index(i).Addr().Interface())
-				}
-				file.SetCellValue(sheetName, cellName, GetString(a.Index(i).Field(c).Interface()))
-				file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
-				excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, GetString(a.Index(i).Field(c).Interface()))
-			} else if t.Field(c).Type.Kind() == reflect.Int && t.Field(c).Type != reflect.TypeOf(0) {
-				// Process static list type
-				value := a.Index(i).Field(c).Interface()
-				file.SetCellValue(sheetName, cellName, GetString(value))
-				file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
-				excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, GetString(value))
-			} else if schema.FieldByName(t.Field(c).Name).Type == cIMAGE {
-				// Process images
-				if a.Index(i).Field(c).String() == "" {
-					continue
-				}
-				file.SetRowHeight(sheetName, i+2, 100)
-				file.SetColWidth(sheetName, colName, colName, 25)
-				True := true
-				False := false
-				graphicsOptions := excelize.GraphicOptions{
-					AutoFit:         true,
-					PrintObject:     &True,
-					LockAspectRatio: true,
-					Locked:          &False,
-					Positioning:     "oneCell",
-					ScaleX:          5.0,
-					ScaleY:          5.0,
-				}
-				file.AddPicture(sheetName, cellName, a.Index(i).Field(c).String()[1:], &graphicsOptions)
-				file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
-			} else if schema.FieldByName(t.Field(c).Name).Type == cCODE {
-				file.SetCellValue(sheetName, cellName, a.Index(i).Field(c).Interface())
-				file.SetCellStyle(sheetName, cellName, cellName, codeStyle)
-				excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, fmt.Sprint(a.Index(i).Field(c).Interface()))
-			} else {
-				// All other data
-				file.SetCellValue(sheetName, cellName, a.Index(i).Field(c).Interface())
-				file.SetCellStyle(sheetName, cellName, cellName, bodyStyle)
-				excelAdjustWidthHight(file, sheetName, colName, cellName, i+2, fmt.Sprint(a.Index(i).Field(c).Interface()))
+			}
+			if progress != nil {
+				progress(i+1, total)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
 
-	exportRoot := "./media/export/"
-	if _, err = os.Stat(exportRoot); os.IsNotExist(err) {
-		os.MkdirAll(exportRoot, 0700)
-		os.Create(exportRoot + "index.html")
-	}
-
-	fileName := GenerateBase64(24)
-	for _, err = os.Stat("./media/export/" + fileName + ".xlsx"); os.IsExist(err); {
-		fileName = GenerateBase64(24)
-	}
-	err = file.SaveAs("./media/export/" + fileName + ".xlsx")
+	fileName := getExportFileName(".xlsx")
+	err = file.SaveAs("." + fileName)
 	if err != nil {
-		Trail(ERROR, "exportHandler unable to save file %s. %s", "./media/export/"+fileName+".xlsx", err)
+		Trail(ERROR, "exportExcel unable to save file %s. %s", fileName, err)
+		return "", err
 	}
-	http.Redirect(w, r, "/media/export/"+fileName+".xlsx", http.StatusSeeOther)
-	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	return fileName, nil
 }
 
 func excelAdjustWidthHight(file *excelize.File, sheetName, colName, cellName string, rowIndex int, content string) {
@@ -0,0 +1,333 @@
+package uadmin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// exportFormats are the formats supported by exports with their file
+// extension and content type
+var exportFormats = map[string][2]string{
+	"xlsx":   {".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"csv":    {".csv", "text/csv"},
+	"json":   {".json", "application/json"},
+	"ndjson": {".ndjson", "application/x-ndjson"},
+}
+
+// exportColumn is a column of a CSV, JSON or NDJSON export
+type exportColumn struct {
+	// index is the index of the field in the struct or -1 for the ID
+	index int
+	// name is the key of the column in JSON and NDJSON
+	name string
+	// header is the header of the column in CSV
+	header string
+	field  *F
+}
+
+// getExportFormat returns the format of an export from a request. An empty
+// format is an excel file.
+func getExportFormat(format string) (string, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		return "xlsx", nil
+	}
+	if _, ok := exportFormats[format]; !ok {
+		return "", fmt.Errorf("invalid export format: %s", format)
+	}
+	return format, nil
+}
+
+// getExportDelimiter returns the delimiter of a CSV export. The default
+// delimiter is a comma and "tab" can be used for tab separated values.
+func getExportDelimiter(delimiter string) (rune, error) {
+	switch strings.ToLower(delimiter) {
+	case "":
+		return ',', nil
+	case "tab", "\\t":
+		return '\t', nil
+	}
+	d, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || d == utf8.RuneError || d == '"' || d == '\r' || d == '\n' {
+		return 0, fmt.Errorf("invalid delimiter: %s", delimiter)
+	}
+	return d, nil
+}
+
+// getExportEncoding returns the character encoding of a text export. It
+// accepts any name in the WHATWG encoding standard like "windows-1252" or
+// "utf-16le" and "utf-8-bom" for UTF-8 with a byte order mark which is
+// required by excel to detect UTF-8 in CSV files.
+func getExportEncoding(name string) (encoding.Encoding, bool, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "utf-8" || name == "utf8" {
+		return nil, false, nil
+	}
+	if name == "utf-8-bom" || name == "utf8-bom" {
+		return nil, true, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid encoding: %s", name)
+	}
+	return enc, false, nil
+}
+
+// newExportWriter wraps w to write a text export in an encoding. Characters
+// that are not supported by the encoding are replaced. The returned writer
+// must be closed to flush the encoded text.
+func newExportWriter(w io.Writer, encodingName string) (io.WriteCloser, error) {
+	enc, bom, err := getExportEncoding(encodingName)
+	if err != nil {
+		return nil, err
+	}
+	if bom {
+		if _, err = w.Write([]byte("\xef\xbb\xbf")); err != nil {
+			return nil, err
+		}
+	}
+	if enc == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder())), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// getExportColumns returns the columns of a model that are exported. List
+// exports use the fields in the list view of the model like excel exports.
+// API exports use all the fields except M2M fields and foreign keys are
+// exported as their IDs.
+func getExportColumns(schema *ModelSchema, t reflect.Type, api bool) []exportColumn {
+	cols := []exportColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous && t.Field(i).Type.Name() == "Model" {
+			if api {
+				cols = append(cols, exportColumn{index: -1, name: "ID", header: "ID"})
+			}
+			continue
+		}
+		f := schema.FieldByName(t.Field(i).Name)
+		if f.Name == "" {
+			// ID fields of foreign keys are not in the schema
+			name := t.Field(i).Name
+			if _, ok := t.FieldByName(strings.TrimSuffix(name, "ID")); api && strings.HasSuffix(name, "ID") && ok {
+				cols = append(cols, exportColumn{index: i, name: name, header: name, field: &F{Name: name, Type: cNUMBER}})
+			}
+			continue
+		}
+		if api {
+			if f.Type == cM2M || f.Type == cFK {
+				continue
+			}
+			cols = append(cols, exportColumn{index: i, name: f.Name, header: f.Name, field: f})
+			continue
+		}
+		if !f.ListDisplay || (t.Field(i).Type.Kind() == reflect.Uint && strings.HasSuffix(f.Name, "ID")) || f.Type == cLINK {
+			continue
+		}
+		cols = append(cols, exportColumn{index: i, name: f.Name, header: f.DisplayName, field: f})
+	}
+	return cols
+}
+
+// getExportValue returns the value of a column in a record. Dates are
+// formatted, foreign keys and static lists are exported as their display
+// values and empty dates are nil.
+func getExportValue(record reflect.Value, col exportColumn) interface{} {
+	if col.index == -1 {
+		return GetID(record)
+	}
+	v := record.Field(col.index)
+	if col.field.Type == cDATE {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		if d, ok := v.Interface().(time.Time); ok {
+			return d.Format("2006-01-02 15:04:05")
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		return GetString(v.Interface())
+	}
+	if v.Kind() == reflect.Int && v.Type() != reflect.TypeOf(0) {
+		// Static list type
+		return GetString(v.Interface())
+	}
+	return v.Interface()
+}
+
+// writeExport writes a list of records in a text format. Records can be a
+// slice of structs or a slice of maps. Foreign keys of list exports are
+// preloaded. progress is called after each written record.
+func writeExport(w io.Writer, format string, delimiter rune, records reflect.Value, cols []exportColumn, preload bool, progress func(done int, total int)) error {
+	ew, err := newExportRecordWriter(w, format, delimiter, cols)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < records.Len(); i++ {
+		if err = ew.write(records.Index(i), preload); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(i+1, records.Len())
+		}
+	}
+	return ew.close()
+}
+
+// exportRecordWriter writes records in a text format one at a time so
+// exports can be written in pages
+type exportRecordWriter struct {
+	w         io.Writer
+	format    string
+	csvWriter *csv.Writer
+	cols      []exportColumn
+	count     int
+}
+
+// newExportRecordWriter returns an exportRecordWriter and writes the start of
+// the file
+func newExportRecordWriter(w io.Writer, format string, delimiter rune, cols []exportColumn) (*exportRecordWriter, error) {
+	ew := &exportRecordWriter{w: w, format: format, cols: cols}
+	if format == "csv" {
+		ew.csvWriter = csv.NewWriter(w)
+		ew.csvWriter.Comma = delimiter
+		headers := make([]string, len(cols))
+		for i := range cols {
+			headers[i] = cols[i].header
+		}
+		if err := ew.csvWriter.Write(headers); err != nil {
+			return nil, err
+		}
+	}
+	if format == "json" {
+		if _, err := io.WriteString(w, "["); err != nil {
+			return nil, err
+		}
+	}
+	return ew, nil
+}
+
+// write writes a record which is a struct or a map
+func (ew *exportRecordWriter) write(record reflect.Value, preload bool) error {
+	if preload {
+		Preload(record.Addr().Interface())
+	}
+	values := make([]interface{}, len(ew.cols))
+	for c := range ew.cols {
+		if record.Kind() == reflect.Map {
+			if v := record.MapIndex(reflect.ValueOf(ew.cols[c].name)); v.IsValid() {
+				values[c] = v.Interface()
+				if b, ok := values[c].([]byte); ok {
+					values[c] = string(b)
+				}
+			}
+		} else {
+			values[c] = getExportValue(record, ew.cols[c])
+		}
+	}
+
+	var err error
+	switch ew.format {
+	case "csv":
+		row := make([]string, len(values))
+		for c := range values {
+			if values[c] != nil {
+				row[c] = fmt.Sprint(values[c])
+			}
+		}
+		err = ew.csvWriter.Write(row)
+	case "json", "ndjson":
+		prefix := ""
+		if ew.format == "json" && ew.count != 0 {
+			prefix = ","
+		}
+		var buf []byte
+		if buf, err = marshalExportRecord(ew.cols, values); err == nil {
+			if ew.format == "ndjson" {
+				buf = append(buf, '\n')
+			}
+			_, err = io.WriteString(ew.w, prefix+string(buf))
+		}
+	}
+	ew.count++
+	return err
+}
+
+// close writes the end of the file
+func (ew *exportRecordWriter) close() error {
+	if ew.format == "json" {
+		if _, err := io.WriteString(ew.w, "]"); err != nil {
+			return err
+		}
+	}
+	if ew.csvWriter != nil {
+		ew.csvWriter.Flush()
+		return ew.csvWriter.Error()
+	}
+	return nil
+}
+
+// marshalExportRecord encodes a record as a JSON object with its keys in the
+// order of the columns
+func marshalExportRecord(cols []exportColumn, values []interface{}) ([]byte, error) {
+	buf := []byte{'{'}
+	for c := range cols {
+		if c != 0 {
+			buf = append(buf, ',')
+		}
+		key, _ := json.Marshal(cols[c].name)
+		value, err := json.Marshal(values[c])
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// getMapExportColumns returns the columns of records read using custom
+// fields in dAPI sorted by their names
+func getMapExportColumns(records []map[string]interface{}) []exportColumn {
+	names := []string{}
+	if len(records) != 0 {
+		for k := range records[0] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	cols := make([]exportColumn, len(names))
+	for i := range names {
+		cols[i] = exportColumn{name: names[i], header: names[i]}
+	}
+	return cols
+}
@@ -1,11 +1,13 @@
 package uadmin
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/xuri/excelize/v2"
@@ -98,8 +100,7 @@ func (t *UAdminTests) TestGetFilter() {
 			t.Errorf("exportHandler returned invalid code. Expected %d, got %d", 303, w.Code)
 			continue
 		}
-		// Check if the file exists
-
+		// Check if the export was started
 		if _, ok := w.Header()["Location"]; !ok {
 			t.Error("exportHandler returned no Location in header")
 			continue
@@ -108,11 +109,34 @@ func (t *UAdminTests) TestGetFilter() {
 			t.Error("exportHandler returned empty Location in header")
 			continue
 		}
-		if _, err := os.Stat("." + w.Header()["Location"][0]); os.IsNotExist(err) {
-			t.Errorf("exportHandler didn't create a file. Expected %s", w.Header()["Location"][0])
+		statusURL := w.Header()["Location"][0]
+		export := waitForExport(strings.TrimPrefix(statusURL, RootURL+"export/?id="))
+		if export.Status != export.Status.Finished() {
+			t.Errorf("exportHandler didn't finish the export. Got %#v", export)
+			continue
+		}
+
+		// Check the status of the export
+		w = httptest.NewRecorder()
+		exportHandler(w, httptest.NewRequest("GET", statusURL, nil), &s1)
+		status := map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &status)
+		if status["progress"] != float64(100) || status["download"] != export.Download || status["records"] != float64(e.count) {
+			t.Errorf("exportHandler returned invalid status. Got %s", w.Body.String())
+		}
+
+		// Download the file
+		w = httptest.NewRecorder()
+		exportHandler(w, httptest.NewRequest("GET", export.Download, nil), &s1)
+		if w.Code != http.StatusOK {
+			t.Errorf("exportHandler didn't download the export. Got %d", w.Code)
+			continue
+		}
+		if _, err := os.Stat("." + export.FileName); os.IsNotExist(err) {
+			t.Errorf("exportHandler didn't create a file. Expected %s", export.FileName)
 			continue
 		}
-		f, err := excelize.OpenFile("." + w.Header()["Location"][0])
+		f, err := excelize.OpenReader(w.Body)
 		if err != nil {
 			t.Errorf("exportHandler created an invalid xlsx file. %s", err)
 			continue
@@ -147,3 +171,185 @@ func (t *UAdminTests) TestGetFilter() {
 	DeleteList(&TestStruct1{}, "")
 	DeleteList(&TestStruct2{}, "")
 }
+
+// TestDataExport is a unit testing function for the limits and expiry of
+// data exports
+func (t *UAdminTests) TestDataExport() {
+	s1 := Session{
+		Active:    true,
+		UserID:    1,
+		LoginTime: time.Now(),
+	}
+	s1.GenerateKey()
+	s1.Save()
+	Preload(&s1)
+
+	// Concurrent exports limit
+	running := DataExport{ModelName: "user", UserID: s1.UserID, Status: ExportStatus(0).Running()}
+	Save(&running)
+	tempMax := ExportMaxConcurrent
+	ExportMaxConcurrent = 1
+	w := httptest.NewRecorder()
+	exportHandler(w, httptest.NewRequest("GET", "/export/?m=user", nil), &s1)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("exportHandler didn't limit concurrent exports. Got %d", w.Code)
+	}
+	ExportMaxConcurrent = tempMax
+	Delete(running)
+
+	w = httptest.NewRecorder()
+	exportHandler(w, httptest.NewRequest("GET", "/export/?m=user", nil), &s1)
+	export := waitForExport(strings.TrimPrefix(w.Header().Get("Location"), RootURL+"export/?id="))
+	if export.Status != export.Status.Finished() || export.ExpiresAt == nil || export.DownloadKey == "" {
+		t.Errorf("exportHandler didn't finish the export. Got %#v", export)
+	}
+
+	// Other users cannot see or download the export
+	s2 := Session{UserID: s1.UserID + 1000, User: User{Username: "other"}}
+	w = httptest.NewRecorder()
+	exportHandler(w, httptest.NewRequest("GET", fmt.Sprintf("/export/?id=%d", export.ID), nil), &s2)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("exportHandler returned the status of an export to another user. Got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	exportHandler(w, httptest.NewRequest("GET", export.Download, nil), &s2)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("exportHandler downloaded an export for another user. Got %d", w.Code)
+	}
+
+	// Expired exports
+	db.Model(&DataExport{}).Where("id = ?", export.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	w = httptest.NewRecorder()
+	exportHandler(w, httptest.NewRequest("GET", export.Download, nil), &s1)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("exportHandler downloaded an expired export. Got %d", w.Code)
+	}
+	removeExpiredExports()
+	Get(&export, "id = ?", export.ID)
+	if export.Status != export.Status.Expired() || export.DownloadKey != "" {
+		t.Errorf("removeExpiredExports didn't expire the export. Got %#v", export)
+	}
+	if _, err := os.Stat("." + export.FileName); !os.IsNotExist(err) {
+		t.Errorf("removeExpiredExports didn't remove the file %s", export.FileName)
+	}
+
+	DeleteList(&[]DataExport{}, "user_id = ?", s1.UserID)
+	Delete(s1)
+}
+
+func waitForExport(ID string) DataExport {
+	export := DataExport{}
+	for i := 0; i < 100; i++ {
+		Get(&export, "id = ?", ID)
+		if export.Status == export.Status.Finished() || export.Status == export.Status.Failed() {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return export
+}
+
+// TestExportFormat is a unit testing function for CSV, JSON and NDJSON
+// exports
+func (t *UAdminTests) TestExportFormat() {
+	s1 := Session{
+		Active:    true,
+		UserID:    1,
+		LoginTime: time.Now(),
+	}
+	Preload(&s1)
+
+	om := TestStruct1{Name: "Other Model"}
+	Save(&om)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		Save(&TestStruct2{
+			Name:         fmt.Sprintf("Café %d", i),
+			Count:        i,
+			Start:        start,
+			Type:         TestType(1),
+			OtherModelID: om.ID,
+		})
+	}
+
+	export := func(query string) string {
+		r := httptest.NewRequest("GET", "/export/?m=teststruct2&q=Caf&"+query, nil)
+		format, _ := getExportFormat(r.URL.Query().Get("format"))
+		fileName, err := exportData(r, &s1, format, nil)
+		if err != nil {
+			t.Errorf("exportData returned an error for %s. %s", query, err)
+			return ""
+		}
+		defer os.Remove("." + fileName)
+		if !strings.HasSuffix(fileName, exportFormats[format][0]) {
+			t.Errorf("exportData returned invalid file name for %s. Got %s", query, fileName)
+		}
+		buf, _ := os.ReadFile("." + fileName)
+		return string(buf)
+	}
+
+	// CSV
+	v := export("format=csv&delimiter=%3B&count__gt=0")
+	expected := "Name;Count;Value;Start;End;Type;Other Model;Another Model;Active\n" +
+		"Café 1;1;0;2024-01-02 03:04:05;;" + GetString(TestType(1)) + ";Other Model;;false\n" +
+		"Café 2;2;0;2024-01-02 03:04:05;;" + GetString(TestType(1)) + ";Other Model;;false\n"
+	if v != expected {
+		t.Errorf("exportData returned invalid CSV. Got %q expected %q", v, expected)
+	}
+	if v = export("format=csv&delimiter=tab&encoding=utf-8-bom"); !strings.HasPrefix(v, "\xef\xbb\xbfName\tCount\t") {
+		t.Errorf("exportData returned invalid tab separated CSV with BOM. Got %q", v)
+	}
+	if v = export("format=csv&encoding=windows-1252"); !strings.Contains(v, "Caf\xe9 0,0,") {
+		t.Errorf("exportData didn't encode CSV to windows-1252. Got %q", v)
+	}
+
+	// JSON
+	records := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(export("format=json")), &records); err != nil || len(records) != 3 {
+		t.Errorf("exportData returned invalid JSON. Got %d records. %v", len(records), err)
+	} else if records[0]["Name"] != "Café 0" || records[0]["Count"] != float64(0) || records[0]["End"] != nil || records[0]["OtherModel"] != "Other Model" {
+		t.Errorf("exportData returned invalid JSON record. Got %#v", records[0])
+	}
+
+	// Records are read in pages
+	pageSize := exportPageSize
+	exportPageSize = 2
+	progress := 0
+	r := httptest.NewRequest("GET", "/export/?m=teststruct2&q=Caf&format=json", nil)
+	fileName, err := exportData(r, &s1, "json", func(done int, total int) {
+		if total == 3 {
+			progress = done
+		}
+	})
+	exportPageSize = pageSize
+	buf, _ := os.ReadFile("." + fileName)
+	os.Remove("." + fileName)
+	records = []map[string]interface{}{}
+	if err = json.Unmarshal(buf, &records); err != nil || len(records) != 3 || records[2]["Name"] != "Café 2" || progress != 3 {
+		t.Errorf("exportData returned invalid JSON in pages. Got %s progress %d. %v", buf, progress, err)
+	}
+
+	// NDJSON
+	lines := strings.Split(strings.TrimSpace(export("format=ndjson")), "\n")
+	if len(lines) != 3 {
+		t.Errorf("exportData returned invalid NDJSON. Expected 3 lines got %d", len(lines))
+	}
+	for _, line := range lines {
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Errorf("exportData returned invalid NDJSON line %q. %s", line, err)
+		}
+	}
+
+	// Invalid options
+	for _, query := range []string{"format=pdf", "format=csv&delimiter=ab", "format=csv&encoding=invalid"} {
+		w := httptest.NewRecorder()
+		exportHandler(w, httptest.NewRequest("GET", "/export/?m=teststruct2&"+query, nil), &s1)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("exportHandler didn't reject %s. Got %d", query, w.Code)
+		}
+	}
+
+	DeleteList(&TestStruct2{}, "")
+	DeleteList(&TestStruct1{}, "")
+}
@@ -0,0 +1,498 @@
+package uadmin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fixtureEntry is an entry in a data fixture. An entry is a record of a
+// model, a row of an M2M table or a media file. JSON fixtures are an array
+// of entries and NDJSON fixtures have an entry in each line.
+type fixtureEntry struct {
+	// Model is the model name of a record
+	Model string `json:"model,omitempty"`
+	// Fields are the values of the record by column name
+	Fields json.RawMessage `json:"fields,omitempty"`
+	// M2M is the table name of an M2M row
+	M2M string `json:"m2m,omitempty"`
+	// IDs are the IDs of the two records in an M2M row
+	IDs []uint `json:"ids,omitempty"`
+	// Media is the path of a media file
+	Media string `json:"media,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+// fixtureWriter writes entries to a JSON or NDJSON fixture
+type fixtureWriter struct {
+	w      io.Writer
+	format string
+	count  int
+}
+
+func (f *fixtureWriter) write(entry fixtureEntry) error {
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if f.format == "json" {
+		prefix = ",\n"
+		if f.count == 0 {
+			prefix = "[\n"
+		}
+	}
+	f.count++
+	_, err = io.WriteString(f.w, prefix+string(buf)+"\n")
+	return err
+}
+
+func (f *fixtureWriter) close() error {
+	if f.format != "json" {
+		return nil
+	}
+	if f.count == 0 {
+		_, err := io.WriteString(f.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(f.w, "]\n")
+	return err
+}
+
+// getFixtureModels returns the model names to dump in the order of their
+// foreign keys so records are loaded after the records they refer to. All
+// registered models are returned if names is empty.
+func getFixtureModels(names []string) ([]string, error) {
+	modelNames := []string{}
+	if len(names) == 0 {
+		for name := range registeredModels() {
+			modelNames = append(modelNames, name)
+		}
+	}
+	for _, name := range names {
+		modelName, ok := getInitialDataModelName(name)
+		if !ok {
+			return nil, fmt.Errorf("model not found: %s", name)
+		}
+		modelNames = append(modelNames, modelName)
+	}
+	return sortFixtureModels(modelNames)
+}
+
+// sortFixtureModels sorts models by their foreign keys. Models that refer
+// to each other are sorted by name.
+func sortFixtureModels(modelNames []string) ([]string, error) {
+	sort.Strings(modelNames)
+	tables := map[string]string{}
+	deps := map[string][]string{}
+	for _, name := range modelNames {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(registeredModels()[name]); err != nil {
+			return nil, err
+		}
+		tables[stmt.Schema.Table] = name
+		for _, rel := range stmt.Schema.Relationships.BelongsTo {
+			deps[name] = append(deps[name], rel.FieldSchema.Table)
+		}
+	}
+
+	sorted := []string{}
+	visited := map[string]int{}
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] != 0 {
+			// Visited or a cycle of foreign keys
+			return
+		}
+		visited[name] = 1
+		for _, table := range deps[name] {
+			if dep, ok := tables[table]; ok && dep != name {
+				visit(dep)
+			}
+		}
+		visited[name] = 2
+		sorted = append(sorted, name)
+	}
+	for _, name := range modelNames {
+		visit(name)
+	}
+	return sorted, nil
+}
+
+// DumpData writes the records of models with their M2M tables to a JSON or
+// NDJSON fixture that can be restored using LoadData. Values are written as
+// they are stored in the database by their column names including deleted
+// records. If media is true, the files of image and file fields are
+// included in the fixture. All registered models are dumped if modelNames
+// is empty.
+func DumpData(w io.Writer, format string, modelNames []string, media bool) error {
+	models := registeredModels()
+	if format != "json" && format != "ndjson" {
+		return fmt.Errorf("invalid fixture format: %s", format)
+	}
+	names, err := getFixtureModels(modelNames)
+	if err != nil {
+		return err
+	}
+	fw := &fixtureWriter{w: w, format: format}
+	mediaFiles := map[string]bool{}
+	m2mTables := map[string]string{}
+
+	for _, name := range names {
+		stmt := &gorm.Statement{DB: db}
+		if err = stmt.Parse(models[name]); err != nil {
+			return err
+		}
+		records, _ := NewModelArray(name, true)
+		if err = modelDB(models[name]).Unscoped().Find(records.Interface()).Error; err != nil {
+			Trail(ERROR, "DumpData unable to read %s. %s", name, err)
+			return err
+		}
+
+		schema, _ := getSchema(name)
+		for i := 0; i < records.Elem().Len(); i++ {
+			record := records.Elem().Index(i)
+			fields := map[string]interface{}{}
+			for _, dbName := range stmt.Schema.DBNames {
+				fields[dbName], _ = stmt.Schema.FieldsByDBName[dbName].ValueOf(stmt.Context, record)
+			}
+			buf, err := json.Marshal(fields)
+			if err != nil {
+				return fmt.Errorf("unable to encode %s %v. %s", name, GetID(record), err)
+			}
+			if err = fw.write(fixtureEntry{Model: name, Fields: buf}); err != nil {
+				return err
+			}
+
+			// Media files of the record
+			for _, f := range schema.Fields {
+				if !media || (f.Type != cIMAGE && f.Type != cFILE) {
+					continue
+				}
+				if fileName := record.FieldByName(f.Name).String(); fileName != "" {
+					for _, path := range getFixtureMediaFiles(fileName, name+"_"+f.Name+"_") {
+						mediaFiles[path] = true
+					}
+				}
+			}
+		}
+
+		// M2M tables
+		t := reflect.TypeOf(models[name])
+		for i := 0; i < t.NumField(); i++ {
+			if !strings.Contains(t.Field(i).Tag.Get("uadmin"), "disable_m2m") &&
+				t.Field(i).Type.Kind() == reflect.Slice && t.Field(i).Type.Elem().Kind() == reflect.Struct {
+				m2mTables[strings.ToLower(getTypeName(t))+"_"+strings.ToLower(getTypeName(t.Field(i).Type.Elem()))] = name
+			}
+		}
+	}
+
+	// M2M rows are written after the records they refer to
+	tables := []string{}
+	for table := range m2mTables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		rows, err := modelDB(models[m2mTables[table]]).Table(table).Select("table1_id, table2_id").Order("table1_id, table2_id").Rows()
+		if err != nil {
+			Trail(ERROR, "DumpData unable to read %s. %s", table, err)
+			return err
+		}
+		for rows.Next() {
+			ids := []uint{0, 0}
+			if err = rows.Scan(&ids[0], &ids[1]); err == nil {
+				err = fw.write(fixtureEntry{M2M: table, IDs: ids})
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+	}
+
+	paths := []string{}
+	for path := range mediaFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile("." + path)
+		if err != nil {
+			Trail(WARNING, "DumpData unable to read media file %s. %s", path, err)
+			continue
+		}
+		if err = fw.write(fixtureEntry{Media: path, Data: buf}); err != nil {
+			return err
+		}
+	}
+	return fw.close()
+}
+
+// getFixtureMediaFiles returns the media files of an uploaded file. Uploads
+// have their own folder with the resized images and the versions of the
+// file, so all the files in the folder are included.
+func getFixtureMediaFiles(fileName string, folderPrefix string) []string {
+	if !strings.HasPrefix(fileName, "/media/") || strings.Contains(fileName, "..") {
+		return nil
+	}
+	dir := filepath.Dir(fileName)
+	if !strings.HasPrefix(filepath.Base(dir), folderPrefix) {
+		return []string{fileName}
+	}
+	files, err := ioutil.ReadDir("." + dir)
+	if err != nil {
+		return []string{fileName}
+	}
+	paths := []string{}
+	for _, f := range files {
+		if !f.IsDir() {
+			paths = append(paths, filepath.ToSlash(filepath.Join(dir, f.Name())))
+		}
+	}
+	return paths
+}
+
+// readFixture reads the entries of a JSON or NDJSON fixture
+func readFixture(r io.Reader) ([]fixtureEntry, error) {
+	reader := bufio.NewReader(r)
+	if bom, _ := reader.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		reader.Discard(3)
+	}
+	dec := json.NewDecoder(reader)
+
+	// JSON fixtures are an array of entries
+	isArray := false
+	for {
+		b, err := reader.Peek(1)
+		if err != nil || (b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n') {
+			isArray = err == nil && b[0] == '['
+			break
+		}
+		reader.ReadByte()
+	}
+	if isArray {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []fixtureEntry{}
+	for dec.More() {
+		entry := fixtureEntry{}
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid fixture entry %d. %s", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// LoadData restores a fixture written by DumpData in a transaction. Records
+// keep their IDs and replace existing records with the same IDs. Records
+// are loaded in the order of their foreign keys and the M2M rows after
+// them. It returns the number of loaded entries.
+func LoadData(r io.Reader) (int, error) {
+	models := registeredModels()
+	entries, err := readFixture(r)
+	if err != nil {
+		return 0, err
+	}
+
+	// Group the records by model
+	records := map[string][]fixtureEntry{}
+	modelNames := []string{}
+	m2m := []fixtureEntry{}
+	media := []fixtureEntry{}
+	for _, entry := range entries {
+		switch {
+		case entry.Model != "":
+			modelName, ok := getInitialDataModelName(entry.Model)
+			if !ok {
+				return 0, fmt.Errorf("model not found: %s", entry.Model)
+			}
+			if _, ok := records[modelName]; !ok {
+				modelNames = append(modelNames, modelName)
+			}
+			records[modelName] = append(records[modelName], entry)
+		case entry.M2M != "":
+			if len(entry.IDs) != 2 {
+				return 0, fmt.Errorf("invalid M2M row in %s: %v", entry.M2M, entry.IDs)
+			}
+			m2m = append(m2m, entry)
+		case entry.Media != "":
+			if !strings.HasPrefix(entry.Media, "/media/") || strings.Contains(entry.Media, "..") {
+				return 0, fmt.Errorf("invalid media file: %s", entry.Media)
+			}
+			media = append(media, entry)
+		}
+	}
+	if modelNames, err = sortFixtureModels(modelNames); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range modelNames {
+			conn := fixtureDB(tx, models[name])
+			stmt := &gorm.Statement{DB: conn}
+			if err := stmt.Parse(models[name]); err != nil {
+				return err
+			}
+			for _, entry := range records[name] {
+				record, err := newFixtureRecord(name, stmt, entry.Fields)
+				if err != nil {
+					return err
+				}
+				if err = conn.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).Create(record.Interface()).Error; err != nil {
+					return fmt.Errorf("unable to save %s %v. %s", name, GetID(record.Elem()), err)
+				}
+				count++
+			}
+			if getDBType(models[name]) == "postgres" && len(records[name]) != 0 {
+				// Move the sequence after the loaded IDs
+				if err := conn.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), (SELECT COALESCE(MAX(id), 1) FROM "+stmt.Quote(stmt.Schema.Table)+"))", stmt.Schema.Table).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for _, entry := range m2m {
+			row := map[string]interface{}{"table1_id": entry.IDs[0], "table2_id": entry.IDs[1]}
+			conn := fixtureDB(tx, models[strings.SplitN(entry.M2M, "_", 2)[0]])
+			if err := conn.Table(entry.M2M).Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error; err != nil {
+				return fmt.Errorf("unable to save %s %v. %s", entry.M2M, entry.IDs, err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		Trail(ERROR, "LoadData unable to load the fixture. %s", err)
+		return 0, err
+	}
+
+	for _, entry := range media {
+		os.MkdirAll("."+filepath.Dir(entry.Media), 0755)
+		if err = ioutil.WriteFile("."+entry.Media, entry.Data, DefaultMediaPermission); err != nil {
+			Trail(ERROR, "LoadData unable to write media file %s. %s", entry.Media, err)
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// newFixtureRecord returns a pointer to a new record of a model with the
+// values of a fixture record
+func newFixtureRecord(name string, stmt *gorm.Statement, fields json.RawMessage) (reflect.Value, error) {
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(fields, &values); err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid record of %s. %s", name, err)
+	}
+	record, _ := NewModel(name, true)
+	for column, value := range values {
+		field, ok := stmt.Schema.FieldsByDBName[column]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s doesn't have a column %s", name, column)
+		}
+		v := reflect.New(field.FieldType)
+		if err := json.Unmarshal(value, v.Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value of %s.%s. %s", name, column, err)
+		}
+		if err := field.Set(stmt.Context, record.Elem(), v.Elem().Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid value of %s.%s. %s", name, column, err)
+		}
+	}
+	return record, nil
+}
+
+// dumpDataCommand runs the dumpdata command:
+//
+//	dumpdata [MODEL...] [--output FILE] [--format json|ndjson] [--media]
+func dumpDataCommand(args []string) error {
+	output := ""
+	format := ""
+	media := false
+	modelNames := []string{}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--output", "-o", "--format":
+			if i+1 == len(args) {
+				return fmt.Errorf("missing value of %s", args[i])
+			}
+			if args[i] == "--format" {
+				format = args[i+1]
+			} else {
+				output = args[i+1]
+			}
+			i++
+		case "--media":
+			media = true
+		default:
+			modelNames = append(modelNames, args[i])
+		}
+	}
+	if format == "" {
+		format = "json"
+		if strings.HasSuffix(output, ".ndjson") {
+			format = "ndjson"
+		}
+	}
+	if output == "" {
+		output = "dumpdata." + format
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = DumpData(f, format, modelNames, media)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Dumped data to", output)
+	return nil
+}
+
+// loadDataCommand runs the loaddata command:
+//
+//	loaddata FILE
+func loadDataCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: loaddata FILE")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	count, err := LoadData(f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Loaded %d entries from %s\n", count, args[0])
+	return nil
+}
+
+// fixtureDB returns the connection to load the records of a model. Models in
+// named databases are loaded in their databases outside the transaction.
+func fixtureDB(tx *gorm.DB, a interface{}) *gorm.DB {
+	if getDBName(a) != "" {
+		return modelDB(a)
+	}
+	return tx
+}
@@ -0,0 +1,123 @@
+package uadmin
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TestFixture is a unit testing function for dumping and loading data
+func (t *UAdminTests) TestFixture() {
+	Schema["teststruct"], _ = getSchema(TestStruct{})
+	models["teststruct"] = TestStruct{}
+
+	clean := func() {
+		db.Unscoped().Where("1 = 1").Delete(&TestStruct2{})
+		db.Unscoped().Where("1 = 1").Delete(&TestStruct{})
+		db.Unscoped().Where("1 = 1").Delete(&TestStruct1{})
+		db.Exec("DELETE FROM teststruct_teststruct")
+	}
+	clean()
+
+	om := TestStruct1{Name: "Other Model", Value: 1}
+	Save(&om)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r1 := TestStruct2{Name: "Record 1", Count: 1, Value: 1.5, Start: start, Type: TestType(0).Active(), OtherModelID: om.ID, Active: true}
+	Save(&r1)
+	r2 := TestStruct2{Name: "Record 2", Start: start, OtherModelID: om.ID}
+	Save(&r2)
+	db.Delete(&r2)
+	p := TestStruct{Name: "Parent", OtherModelID: om.ID}
+	Save(&p)
+	c := TestStruct{Name: "Child", ParentID: p.ID, OtherModelID: om.ID}
+	Save(&c)
+	db.Exec(strings.Replace(strings.Replace(sqlDialect[Database.Type]["insertM2M"], "{TABLE1}", "teststruct", -1), "{TABLE2}", "teststruct", -1), p.ID, c.ID)
+
+	for _, format := range []string{"ndjson", "json"} {
+		buf := &bytes.Buffer{}
+		if err := DumpData(buf, format, []string{"teststruct2", "test_structs", "TestStruct1"}, false); err != nil {
+			t.Errorf("DumpData returned an error. %s", err)
+		}
+		fixture := buf.String()
+
+		// Records are dumped in the order of their foreign keys
+		if i1, i2 := strings.Index(fixture, `"model":"teststruct1"`), strings.Index(fixture, `"model":"teststruct2"`); i1 == -1 || i2 == -1 || i1 > i2 {
+			t.Errorf("DumpData didn't dump the records in the order of foreign keys. Got %s", fixture)
+		}
+		if format == "ndjson" && len(strings.Split(strings.TrimSpace(fixture), "\n")) != 6 {
+			t.Errorf("DumpData returned invalid ndjson. Got %s", fixture)
+		}
+		if format == "json" && (!strings.HasPrefix(fixture, "[") || !strings.Contains(fixture, fmt.Sprintf(`{"m2m":"teststruct_teststruct","ids":[%d,%d]}`, p.ID, c.ID))) {
+			t.Errorf("DumpData returned invalid json. Got %s", fixture)
+		}
+
+		// Load the fixture into an empty database
+		clean()
+		count, err := LoadData(strings.NewReader(fixture))
+		if err != nil || count != 6 {
+			t.Errorf("LoadData didn't load the %s fixture. Got %d %s", format, count, err)
+		}
+		record := TestStruct2{}
+		db.Unscoped().Where("id = ?", r1.ID).First(&record)
+		if record.Name != r1.Name || record.Value != 1.5 || record.Type != r1.Type || !record.Active || record.OtherModelID != om.ID ||
+			!record.Start.Equal(start) || record.End != nil || record.DeletedAt.Valid {
+			t.Errorf("LoadData didn't restore the record. Got %#v", record)
+		}
+		record = TestStruct2{}
+		db.Unscoped().Where("id = ?", r2.ID).First(&record)
+		if record.ID != r2.ID || !record.DeletedAt.Valid {
+			t.Errorf("LoadData didn't restore the deleted record. Got %#v", record)
+		}
+		var m2m int64
+		db.Table("teststruct_teststruct").Where("table1_id = ? AND table2_id = ?", p.ID, c.ID).Count(&m2m)
+		if m2m != 1 {
+			t.Errorf("LoadData didn't restore the M2M rows. Got %d", m2m)
+		}
+
+		// Loading the fixture again replaces the records
+		if _, err = LoadData(strings.NewReader(fixture)); err != nil || Count(&[]TestStruct1{}, "") != 1 {
+			t.Errorf("LoadData duplicated the records. %v", err)
+		}
+	}
+
+	// New records get IDs after the loaded IDs
+	n := TestStruct1{Name: "New"}
+	Save(&n)
+	if n.ID <= om.ID {
+		t.Errorf("LoadData didn't keep the IDs of new records after the loaded IDs. Got %d", n.ID)
+	}
+
+	// Invalid fixtures are not loaded
+	if _, err := LoadData(strings.NewReader(`{"model":"unknown","fields":{}}`)); err == nil {
+		t.Errorf("LoadData didn't return an error for an unknown model")
+	}
+	if _, err := LoadData(strings.NewReader(`{"model":"teststruct1","fields":{"unknown":1}}`)); err == nil {
+		t.Errorf("LoadData didn't return an error for an unknown column")
+	}
+	if _, err := LoadData(strings.NewReader(`{"media":"/etc/passwd","data":""}`)); err == nil {
+		t.Errorf("LoadData didn't return an error for a file outside media")
+	}
+
+	// Media files
+	os.MkdirAll("./media/files/teststruct2_File_abc/", 0755)
+	os.WriteFile("./media/files/teststruct2_File_abc/file.txt", []byte("file"), 0644)
+	os.WriteFile("./media/files/teststruct2_File_abc/file_v1.txt", []byte("v1"), 0644)
+	if files := getFixtureMediaFiles("/media/files/teststruct2_File_abc/file.txt", "teststruct2_File_"); len(files) != 2 {
+		t.Errorf("getFixtureMediaFiles didn't return the files of the upload. Got %v", files)
+	}
+	if files := getFixtureMediaFiles("/media/../file.txt", "teststruct2_File_"); len(files) != 0 {
+		t.Errorf("getFixtureMediaFiles returned a file outside media. Got %v", files)
+	}
+	os.RemoveAll("./media/files/teststruct2_File_abc/")
+	if count, err := LoadData(strings.NewReader(`[{"media":"/media/files/teststruct2_File_abc/file.txt","data":"ZmlsZQ=="}]`)); err != nil || count != 1 {
+		t.Errorf("LoadData didn't load the media file. Got %d %s", count, err)
+	}
+	if buf, _ := os.ReadFile("./media/files/teststruct2_File_abc/file.txt"); string(buf) != "file" {
+		t.Errorf("LoadData didn't write the media file. Got %s", buf)
+	}
+	os.RemoveAll("./media/files/teststruct2_File_abc/")
+
+	clean()
+}
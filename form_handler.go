@@ -18,6 +18,7 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 		Schema          ModelSchema
 		SaveAndContinue bool
 		IsUpdated       bool
+		ErrMsg          string
 		CanUpdate       bool
 		SiteName        string
 		Language        Language
@@ -28,6 +29,8 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 		Logo            string
 		FavIcon         string
 		Menu            []DashboardMenu
+		History         []RecordVersion
+		CanRestore      bool
 	}
 	var err error
 	c := Context{}
@@ -46,7 +49,6 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	ModelName := URLPath[0]
 	ModelID, _ := strconv.ParseUint(URLPath[1], 10, 64)
 	ID := uint(ModelID)
-	_ = ID
 
 	m, ok := NewModel(ModelName, false)
 	if !ok {
@@ -87,10 +89,14 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 		}
 		if r.FormValue("delete") == "delete" {
 			if InlineModelName != "" {
-				processDelete(InlineModelName, w, r, session, &user)
+				err = processDelete(InlineModelName, w, r, session, &user)
+			}
+			if err != nil {
+				c.ErrMsg = err.Error()
+			} else {
+				c.IsUpdated = true
+				http.Redirect(w, r, fmt.Sprint(RootURL+r.URL.Path), http.StatusSeeOther)
 			}
-			c.IsUpdated = true
-			http.Redirect(w, r, fmt.Sprint(RootURL+r.URL.Path), http.StatusSeeOther)
 		} else {
 			// Process the form and check for validation errors
 			m = processForm(ModelName, w, r, session, &c.Schema)
@@ -106,11 +112,13 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	}
 
 	if r.FormValue("new_url") == "" {
+		ctx, cancel := queryContext(r, FormQueryTimeout)
 		if OptimizeSQLQuery {
-			GetForm(m.Addr().Interface(), &c.Schema, "id = ?", ModelID)
+			GetFormContext(ctx, m.Addr().Interface(), &c.Schema, "id = ?", ModelID)
 		} else {
-			Get(m.Addr().Interface(), "id = ?", ModelID)
+			GetContext(ctx, m.Addr().Interface(), "id = ?", ModelID)
 		}
+		cancel()
 	}
 
 	// Return 404 incase the ID doens't exist in the DB and its not in new form
@@ -147,6 +155,14 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 		c.Menu[i].MenuName = Translate(c.Menu[i].MenuName, c.Language.Code, true)
 	}
 
+	// Get the version history of the record
+	if ModelID > 0 {
+		c.History, _ = GetRecordHistory(ModelName, ID)
+		// Restoring a version of a deleted record restores the record
+		deleted := len(c.History) != 0 && c.History[len(c.History)-1].Deleted
+		c.CanRestore = perm.Edit && (!deleted || perm.Restore)
+	}
+
 	RenderHTML(w, r, "./templates/uadmin/"+c.Schema.GetFormTheme()+"/form.html", c)
 
 	// Store Read Log in a separate go routine
@@ -154,7 +170,7 @@ func formHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 		go func() {
 			if ModelID > 0 {
 				log := &Log{}
-				log.ParseRecord(m, m.Type().Name(), uint(ModelID), &session.User, log.Action.Read(), r)
+				log.ParseRecord(m, getTypeName(m.Type()), uint(ModelID), &session.User, log.Action.Read(), r)
 				log.Save()
 			}
 		}()
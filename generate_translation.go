@@ -155,7 +155,7 @@ func syncModelTranslation(m ModelSchema) map[string]int {
 	}
 
 	// Get information about the model
-	pkgName := fmt.Sprint(reflect.TypeOf(models[m.ModelName]))
+	pkgName := fmt.Sprint(reflect.TypeOf(registeredModels()[m.ModelName]))
 	pkgName = strings.Split(pkgName, ".")[0]
 
 	// Get the model's original language file
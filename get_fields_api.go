@@ -10,7 +10,7 @@ func GetFieldsAPI(w http.ResponseWriter, r *http.Request, session *Session) {
 
 	response := []string{}
 	s := ModelSchema{}
-	for _, v := range Schema {
+	for _, v := range registeredSchema() {
 		if v.ModelName == modelName {
 			s = v
 			break
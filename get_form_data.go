@@ -102,7 +102,7 @@ func getFormData(a interface{}, r *http.Request, session *Session, s *ModelSchem
 		if f.Approval {
 			// Check if there is an approval record
 			approvals := []Approval{}
-			Filter(&approvals, "model_name = ? AND column_name = ? AND model_pk = ?", strings.ToLower(t.Name()), f.Name, ModelID64)
+			Filter(&approvals, "model_name = ? AND column_name = ? AND model_pk = ?", strings.ToLower(getTypeName(t)), f.Name, ModelID64)
 			if len(approvals) != 0 {
 
 				// Get the last approval
@@ -140,9 +140,9 @@ func getFormData(a interface{}, r *http.Request, session *Session, s *ModelSchem
 			value = fkValue
 
 			if f.LimitChoicesTo == nil {
-				fkType := t.Field(index).Type.Name()
+				fkType := getTypeName(t.Field(index).Type)
 				if t.Field(index).Type.Kind() == reflect.Ptr {
-					fkType = t.Field(index).Type.Elem().Name()
+					fkType = getTypeName(t.Field(index).Type.Elem())
 				}
 				fkList, _ := NewModelArray(strings.ToLower(fkType), false)
 				All(fkList.Addr().Interface())
@@ -176,7 +176,7 @@ func getFormData(a interface{}, r *http.Request, session *Session, s *ModelSchem
 				continue
 			}
 			fKType := reflect.TypeOf(fieldValue.Interface()).Elem()
-			m, ok := NewModelArray(strings.ToLower(fKType.Name()), false)
+			m, ok := NewModelArray(strings.ToLower(getTypeName(fKType)), false)
 
 			if !ok {
 				Trail(ERROR, "GetListSchema.NewModelArray. No model name (%s)", s.ModelName)
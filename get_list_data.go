@@ -56,17 +56,28 @@ func getListData(a interface{}, PageLength int, r *http.Request, session *Sessio
 		}
 		args = append(args, _args...)
 	}
+	// List soft deleted records in the trash view
+	if r.FormValue("trash") == "1" {
+		trashPage(o, asc, int(page-1)*PageLength, PageLength, m.Addr().Interface(), query, args...)
+		l.Count = trashCount(m.Interface(), query, args...)
+		for i := 0; i < m.Len(); i++ {
+			l.Rows = append(l.Rows, evaluateObject(m.Index(i).Interface(), t, schema, language.Code, session))
+		}
+		return
+	}
+	ctx, cancel := queryContext(r, ListQueryTimeout)
+	defer cancel()
 	if !isPager {
 		if OptimizeSQLQuery {
-			FilterList(schema, o, asc, int(page-1)*PageLength, PageLength, m.Addr().Interface(), query, args...)
+			FilterListContext(ctx, schema, o, asc, int(page-1)*PageLength, PageLength, m.Addr().Interface(), query, args...)
 		} else {
-			AdminPage(o, asc, int(page-1)*PageLength, PageLength, m.Addr().Interface(), query, args...)
+			AdminPageContext(ctx, o, asc, int(page-1)*PageLength, PageLength, m.Addr().Interface(), query, args...)
 		}
 	} else {
 		iPager.AdminPage(o, asc, int(page-1)*PageLength, PageLength, m.Addr().Interface(), query, args...)
 	}
 	if !isCounter {
-		l.Count = Count(m.Interface(), query, args...)
+		l.Count = CountContext(ctx, m.Interface(), query, args...)
 	} else {
 		l.Count = iCounter.Count(m.Interface(), query, args...)
 	}
@@ -192,9 +203,9 @@ func evaluateObject(obj interface{}, t reflect.Type, s *ModelSchema, lang string
 		} else if s.Fields[index].Type == cFK {
 			vID := value.FieldByName(field.Name + "ID")
 			cIndex, _ := vID.Interface().(uint)
-			fkFieldName := strings.ToLower(value.FieldByName(s.Fields[index].Name).Type().Name())
+			fkFieldName := strings.ToLower(getTypeName(value.FieldByName(s.Fields[index].Name).Type()))
 			if value.FieldByName(s.Fields[index].Name).Type().Kind() == reflect.Ptr {
-				fkFieldName = strings.ToLower(value.FieldByName(s.Fields[index].Name).Type().Elem().Name())
+				fkFieldName = strings.ToLower(getTypeName(value.FieldByName(s.Fields[index].Name).Type().Elem()))
 			}
 
 			// Fetch that record from DB
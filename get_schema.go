@@ -20,10 +20,18 @@ func getSchema(a interface{}) (s ModelSchema, ok bool) {
 	}
 
 	// Check if the models has been processed and return it from global schema
-	if val, ok := Schema[modelName]; ok {
+	if val, ok := registeredSchema()[modelName]; ok {
 		cpy, ok := deepCopy(val).(ModelSchema)
 		return cpy, ok
 	}
+	return buildSchema(a)
+}
+
+// buildSchema returns a new schema of a model without reading it from the
+// global schema
+func buildSchema(a interface{}) (s ModelSchema, ok bool) {
+	t := reflect.TypeOf(a)
+	modelName := getModelName(a)
 
 	if t.Kind() != reflect.Struct {
 		Trail(WARNING, string(debug.Stack()))
@@ -32,9 +40,9 @@ func getSchema(a interface{}) (s ModelSchema, ok bool) {
 	}
 
 	// Get basic information about the model
-	s.Name = t.Name()
-	s.ModelName = strings.ToLower(t.Name())
-	s.DisplayName = getDisplayName(t.Name())
+	s.Name = getTypeName(t)
+	s.ModelName = strings.ToLower(s.Name)
+	s.DisplayName = getDisplayName(s.Name)
 	s.TableName = db.Config.NamingStrategy.TableName(s.Name)
 	s.Category = func() string {
 		dashboard := DashboardMenu{}
@@ -137,6 +145,9 @@ func getSchema(a interface{}) (s ModelSchema, ok bool) {
 		_, f.WebCam = tagMap["webcam"]
 		_, f.Stringer = tagMap["stringer"]
 		_, f.Deprecated = tagMap["deprecated"]
+		_, f.Index = tagMap["index"]
+		_, f.Unique = tagMap["unique"]
+		f.UniqueTogether = tagMap["unique_together"]
 		f.Min = tagMap["min"]
 		f.Max = tagMap["max"]
 		f.Format = tagMap["format"]
@@ -167,6 +178,13 @@ func getSchema(a interface{}) (s ModelSchema, ok bool) {
 		// f.TypeName = t.Field(index).Type.Name()
 		typeName := strings.Split(t.Field(index).Type.String(), ".")
 		f.TypeName = typeName[len(typeName)-1]
+		if fieldType := t.Field(index).Type; fieldType.Kind() == reflect.Struct && fieldType.Name() == "" {
+			f.TypeName = getTypeName(fieldType)
+		} else if fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Ptr {
+			if fieldType.Elem().Kind() == reflect.Struct && fieldType.Elem().Name() == "" {
+				f.TypeName = getTypeName(fieldType.Elem())
+			}
+		}
 
 		// Process the field's data type
 		if t.Field(index).Type == SType {
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"sync"
+	"time"
 )
 
 // Constants
@@ -116,6 +118,35 @@ var ReportTimeStamp = false
 // DebugDB prints all SQL statements going to DB.
 var DebugDB = false
 
+// ReadYourWritesWindow is how long reads of a session go to the primary
+// database instead of read replicas after the session writes so users read
+// their own writes.
+var ReadYourWritesWindow = 5 * time.Second
+
+// ListQueryTimeout is the timeout of the database queries of the list view.
+// Zero means no timeout.
+var ListQueryTimeout time.Duration
+
+// FormQueryTimeout is the timeout of the database queries of the form view.
+// Zero means no timeout.
+var FormQueryTimeout time.Duration
+
+// APIQueryTimeout is the timeout of the database queries of dAPI read. Zero
+// means no timeout.
+var APIQueryTimeout time.Duration
+
+// APIWriteTimeout is the timeout of the database queries of dAPI add, edit
+// and delete. Zero means no timeout.
+var APIWriteTimeout time.Duration
+
+// QueryCacheStore stores the cached query results of models that implement
+// QueryCacher. Set it to a shared store to share the cache between servers.
+var QueryCacheStore CacheStore = NewMemoryCacheStore()
+
+// QueryCacheMemoryLimit is the maximum size in bytes of the query results
+// kept by the memory cache store.
+var QueryCacheMemoryLimit = 64 << 20
+
 // Schema is the global schema of the system.
 var Schema map[string]ModelSchema
 
@@ -226,6 +257,81 @@ var RetainMediaVersions = true
 // ApprovalHandleFunc is a function that could be called during the save process of each approval
 var ApprovalHandleFunc func(*Approval) bool
 
+// JobSchedulerInterval is how often the scheduler checks for jobs that are due
+var JobSchedulerInterval = time.Second * 10
+
+// JobTimeout is the maximum time a job can run. The job's context is canceled
+// and its lock expires after this time
+var JobTimeout = time.Hour
+
+// SQLiteVacuumSchedule is the cron spec of the job that runs VACUUM on a
+// SQLite database to reclaim the space of deleted records. It is disabled
+// if it is empty.
+var SQLiteVacuumSchedule = ""
+
+// SQLiteAnalyzeSchedule is the cron spec of the job that runs ANALYZE on a
+// SQLite database to update the statistics used to plan queries. It is
+// disabled if it is empty.
+var SQLiteAnalyzeSchedule = ""
+
+// TaskWorkers is the number of workers processing the background task queue
+var TaskWorkers = 4
+
+// TaskPollInterval is how often idle workers check the task queue
+var TaskPollInterval = time.Second
+
+// TaskMaxAttempts is the number of times a task is tried before it is
+// marked as dead
+var TaskMaxAttempts = 5
+
+// TaskRetryBackoff is the delay before retrying a failed task. It doubles
+// after every failed attempt
+var TaskRetryBackoff = time.Second * 30
+
+// TaskTimeout is the maximum time a task can run. The task's context is
+// canceled and it could be claimed by another worker after this time
+var TaskTimeout = time.Minute * 10
+
+// TaskRetention is how long succeeded and dead tasks are kept before they are
+// deleted. Zero keeps them
+var TaskRetention = time.Hour * 24 * 7
+
+// ExportMaxConcurrent is the number of exports a user can run at the same
+// time. Zero for no limit
+var ExportMaxConcurrent = 2
+
+// ExportExpiry is how long the file of a finished export can be downloaded
+var ExportExpiry = time.Hour * 24
+
+// ImportExpiry is how long an uploaded import file is kept. Files are
+// removed after they are imported
+var ImportExpiry = time.Hour * 24
+
+// ExportNotifyEmail sends an email to the user when their export is ready
+var ExportNotifyEmail = true
+
+// MigrationsPath is the folder of data migration files
+var MigrationsPath = "./migrations"
+
+// SchemaMigrationsPath is the folder of schema migration SQL files
+var SchemaMigrationsPath = "./migrations/schema"
+
+// AutoMigrateSchema migrates the schema of registered models when the
+// server starts. Columns are added and renamed but never dropped. If it is
+// false, only missing tables are created and schema changes are applied
+// using SQL files generated by "uadmin migrate make". Models in named
+// databases are always migrated when the server starts.
+var AutoMigrateSchema = true
+
+// MigrateDataOnStartup applies pending data migrations when the server
+// starts
+var MigrateDataOnStartup = true
+
+// MigrationLockTimeout is how long an instance can lock the data migrations
+// while it applies them. Other instances wait until the lock is released or
+// expires.
+var MigrationLockTimeout = time.Hour
+
 // RateLimit is the maximum number of requests/second for any unique IP
 var RateLimit int64 = 3
 
@@ -529,6 +635,11 @@ var langMapCache = map[string][]byte{}
 // Models is where we keep all registered models
 var models map[string]interface{}
 
+// modelsMutex protects models and Schema which are replaced when the models
+// of the model builder are loaded. Read them with registeredModels and
+// registeredSchema.
+var modelsMutex sync.RWMutex
+
 // Inlines is where we keep all registered models' inlines
 var inlines map[string][]interface{}
 
@@ -16,6 +16,8 @@ type GroupPermission struct {
 	Edit            bool `uadmin:"filter"`
 	Delete          bool `uadmin:"filter"`
 	Approval        bool `uadmin:"filter"`
+	Restore         bool `uadmin:"filter"`
+	Purge           bool `uadmin:"filter"`
 }
 
 func (g GroupPermission) String() string {
@@ -96,8 +96,14 @@ func Handler(f func(http.ResponseWriter, *http.Request)) func(http.ResponseWrite
 			HTTP_LOG_MSG = strings.Replace(HTTP_LOG_MSG, "%{POST}f", strings.Join(v, "&"), -1)
 		}
 
-		// Add context with stime
+		// Add context with stime and the session that reads from the read
+		// replicas
 		ctx := context.WithValue(r.Context(), CKey("start"), time.Now())
+		sessionKey := ""
+		if len(replicas) != 0 {
+			sessionKey = getSession(r)
+			ctx = withReadSession(ctx, sessionKey)
+		}
 		r = r.WithContext(ctx)
 		res := responseWriter{
 			w: w,
@@ -106,6 +112,12 @@ func Handler(f func(http.ResponseWriter, *http.Request)) func(http.ResponseWrite
 		// Execute the actual handler
 		f(&res, r)
 
+		// Reads of the session go to the primary database after requests
+		// that can write even if their writes didn't have the context
+		if r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS" {
+			markSessionWrite(sessionKey)
+		}
+
 		// add etime
 		ctx = context.WithValue(r.Context(), CKey("end"), time.Now())
 		r = r.WithContext(ctx)
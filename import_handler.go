@@ -0,0 +1,175 @@
+package uadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// importColumn is a column of an import file in the mapping page
+type importColumn struct {
+	Index  int
+	Header string
+	Sample string
+	Field  string
+}
+
+// importHandler handles the import page of a model. The user uploads an
+// excel or CSV file, maps its columns to the fields of the model and runs a
+// dry run to validate the rows before importing them.
+func importHandler(w http.ResponseWriter, r *http.Request, session *Session) {
+	//http://hostname/admin/import/?m=orders
+	r.ParseMultipartForm(32 << 20)
+	type Context struct {
+		User     string
+		SiteName string
+		Language Language
+		RootURL  string
+		Logo     string
+		FavIcon  string
+		Schema   ModelSchema
+		Fields   []F
+		Import   DataImport
+		Columns  []importColumn
+		Errors   []importError
+		ErrMsg   string
+	}
+
+	modelName := r.FormValue("m")
+	schema, ok := getSchema(modelName)
+	perm := session.User.GetAccess(modelName)
+	if !ok || (!perm.Add && !perm.Edit) {
+		pageErrorHandler(w, r, session)
+		return
+	}
+
+	c := Context{}
+	c.RootURL = RootURL
+	c.Language = getLanguage(r)
+	c.SiteName = SiteName
+	c.User = session.User.Username
+	c.Logo = Logo
+	c.FavIcon = FavIcon
+	c.Schema = schema
+	c.Fields = getImportFields(&schema)
+
+	if r.Method == cPOST {
+		if CheckCSRF(r) {
+			pageErrorHandler(w, r, session)
+			return
+		}
+
+		var rows [][]string
+		var err error
+		if _, _, fileErr := r.FormFile("file"); fileErr == nil {
+			// Upload a new file
+			c.Import, err = uploadImportFile(r, session, modelName)
+			if err == nil {
+				rows, err = readImportFile(&c.Import)
+			}
+			if err == nil && len(rows) == 0 {
+				err = fmt.Errorf("the file is empty")
+			}
+			if err == nil {
+				mapping := guessImportMapping(&schema, rows[0])
+				buf, _ := json.Marshal(mapping)
+				c.Import.Mapping = string(buf)
+				Save(&c.Import)
+			}
+		} else {
+			// Run an uploaded file
+			Get(&c.Import, "id = ? AND user_id = ? AND model_name = ?", r.FormValue("id"), session.UserID, modelName)
+			if c.Import.ID == 0 || c.Import.Status == c.Import.Status.Imported() {
+				pageErrorHandler(w, r, session)
+				return
+			}
+			if rows, err = readImportFile(&c.Import); err == nil && len(rows) != 0 {
+				mapping := make([]string, len(rows[0]))
+				for i := range mapping {
+					mapping[i] = r.FormValue(fmt.Sprintf("column_%d", i))
+				}
+				c.Errors = runImport(&c.Import, rows, mapping, r.FormValue("action") != "import", session, r)
+			}
+		}
+		if err != nil {
+			c.ErrMsg = err.Error()
+		} else {
+			c.Columns = getImportColumns(&c.Import, rows)
+		}
+	}
+
+	RenderHTML(w, r, "./templates/uadmin/"+Theme+"/import.html", c)
+}
+
+// uploadImportFile saves an uploaded import file under media and creates
+// its import
+func uploadImportFile(r *http.Request, session *Session, modelName string) (DataImport, error) {
+	d := DataImport{}
+	f, handler, err := r.FormFile("file")
+	if err != nil {
+		return d, err
+	}
+	defer f.Close()
+
+	ext := strings.ToLower(filepath.Ext(handler.Filename))
+	if ext != ".xlsx" && ext != ".csv" {
+		return d, fmt.Errorf("invalid file type %s. Upload an xlsx or csv file", ext)
+	}
+	if _, err = getExportDelimiter(r.FormValue("delimiter")); err != nil {
+		return d, err
+	}
+	if _, _, err = getExportEncoding(r.FormValue("encoding")); err != nil {
+		return d, err
+	}
+
+	importRoot := "./media/import/"
+	if _, err = os.Stat(importRoot); os.IsNotExist(err) {
+		os.MkdirAll(importRoot, 0700)
+		os.Create(importRoot + "index.html")
+	}
+	fileName := "/media/import/" + GenerateBase64(24) + ext
+	dst, err := os.Create("." + fileName)
+	if err != nil {
+		Trail(ERROR, "uploadImportFile unable to create file %s. %s", fileName, err)
+		return d, err
+	}
+	defer dst.Close()
+	if _, err = io.Copy(dst, f); err != nil {
+		return d, err
+	}
+
+	d = DataImport{
+		ModelName: modelName,
+		FileName:  fileName,
+		Delimiter: r.FormValue("delimiter"),
+		Encoding:  r.FormValue("encoding"),
+		UserID:    session.UserID,
+		Status:    DataImport{}.Status.Uploaded(),
+	}
+	return d, Save(&d)
+}
+
+// getImportColumns returns the columns of an import file with their mapped
+// fields and a sample value from the first row
+func getImportColumns(d *DataImport, rows [][]string) []importColumn {
+	if len(rows) == 0 {
+		return nil
+	}
+	mapping := []string{}
+	json.Unmarshal([]byte(d.Mapping), &mapping)
+	cols := make([]importColumn, len(rows[0]))
+	for i := range rows[0] {
+		cols[i] = importColumn{Index: i, Header: rows[0][i]}
+		if len(rows) > 1 && i < len(rows[1]) {
+			cols[i].Sample = rows[1][i]
+		}
+		if i < len(mapping) {
+			cols[i].Field = mapping[i]
+		}
+	}
+	return cols
+}
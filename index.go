@@ -0,0 +1,353 @@
+package uadmin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// modelIndex is an index of a model declared with the index, unique and
+// unique_together tags
+type modelIndex struct {
+	Name    string
+	Table   string
+	Fields  []string
+	Columns []string
+	Unique  bool
+}
+
+// getUadminTag returns the value of a tag in the uadmin tag of a field and
+// true if the field has the tag
+func getUadminTag(field reflect.StructField, name string) (string, bool) {
+	for _, tag := range strings.Split(field.Tag.Get("uadmin"), ";") {
+		tagParts := strings.SplitN(tag, ":", 2)
+		if strings.TrimSpace(tagParts[0]) != name {
+			continue
+		}
+		if len(tagParts) == 1 {
+			return "", true
+		}
+		return tagParts[1], true
+	}
+	return "", false
+}
+
+// getModelIndexes returns the indexes of a model. A field is indexed with
+// `uadmin:"index"` and unique with `uadmin:"unique"`. Fields with the same
+// group in `uadmin:"unique_together:group"` are unique together and a field
+// can be in more than one group separated by commas.
+func getModelIndexes(model interface{}) ([]modelIndex, error) {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	stmt := &gorm.Statement{DB: modelDB(model)}
+	if err := stmt.Parse(reflect.New(t).Interface()); err != nil {
+		return nil, err
+	}
+	table := stmt.Schema.Table
+
+	indexes := []modelIndex{}
+	groups := map[string]*modelIndex{}
+	groupNames := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			continue
+		}
+		_, index := getUadminTag(field, "index")
+		_, unique := getUadminTag(field, "unique")
+		together, isTogether := getUadminTag(field, "unique_together")
+		if !index && !unique && !isTogether {
+			continue
+		}
+
+		// Foreign keys are stored in the ID field of the FK
+		column := ""
+		if f := stmt.Schema.LookUpField(field.Name); f != nil && f.DBName != "" {
+			column = f.DBName
+		} else if f := stmt.Schema.LookUpField(field.Name + "ID"); f != nil && f.DBName != "" {
+			column = f.DBName
+		}
+		if column == "" {
+			return nil, fmt.Errorf("field %s of %s has no column to index", field.Name, t.Name())
+		}
+
+		if unique {
+			indexes = append(indexes, modelIndex{Name: "uix_" + table + "_" + column, Table: table, Fields: []string{field.Name}, Columns: []string{column}, Unique: true})
+		} else if index {
+			indexes = append(indexes, modelIndex{Name: "idx_" + table + "_" + column, Table: table, Fields: []string{field.Name}, Columns: []string{column}})
+		}
+		for _, group := range strings.Split(together, ",") {
+			group = strings.TrimSpace(group)
+			if !isTogether || group == "" {
+				continue
+			}
+			if _, ok := groups[group]; !ok {
+				groups[group] = &modelIndex{Name: "uix_" + table + "_" + group, Table: table, Unique: true}
+				groupNames = append(groupNames, group)
+			}
+			groups[group].Fields = append(groups[group].Fields, field.Name)
+			groups[group].Columns = append(groups[group].Columns, column)
+		}
+	}
+	sort.Strings(groupNames)
+	for _, group := range groupNames {
+		indexes = append(indexes, *groups[group])
+	}
+	return indexes, nil
+}
+
+// planIndexChanges returns the indexes of a model that are not in the
+// database
+func planIndexChanges(model interface{}) ([]SchemaChange, error) {
+	indexes, err := getModelIndexes(model)
+	if err != nil {
+		return nil, err
+	}
+	conn := modelDB(model)
+	hasTable := conn.Migrator().HasTable(model)
+	changes := []SchemaChange{}
+	for _, index := range indexes {
+		if hasTable && conn.Migrator().HasIndex(model, index.Name) {
+			continue
+		}
+		columns := []interface{}{}
+		for _, column := range index.Columns {
+			columns = append(columns, clause.Column{Name: column})
+		}
+		sql, err := dryRunSQL(func(tx *gorm.DB) error {
+			if index.Unique {
+				return tx.Exec("CREATE UNIQUE INDEX ? ON ? ?", clause.Column{Name: index.Name}, clause.Table{Name: index.Table}, columns).Error
+			}
+			return tx.Exec("CREATE INDEX ? ON ? ?", clause.Column{Name: index.Name}, clause.Table{Name: index.Table}, columns).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		change := SchemaChange{Type: CreateIndex, Table: index.Table, Index: index.Name, SQL: sql}
+		if hasTable && index.Unique {
+			if change.Conflicts, err = getIndexConflicts(conn, index); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// getIndexConflicts returns the records of a table that have the same
+// values in the columns of a unique index. Records with a NULL column
+// don't conflict.
+func getIndexConflicts(conn *gorm.DB, index modelIndex) ([]string, error) {
+	columns := []string{}
+	notNull := []string{}
+	vars := []interface{}{}
+	for _, column := range index.Columns {
+		columns = append(columns, "?")
+		notNull = append(notNull, "? IS NOT NULL")
+		vars = append(vars, clause.Column{Name: column})
+	}
+	args := append([]interface{}{}, vars...)
+	args = append(args, clause.Table{Name: index.Table})
+	args = append(args, vars...)
+	args = append(args, vars...)
+	sql := "SELECT " + strings.Join(columns, ", ") + " FROM ? WHERE " + strings.Join(notNull, " AND ") +
+		" GROUP BY " + strings.Join(columns, ", ") + " HAVING COUNT(*) > 1"
+	rows := []map[string]interface{}{}
+	if err := conn.Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	for _, row := range rows {
+		values := []string{}
+		tx := conn.Unscoped().Table(index.Table)
+		for _, column := range index.Columns {
+			value := row[column]
+			if buf, ok := value.([]byte); ok {
+				value = string(buf)
+			}
+			values = append(values, fmt.Sprintf("%s=%v", column, value))
+			tx = tx.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+		}
+		ids := []string{}
+		if err := tx.Order("id").Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s in records %s", strings.Join(values, ", "), strings.Join(ids, ", ")))
+	}
+	return conflicts, nil
+}
+
+// indexConflictError is returned when a unique index can't be created
+// because records have the same values
+type indexConflictError struct {
+	Index     string
+	Conflicts []string
+}
+
+func (e indexConflictError) Error() string {
+	return fmt.Sprintf("unable to create index %s because records have the same values: %s", e.Index, strings.Join(e.Conflicts, "; "))
+}
+
+// createIndexes creates the indexes of a model that are not in the database.
+// It returns an indexConflictError without creating the index if records
+// have the same values in a unique index.
+func createIndexes(model interface{}) error {
+	changes, err := planIndexChanges(model)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if len(c.Conflicts) != 0 {
+			return indexConflictError{Index: c.Index, Conflicts: c.Conflicts}
+		}
+		for _, sql := range c.SQL {
+			if err = modelDB(model).Exec(sql).Error; err != nil {
+				return fmt.Errorf("unable to create index %s. %s", c.Index, err)
+			}
+		}
+	}
+	return nil
+}
+
+// getUniqueValues returns the column values of the unique fields of a
+// record
+func getUniqueValues(model interface{}, record reflect.Value) map[string]interface{} {
+	for record.Kind() == reflect.Ptr {
+		record = record.Elem()
+	}
+	values := map[string]interface{}{}
+	indexes, _ := getModelIndexes(model)
+	for _, index := range indexes {
+		if !index.Unique {
+			continue
+		}
+		for i, name := range index.Fields {
+			field := record.FieldByName(name)
+			if field.Kind() == reflect.Struct || field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+				field = record.FieldByName(name + "ID")
+			}
+			if field.IsValid() {
+				values[index.Columns[i]] = field.Interface()
+			}
+		}
+	}
+	return values
+}
+
+// validateUnique returns error messages by field name for the unique fields
+// of a record whose values are used by another record. values are the column
+// values of the record and id is its ID or zero for new records. Unique
+// fields without a value in values are not checked.
+func validateUnique(model interface{}, s *ModelSchema, values map[string]interface{}, id uint) map[string]string {
+	return validateUniqueWith(modelDB(model), model, s, values, id)
+}
+
+// validateUniqueWith checks the unique fields of a record like
+// validateUnique using a connection
+func validateUniqueWith(conn *gorm.DB, model interface{}, s *ModelSchema, values map[string]interface{}, id uint) map[string]string {
+	errMap := map[string]string{}
+	indexes, err := getModelIndexes(model)
+	if err != nil {
+		Trail(ERROR, "validateUnique unable to get indexes of %s. %s", s.ModelName, err)
+		return errMap
+	}
+	for _, index := range indexes {
+		if !index.Unique {
+			continue
+		}
+		// Soft deleted records are checked because they are in the index
+		tx := conn.Unscoped().Table(index.Table)
+		checked := true
+		for _, column := range index.Columns {
+			value, ok := values[column]
+			if !ok {
+				checked = false
+				break
+			}
+			tx = tx.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+		}
+		if !checked {
+			continue
+		}
+		if id != 0 {
+			tx = tx.Where("id <> ?", id)
+		}
+		var count int64
+		if err = tx.Count(&count).Error; err != nil {
+			Trail(ERROR, "validateUnique unable to check %s. %s", index.Name, err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		names := []string{}
+		for _, name := range index.Fields {
+			names = append(names, s.FieldByName(name).DisplayName)
+		}
+		msg := fmt.Sprintf("%s already exists", strings.Join(names, " and "))
+		if len(names) > 1 {
+			msg = fmt.Sprintf("A record with the same %s already exists", strings.Join(names, " and "))
+		}
+		for _, name := range index.Fields {
+			if _, ok := errMap[name]; !ok {
+				errMap[name] = msg
+			}
+		}
+	}
+	return errMap
+}
+
+// getUniqueErrMsg returns the error messages of validateUnique in one
+// message
+func getUniqueErrMsg(errMap map[string]string) string {
+	msgs := []string{}
+	seen := map[string]bool{}
+	for _, msg := range errMap {
+		if !seen[msg] {
+			seen[msg] = true
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.Strings(msgs)
+	return strings.Join(msgs, ". ")
+}
+
+// validateEditUnique returns error messages by field name for the unique
+// fields changed by a dAPI edit of a record
+func validateEditUnique(model interface{}, s *ModelSchema, record reflect.Value, writeMap map[string]interface{}) map[string]string {
+	if GetID(record) == 0 {
+		return map[string]string{}
+	}
+	indexes, _ := getModelIndexes(model)
+	current := getUniqueValues(model, record)
+	values := map[string]interface{}{}
+	for _, index := range indexes {
+		changed := false
+		for _, column := range index.Columns {
+			if _, ok := writeMap[column]; ok {
+				changed = true
+			}
+		}
+		if !index.Unique || !changed {
+			continue
+		}
+		for _, column := range index.Columns {
+			if v, ok := writeMap[column]; ok {
+				values[column] = v
+			} else {
+				values[column] = current[column]
+			}
+		}
+	}
+	if len(values) == 0 {
+		return map[string]string{}
+	}
+	return validateUnique(model, s, values, GetID(record))
+}
@@ -0,0 +1,109 @@
+package uadmin
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type TestUniqueItem struct {
+	Model
+	Code   string `uadmin:"unique"`
+	Name   string `uadmin:"index"`
+	Branch string `uadmin:"unique_together:branch_room"`
+	Room   int    `uadmin:"unique_together:branch_room"`
+}
+
+// TestIndex is a unit testing function for index and unique tags
+func (t *UAdminTests) TestIndex() {
+	initializeDB(TestUniqueItem{})
+	defer db.Migrator().DropTable(&TestUniqueItem{})
+
+	// Indexes are created during migration
+	for _, name := range []string{"uix_test_unique_items_code", "idx_test_unique_items_name", "uix_test_unique_items_branch_room"} {
+		if !db.Migrator().HasIndex(&TestUniqueItem{}, name) {
+			t.Errorf("initializeDB didn't create index %s", name)
+		}
+	}
+	changes, err := planIndexChanges(TestUniqueItem{})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("planIndexChanges returned changes for existing indexes. %v %s", changes, err)
+	}
+	db.Exec("DROP INDEX uix_test_unique_items_branch_room")
+	changes, _ = planIndexChanges(TestUniqueItem{})
+	if len(changes) != 1 || changes[0].String() != "Create index uix_test_unique_items_branch_room on test_unique_items" ||
+		!strings.HasPrefix(changes[0].SQL[0], "CREATE UNIQUE INDEX") {
+		t.Errorf("planIndexChanges didn't plan the missing index. Got %v", changes)
+	}
+	createIndexes(TestUniqueItem{})
+
+	// The database rejects duplicates
+	item := TestUniqueItem{Code: "A1", Name: "First", Branch: "North", Room: 1}
+	Save(&item)
+	if err = db.Create(&TestUniqueItem{Code: "A1", Branch: "South", Room: 1}).Error; err == nil {
+		t.Errorf("Unique index didn't reject a duplicate code")
+	}
+	if err = db.Create(&TestUniqueItem{Code: "A2", Branch: "North", Room: 1}).Error; err == nil {
+		t.Errorf("Unique index didn't reject a duplicate branch and room")
+	}
+
+	// Duplicates are validated before writing
+	s, _ := getSchema(TestUniqueItem{})
+	errMap := validateUnique(TestUniqueItem{}, &s, getUniqueValues(TestUniqueItem{}, reflect.ValueOf(TestUniqueItem{Code: "A1", Branch: "North", Room: 1})), 0)
+	expected := map[string]string{
+		"Code":   "Code already exists",
+		"Branch": "A record with the same Branch and Room already exists",
+		"Room":   "A record with the same Branch and Room already exists",
+	}
+	if !reflect.DeepEqual(errMap, expected) {
+		t.Errorf("validateUnique returned %#v, expected %#v", errMap, expected)
+	}
+	if errMap = validateUnique(TestUniqueItem{}, &s, getUniqueValues(TestUniqueItem{}, reflect.ValueOf(item)), item.ID); len(errMap) != 0 {
+		t.Errorf("validateUnique returned errors for the values of the same record. %#v", errMap)
+	}
+
+	// Edits are only validated for changed unique fields
+	other := TestUniqueItem{Code: "B1", Branch: "South", Room: 1}
+	Save(&other)
+	errMap = validateEditUnique(TestUniqueItem{}, &s, reflect.ValueOf(other), map[string]interface{}{"room": "2"})
+	if len(errMap) != 0 {
+		t.Errorf("validateEditUnique returned errors for a valid edit. %#v", errMap)
+	}
+	errMap = validateEditUnique(TestUniqueItem{}, &s, reflect.ValueOf(other), map[string]interface{}{"branch": "North"})
+	if errMap["Branch"] == "" || errMap["Code"] != "" {
+		t.Errorf("validateEditUnique didn't return an error for a duplicate branch and room. %#v", errMap)
+	}
+	if msg := getUniqueErrMsg(errMap); msg != "A record with the same Branch and Room already exists" {
+		t.Errorf("getUniqueErrMsg returned %s", msg)
+	}
+
+	// Unique indexes are not created for records with the same values
+	db.Exec("DROP INDEX uix_test_unique_items_code")
+	duplicate := TestUniqueItem{Code: "A1", Branch: "East", Room: 1}
+	Save(&duplicate)
+	changes, err = planIndexChanges(TestUniqueItem{})
+	conflict := fmt.Sprintf("code=A1 in records %d, %d", item.ID, duplicate.ID)
+	if err != nil || len(changes) != 1 || !reflect.DeepEqual(changes[0].Conflicts, []string{conflict}) {
+		t.Errorf("planIndexChanges didn't report the conflicting records. Got %#v %s", changes, err)
+	}
+	err = createIndexes(TestUniqueItem{})
+	if _, ok := err.(indexConflictError); !ok || !strings.Contains(err.Error(), conflict) {
+		t.Errorf("createIndexes didn't fail for conflicting records. Got %v", err)
+	}
+	if db.Migrator().HasIndex(&TestUniqueItem{}, "uix_test_unique_items_code") {
+		t.Errorf("createIndexes created an index with conflicting records")
+	}
+
+	// migrate plan reports the conflicts and fails
+	modelsMutex.Lock()
+	models["testuniqueitem"] = TestUniqueItem{}
+	modelsMutex.Unlock()
+	defer func() {
+		modelsMutex.Lock()
+		delete(models, "testuniqueitem")
+		modelsMutex.Unlock()
+	}()
+	if ok, err := runCommand([]string{"migrate", "plan"}); !ok || err == nil || !strings.Contains(err.Error(), "1 unique indexes") {
+		t.Errorf("migrate plan didn't fail for conflicting records. Got %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package uadmin
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// inspectInitialisms are words that are written in upper case in Go names
+var inspectInitialisms = map[string]string{
+	"id": "ID", "url": "URL", "uri": "URI", "ip": "IP", "api": "API", "html": "HTML", "json": "JSON",
+	"xml": "XML", "uuid": "UUID", "http": "HTTP", "sql": "SQL", "otp": "OTP", "css": "CSS", "ssl": "SSL",
+}
+
+// inspectTable is the structure of a database table
+type inspectTable struct {
+	name    string
+	columns []gorm.ColumnType
+	// fks are the referenced tables of foreign key columns
+	fks map[string]string
+	// m2m is true for join tables of M2M fields
+	m2m bool
+}
+
+// inspectM2M is an M2M field of a generated model
+type inspectM2M struct {
+	joinTable string
+	table     string
+}
+
+// toGoName converts a table or column name to a Go name
+func toGoName(name string) string {
+	goName := ""
+	for _, part := range regexp.MustCompile(`[^a-zA-Z0-9]+`).Split(name, -1) {
+		if part == "" {
+			continue
+		}
+		if v, ok := inspectInitialisms[strings.ToLower(part)]; ok {
+			goName += v
+			continue
+		}
+		goName += strings.ToUpper(part[:1]) + part[1:]
+	}
+	if goName == "" || (goName[0] >= '0' && goName[0] <= '9') {
+		goName = "X" + goName
+	}
+	return goName
+}
+
+// singularize returns the singular of an English table name
+func singularize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// pluralize returns the plural of an English name
+func pluralize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "y") && !strings.HasSuffix(lower, "ay") && !strings.HasSuffix(lower, "ey") && !strings.HasSuffix(lower, "oy"):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	}
+	return name + "s"
+}
+
+// getInspectTables reads the structure of tables in the database. All the
+// tables except the tables of registered models are read if names is empty.
+func getInspectTables(names []string) (map[string]*inspectTable, []string, error) {
+	allTables, err := db.Migrator().GetTables()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(allTables)
+	registered := map[string]bool{}
+	for _, m := range registeredModels() {
+		stmt := &gorm.Statement{DB: db}
+		if stmt.Parse(m) == nil {
+			registered[stmt.Schema.Table] = true
+		}
+	}
+
+	if len(names) == 0 {
+		for _, table := range allTables {
+			if !registered[table] && !strings.HasPrefix(table, "sqlite_") && !strings.HasSuffix(table, "__temp") {
+				names = append(names, table)
+			}
+		}
+	}
+
+	tables := map[string]*inspectTable{}
+	for _, name := range names {
+		if !db.Migrator().HasTable(name) {
+			return nil, nil, fmt.Errorf("table not found: %s", name)
+		}
+		columns, err := db.Migrator().ColumnTypes(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read the columns of %s. %s", name, err)
+		}
+		t := &inspectTable{name: name, columns: columns, fks: map[string]string{}}
+
+		// Foreign key constraints
+		fks := []struct {
+			ColumnName      string
+			ReferencedTable string
+		}{}
+		if err = db.Raw(sqlDialect[Database.Type]["foreignKeys"], name).Scan(&fks).Error; err != nil {
+			Trail(WARNING, "InspectDB unable to read the foreign keys of %s. %s", name, err)
+		}
+		for _, fk := range fks {
+			t.fks[fk.ColumnName] = fk.ReferencedTable
+		}
+
+		// Columns named like a table with an _id suffix
+		for _, c := range columns {
+			if _, ok := t.fks[c.Name()]; ok || !strings.HasSuffix(strings.ToLower(c.Name()), "_id") {
+				continue
+			}
+			if ref := guessReferencedTable(strings.TrimSuffix(strings.ToLower(c.Name()), "_id"), allTables); ref != "" {
+				t.fks[c.Name()] = ref
+			}
+		}
+		tables[name] = t
+	}
+
+	// Join tables have two foreign keys and no other columns except an ID
+	for _, t := range tables {
+		others := 0
+		for _, c := range t.columns {
+			if _, ok := t.fks[c.Name()]; !ok && c.Name() != "id" {
+				others++
+			}
+		}
+		t.m2m = len(t.fks) == 2 && others == 0
+	}
+	return tables, names, nil
+}
+
+// guessReferencedTable returns the table that a column named base_id refers
+// to. The table can have a prefix like legacy_base or be plural.
+func guessReferencedTable(base string, tables []string) string {
+	candidates := []string{base, pluralize(base)}
+	for _, c := range candidates {
+		for _, table := range tables {
+			if strings.ToLower(table) == c {
+				return table
+			}
+		}
+	}
+	found := ""
+	for _, c := range candidates {
+		for _, table := range tables {
+			if strings.HasSuffix(strings.ToLower(table), "_"+c) {
+				if found != "" && found != table {
+					// Ambiguous
+					return ""
+				}
+				found = table
+			}
+		}
+	}
+	return found
+}
+
+// getInspectGoType returns the Go type of a column
+func getInspectGoType(c gorm.ColumnType) string {
+	dbType := strings.ToLower(c.DatabaseTypeName())
+	fullType, _ := c.ColumnType()
+	fullType = strings.ToLower(fullType)
+	nullable, _ := c.Nullable()
+	unsigned := strings.Contains(fullType, "unsigned")
+
+	switch {
+	case strings.HasPrefix(fullType, "tinyint(1)") || dbType == "bool" || dbType == "boolean":
+		return "bool"
+	case strings.Contains(dbType, "int") || dbType == "serial" || dbType == "bigserial":
+		if unsigned {
+			return "uint"
+		}
+		return "int"
+	case strings.Contains(dbType, "float") || strings.Contains(dbType, "double") || dbType == "real" ||
+		strings.Contains(dbType, "numeric") || strings.Contains(dbType, "decimal") || dbType == "money":
+		return "float64"
+	case strings.Contains(dbType, "date") || strings.Contains(dbType, "time"):
+		if nullable {
+			return "*time.Time"
+		}
+		return "time.Time"
+	case strings.Contains(dbType, "blob") || strings.Contains(dbType, "binary") || dbType == "bytea":
+		return "[]byte"
+	}
+	return "string"
+}
+
+// getInspectDefault returns the default value of a column without quotes.
+// Defaults that are SQL expressions are ignored.
+func getInspectDefault(c gorm.ColumnType) string {
+	value, ok := c.DefaultValue()
+	if !ok {
+		return ""
+	}
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "::"); i != -1 {
+		// PostgreSQL casts like 'abc'::character varying
+		value = value[:i]
+	}
+	if len(value) > 1 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+	if strings.EqualFold(value, "null") || strings.ContainsAny(value, "()") || strings.HasPrefix(strings.ToUpper(value), "CURRENT_") {
+		return ""
+	}
+	return value
+}
+
+// getInspectTags returns the gorm and uadmin tags of a column
+func getInspectTags(c gorm.ColumnType, fieldName string, goType string) string {
+	gormTags := []string{}
+	uadminTags := []string{}
+	if db.Config.NamingStrategy.ColumnName("", fieldName) != c.Name() {
+		gormTags = append(gormTags, "column:"+c.Name())
+	}
+
+	nullable, _ := c.Nullable()
+	defaultValue := getInspectDefault(c)
+	if goType == "string" {
+		if length, ok := c.Length(); ok && length > 0 && length < 65535 {
+			gormTags = append(gormTags, fmt.Sprintf("size:%d", length))
+			uadminTags = append(uadminTags, fmt.Sprintf("pattern:^(.|\\\\n){0,%d}$", length), fmt.Sprintf("pattern_msg:Maximum length is %d", length))
+		}
+	}
+	if goType == "bool" {
+		// MySQL and SQLite store booleans as 0 and 1
+		switch strings.ToLower(defaultValue) {
+		case "1", "true":
+			defaultValue = "true"
+		case "0", "false":
+			defaultValue = "false"
+		}
+	}
+	if defaultValue != "" {
+		gormTags = append(gormTags, "default:"+strings.Replace(defaultValue, ";", "\\\\;", -1))
+		uadminTags = append(uadminTags, "default_value:"+strings.Replace(defaultValue, ";", "\\\\;", -1))
+	}
+	if !nullable && defaultValue == "" && goType != "bool" {
+		uadminTags = append([]string{"required"}, uadminTags...)
+	}
+	if fullType, _ := c.ColumnType(); goType == "uint" && strings.Contains(strings.ToLower(fullType), "unsigned") {
+		uadminTags = append(uadminTags, "min:0")
+	}
+	if goType == "int" || goType == "uint" {
+		dbType := strings.ToLower(c.DatabaseTypeName())
+		unsigned := goType == "uint"
+		if dbType == "tinyint" {
+			uadminTags = append(uadminTags, map[bool]string{true: "max:255", false: "min:-128;max:127"}[unsigned])
+		} else if dbType == "smallint" {
+			uadminTags = append(uadminTags, map[bool]string{true: "max:65535", false: "min:-32768;max:32767"}[unsigned])
+		}
+	}
+
+	tags := []string{}
+	if len(gormTags) != 0 {
+		tags = append(tags, fmt.Sprintf(`gorm:"%s"`, strings.Join(gormTags, ";")))
+	}
+	if len(uadminTags) != 0 {
+		tags = append(tags, fmt.Sprintf(`uadmin:"%s"`, strings.Join(uadminTags, ";")))
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return "`" + strings.Join(tags, " ") + "`"
+}
+
+// InspectDB writes Go models for tables in the database to w. The models
+// embed uadmin.Model and have fields for the columns of the tables with
+// foreign keys, M2M fields of join tables and uadmin tags for required
+// columns, the maximum length of strings and default values. All the tables
+// except the tables of registered models are inspected if tables is empty.
+func InspectDB(w io.Writer, packageName string, tables []string) error {
+	inspected, names, err := getInspectTables(tables)
+	if err != nil {
+		return err
+	}
+	uadminPkg := reflect.TypeOf(Model{}).PkgPath()
+
+	// Types of tables
+	typeNames := map[string]string{}
+	for _, name := range names {
+		typeNames[name] = toGoName(singularize(name))
+	}
+	for _, m := range registeredModels() {
+		stmt := &gorm.Statement{DB: db}
+		if stmt.Parse(m) == nil {
+			t := reflect.TypeOf(m)
+			typeNames[stmt.Schema.Table] = t.Name()
+			if t.PkgPath() == uadminPkg {
+				typeNames[stmt.Schema.Table] = "uadmin." + t.Name()
+			}
+		}
+	}
+
+	// M2M fields are added to the model of the first foreign key of a
+	// join table
+	m2m := map[string][]inspectM2M{}
+	for _, name := range names {
+		t := inspected[name]
+		if !t.m2m {
+			continue
+		}
+		refs := []string{}
+		for _, c := range t.columns {
+			if ref, ok := t.fks[c.Name()]; ok {
+				refs = append(refs, ref)
+			}
+		}
+		if _, ok := inspected[refs[0]]; ok && !inspected[refs[0]].m2m {
+			m2m[refs[0]] = append(m2m[refs[0]], inspectM2M{joinTable: name, table: refs[1]})
+		} else if _, ok := inspected[refs[1]]; ok && !inspected[refs[1]].m2m {
+			m2m[refs[1]] = append(m2m[refs[1]], inspectM2M{joinTable: name, table: refs[0]})
+		} else {
+			// The join table is generated as a model if its tables are not
+			// inspected
+			t.m2m = false
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	modelNames := []string{}
+	for _, name := range names {
+		t := inspected[name]
+		if t.m2m {
+			continue
+		}
+		typeName := typeNames[name]
+		modelNames = append(modelNames, typeName)
+
+		fmt.Fprintf(buf, "// %s is a model of the %s table\n", typeName, name)
+		fmt.Fprintf(buf, "type %s struct {\n\tuadmin.Model\n", typeName)
+		hasID := false
+		for _, c := range t.columns {
+			if c.Name() == "id" {
+				hasID = true
+				continue
+			}
+			if c.Name() == "deleted_at" {
+				continue
+			}
+			goType := getInspectGoType(c)
+			if ref, ok := t.fks[c.Name()]; ok {
+				fieldName := toGoName(strings.TrimSuffix(strings.TrimSuffix(c.Name(), "_id"), "_ID"))
+				if refType, ok := typeNames[ref]; ok {
+					fmt.Fprintf(buf, "\t%s %s\n", fieldName, refType)
+					fmt.Fprintf(buf, "\t%sID uint %s\n", fieldName, getInspectTags(c, fieldName+"ID", "uint"))
+					continue
+				}
+				fmt.Fprintf(buf, "\t// %s refers to %s which is not inspected\n", fieldName+"ID", ref)
+				fmt.Fprintf(buf, "\t%sID uint %s\n", fieldName, getInspectTags(c, fieldName+"ID", "uint"))
+				continue
+			}
+			fieldName := toGoName(c.Name())
+			if pk, _ := c.PrimaryKey(); pk {
+				fmt.Fprintf(buf, "\t// %s is the primary key of the table. uAdmin models use an integer id\n", fieldName)
+			}
+			fmt.Fprintf(buf, "\t%s %s %s\n", fieldName, goType, getInspectTags(c, fieldName, goType))
+		}
+		for _, f := range m2m[name] {
+			refType := typeNames[f.table]
+			fieldName := pluralize(strings.TrimPrefix(refType, "uadmin."))
+			expected := strings.ToLower(typeName) + "_" + strings.ToLower(strings.TrimPrefix(refType, "uadmin."))
+			if f.joinTable != expected {
+				fmt.Fprintf(buf, "\t// %s is stored in %s. uAdmin stores M2M fields in %s with table1_id and table2_id columns\n", fieldName, f.joinTable, expected)
+			}
+			fmt.Fprintf(buf, "\t%s []%s\n", fieldName, refType)
+		}
+		if !hasID {
+			fmt.Fprintf(buf, "\t// The table does not have an id column\n")
+		}
+		fmt.Fprintf(buf, "}\n\n")
+
+		if db.Config.NamingStrategy.TableName(typeName) != name {
+			fmt.Fprintf(buf, "// TableName returns the table name of %s\n", typeName)
+			fmt.Fprintf(buf, "func (%s) TableName() string {\n\treturn %q\n}\n\n", typeName, name)
+		}
+	}
+
+	fmt.Fprintf(buf, "// Models are the inspected models to pass to uadmin.Register\n")
+	fmt.Fprintf(buf, "var Models = []interface{}{\n")
+	for _, name := range modelNames {
+		fmt.Fprintf(buf, "\t%s{},\n", name)
+	}
+	fmt.Fprintf(buf, "}\n")
+
+	header := &bytes.Buffer{}
+	fmt.Fprintf(header, "// Code generated by uadmin inspectdb. Review the models before using them.\n\n")
+	fmt.Fprintf(header, "package %s\n\n", packageName)
+	if bytes.Contains(buf.Bytes(), []byte("time.Time")) {
+		fmt.Fprintf(header, "import (\n\t\"time\"\n\n\t%q\n)\n\n", uadminPkg)
+	} else {
+		fmt.Fprintf(header, "import %q\n\n", uadminPkg)
+	}
+
+	src, err := format.Source(append(header.Bytes(), buf.Bytes()...))
+	if err != nil {
+		return fmt.Errorf("unable to format the generated code. %s", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// inspectDBCommand runs the inspectdb command:
+//
+//	inspectdb [TABLE...] [--package NAME] [--output FILE]
+func inspectDBCommand(args []string) error {
+	flags, tables, err := parseCommandFlags(args)
+	if err != nil {
+		return err
+	}
+	packageName := flags["package"]
+	if packageName == "" {
+		packageName = "models"
+	}
+	output := flags["output"]
+	if output == "" {
+		output = "inspectdb.go"
+	}
+	buf := &bytes.Buffer{}
+	if err = InspectDB(buf, packageName, tables); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Generated models in", output)
+	return nil
+}
@@ -0,0 +1,72 @@
+package uadmin
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// TestInspectDB is a unit testing function for generating models from
+// database tables
+func (t *UAdminTests) TestInspectDB() {
+	tables := []string{"legacy_order_tags", "legacy_tag", "legacy_orders", "legacy_customers"}
+	drop := func() {
+		for _, table := range tables {
+			db.Exec("DROP TABLE IF EXISTS " + table)
+		}
+	}
+	drop()
+	defer drop()
+
+	statements := []string{
+		"CREATE TABLE legacy_customers (id INTEGER PRIMARY KEY, full_name VARCHAR(100) NOT NULL, email VARCHAR(255), vip BOOLEAN NOT NULL DEFAULT false, country VARCHAR(2) NOT NULL DEFAULT 'JO')",
+		"CREATE TABLE legacy_orders (id INTEGER PRIMARY KEY, customer_id INTEGER NOT NULL REFERENCES legacy_customers(id), total DECIMAL(10,2) NOT NULL, notes TEXT, shipped_at TIMESTAMP NULL)",
+		"CREATE TABLE legacy_tag (id INTEGER PRIMARY KEY, name VARCHAR(50) NOT NULL)",
+		"CREATE TABLE legacy_order_tags (order_id INTEGER NOT NULL REFERENCES legacy_orders(id), tag_id INTEGER NOT NULL REFERENCES legacy_tag(id), PRIMARY KEY (order_id, tag_id))",
+	}
+	for _, sql := range statements {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Errorf("TestInspectDB unable to create a table. %s", err)
+			return
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := InspectDB(buf, "models", []string{"legacy_customers", "legacy_orders", "legacy_tag", "legacy_order_tags"}); err != nil {
+		t.Errorf("InspectDB returned an error. %s", err)
+		return
+	}
+	src := buf.String()
+	if _, err := parser.ParseFile(token.NewFileSet(), "models.go", src, 0); err != nil {
+		t.Errorf("InspectDB generated invalid code. %s\n%s", err, src)
+	}
+
+	examples := []string{
+		"package models",
+		"type LegacyCustomer struct {\n\tuadmin.Model\n",
+		"FullName string `gorm:\"size:100\" uadmin:\"required;pattern:^(.|\\\\n){0,100}$;pattern_msg:Maximum length is 100\"`",
+		"default:JO",
+		"default_value:false",
+		"Customer   LegacyCustomer\n",
+		"CustomerID uint",
+		"Total      float64 `uadmin:\"required\"`",
+		"ShippedAt  *time.Time",
+		"LegacyTags []LegacyTag",
+		"// LegacyTags is stored in legacy_order_tags.",
+		"func (LegacyTag) TableName() string {\n\treturn \"legacy_tag\"\n}",
+		"var Models = []interface{}{\n\tLegacyCustomer{},\n\tLegacyOrder{},\n\tLegacyTag{},\n}",
+	}
+	for _, e := range examples {
+		if !strings.Contains(src, e) {
+			t.Errorf("InspectDB didn't generate %q. Got\n%s", e, src)
+		}
+	}
+	if strings.Contains(src, "type LegacyOrderTag struct") {
+		t.Errorf("InspectDB generated a model for the join table legacy_order_tags")
+	}
+
+	if err := InspectDB(buf, "models", []string{"legacy_missing"}); err == nil {
+		t.Errorf("InspectDB didn't return an error for a missing table")
+	}
+}
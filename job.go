@@ -0,0 +1,317 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// JobStatus is the status of a job run
+type JobStatus int
+
+// Running is a job run that did not finish yet
+func (JobStatus) Running() JobStatus {
+	return 1
+}
+
+// Succeeded is a job run that finished without an error
+func (JobStatus) Succeeded() JobStatus {
+	return 2
+}
+
+// Failed is a job run that returned an error
+func (JobStatus) Failed() JobStatus {
+	return 3
+}
+
+// Job is a model that stores the schedule and state of a job registered
+// using RegisterJob
+type Job struct {
+	Model
+	Name         string     `uadmin:"read_only;search"`
+	CronSpec     string     `uadmin:"read_only"`
+	Paused       bool       `uadmin:"filter"`
+	RunNow       bool       `uadmin:"help:Run the job as soon as possible"`
+	NextRun      *time.Time `uadmin:"read_only"`
+	LastRun      *time.Time `uadmin:"read_only"`
+	LastStatus   JobStatus  `uadmin:"read_only;filter"`
+	LastDuration string     `uadmin:"read_only"`
+	LastError    string     `uadmin:"read_only;list_exclude"`
+	LockedBy     string     `uadmin:"read_only;list_exclude"`
+	LockedUntil  *time.Time `uadmin:"read_only;list_exclude"`
+}
+
+func (j Job) String() string {
+	return j.Name
+}
+
+// Save overides save to only store the fields that can be changed by users
+// so it doesn't overwrite the state of a running job
+func (j *Job) Save() {
+	if j.ID == 0 {
+		Save(j)
+		return
+	}
+	old := Job{}
+	Get(&old, "id = ?", j.ID)
+	updates := map[string]interface{}{
+		"paused":  j.Paused,
+		"run_now": j.RunNow,
+	}
+	// Skip the runs that were missed while the job was paused
+	if old.Paused && !j.Paused {
+		if rj := getRegisteredJob(j.Name); rj != nil {
+			updates["next_run"] = rj.schedule.Next(time.Now())
+		}
+	}
+	if err := db.Model(&Job{}).Where("id = ?", j.ID).Updates(updates).Error; err != nil {
+		Trail(ERROR, "Job.Save unable to save %s. %s", j.Name, err)
+	}
+}
+
+// JobRun is a model that stores a run of a job
+type JobRun struct {
+	Model
+	Job        Job `uadmin:"read_only;filter"`
+	JobID      uint
+	Instance   string     `uadmin:"read_only"`
+	StartedAt  time.Time  `uadmin:"read_only"`
+	FinishedAt *time.Time `uadmin:"read_only"`
+	Duration   string     `uadmin:"read_only"`
+	Status     JobStatus  `uadmin:"read_only;filter"`
+	Error      string     `uadmin:"read_only;list_exclude"`
+}
+
+func (j JobRun) String() string {
+	return fmt.Sprintf("%s %s", j.Job.Name, j.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (JobRun) HideInDashboard() bool {
+	return true
+}
+
+type registeredJob struct {
+	name     string
+	spec     string
+	schedule *cronSchedule
+	f        func(context.Context) error
+}
+
+var registeredJobs = map[string]*registeredJob{}
+var registeredJobsMutex = sync.Mutex{}
+
+// jobInstance identifies this instance when it locks a job
+var jobInstance = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), GenerateBase32(8))
+}()
+
+// RegisterJob registers a function to run on a schedule. The schedule is a
+// cron spec with five fields (minute hour day-of-month month day-of-week),
+// a descriptor like @daily or a fixed interval like "@every 15m". Jobs are
+// stored in the database and locked while they run so only one instance runs
+// a job when there are multiple instances of the application. The context
+// is canceled after JobTimeout.
+//
+//	uadmin.RegisterJob("cleanup", "0 2 * * *", func(ctx context.Context) error {
+//		return uadmin.DeleteList(&[]Session{}, "active = ?", false)
+//	})
+func RegisterJob(name string, cronSpec string, f func(ctx context.Context) error) error {
+	schedule, err := parseCronSpec(cronSpec)
+	if err != nil {
+		Trail(ERROR, "RegisterJob unable to register %s. %s", name, err)
+		return err
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		err = fmt.Errorf("cron spec never matches: %s", cronSpec)
+		Trail(ERROR, "RegisterJob unable to register %s. %s", name, err)
+		return err
+	}
+	rj := &registeredJob{
+		name:     name,
+		spec:     cronSpec,
+		schedule: schedule,
+		f:        f,
+	}
+	registeredJobsMutex.Lock()
+	registeredJobs[name] = rj
+	registeredJobsMutex.Unlock()
+
+	if dbOK {
+		syncJob(rj)
+	}
+	return nil
+}
+
+// RunJobNow runs a job as soon as possible even if it is paused
+func RunJobNow(name string) error {
+	return updateJob(name, "run_now", true)
+}
+
+// PauseJob stops the scheduled runs of a job
+func PauseJob(name string) error {
+	return updateJob(name, "paused", true)
+}
+
+// ResumeJob resumes the scheduled runs of a paused job
+func ResumeJob(name string) error {
+	job := Job{}
+	Get(&job, "name = ?", name)
+	if job.ID == 0 {
+		return fmt.Errorf("job %s is not registered", name)
+	}
+	job.Paused = false
+	job.Save()
+	return nil
+}
+
+func updateJob(name string, column string, value interface{}) error {
+	result := db.Model(&Job{}).Where("name = ?", name).Update(column, value)
+	if result.Error != nil {
+		Trail(ERROR, "Unable to update job %s. %s", name, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("job %s is not registered", name)
+	}
+	return nil
+}
+
+func getRegisteredJob(name string) *registeredJob {
+	registeredJobsMutex.Lock()
+	defer registeredJobsMutex.Unlock()
+	return registeredJobs[name]
+}
+
+// nextRun returns the next time the job runs after t or nil if its schedule
+// doesn't match any time
+func (rj *registeredJob) nextRun(t time.Time) *time.Time {
+	next := rj.schedule.Next(t)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// unregisterJob removes a registered job so it doesn't run anymore
+func unregisterJob(name string) {
+	registeredJobsMutex.Lock()
+	defer registeredJobsMutex.Unlock()
+	delete(registeredJobs, name)
+}
+
+// syncJob creates or updates the job record of a registered job
+func syncJob(rj *registeredJob) Job {
+	job := Job{}
+	Get(&job, "name = ?", rj.name)
+	if job.ID == 0 || job.CronSpec != rj.spec {
+		job.Name = rj.name
+		job.CronSpec = rj.spec
+		job.NextRun = rj.nextRun(time.Now())
+		if job.ID == 0 {
+			Save(&job)
+		} else {
+			db.Model(&Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+				"cron_spec": job.CronSpec,
+				"next_run":  job.NextRun,
+			})
+		}
+	}
+	return job
+}
+
+func jobService() {
+	for {
+		runDueJobs()
+		time.Sleep(JobSchedulerInterval)
+	}
+}
+
+// runDueJobs starts the registered jobs that are due and not locked by
+// another instance
+func runDueJobs() {
+	registeredJobsMutex.Lock()
+	rjs := []*registeredJob{}
+	for _, rj := range registeredJobs {
+		rjs = append(rjs, rj)
+	}
+	registeredJobsMutex.Unlock()
+
+	now := time.Now()
+	for _, rj := range rjs {
+		job := syncJob(rj)
+		due := job.RunNow || (!job.Paused && job.NextRun != nil && !job.NextRun.IsZero() && !now.Before(*job.NextRun))
+		if !due || !lockJob(&job) {
+			continue
+		}
+		go runJob(rj, job)
+	}
+}
+
+// lockJob locks a job in the database for this instance. It returns false if
+// the job is locked by another instance.
+func lockJob(job *Job) bool {
+	now := time.Now()
+	lockedUntil := now.Add(JobTimeout)
+	result := db.Model(&Job{}).Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", job.ID, now).Updates(map[string]interface{}{
+		"locked_by":    jobInstance,
+		"locked_until": lockedUntil,
+	})
+	if result.Error != nil {
+		Trail(ERROR, "Unable to lock job %s. %s", job.Name, result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// runJob runs a locked job, stores the run and schedules the next run
+func runJob(rj *registeredJob, job Job) JobRun {
+	run := JobRun{
+		JobID:     job.ID,
+		Instance:  jobInstance,
+		StartedAt: time.Now(),
+		Status:    JobStatus(0).Running(),
+	}
+	Save(&run)
+
+	ctx, cancel := context.WithTimeout(context.Background(), JobTimeout)
+	defer cancel()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return rj.f(ctx)
+	}()
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Duration = finishedAt.Sub(run.StartedAt).Round(time.Millisecond).String()
+	run.Status = run.Status.Succeeded()
+	if err != nil {
+		run.Status = run.Status.Failed()
+		run.Error = err.Error()
+		Trail(ERROR, "Job %s failed. %s", job.Name, err)
+	}
+	Save(&run)
+
+	err = db.Model(&Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"run_now":       false,
+		"next_run":      rj.nextRun(finishedAt),
+		"last_run":      run.StartedAt,
+		"last_status":   run.Status,
+		"last_duration": run.Duration,
+		"last_error":    run.Error,
+		"locked_by":     "",
+		"locked_until":  nil,
+	}).Error
+	if err != nil {
+		Trail(ERROR, "Unable to update job %s. %s", job.Name, err)
+	}
+	return run
+}
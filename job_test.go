@@ -0,0 +1,160 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TestCronSpec is a unit testing function for parseCronSpec() and
+// cronSchedule.Next()
+func (t *UAdminTests) TestCronSpec() {
+	// Monday 2024-01-01 10:15:30
+	now := time.Date(2024, 1, 1, 10, 15, 30, 0, time.UTC)
+	examples := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 1, 10, 16, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, 1, 1, 10, 20, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC)},
+		{"30 9-17 * * 1-5", time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2024, 1, 1, 11, 45, 30, 0, time.UTC)},
+	}
+	for _, e := range examples {
+		s, err := parseCronSpec(e.spec)
+		if err != nil {
+			t.Errorf("parseCronSpec returned an error for %q. %s", e.spec, err)
+			continue
+		}
+		if next := s.Next(now); !next.Equal(e.next) {
+			t.Errorf("cronSchedule.Next returned invalid time for %q. Got %s expected %s", e.spec, next, e.next)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *", "@every 1ms"} {
+		if _, err := parseCronSpec(spec); err == nil {
+			t.Errorf("parseCronSpec didn't return an error for %q", spec)
+		}
+	}
+}
+
+// TestJob is a unit testing function for RegisterJob() and the job scheduler
+func (t *UAdminTests) TestJob() {
+	runs := make(chan string, 10)
+	fail := false
+	err := RegisterJob("test-job", "@every 1h", func(ctx context.Context) error {
+		runs <- "run"
+		if fail {
+			return fmt.Errorf("job failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("RegisterJob returned an error. %s", err)
+	}
+	defer func() {
+		registeredJobsMutex.Lock()
+		delete(registeredJobs, "test-job")
+		registeredJobsMutex.Unlock()
+	}()
+	if err = RegisterJob("test-invalid-job", "invalid", nil); err == nil {
+		t.Errorf("RegisterJob didn't return an error for an invalid cron spec")
+	}
+	if err = RegisterJob("test-never-job", "0 0 30 2 *", nil); err == nil || getRegisteredJob("test-never-job") != nil {
+		t.Errorf("RegisterJob didn't return an error for a cron spec that never matches")
+	}
+
+	job := Job{}
+	Get(&job, "name = ?", "test-job")
+	if job.ID == 0 || job.CronSpec != "@every 1h" || job.NextRun == nil || job.NextRun.Before(time.Now().Add(time.Minute*59)) {
+		t.Errorf("RegisterJob didn't store the job. Got %#v", job)
+	}
+
+	waitForRun := func() JobRun {
+		select {
+		case <-runs:
+		case <-time.After(time.Second * 5):
+			t.Errorf("Job didn't run")
+			return JobRun{}
+		}
+		run := JobRun{}
+		for i := 0; i < 50; i++ {
+			Get(&run, "job_id = ? AND status <> ?", job.ID, run.Status.Running())
+			if run.ID != 0 {
+				break
+			}
+			time.Sleep(time.Millisecond * 100)
+		}
+		return run
+	}
+
+	// The job is not due
+	runDueJobs()
+	select {
+	case <-runs:
+		t.Errorf("runDueJobs ran a job that is not due")
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	// Run now
+	RunJobNow("test-job")
+	runDueJobs()
+	run := waitForRun()
+	if run.Status != run.Status.Succeeded() || run.FinishedAt == nil || run.Duration == "" {
+		t.Errorf("runJob didn't store a successful run. Got %#v", run)
+	}
+	Get(&job, "id = ?", job.ID)
+	if job.RunNow || job.LastStatus != job.LastStatus.Succeeded() || job.LockedUntil != nil || job.LastRun == nil {
+		t.Errorf("runJob didn't update the job. Got %#v", job)
+	}
+
+	// A job locked by another instance doesn't run
+	lockedUntil := time.Now().Add(time.Minute)
+	db.Model(&Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{"locked_by": "other", "locked_until": lockedUntil, "run_now": true})
+	runDueJobs()
+	select {
+	case <-runs:
+		t.Errorf("runDueJobs ran a job locked by another instance")
+	case <-time.After(time.Millisecond * 200):
+	}
+	db.Model(&Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{"locked_by": "", "locked_until": nil, "run_now": false})
+
+	// Paused jobs don't run on schedule
+	PauseJob("test-job")
+	db.Model(&Job{}).Where("id = ?", job.ID).Update("next_run", time.Now().Add(-time.Minute))
+	runDueJobs()
+	select {
+	case <-runs:
+		t.Errorf("runDueJobs ran a paused job")
+	case <-time.After(time.Millisecond * 200):
+	}
+	ResumeJob("test-job")
+	Get(&job, "id = ?", job.ID)
+	if job.Paused || job.NextRun == nil || job.NextRun.Before(time.Now()) {
+		t.Errorf("ResumeJob didn't resume the job. Got %#v", job)
+	}
+
+	// Failed run
+	fail = true
+	DeleteList(&[]JobRun{}, "job_id = ?", job.ID)
+	RunJobNow("test-job")
+	runDueJobs()
+	run = waitForRun()
+	if run.Status != run.Status.Failed() || run.Error != "job failed" {
+		t.Errorf("runJob didn't store a failed run. Got %#v", run)
+	}
+	Get(&job, "id = ?", job.ID)
+	if job.LastStatus != job.LastStatus.Failed() || job.LastError != "job failed" {
+		t.Errorf("runJob didn't update the failed job. Got %#v", job)
+	}
+
+	DeleteList(&[]JobRun{}, "job_id = ?", job.ID)
+	Delete(job)
+}
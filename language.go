@@ -22,6 +22,7 @@ func (l Language) String() string {
 
 // Save !
 func (l *Language) Save() {
+	schemas := registeredSchema()
 	if l.Default {
 		Update([]Language{}, "default", false, "`default` = ?", true)
 		DefaultLang = *l
@@ -41,10 +42,10 @@ func (l *Language) Save() {
 		})
 	}
 
-	for modelName := range Schema {
-		for i := range Schema[modelName].Fields {
-			if Schema[modelName].Fields[i].Type == cMULTILINGUAL || Schema[modelName].Fields[i].Type == cHTML_MULTILINGUAL {
-				Schema[modelName].Fields[i].Translations = tanslationList
+	for modelName := range schemas {
+		for i := range schemas[modelName].Fields {
+			if schemas[modelName].Fields[i].Type == cMULTILINGUAL || schemas[modelName].Fields[i].Type == cHTML_MULTILINGUAL {
+				schemas[modelName].Fields[i].Translations = tanslationList
 			}
 		}
 	}
@@ -16,8 +16,12 @@ func listHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 		Data           *listData
 		Schema         ModelSchema
 		IsUpdated      bool
+		ErrMsg         string
 		CanAdd         bool
 		CanDelete      bool
+		CanRestore     bool
+		CanPurge       bool
+		Trash          bool
 		HasAccess      bool
 		SiteName       string
 		Language       Language
@@ -53,6 +57,15 @@ func listHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	c.HasAccess = perm.Read
 	c.CanAdd = perm.Add
 	c.CanDelete = perm.Delete
+	c.CanRestore = perm.Restore
+	c.CanPurge = perm.Purge
+	c.Trash = r.FormValue("trash") == "1"
+
+	// Only users who can restore or purge records can see the trash
+	if c.Trash && !c.CanRestore && !c.CanPurge {
+		pageErrorHandler(w, r, session)
+		return
+	}
 
 	// Initialize the schema
 	m, ok := NewModel(ModelName, false)
@@ -66,9 +79,17 @@ func listHandler(w http.ResponseWriter, r *http.Request, session *Session) {
 	// Process delete
 	if r.Method == cPOST {
 		if r.FormValue("delete") == "delete" {
-			processDelete(ModelName, w, r, session, &user)
+			if err := processDelete(ModelName, w, r, session, &user); err != nil {
+				c.ErrMsg = err.Error()
+			} else {
+				c.IsUpdated = true
+				http.Redirect(w, r, fmt.Sprint(RootURL+r.URL.Path), http.StatusSeeOther)
+			}
+		}
+		if r.FormValue("restore") == "restore" || r.FormValue("purge") == "purge" {
+			processTrash(ModelName, w, r, session, &user)
 			c.IsUpdated = true
-			http.Redirect(w, r, fmt.Sprint(RootURL+r.URL.Path), http.StatusSeeOther)
+			http.Redirect(w, r, fmt.Sprint(RootURL+r.URL.Path+"?trash=1"), http.StatusSeeOther)
 		}
 	}
 
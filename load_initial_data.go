@@ -1,10 +1,14 @@
 package uadmin
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type initialDataRecords []map[string]interface{}
@@ -13,16 +17,32 @@ type initialData struct {
 	Init   []string                      `json:"init"`
 	Data   map[string]initialDataRecords `json:"data"`
 	Finish []string                      `json:"finish"`
+
+	// Rollback is used by data migration files to revert the migration
+	Rollback []string `json:"rollback"`
 }
 
+// initialDataMigrationName is the name of the migration that records the
+// checksum of the last initial_data.json that was loaded
+const initialDataMigrationName = "initial_data.json"
+
 // loadInitialData reads a file named initial_data.json and
-// saves its content in the database
+// saves its content in the database. The checksum of the file is
+// stored in the migrations table so the file is only loaded again
+// when it changes.
 func loadInitialData() error {
 	buf, err := ioutil.ReadFile("initial_data.json")
 	if err != nil {
 		return nil
 	}
 
+	checksum := fmt.Sprintf("%x", sha256.Sum256(buf))
+	migration := Migration{}
+	Get(&migration, "name = ?", initialDataMigrationName)
+	if migration.Checksum == checksum {
+		return nil
+	}
+
 	// Load json daa into struct
 	data := initialData{}
 	err = json.Unmarshal(buf, &data)
@@ -31,67 +51,17 @@ func loadInitialData() error {
 	}
 
 	// Execute SQL in Init section
-	for _, SQL := range data.Init {
-		// Check if this is a uadmin command
-		if strings.HasPrefix(SQL, "!") {
-			command := strings.Split(SQL, " ")
-			switch strings.ToUpper(command[0]) {
-			case "!MIGRATE":
-				if len(command) < 2 {
-					return fmt.Errorf("invalid uadmin command in initial_data.json in init section. %s", SQL)
-				}
-
-				// Check if the model name is correct
-				if _, ok := models[command[1]]; !ok {
-					if len(command) < 2 {
-						return fmt.Errorf("model name does not exist in initial_data.json in init section. %s", SQL)
-					}
-				}
-				db.AutoMigrate(models[command[1]])
-			}
-			continue
-		}
-
-		// This is a SQL command
-		err = db.Exec(SQL).Error
-		if err != nil {
-			return fmt.Errorf("loadInitialData.Exec: Error in in Init section (%s). %s", SQL, err)
-		}
+	if err = execInitialDataCommands(db, "Init", data.Init); err != nil {
+		return err
 	}
 
 	// Load data
 	for table, records := range data.Data {
-		// get modelname from table name
-		// For the record:
-		//   - Name       :  OrderItem
-		//   - DisplayName:  Order Items
-		//   - ModelName  :  orderitem
-		//   - TableName  :  order_items
-		tabeFound := false
-		for k, v := range Schema {
-			// check if table is a ModelName
-			if table == k {
-				tabeFound = true
-				break
-			}
-			// check if the table is a database TableName
-			// and convert it into modelname
-			if v.TableName == table {
-				table = k
-				tabeFound = true
-				break
-			}
-			// check if the table is a Name
-			// and convert it into modelname
-			if v.Name == table {
-				table = k
-				tabeFound = true
-				break
-			}
-		}
-		if !tabeFound {
+		modelName, ok := getInitialDataModelName(table)
+		if !ok {
 			return fmt.Errorf("loadInitialData: Table not found for (%s)", table)
 		}
+		table = modelName
 
 		// Put records into Model Array
 		modelArray, _ := NewModelArray(table, true)
@@ -113,33 +83,64 @@ func loadInitialData() error {
 	}
 
 	// Execute SQL in Finish section
-	for _, SQL := range data.Finish {
+	if err = execInitialDataCommands(db, "Finish", data.Finish); err != nil {
+		return err
+	}
+
+	migration.Name = initialDataMigrationName
+	migration.Source = "initial_data.json"
+	migration.Checksum = checksum
+	migration.AppliedAt = time.Now()
+	return Save(&migration)
+}
+
+// execInitialDataCommands executes the SQL commands and uadmin commands
+// like !MIGRATE in a section of initial data
+func execInitialDataCommands(tx *gorm.DB, section string, commands []string) error {
+	models := registeredModels()
+	for _, SQL := range commands {
 		// Check if this is a uadmin command
 		if strings.HasPrefix(SQL, "!") {
 			command := strings.Split(SQL, " ")
 			switch strings.ToUpper(command[0]) {
 			case "!MIGRATE":
 				if len(command) < 2 {
-					return fmt.Errorf("invalid uadmin command in initial_data.json in init section. %s", SQL)
+					return fmt.Errorf("invalid uadmin command in %s section. %s", strings.ToLower(section), SQL)
 				}
 
 				// Check if the model name is correct
 				if _, ok := models[command[1]]; !ok {
-					if len(command) < 2 {
-						return fmt.Errorf("model name does not exist in initial_data.json in init section. %s", SQL)
-					}
+					return fmt.Errorf("model name does not exist in %s section. %s", strings.ToLower(section), SQL)
+				}
+				if err := tx.AutoMigrate(models[command[1]]); err != nil {
+					return fmt.Errorf("unable to migrate %s. %s", command[1], err)
 				}
-				db.AutoMigrate(models[command[1]])
 			}
 			continue
 		}
 
 		// This is a SQL command
-		err = db.Exec(SQL).Error
-		if err != nil {
-			return fmt.Errorf("loadInitialData.Exec: Error in in Finish section (%s). %s", SQL, err)
+		if err := tx.Exec(SQL).Error; err != nil {
+			return fmt.Errorf("loadInitialData.Exec: Error in in %s section (%s). %s", section, SQL, err)
 		}
 	}
-
 	return nil
 }
+
+// getInitialDataModelName returns the model name of a table in initial data
+func getInitialDataModelName(table string) (string, bool) {
+	// get modelname from table name
+	// For the record:
+	//   - Name       :  OrderItem
+	//   - DisplayName:  Order Items
+	//   - ModelName  :  orderitem
+	//   - TableName  :  order_items
+	for k, v := range registeredSchema() {
+		// check if table is a ModelName, a database TableName
+		// or a Name and convert it into modelname
+		if table == k || v.TableName == table || v.Name == table {
+			return k, true
+		}
+	}
+	return "", false
+}
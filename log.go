@@ -9,6 +9,9 @@ import (
 	"time"
 )
 
+// logTimeFormat is the format used to store dates in a log's activity
+const logTimeFormat = "2006-01-02 15:04:05 -0700"
+
 // Action !
 type Action int
 
@@ -66,6 +69,16 @@ func (a Action) GetSchema() Action {
 	return 11
 }
 
+// Restored !
+func (a Action) Restored() Action {
+	return 12
+}
+
+// Purged !
+func (a Action) Purged() Action {
+	return 13
+}
+
 // Custom !
 func (a Action) Custom() Action {
 	return 99
@@ -117,38 +130,52 @@ func (l *Log) ParseRecord(a reflect.Value, modelName string, ID uint, user *User
 		a = a.Elem()
 	}
 
-	jsonifyValue := map[string]string{
-		"_IP": GetRemoteIP(r),
+	jsonifyValue := getRecordSnapshot(a, &s)
+	jsonifyValue["_IP"] = GetRemoteIP(r)
+	json, _ := json.Marshal(jsonifyValue)
+	l.Activity = string(json)
+
+	return nil
+}
+
+// getRecordSnapshot returns a flat string representation of a record's
+// fields. FKs are stored as their ID, M2M fields as a comma separated list
+// of IDs and dates using logTimeFormat.
+func getRecordSnapshot(a reflect.Value, s *ModelSchema) map[string]string {
+	if a.Kind() == reflect.Ptr {
+		a = a.Elem()
 	}
+	snapshot := map[string]string{}
 	for _, f := range s.Fields {
-		if !f.IsMethod {
-			if f.Type == cFK {
-				jsonifyValue[f.Name+"ID"] = fmt.Sprint(a.FieldByName(f.Name + "ID").Interface())
-			} else if f.Type == cDATE {
-				val := time.Time{}
-				if a.FieldByName(f.Name).Type().Kind() == reflect.Ptr {
-					if a.FieldByName(f.Name).IsNil() {
-						jsonifyValue[f.Name] = ""
-					} else {
-						val, _ = a.FieldByName(f.Name).Elem().Interface().(time.Time)
-						jsonifyValue[f.Name] = val.Format("2006-01-02 15:04:05 -0700")
-					}
-
+		if f.IsMethod {
+			continue
+		}
+		if f.Type == cFK {
+			snapshot[f.Name+"ID"] = fmt.Sprint(a.FieldByName(f.Name + "ID").Interface())
+		} else if f.Type == cDATE {
+			val := time.Time{}
+			if a.FieldByName(f.Name).Type().Kind() == reflect.Ptr {
+				if a.FieldByName(f.Name).IsNil() {
+					snapshot[f.Name] = ""
 				} else {
-					val, _ = a.FieldByName(f.Name).Interface().(time.Time)
-					jsonifyValue[f.Name] = val.Format("2006-01-02 15:04:05 -0700")
+					val, _ = a.FieldByName(f.Name).Elem().Interface().(time.Time)
+					snapshot[f.Name] = val.Format(logTimeFormat)
 				}
-
 			} else {
-				jsonifyValue[f.Name] = fmt.Sprint(a.FieldByName(f.Name).Interface())
+				val, _ = a.FieldByName(f.Name).Interface().(time.Time)
+				snapshot[f.Name] = val.Format(logTimeFormat)
 			}
-
+		} else if f.Type == cM2M {
+			ids := []string{}
+			for i := 0; i < a.FieldByName(f.Name).Len(); i++ {
+				ids = append(ids, fmt.Sprint(GetID(a.FieldByName(f.Name).Index(i))))
+			}
+			snapshot[f.Name] = strings.Join(ids, ",")
+		} else {
+			snapshot[f.Name] = fmt.Sprint(a.FieldByName(f.Name).Interface())
 		}
 	}
-	json, _ := json.Marshal(jsonifyValue)
-	l.Activity = string(json)
-
-	return nil
+	return snapshot
 }
 
 // SignIn !
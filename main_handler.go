@@ -68,6 +68,10 @@ func mainHandler(w http.ResponseWriter, r *http.Request) {
 			exportHandler(w, r, session)
 			return
 		}
+		if URLParts[0] == "import" {
+			importHandler(w, r, session)
+			return
+		}
 		if URLParts[0] == "cropper" {
 			cropImageHandler(w, r, session)
 			return
@@ -0,0 +1,463 @@
+package uadmin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigrationFunc is a function that applies or rolls back a data migration
+// inside a transaction
+type MigrationFunc func(tx *Tx) error
+
+// Migration is a model that stores the data migrations that were applied
+// to the database
+type Migration struct {
+	Model
+	Name      string    `uadmin:"read_only;search"`
+	Source    string    `uadmin:"read_only;filter"`
+	Checksum  string    `uadmin:"read_only;list_exclude"`
+	Batch     int       `uadmin:"read_only;filter"`
+	AppliedAt time.Time `uadmin:"read_only"`
+	Duration  string    `uadmin:"read_only"`
+}
+
+func (m Migration) String() string {
+	return m.Name
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (Migration) HideInDashboard() bool {
+	return true
+}
+
+// MigrationLock is a model with one record that an instance locks while it
+// applies or rolls back data migrations so instances that start together
+// don't apply the same migrations
+type MigrationLock struct {
+	Model
+	LockedBy    string     `uadmin:"read_only"`
+	LockedUntil *time.Time `uadmin:"read_only"`
+}
+
+func (l MigrationLock) String() string {
+	return l.LockedBy
+}
+
+// HideInDashboard indicates that startup register call
+// should hide this model in dashboard menu
+func (MigrationLock) HideInDashboard() bool {
+	return true
+}
+
+// MigrationStatus is the state of a data migration
+type MigrationStatus struct {
+	Name    string
+	Source  string
+	Applied bool
+	// Missing is an applied migration that is no longer registered or
+	// its file was removed
+	Missing bool
+	// Changed is an applied migration file that changed after it was
+	// applied
+	Changed   bool
+	Batch     int
+	AppliedAt *time.Time
+	Rollback  bool
+}
+
+// dataMigration is a registered or file data migration
+type dataMigration struct {
+	name     string
+	source   string
+	checksum string
+	up       MigrationFunc
+	down     MigrationFunc
+}
+
+var registeredMigrations = map[string]*dataMigration{}
+var registeredMigrationsMutex = sync.Mutex{}
+
+// RegisterMigration registers a data migration. Migrations are applied once
+// in the order of their names so they should be numbered like
+// "0001_add_countries". down is used to roll back the migration and can be
+// nil if the migration cannot be rolled back.
+func RegisterMigration(name string, up MigrationFunc, down MigrationFunc) {
+	registeredMigrationsMutex.Lock()
+	defer registeredMigrationsMutex.Unlock()
+	if _, ok := registeredMigrations[name]; ok {
+		Trail(WARNING, "RegisterMigration: migration %s is already registered", name)
+	}
+	registeredMigrations[name] = &dataMigration{
+		name:   name,
+		source: "go",
+		up:     up,
+		down:   down,
+	}
+}
+
+// getMigrations returns the registered migrations and the migration files
+// in MigrationsPath sorted by their names
+func getMigrations() ([]*dataMigration, error) {
+	migrations := map[string]*dataMigration{}
+	registeredMigrationsMutex.Lock()
+	for k, v := range registeredMigrations {
+		migrations[k] = v
+	}
+	registeredMigrationsMutex.Unlock()
+
+	files, _ := filepath.Glob(filepath.Join(MigrationsPath, "*.json"))
+	for _, fileName := range files {
+		name := strings.TrimSuffix(filepath.Base(fileName), ".json")
+		if _, ok := migrations[name]; ok {
+			return nil, fmt.Errorf("migration %s is registered and has a file %s", name, fileName)
+		}
+		m, err := loadMigrationFile(name, fileName)
+		if err != nil {
+			return nil, err
+		}
+		migrations[name] = m
+	}
+
+	list := []*dataMigration{}
+	for _, m := range migrations {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list, nil
+}
+
+// loadMigrationFile reads a data migration file. The file has the same
+// format as initial_data.json with a rollback section with the commands to
+// revert the migration.
+func loadMigrationFile(name string, fileName string) (*dataMigration, error) {
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	data := initialData{}
+	if err = json.Unmarshal(buf, &data); err != nil {
+		return nil, fmt.Errorf("unable to parse migration file %s. %s", fileName, err)
+	}
+
+	m := &dataMigration{
+		name:     name,
+		source:   fileName,
+		checksum: fmt.Sprintf("%x", sha256.Sum256(buf)),
+	}
+	m.up = func(tx *Tx) error {
+		if err := execInitialDataCommands(tx.DB(), "Init", data.Init); err != nil {
+			return err
+		}
+		tables := []string{}
+		for table := range data.Data {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			if err := saveMigrationRecords(tx.DB(), table, data.Data[table]); err != nil {
+				return err
+			}
+		}
+		return execInitialDataCommands(tx.DB(), "Finish", data.Finish)
+	}
+	if len(data.Rollback) != 0 {
+		m.down = func(tx *Tx) error {
+			return execInitialDataCommands(tx.DB(), "Rollback", data.Rollback)
+		}
+	}
+	return m, nil
+}
+
+// saveMigrationRecords saves the records of a table in a migration file.
+// Records with an ID that exists are updated.
+func saveMigrationRecords(tx *gorm.DB, table string, records initialDataRecords) error {
+	modelName, ok := getInitialDataModelName(table)
+	if !ok {
+		return fmt.Errorf("table not found for (%s)", table)
+	}
+	for i := range records {
+		model, _ := NewModel(modelName, true)
+		if id, ok := records[i]["id"]; ok {
+			tx.Where("id = ?", id).First(model.Interface())
+		} else if id, ok := records[i]["ID"]; ok {
+			tx.Where("id = ?", id).First(model.Interface())
+		}
+		buf, _ := json.Marshal(records[i])
+		if err := json.Unmarshal(buf, model.Interface()); err != nil {
+			return fmt.Errorf("error parsing %s[%d]. %s", table, i, err)
+		}
+		encryptRecord(model.Interface())
+		if err := tx.Save(model.Interface()).Error; err != nil {
+			return fmt.Errorf("error saving %s[%d]. %s", table, i, err)
+		}
+	}
+	return nil
+}
+
+// getAppliedMigrations returns the applied data migrations by name. The
+// record of initial_data.json and schema migrations are not included.
+func getAppliedMigrations() map[string]Migration {
+	migrations := []Migration{}
+	Filter(&migrations, "name <> ? AND source <> ?", initialDataMigrationName, schemaMigrationSource)
+	applied := map[string]Migration{}
+	for _, m := range migrations {
+		applied[m.Name] = m
+	}
+	return applied
+}
+
+// lockMigrations locks the data migrations in the database for this
+// instance. It waits while they are locked by another instance until the
+// lock is released or expires after MigrationLockTimeout.
+func lockMigrations() error {
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&MigrationLock{Model: Model{ID: 1}}).Error
+	if err != nil {
+		return err
+	}
+	for {
+		now := time.Now()
+		result := db.Model(&MigrationLock{}).Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", 1, now).Updates(map[string]interface{}{
+			"locked_by":    jobInstance,
+			"locked_until": now.Add(MigrationLockTimeout),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+		time.Sleep(migrationLockInterval)
+	}
+}
+
+// unlockMigrations releases the lock of the data migrations
+func unlockMigrations() {
+	err := db.Model(&MigrationLock{}).Where("id = ? AND locked_by = ?", 1, jobInstance).Updates(map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+	}).Error
+	if err != nil {
+		Trail(ERROR, "Unable to unlock data migrations. %s", err)
+	}
+}
+
+// migrationLockInterval is how often an instance checks if the lock of the
+// data migrations was released
+var migrationLockInterval = time.Second
+
+// MigrateData applies the data migrations that were not applied yet in the
+// order of their names. Each migration runs in a transaction and the
+// migrations applied together share a batch number so they can be rolled
+// back together. Other instances wait until the migrations are applied. It
+// returns the names of the applied migrations.
+func MigrateData() ([]string, error) {
+	migrations, err := getMigrations()
+	if err != nil {
+		Trail(ERROR, "MigrateData: %s", err)
+		return nil, err
+	}
+	if err = lockMigrations(); err != nil {
+		Trail(ERROR, "MigrateData: unable to lock data migrations. %s", err)
+		return nil, err
+	}
+	defer unlockMigrations()
+	applied := getAppliedMigrations()
+
+	batch := 1
+	last := Migration{}
+	db.Model(&Migration{}).Where("source <> ?", schemaMigrationSource).Order("batch desc").First(&last)
+	if last.ID != 0 {
+		batch = last.Batch + 1
+	}
+
+	names := []string{}
+	for _, m := range migrations {
+		if _, ok := applied[m.name]; ok {
+			continue
+		}
+		start := time.Now()
+		err = Transaction(context.Background(), func(tx *Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.DB().Create(&Migration{
+				Name:      m.name,
+				Source:    m.source,
+				Checksum:  m.checksum,
+				Batch:     batch,
+				AppliedAt: start,
+				Duration:  time.Since(start).String(),
+			}).Error
+		})
+		if err != nil {
+			Trail(ERROR, "MigrateData: unable to apply %s. %s", m.name, err)
+			return names, fmt.Errorf("unable to apply %s. %s", m.name, err)
+		}
+		Trail(INFO, "Applied data migration %s", m.name)
+		names = append(names, m.name)
+	}
+	return names, nil
+}
+
+// RollbackData rolls back the last batches of data migrations in reverse
+// order. It stops at the first migration that does not have a rollback and
+// returns the names of the migrations that were rolled back.
+func RollbackData(batches int) ([]string, error) {
+	migrations, err := getMigrations()
+	if err != nil {
+		Trail(ERROR, "RollbackData: %s", err)
+		return nil, err
+	}
+	if err = lockMigrations(); err != nil {
+		Trail(ERROR, "RollbackData: unable to lock data migrations. %s", err)
+		return nil, err
+	}
+	defer unlockMigrations()
+	byName := map[string]*dataMigration{}
+	for _, m := range migrations {
+		byName[m.name] = m
+	}
+
+	names := []string{}
+	for i := 0; i < batches; i++ {
+		last := Migration{}
+		db.Model(&Migration{}).Where("name <> ? AND source <> ?", initialDataMigrationName, schemaMigrationSource).Order("batch desc").First(&last)
+		if last.ID == 0 {
+			break
+		}
+		applied := []Migration{}
+		FilterSorted("id", false, &applied, "batch = ? AND name <> ? AND source <> ?", last.Batch, initialDataMigrationName, schemaMigrationSource)
+		for _, a := range applied {
+			m, ok := byName[a.Name]
+			if !ok {
+				return names, fmt.Errorf("migration %s is not registered", a.Name)
+			}
+			if m.down == nil {
+				return names, fmt.Errorf("migration %s does not have a rollback", a.Name)
+			}
+			err = Transaction(context.Background(), func(tx *Tx) error {
+				if err := m.down(tx); err != nil {
+					return err
+				}
+				return tx.DB().Unscoped().Delete(&Migration{}, a.ID).Error
+			})
+			if err != nil {
+				Trail(ERROR, "RollbackData: unable to roll back %s. %s", a.Name, err)
+				return names, fmt.Errorf("unable to roll back %s. %s", a.Name, err)
+			}
+			Trail(INFO, "Rolled back data migration %s", a.Name)
+			names = append(names, a.Name)
+		}
+	}
+	return names, nil
+}
+
+// GetMigrationStatus returns the status of the data migrations including
+// applied migrations that are no longer registered
+func GetMigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := getMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := getAppliedMigrations()
+
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		s := MigrationStatus{
+			Name:     m.name,
+			Source:   m.source,
+			Rollback: m.down != nil,
+		}
+		if a, ok := applied[m.name]; ok {
+			s.Applied = true
+			s.Changed = m.checksum != "" && a.Checksum != m.checksum
+			s.Batch = a.Batch
+			s.AppliedAt = &a.AppliedAt
+			delete(applied, m.name)
+		}
+		status = append(status, s)
+	}
+	for _, a := range applied {
+		a := a
+		status = append(status, MigrationStatus{
+			Name:      a.Name,
+			Source:    a.Source,
+			Applied:   true,
+			Missing:   true,
+			Batch:     a.Batch,
+			AppliedAt: &a.AppliedAt,
+		})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status, nil
+}
+
+// migrateDataCommand runs the datamigrate command:
+//
+//	datamigrate [up]
+//	datamigrate down [BATCHES]
+//	datamigrate status
+func migrateDataCommand(args []string) error {
+	subcommand := "up"
+	if len(args) != 0 {
+		subcommand = args[0]
+	}
+	switch subcommand {
+	case "up":
+		names, err := MigrateData()
+		for _, name := range names {
+			fmt.Println("Applied", name)
+		}
+		if err == nil && len(names) == 0 {
+			fmt.Println("No data migrations to apply")
+		}
+		return err
+	case "down":
+		batches := 1
+		if len(args) > 1 {
+			if _, err := fmt.Sscanf(args[1], "%d", &batches); err != nil || batches < 1 {
+				return fmt.Errorf("invalid number of batches: %s", args[1])
+			}
+		}
+		names, err := RollbackData(batches)
+		for _, name := range names {
+			fmt.Println("Rolled back", name)
+		}
+		return err
+	case "status":
+		status, err := GetMigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Missing {
+				state = "missing"
+			} else if s.Applied {
+				state = fmt.Sprintf("applied batch %d at %s", s.Batch, s.AppliedAt.Format("2006-01-02 15:04:05"))
+				if s.Changed {
+					state += " (changed)"
+				}
+			}
+			fmt.Printf("%-40s %-6s %s\n", s.Name, s.Source, state)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown datamigrate command: %s", subcommand)
+}
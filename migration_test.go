@@ -0,0 +1,134 @@
+package uadmin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TestMigration is a unit testing function for data migrations
+func (t *UAdminTests) TestMigration() {
+	oldPath := MigrationsPath
+	oldInterval := migrationLockInterval
+	migrationLockInterval = time.Millisecond * 50
+	MigrationsPath = filepath.Join(os.TempDir(), "uadmin_migrations_"+GenerateBase32(8))
+	os.MkdirAll(MigrationsPath, 0700)
+	defer func() {
+		os.RemoveAll(MigrationsPath)
+		MigrationsPath = oldPath
+		migrationLockInterval = oldInterval
+		registeredMigrationsMutex.Lock()
+		delete(registeredMigrations, "0001_go")
+		delete(registeredMigrations, "0003_fail")
+		registeredMigrationsMutex.Unlock()
+		DeleteList(&TestStruct1{}, "")
+		db.Unscoped().Where("name <> ?", initialDataMigrationName).Delete(&Migration{})
+		db.Unscoped().Where("id = ?", 1).Delete(&MigrationLock{})
+	}()
+
+	RegisterMigration("0001_go", func(tx *Tx) error {
+		return tx.Save(&TestStruct1{Name: "Go Migration", Value: 1})
+	}, func(tx *Tx) error {
+		return tx.DB().Unscoped().Where("name = ?", "Go Migration").Delete(&TestStruct1{}).Error
+	})
+	os.WriteFile(filepath.Join(MigrationsPath, "0002_file.json"), []byte(`{
+		"data": {"teststruct1": [{"Name": "File Migration", "Value": 2}]},
+		"finish": ["UPDATE test_struct1 SET value = 3 WHERE name = 'File Migration'"],
+		"rollback": ["DELETE FROM test_struct1 WHERE name = 'File Migration'"]
+	}`), 0600)
+
+	// Apply migrations in order
+	names, err := MigrateData()
+	if err != nil || fmt.Sprint(names) != "[0001_go 0002_file]" {
+		t.Errorf("MigrateData didn't apply the migrations. Got %v %s", names, err)
+	}
+	record := TestStruct1{}
+	Get(&record, "name = ?", "File Migration")
+	if record.ID == 0 || record.Value != 3 || Count(&[]TestStruct1{}, "name = ?", "Go Migration") != 1 {
+		t.Errorf("MigrateData didn't apply the migrations. Got %#v", record)
+	}
+
+	// Migrations are applied once
+	if names, err = MigrateData(); err != nil || len(names) != 0 {
+		t.Errorf("MigrateData applied migrations twice. Got %v %s", names, err)
+	}
+	if Count(&[]TestStruct1{}, "") != 2 {
+		t.Errorf("MigrateData applied migrations twice")
+	}
+
+	// Migrations wait while another instance locks them
+	lockedUntil := time.Now().Add(time.Millisecond * 300)
+	db.Model(&MigrationLock{}).Where("id = ?", 1).Updates(map[string]interface{}{
+		"locked_by":    "other",
+		"locked_until": lockedUntil,
+	})
+	if names, err = MigrateData(); err != nil || len(names) != 0 || time.Now().Before(lockedUntil) {
+		t.Errorf("MigrateData didn't wait for the lock of another instance. Got %v %s", names, err)
+	}
+	lock := MigrationLock{}
+	Get(&lock, "id = ?", 1)
+	if lock.ID != 1 || lock.LockedBy != "" || lock.LockedUntil != nil {
+		t.Errorf("MigrateData didn't release the lock. Got %#v", lock)
+	}
+
+	// A failed migration is rolled back and stops the next migrations
+	RegisterMigration("0003_fail", func(tx *Tx) error {
+		tx.DB().Create(&TestStruct1{Name: "Failed Migration"})
+		return fmt.Errorf("failed")
+	}, nil)
+	os.WriteFile(filepath.Join(MigrationsPath, "0004_file.json"), []byte(`{"data": {"teststruct1": [{"Name": "Next Migration"}]}}`), 0600)
+	if names, err = MigrateData(); err == nil || len(names) != 0 {
+		t.Errorf("MigrateData didn't return an error for a failed migration. Got %v", names)
+	}
+	if Count(&[]TestStruct1{}, "name IN (?)", []string{"Failed Migration", "Next Migration"}) != 0 {
+		t.Errorf("MigrateData didn't roll back the failed migration")
+	}
+
+	// Status
+	status, err := GetMigrationStatus()
+	if err != nil || len(status) != 4 {
+		t.Errorf("GetMigrationStatus returned invalid status. Got %#v %s", status, err)
+	} else {
+		if !status[0].Applied || status[0].Source != "go" || !status[1].Applied || status[1].Batch != status[0].Batch {
+			t.Errorf("GetMigrationStatus returned invalid status for applied migrations. Got %#v", status[:2])
+		}
+		if status[2].Applied || status[2].Rollback || status[3].Applied {
+			t.Errorf("GetMigrationStatus returned invalid status for pending migrations. Got %#v", status[2:])
+		}
+	}
+	os.WriteFile(filepath.Join(MigrationsPath, "0002_file.json"), []byte(`{}`), 0600)
+	if status, _ = GetMigrationStatus(); len(status) < 2 || !status[1].Changed {
+		t.Errorf("GetMigrationStatus didn't report a changed migration file. Got %#v", status)
+	}
+	os.Remove(filepath.Join(MigrationsPath, "0002_file.json"))
+	if status, _ = GetMigrationStatus(); len(status) < 2 || !status[1].Missing {
+		t.Errorf("GetMigrationStatus didn't report a missing migration. Got %#v", status)
+	}
+	os.WriteFile(filepath.Join(MigrationsPath, "0002_file.json"), []byte(`{
+		"rollback": ["DELETE FROM test_struct1 WHERE name = 'File Migration'"]
+	}`), 0600)
+
+	// Roll back the last batch
+	if names, err = RollbackData(1); err != nil || fmt.Sprint(names) != "[0002_file 0001_go]" {
+		t.Errorf("RollbackData didn't roll back the migrations. Got %v %s", names, err)
+	}
+	if Count(&[]TestStruct1{}, "") != 0 || len(getAppliedMigrations()) != 0 {
+		t.Errorf("RollbackData didn't roll back the migrations")
+	}
+
+	// Commands
+	os.Remove(filepath.Join(MigrationsPath, "0004_file.json"))
+	registeredMigrationsMutex.Lock()
+	delete(registeredMigrations, "0003_fail")
+	registeredMigrationsMutex.Unlock()
+	if ok, err := runCommand([]string{"datamigrate"}); !ok || err != nil || len(getAppliedMigrations()) != 2 {
+		t.Errorf("runCommand didn't apply the migrations. Got %v %s", ok, err)
+	}
+	if ok, err := runCommand([]string{"datamigrate", "down", "x"}); !ok || err == nil {
+		t.Errorf("runCommand didn't return an error for an invalid number of batches")
+	}
+	if ok, _ := runCommand([]string{"-test.v"}); ok {
+		t.Errorf("runCommand ran an invalid command")
+	}
+}
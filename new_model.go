@@ -6,7 +6,7 @@ import (
 
 // NewModel creates a new model from a model name
 func NewModel(modelName string, pointer bool) (reflect.Value, bool) {
-	model := models[modelName]
+	model := registeredModels()[modelName]
 	if model == nil {
 		return reflect.ValueOf(nil), false
 	}
@@ -19,7 +19,7 @@ func NewModel(modelName string, pointer bool) (reflect.Value, bool) {
 
 // NewModelArray creates a new model from a model name
 func NewModelArray(modelName string, pointer bool) (reflect.Value, bool) {
-	model := models[modelName]
+	model := registeredModels()[modelName]
 	if model == nil {
 		return reflect.ValueOf(nil), false
 	}
@@ -0,0 +1,285 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OnDelete is what happens to the records that point to a deleted record
+// through a foreign key. It is set with the on_delete tag of the foreign key
+// like `uadmin:"on_delete:cascade"`. Records of foreign keys without the tag
+// are not changed.
+type OnDelete string
+
+// On delete rules
+const (
+	// OnDeleteCascade deletes the related records
+	OnDeleteCascade OnDelete = "cascade"
+	// OnDeleteProtect stops the delete while there are related records
+	OnDeleteProtect OnDelete = "protect"
+	// OnDeleteSetNull sets the foreign key of the related records to NULL
+	OnDeleteSetNull OnDelete = "set_null"
+)
+
+// DeleteImpact is a record that is changed by deleting records with the
+// records that are changed because of it in Related
+type DeleteImpact struct {
+	Model string `json:"model"`
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	// Action is delete, set_null or protect
+	Action string `json:"action"`
+	// Column is the foreign key of a related record
+	Column  string         `json:"column,omitempty"`
+	Related []DeleteImpact `json:"related,omitempty"`
+}
+
+// deleteRelation is a foreign key of a model to another model with an on
+// delete rule
+type deleteRelation struct {
+	Model  string
+	Column string
+	Rule   OnDelete
+}
+
+// getOnDelete returns the on delete rule in the tag of a field
+func getOnDelete(field reflect.StructField, modelName string) OnDelete {
+	val, ok := getUadminTag(field, "on_delete")
+	if !ok {
+		return ""
+	}
+	rule := OnDelete(strings.ToLower(strings.TrimSpace(val)))
+	switch rule {
+	case OnDeleteCascade, OnDeleteProtect, OnDeleteSetNull:
+		return rule
+	}
+	Trail(WARNING, "Unknown on_delete rule (%s) in %s.%s", val, modelName, field.Name)
+	return ""
+}
+
+// getDeleteRelations returns the foreign keys of registered models and
+// inlines that point to a model and have an on delete rule
+func getDeleteRelations(modelName string) []deleteRelation {
+	models := registeredModels()
+	parent, ok := models[modelName]
+	if !ok {
+		return nil
+	}
+	parentType := reflect.TypeOf(parent)
+
+	names := []string{}
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	relations := []deleteRelation{}
+	for _, name := range names {
+		t := reflect.TypeOf(models[name])
+		stmt := &gorm.Statement{DB: modelDB(models[name])}
+		if err := stmt.Parse(models[name]); err != nil {
+			continue
+		}
+		columns := map[string]bool{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			fType := field.Type
+			if fType.Kind() == reflect.Ptr {
+				fType = fType.Elem()
+			}
+			if field.Anonymous || fType != parentType {
+				continue
+			}
+			idField, ok := t.FieldByName(field.Name + "ID")
+			f := stmt.Schema.LookUpField(field.Name + "ID")
+			if !ok || f == nil || f.DBName == "" {
+				continue
+			}
+			columns[f.DBName] = true
+			rule := getOnDelete(field, name)
+			if rule == "" {
+				rule = getOnDelete(idField, name)
+			}
+			if rule != "" {
+				relations = append(relations, deleteRelation{Model: name, Column: f.DBName, Rule: rule})
+			}
+		}
+
+		// Inlines can point to the model with an ID field only
+		column, ok := foreignKeys[modelName][name]
+		if !ok || columns[column] {
+			continue
+		}
+		if f := stmt.Schema.LookUpField(column); f != nil {
+			if rule := getOnDelete(f.StructField, name); rule != "" {
+				relations = append(relations, deleteRelation{Model: name, Column: column, Rule: rule})
+			}
+		}
+	}
+	return relations
+}
+
+// GetDeleteImpact returns the records that are changed by deleting records
+// of a model based on the on delete rules of the foreign keys that point to
+// them
+func GetDeleteImpact(modelName string, ids ...uint) ([]DeleteImpact, error) {
+	impacts := []DeleteImpact{}
+	visited := map[string]bool{}
+	relations := map[string][]deleteRelation{}
+	for _, id := range ids {
+		visited[fmt.Sprintf("%s.%d", modelName, id)] = true
+	}
+	for _, id := range ids {
+		m, ok := NewModel(modelName, true)
+		if !ok {
+			return nil, fmt.Errorf("unknown model %s", modelName)
+		}
+		modelDB(m.Interface()).Where("id = ?", id).First(m.Interface())
+		related, err := getRelatedImpact(modelName, id, visited, relations)
+		if err != nil {
+			return nil, err
+		}
+		impacts = append(impacts, DeleteImpact{
+			Model:   modelName,
+			ID:      id,
+			Name:    GetString(m.Interface()),
+			Action:  "delete",
+			Related: related,
+		})
+	}
+	return impacts, nil
+}
+
+// getRelatedImpact returns the related records that are changed by deleting
+// a record. visited has the records already in the impact and relations has
+// the relations of the models already checked.
+func getRelatedImpact(modelName string, id uint, visited map[string]bool, relations map[string][]deleteRelation) ([]DeleteImpact, error) {
+	if _, ok := relations[modelName]; !ok {
+		relations[modelName] = getDeleteRelations(modelName)
+	}
+	impacts := []DeleteImpact{}
+	for _, rel := range relations[modelName] {
+		records, _ := NewModelArray(rel.Model, true)
+		err := modelDB(records.Interface()).Where(fmt.Sprintf("%s = ?", columnEnclosure()+rel.Column+columnEnclosure()), id).Order("id").Find(records.Interface()).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < records.Elem().Len(); i++ {
+			record := records.Elem().Index(i)
+			impact := DeleteImpact{
+				Model:  rel.Model,
+				ID:     GetID(record),
+				Name:   GetString(record.Addr().Interface()),
+				Action: string(rel.Rule),
+				Column: rel.Column,
+			}
+			if rel.Rule == OnDeleteCascade {
+				impact.Action = "delete"
+				key := fmt.Sprintf("%s.%d", rel.Model, impact.ID)
+				if visited[key] {
+					continue
+				}
+				visited[key] = true
+				if impact.Related, err = getRelatedImpact(rel.Model, impact.ID, visited, relations); err != nil {
+					return nil, err
+				}
+			}
+			impacts = append(impacts, impact)
+		}
+	}
+	return impacts, nil
+}
+
+// IsDeleteProtected returns true if a record in the delete impact is
+// protected
+func IsDeleteProtected(impacts []DeleteImpact) bool {
+	for _, impact := range impacts {
+		if impact.Action == string(OnDeleteProtect) || IsDeleteProtected(impact.Related) {
+			return true
+		}
+	}
+	return false
+}
+
+// errDeleteProtected is returned when records with protected related
+// records are deleted
+var errDeleteProtected = fmt.Errorf("unable to delete records with protected related records")
+
+// deleteWithImpact deletes and sets to NULL the related records in a delete
+// impact and deletes the records that were asked to be deleted with del in
+// one transaction. If user is not nil, a Deleted log is created for each
+// related record that is deleted and a Modified log for each related record
+// that is set to NULL. The records are soft deleted with the same time so
+// restoring a record from the trash restores the related records that were
+// deleted with it.
+func deleteWithImpact(ctx context.Context, impacts []DeleteImpact, user *User, r *http.Request, del func(tx *Tx) error) error {
+	if IsDeleteProtected(impacts) {
+		return errDeleteProtected
+	}
+	now := time.Now()
+	return Transaction(ctx, func(tx *Tx) error {
+		tx = &Tx{db: tx.DB().Session(&gorm.Session{NowFunc: func() time.Time { return now }})}
+		for _, impact := range impacts {
+			if err := applyRelatedImpact(tx, impact.Related, user, r); err != nil {
+				return err
+			}
+		}
+		if del == nil {
+			return nil
+		}
+		return del(tx)
+	})
+}
+
+// impactDB returns the connection of a model in a transaction. Models in
+// named databases are not in the transaction but use its time.
+func impactDB(tx *Tx, a interface{}) *gorm.DB {
+	if getDBName(a) != "" {
+		return modelDB(a).Session(&gorm.Session{Context: tx.DB().Statement.Context, NowFunc: tx.DB().NowFunc})
+	}
+	return tx.DB()
+}
+
+// applyRelatedImpact deletes and sets to NULL related records and the
+// records related to them
+func applyRelatedImpact(tx *Tx, impacts []DeleteImpact, user *User, r *http.Request) error {
+	for _, impact := range impacts {
+		if err := applyRelatedImpact(tx, impact.Related, user, r); err != nil {
+			return err
+		}
+		m, _ := NewModel(impact.Model, true)
+		conn := impactDB(tx, m.Interface())
+
+		// Take the snapshot of the record for its log before changing it
+		log := Log{}
+		if user != nil && conn.Where("id = ?", impact.ID).First(m.Interface()).Error == nil {
+			decryptRecord(m.Interface())
+			customGet(m.Interface())
+			action := log.Action.Deleted()
+			if impact.Action == string(OnDeleteSetNull) {
+				action = log.Action.Modified()
+			}
+			log.ParseRecord(m, impact.Model, impact.ID, user, action, r)
+		}
+		var err error
+		if impact.Action == string(OnDeleteSetNull) {
+			err = conn.Model(m.Interface()).Where("id = ?", impact.ID).Update(impact.Column, nil).Error
+		} else {
+			err = conn.Where("id = ?", impact.ID).Delete(m.Interface()).Error
+		}
+		if err == nil && log.TableName != "" {
+			err = tx.DB().Create(&log).Error
+		}
+		if err != nil {
+			return fmt.Errorf("unable to %s %s %d. %s", strings.Replace(impact.Action, "_", " ", -1), impact.Model, impact.ID, err)
+		}
+	}
+	return nil
+}
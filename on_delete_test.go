@@ -0,0 +1,186 @@
+package uadmin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"time"
+)
+
+type TestAuthor struct {
+	Model
+	Name string
+}
+
+type TestBook struct {
+	Model
+	Name     string
+	Author   TestAuthor `uadmin:"on_delete:cascade"`
+	AuthorID uint
+}
+
+type TestReview struct {
+	Model
+	Text   string
+	Book   TestBook `uadmin:"on_delete:set_null"`
+	BookID uint
+}
+
+type TestAward struct {
+	Model
+	Name     string
+	Author   TestAuthor `uadmin:"on_delete:protect"`
+	AuthorID uint
+}
+
+// TestOnDelete is a unit testing function for on delete rules of foreign keys
+func (t *UAdminTests) TestOnDelete() {
+	// The models are added to the registered models without registering
+	// them to keep them out of the other tests
+	testModels := map[string]interface{}{
+		"testauthor": TestAuthor{},
+		"testbook":   TestBook{},
+		"testreview": TestReview{},
+		"testaward":  TestAward{},
+	}
+	initializeDB(TestAuthor{}, TestBook{}, TestReview{}, TestAward{})
+	for name, model := range testModels {
+		models[name] = model
+		Schema[name], _ = getSchema(model)
+	}
+	defer func() {
+		for name := range testModels {
+			delete(models, name)
+			delete(Schema, name)
+		}
+		db.Migrator().DropTable(&TestAuthor{}, &TestBook{}, &TestReview{}, &TestAward{})
+	}()
+
+	a1 := TestAuthor{Name: "Author 1"}
+	a2 := TestAuthor{Name: "Author 2"}
+	Save(&a1)
+	Save(&a2)
+	b1 := TestBook{Name: "Book 1", AuthorID: a1.ID}
+	b2 := TestBook{Name: "Book 2", AuthorID: a1.ID}
+	Save(&b1)
+	Save(&b2)
+	r1 := TestReview{Text: "Review 1", BookID: b1.ID}
+	Save(&r1)
+	Save(&TestAward{Name: "Award 1", AuthorID: a2.ID})
+
+	// The impact follows cascades to the records related to deleted records
+	impact, err := GetDeleteImpact("testauthor", a1.ID)
+	if err != nil {
+		t.Errorf("GetDeleteImpact returned an error. %s", err)
+		return
+	}
+	type node struct {
+		Model  string
+		ID     uint
+		Action string
+	}
+	nodes := []node{}
+	var walk func([]DeleteImpact)
+	walk = func(impacts []DeleteImpact) {
+		for _, i := range impacts {
+			nodes = append(nodes, node{i.Model, i.ID, i.Action})
+			walk(i.Related)
+		}
+	}
+	walk(impact)
+	expected := []node{
+		{"testauthor", a1.ID, "delete"},
+		{"testbook", b1.ID, "delete"},
+		{"testreview", r1.ID, "set_null"},
+		{"testbook", b2.ID, "delete"},
+	}
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("GetDeleteImpact returned %v, expected %v", nodes, expected)
+	}
+	if IsDeleteProtected(impact) {
+		t.Errorf("IsDeleteProtected returned true for an impact without protected records")
+	}
+
+	// A failed delete rolls back the changes to the related records
+	r := httptest.NewRequest("POST", "/api/d/testauthor/delete/", nil)
+	err = deleteWithImpact(context.Background(), impact, nil, r, func(tx *Tx) error {
+		return fmt.Errorf("delete failed")
+	})
+	if err == nil {
+		t.Errorf("deleteWithImpact didn't return the error of the delete")
+	}
+	if Count(&[]TestBook{}, "author_id = ?", a1.ID) != 2 {
+		t.Errorf("deleteWithImpact didn't roll back the cascade of a failed delete")
+	}
+
+	// Applying the impact deletes and clears the related records
+	user := User{Username: "ondeleteuser"}
+	defer db.Where("table_name IN (?)", []string{"testauthor", "testbook", "testreview"}).Delete(&Log{})
+	err = deleteWithImpact(context.Background(), impact, &user, r, func(tx *Tx) error {
+		return tx.DB().Where("id = ?", a1.ID).Delete(&TestAuthor{}).Error
+	})
+	if err != nil {
+		t.Errorf("deleteWithImpact returned an error. %s", err)
+	}
+	if Count(&[]TestBook{}, "author_id = ?", a1.ID) != 0 {
+		t.Errorf("deleteWithImpact didn't cascade the delete to the books")
+	}
+	review := TestReview{}
+	Get(&review, "id = ?", r1.ID)
+	if review.ID != r1.ID || review.BookID != 0 {
+		t.Errorf("deleteWithImpact didn't set the book of the review to NULL. Got %#v", review)
+	}
+	if Count(&[]TestAuthor{}, "id = ?", a1.ID) != 0 {
+		t.Errorf("deleteWithImpact didn't delete the record that was asked to be deleted")
+	}
+	if n := Count(&[]Log{}, "table_name = ? AND action = ? AND username = ?", "testbook", Action(0).Deleted(), user.Username); n != 2 {
+		t.Errorf("deleteWithImpact didn't log the cascaded deletes. Expected 2, got %d", n)
+	}
+	log := Log{}
+	Get(&log, "table_name = ? AND table_id = ? AND action = ?", "testreview", r1.ID, Action(0).Modified())
+	if activity := map[string]string{}; json.Unmarshal([]byte(log.Activity), &activity) != nil || activity["BookID"] != fmt.Sprint(b1.ID) {
+		t.Errorf("deleteWithImpact didn't log the review set to NULL with its book. Got %s", log.Activity)
+	}
+
+	// Restoring a record from the trash restores the records deleted with
+	// it and not the related records that were deleted before
+	db.Unscoped().Model(&TestBook{}).Where("id = ?", b2.ID).Update("deleted_at", time.Now().Add(-time.Hour))
+	if err = restoreRecord("testauthor", a1.ID, &user, r); err != nil {
+		t.Errorf("restoreRecord returned an error. %s", err)
+	}
+	if Count(&[]TestAuthor{}, "id = ?", a1.ID) != 1 || Count(&[]TestBook{}, "author_id = ?", a1.ID) != 1 {
+		t.Errorf("restoreRecord didn't restore the books deleted with the author")
+	}
+	if n := Count(&[]Log{}, "table_name = ? AND action = ? AND username = ?", "testbook", Action(0).Restored(), user.Username); n != 1 {
+		t.Errorf("restoreRecord didn't log the restored books. Expected 1, got %d", n)
+	}
+
+	// Protected records stop the delete
+	impact, _ = GetDeleteImpact("testauthor", a2.ID)
+	if !IsDeleteProtected(impact) {
+		t.Errorf("IsDeleteProtected returned false for an impact with protected records")
+	}
+	if err = deleteWithImpact(context.Background(), impact, nil, r, nil); err != errDeleteProtected {
+		t.Errorf("deleteWithImpact didn't return an error for protected records. Got %v", err)
+	}
+
+	// dAPI returns the impact for $dryrun
+	w := httptest.NewRecorder()
+	if _, ok := dAPIDeleteImpact(w, r, map[string]string{"$dryrun": "1"}, "testauthor", []uint{a2.ID}); ok {
+		t.Errorf("dAPIDeleteImpact didn't write the response of $dryrun")
+	}
+	result := struct {
+		Status    string
+		Protected bool
+		Result    []DeleteImpact
+	}{}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Status != "ok" || !result.Protected || len(result.Result) != 1 || len(result.Result[0].Related) != 1 {
+		t.Errorf("dAPIDeleteImpact returned the wrong impact for $dryrun. Got %s", w.Body.String())
+	}
+	if Count(&[]TestAward{}, "") != 1 {
+		t.Errorf("dAPIDeleteImpact changed records in $dryrun")
+	}
+}
@@ -27,7 +27,7 @@ func GenerateOpenAPISchema() {
 	s.Info.Title = SiteName + s.Info.Title
 
 	// Add Models to /components/schema
-	for _, v := range Schema {
+	for _, v := range registeredSchema() {
 		// Parse fields
 		fields := map[string]*openapi.SchemaObject{}
 		required := []string{}
@@ -471,6 +471,16 @@ func processForm(modelName string, w http.ResponseWriter, r *http.Request, sessi
 		}
 	}
 
+	// Check unique fields before saving to show an error instead of the
+	// error of the unique index
+	for k, v := range validateUnique(m.Interface(), s, getUniqueValues(m.Interface(), m), ID) {
+		for i := range s.Fields {
+			if s.Fields[i].Name == k && s.Fields[i].ErrMsg == "" {
+				s.Fields[i].ErrMsg = v
+			}
+		}
+	}
+
 	formError := false
 	for _, f := range s.Fields {
 		if f.ErrMsg != "" {
@@ -513,11 +523,13 @@ func processForm(modelName string, w http.ResponseWriter, r *http.Request, sessi
 		saverI.Save()
 	}
 
-	// Save Approvals
-	for _, approval := range appList {
-		approval.ModelPK = GetID(m)
-		approval.Save()
+	// Save Approvals and notify the approvers of their requests once all
+	// of them are saved
+	for i := range appList {
+		appList[i].ModelPK = GetID(m)
+		appList[i].Save()
 	}
+	notifyApprovalRequests(appList)
 
 	// Store the log for a new record
 	if LogAdd {
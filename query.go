@@ -0,0 +1,450 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Operator is a comparison operator of a query condition. Operators match
+// the operators of dAPI filters like __gt and __contains.
+type Operator int
+
+// Operators of query conditions
+const (
+	Eq Operator = iota
+	Ne
+	Gt
+	Gte
+	Lt
+	Lte
+	In
+	NotIn
+	IsNull
+	NotNull
+	Between
+	Contains
+	IContains
+	StartsWith
+	IStartsWith
+	EndsWith
+	IEndsWith
+)
+
+// Condition is a condition of a query on a field or a group of conditions
+// joined by AND or OR
+type Condition struct {
+	field string
+	op    Operator
+	value interface{}
+	group []Condition
+	or    bool
+}
+
+// C returns a condition on a field. The field is the name of the field
+// in the model or its column name.
+func C(field string, op Operator, value interface{}) Condition {
+	return Condition{field: field, op: op, value: value}
+}
+
+// Or returns a group of conditions where any of them matches like $or in
+// the dAPI
+func Or(conds ...Condition) Condition {
+	return Condition{group: conds, or: true}
+}
+
+// And returns a group of conditions where all of them match
+func And(conds ...Condition) Condition {
+	return Condition{group: conds}
+}
+
+// QuerySet is a typed query of a model built by Query
+type QuerySet[T any] struct {
+	schema ModelSchema
+	conds  []Condition
+	order  []string
+	group  []string
+	offset int
+	limit  int
+	err    error
+}
+
+// Query returns a typed query of a model. Field names are checked against
+// the schema of the model when the query runs:
+//
+//	orders, err := uadmin.Query[Order]().Where("Status", uadmin.Eq, 1).OrderBy("-id").Limit(10).All(ctx)
+func Query[T any]() *QuerySet[T] {
+	q := &QuerySet[T]{}
+	var m T
+	if reflect.TypeOf(m) == nil || reflect.TypeOf(m).Kind() != reflect.Struct {
+		q.err = fmt.Errorf("query of a type that is not a model: %T", m)
+		return q
+	}
+	q.schema, _ = getSchema(m)
+	return q
+}
+
+// Where adds a condition on a field
+func (q *QuerySet[T]) Where(field string, op Operator, value interface{}) *QuerySet[T] {
+	q.conds = append(q.conds, C(field, op, value))
+	return q
+}
+
+// Filter adds conditions that all have to match. Use Or to group conditions
+// where any of them matches.
+func (q *QuerySet[T]) Filter(conds ...Condition) *QuerySet[T] {
+	q.conds = append(q.conds, conds...)
+	return q
+}
+
+// OrderBy sorts the records by fields. A field that starts with - is
+// sorted descending.
+func (q *QuerySet[T]) OrderBy(fields ...string) *QuerySet[T] {
+	for _, name := range fields {
+		desc := strings.HasPrefix(name, "-")
+		column, err := q.column(strings.TrimPrefix(name, "-"))
+		if err != nil {
+			q.err = err
+			return q
+		}
+		if desc {
+			column += " desc"
+		} else {
+			column += " asc"
+		}
+		q.order = append(q.order, column)
+	}
+	return q
+}
+
+// Offset skips the first records
+func (q *QuerySet[T]) Offset(offset int) *QuerySet[T] {
+	q.offset = offset
+	return q
+}
+
+// Limit limits the number of records
+func (q *QuerySet[T]) Limit(limit int) *QuerySet[T] {
+	q.limit = limit
+	return q
+}
+
+// All returns the records that match the query. Records are decrypted like
+// Filter and soft deleted records are excluded.
+func (q *QuerySet[T]) All(ctx context.Context) ([]T, error) {
+	list := []T{}
+	tx, err := q.build(ctx)
+	if err != nil {
+		return list, err
+	}
+	TimeMetric("uadmin/db/duration", 1000, func() {
+		err = tx.Find(&list).Error
+	})
+	if err != nil {
+		Trail(ERROR, "DB error in Query(%s).All. %s", q.schema.Name, err)
+		return list, err
+	}
+	decryptArray(&list)
+	return list, nil
+}
+
+// First returns the first record that matches the query with its M2M
+// fields like Get
+func (q *QuerySet[T]) First(ctx context.Context) (T, error) {
+	var m T
+	tx, err := q.build(ctx)
+	if err != nil {
+		return m, err
+	}
+	TimeMetric("uadmin/db/duration", 1000, func() {
+		if len(q.order) == 0 {
+			tx = tx.Order("id")
+		}
+		err = tx.Limit(1).Find(&m).Error
+	})
+	if err != nil {
+		Trail(ERROR, "DB error in Query(%s).First. %s", q.schema.Name, err)
+		return m, err
+	}
+	if GetID(reflect.ValueOf(m)) == 0 {
+		return m, gorm.ErrRecordNotFound
+	}
+	if err = customGetWith(readDBContext(ctx, &m), &m); err != nil {
+		return m, err
+	}
+	decryptRecord(&m)
+	return m, nil
+}
+
+// Count returns the number of records that match the query
+func (q *QuerySet[T]) Count(ctx context.Context) (int, error) {
+	var count int64
+	tx, err := q.build(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var m T
+	TimeMetric("uadmin/db/duration", 1000, func() {
+		err = tx.Model(&m).Offset(-1).Limit(-1).Count(&count).Error
+	})
+	if err != nil {
+		Trail(ERROR, "DB error in Query(%s).Count. %s", q.schema.Name, err)
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// build returns the gorm query
+func (q *QuerySet[T]) build(ctx context.Context) (*gorm.DB, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var m T
+	tx := readDBContext(ctx, &m)
+	for _, cond := range q.conds {
+		sql, args, err := q.conditionSQL(cond)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(sql, args...)
+	}
+	if len(q.order) != 0 {
+		tx = tx.Order(strings.Join(q.order, ", "))
+	}
+	if q.offset > 0 {
+		tx = tx.Offset(q.offset)
+	}
+	if q.limit > 0 {
+		tx = tx.Limit(q.limit)
+	}
+	return tx, nil
+}
+
+// conditionSQL returns the SQL and args of a condition
+func (q *QuerySet[T]) conditionSQL(cond Condition) (string, []interface{}, error) {
+	if cond.group != nil {
+		sqls := []string{}
+		args := []interface{}{}
+		for _, c := range cond.group {
+			sql, cArgs, err := q.conditionSQL(c)
+			if err != nil {
+				return "", nil, err
+			}
+			sqls = append(sqls, "("+sql+")")
+			args = append(args, cArgs...)
+		}
+		if len(sqls) == 0 {
+			return "1 = 1", args, nil
+		}
+		join := " AND "
+		if cond.or {
+			join = " OR "
+		}
+		return strings.Join(sqls, join), args, nil
+	}
+
+	column, err := q.column(cond.field)
+	if err != nil {
+		return "", nil, err
+	}
+	value := fmt.Sprint(cond.value)
+	switch cond.op {
+	case Eq:
+		return column + " = ?", []interface{}{cond.value}, nil
+	case Ne:
+		return column + " <> ?", []interface{}{cond.value}, nil
+	case Gt:
+		return column + " > ?", []interface{}{cond.value}, nil
+	case Gte:
+		return column + " >= ?", []interface{}{cond.value}, nil
+	case Lt:
+		return column + " < ?", []interface{}{cond.value}, nil
+	case Lte:
+		return column + " <= ?", []interface{}{cond.value}, nil
+	case In:
+		return column + " IN (?)", []interface{}{cond.value}, nil
+	case NotIn:
+		return column + " NOT IN (?)", []interface{}{cond.value}, nil
+	case IsNull:
+		return column + " IS NULL", nil, nil
+	case NotNull:
+		return column + " IS NOT NULL", nil, nil
+	case Between:
+		v := reflect.ValueOf(cond.value)
+		if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != 2 {
+			return "", nil, fmt.Errorf("the Between operator needs a slice of two values for %s", cond.field)
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{v.Index(0).Interface(), v.Index(1).Interface()}, nil
+	case Contains:
+		return column + " " + getLike(true) + " ?", []interface{}{"%" + value + "%"}, nil
+	case IContains:
+		return column + " " + getLike(false) + " ?", []interface{}{"%" + value + "%"}, nil
+	case StartsWith:
+		return column + " " + getLike(true) + " ?", []interface{}{value + "%"}, nil
+	case IStartsWith:
+		return column + " " + getLike(false) + " ?", []interface{}{value + "%"}, nil
+	case EndsWith:
+		return column + " " + getLike(true) + " ?", []interface{}{"%" + value}, nil
+	case IEndsWith:
+		return column + " " + getLike(false) + " ?", []interface{}{"%" + value}, nil
+	}
+	return "", nil, fmt.Errorf("invalid operator %d for %s", cond.op, cond.field)
+}
+
+// column returns the enclosed column name of a field name or column name.
+// Foreign keys are queried by their ID column.
+func (q *QuerySet[T]) column(name string) (string, error) {
+	f := q.schema.FieldByName(name)
+	if f.Name == "" {
+		f = q.schema.FieldByColumnName(name)
+	}
+	if f == nil || f.Name == "" {
+		// Foreign key IDs like CustomerID or customer_id
+		if fk := q.schema.FieldByName(strings.TrimSuffix(name, "ID")); strings.HasSuffix(name, "ID") && fk.Type == cFK {
+			f = fk
+		} else if fk := q.schema.FieldByColumnName(strings.TrimSuffix(name, "_id")); strings.HasSuffix(name, "_id") && fk != nil && fk.Type == cFK {
+			f = fk
+		}
+	}
+	if f == nil || f.Name == "" || f.IsMethod {
+		return "", fmt.Errorf("%s has no field named %s", q.schema.Name, name)
+	}
+	if f.Type == cM2M {
+		return "", fmt.Errorf("%s.%s is a M2M field and cannot be queried", q.schema.Name, f.Name)
+	}
+	if f.Encrypt {
+		return "", fmt.Errorf("%s.%s is encrypted and cannot be queried", q.schema.Name, f.Name)
+	}
+	column := f.ColumnName
+	if f.Type == cFK {
+		column += "_id"
+	}
+	return columnEnclosure() + column + columnEnclosure(), nil
+}
+
+// groupTruncations are the dialect SQL of date truncations of grouped
+// fields
+var groupTruncations = map[string]string{
+	"date":  "truncDay",
+	"day":   "truncDay",
+	"month": "truncMonth",
+	"year":  "truncYear",
+}
+
+// aggregateFuncs are the SQL functions of aggregates like the dAPI
+var aggregateFuncs = map[string]string{
+	"sum":   "SUM",
+	"avg":   "AVG",
+	"min":   "MIN",
+	"max":   "MAX",
+	"count": "COUNT",
+}
+
+// GroupBy groups the records by fields for Aggregate. A date field can be
+// truncated to the day, month or year like created_at__month. Truncated
+// dates are strings like 2006-01-01. Unlike the dAPI, __day and __month
+// truncate the date instead of returning the day or month number.
+func (q *QuerySet[T]) GroupBy(fields ...string) *QuerySet[T] {
+	q.group = append(q.group, fields...)
+	return q
+}
+
+// Aggregate computes aggregates of the records that match the query for
+// each group of GroupBy and scans a row for each group into dst. Aggregates
+// use the syntax of the dAPI like total__sum, price__avg or id__count and
+// count counts the records. dst is a pointer to a slice of structs or of
+// map[string]interface{}. Columns are named after the field and aggregate
+// like total_sum so they scan into a TotalSum field:
+//
+//	type Row struct {
+//		Category       string
+//		CreatedAtMonth string
+//		TotalSum       float64
+//		Count          int
+//	}
+//	rows := []Row{}
+//	err := uadmin.Query[Order]().GroupBy("Category", "CreatedAt__month").Aggregate(ctx, &rows, "Total__sum", "count")
+func (q *QuerySet[T]) Aggregate(ctx context.Context, dst interface{}, aggregates ...string) error {
+	var m T
+	tx, err := q.build(ctx)
+	if err != nil {
+		return err
+	}
+	dbType := getDBType(&m)
+
+	selects := []string{}
+	groups := []string{}
+	for _, field := range q.group {
+		name, trunc := field, ""
+		if parts := strings.SplitN(field, "__", 2); len(parts) == 2 {
+			name, trunc = parts[0], parts[1]
+		}
+		column, err := q.column(name)
+		if err != nil {
+			return err
+		}
+		alias := trimEnclosure(column)
+		expr := column
+		if trunc != "" {
+			dialect, ok := groupTruncations[trunc]
+			if !ok {
+				return fmt.Errorf("invalid date truncation %s for %s", trunc, name)
+			}
+			expr = strings.Replace(sqlDialect[dbType][dialect], "{COLUMN}", column, -1)
+			alias += "_" + trunc
+		}
+		selects = append(selects, expr+" AS "+columnEnclosure()+alias+columnEnclosure())
+		groups = append(groups, expr)
+	}
+	if len(aggregates) == 0 {
+		return fmt.Errorf("no aggregates to compute")
+	}
+	for _, aggregate := range aggregates {
+		if aggregate == "count" {
+			selects = append(selects, "COUNT(*) AS "+columnEnclosure()+"count"+columnEnclosure())
+			continue
+		}
+		parts := strings.SplitN(aggregate, "__", 2)
+		if len(parts) != 2 || aggregateFuncs[parts[1]] == "" {
+			return fmt.Errorf("invalid aggregate %s", aggregate)
+		}
+		column, err := q.column(parts[0])
+		if err != nil {
+			return err
+		}
+		alias := trimEnclosure(column) + "_" + parts[1]
+		selects = append(selects, aggregateFuncs[parts[1]]+"("+column+") AS "+columnEnclosure()+alias+columnEnclosure())
+	}
+
+	tx = tx.Model(&m).Select(strings.Join(selects, ", "))
+	if len(groups) != 0 {
+		tx = tx.Group(strings.Join(groups, ", "))
+		if len(q.order) == 0 {
+			tx = tx.Order(strings.Join(groups, ", "))
+		}
+	}
+	TimeMetric("uadmin/db/duration", 1000, func() {
+		err = tx.Scan(dst).Error
+	})
+	if err != nil {
+		Trail(ERROR, "DB error in Query(%s).Aggregate. %s", q.schema.Name, err)
+		return err
+	}
+	// gorm scans values of maps as pointers when the query has a model
+	if rows, ok := dst.(*[]map[string]interface{}); ok {
+		for _, row := range *rows {
+			for k, v := range row {
+				if p, ok := v.(*interface{}); ok {
+					row[k] = *p
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package uadmin
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TestSale struct {
+	Model
+	Category string
+	Total    float64
+	SoldAt   time.Time
+}
+
+// TestQuery is a unit testing function for the typed query API
+func (t *UAdminTests) TestQuery() {
+	a1 := TestModelA{Name: "query_a"}
+	a2 := TestModelA{Name: "query_b"}
+	a3 := TestModelA{Name: "query_c"}
+	for _, m := range []*TestModelA{&a1, &a2, &a3} {
+		Save(m)
+	}
+	b1 := TestModelB{Name: "query_b1", ItemCount: 2, OtherModelID: a1.ID, Phone: "0912345678", ModelAList: []TestModelA{a2, a3}}
+	b2 := TestModelB{Name: "query_b2", ItemCount: 4, OtherModelID: a2.ID}
+	Save(&b1)
+	Save(&b2)
+	defer DeleteList(&TestModelA{}, "name LIKE ?", "query_%")
+	defer DeleteList(&TestModelB{}, "name LIKE ?", "query_%")
+	ctx := context.Background()
+
+	list, err := Query[TestModelA]().Where("Name", StartsWith, "query_").OrderBy("-name").Limit(2).All(ctx)
+	if err != nil || len(list) != 2 || list[0].Name != "query_c" || list[1].Name != "query_b" {
+		t.Errorf("Query.All didn't return sorted and limited records. Got %v %v", list, err)
+	}
+	if n, err := Query[TestModelA]().Where("name", In, []string{"query_a", "query_c"}).Count(ctx); err != nil || n != 2 {
+		t.Errorf("Query.Count didn't count records with a column name. Got %d %v", n, err)
+	}
+
+	// Grouped conditions
+	list, _ = Query[TestModelA]().Filter(
+		C("Name", StartsWith, "query_"),
+		Or(C("Name", Eq, "query_a"), C("ID", Eq, a3.ID)),
+	).OrderBy("id").All(ctx)
+	if len(list) != 2 || list[0].ID != a1.ID || list[1].ID != a3.ID {
+		t.Errorf("Query.Filter didn't group conditions using Or. Got %v", list)
+	}
+
+	// Foreign keys, M2M fields and encrypted fields are handled like Get
+	b, err := Query[TestModelB]().Where("OtherModel", Eq, a1.ID).Where("ItemCount", Between, []int{1, 3}).First(ctx)
+	if err != nil || b.ID != b1.ID {
+		t.Errorf("Query.First didn't get a record by its foreign key. Got %d %v", b.ID, err)
+	}
+	if len(b.ModelAList) != 2 || b.Phone != "0912345678" {
+		t.Errorf("Query.First didn't get M2M fields or decrypt fields. Got %v %s", b.ModelAList, b.Phone)
+	}
+	if n, _ := Query[TestModelB]().Where("other_model_id", Ne, a1.ID).Where("Name", StartsWith, "query_").Count(ctx); n != 1 {
+		t.Errorf("Query.Count didn't count records by foreign key ID column. Got %d", n)
+	}
+	if _, err = Query[TestModelA]().Where("Name", Eq, "query_none").First(ctx); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Query.First didn't return ErrRecordNotFound. Got %v", err)
+	}
+
+	// Soft deleted records are excluded
+	Delete(&a3)
+	if n, _ := Query[TestModelA]().Where("Name", StartsWith, "query_").Count(ctx); n != 2 {
+		t.Errorf("Query.Count counted soft deleted records. Got %d", n)
+	}
+
+	// Field names are checked against the schema
+	for _, q := range []*QuerySet[TestModelB]{
+		Query[TestModelB]().Where("Nmae", Eq, "x"),
+		Query[TestModelB]().OrderBy("-Nmae"),
+		Query[TestModelB]().Where("ModelAList", Eq, 1),
+		Query[TestModelB]().Where("Phone", Eq, "0912345678"),
+		Query[TestModelB]().Filter(Or(C("Name", Eq, "x"), C("Nmae", Eq, "y"))),
+	} {
+		if _, err := q.All(ctx); err == nil {
+			t.Errorf("Query didn't return an error for an invalid field")
+		}
+	}
+}
+
+// TestQueryAggregate is a unit testing function for grouped aggregation
+func (t *UAdminTests) TestQueryAggregate() {
+	initializeDB(TestSale{})
+	defer db.Migrator().DropTable(&TestSale{})
+	sales := []TestSale{
+		{Category: "books", Total: 10, SoldAt: time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)},
+		{Category: "books", Total: 20, SoldAt: time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)},
+		{Category: "books", Total: 5, SoldAt: time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{Category: "games", Total: 50, SoldAt: time.Date(2026, 1, 7, 10, 0, 0, 0, time.UTC)},
+		{Category: "games", Total: 70, SoldAt: time.Date(2025, 12, 7, 10, 0, 0, 0, time.UTC)},
+	}
+	for i := range sales {
+		Save(&sales[i])
+	}
+	Delete(&sales[4])
+	ctx := context.Background()
+
+	type categoryRow struct {
+		Category string
+		TotalSum float64
+		TotalMax float64
+		TotalAvg float64
+		IDCount  int
+		Count    int
+	}
+	rows := []categoryRow{}
+	if err := Query[TestSale]().GroupBy("Category").Aggregate(ctx, &rows, "Total__sum", "Total__max", "Total__avg", "ID__count", "count"); err != nil {
+		t.Errorf("Query.Aggregate returned an error. %s", err)
+	}
+	expected := []categoryRow{{"books", 35, 20, 35.0 / 3, 3, 3}, {"games", 50, 50, 50, 1, 1}}
+	if len(rows) != len(expected) {
+		t.Errorf("Query.Aggregate didn't group by category. Got %#v", rows)
+	} else {
+		for i := range expected {
+			if rows[i].Category != expected[i].Category || rows[i].TotalSum != expected[i].TotalSum || rows[i].TotalMax != expected[i].TotalMax || int(rows[i].TotalAvg*100) != int(expected[i].TotalAvg*100) || rows[i].Count != expected[i].Count || rows[i].IDCount != expected[i].IDCount {
+				t.Errorf("Query.Aggregate returned an invalid row. Expected %#v got %#v", expected[i], rows[i])
+			}
+		}
+	}
+
+	// Date truncations with conditions
+	monthRows := []map[string]interface{}{}
+	Query[TestSale]().Where("Category", Eq, "books").GroupBy("SoldAt__month").Aggregate(ctx, &monthRows, "Total__sum")
+	if len(monthRows) != 2 || monthRows[0]["sold_at_month"] != "2026-01-01" || monthRows[1]["sold_at_month"] != "2026-02-01" {
+		t.Errorf("Query.Aggregate didn't group by month. Got %v", monthRows)
+	}
+	type yearRow struct {
+		SoldAtYear string
+		Count      int
+	}
+	yearRows := []yearRow{}
+	Query[TestSale]().GroupBy("sold_at__year").Aggregate(ctx, &yearRows, "count")
+	if len(yearRows) != 1 || yearRows[0].SoldAtYear != "2026-01-01" || yearRows[0].Count != 4 {
+		t.Errorf("Query.Aggregate didn't group by year. Got %v", yearRows)
+	}
+
+	// Invalid aggregates
+	for _, err := range []error{
+		Query[TestSale]().GroupBy("Category").Aggregate(ctx, &rows),
+		Query[TestSale]().GroupBy("Category").Aggregate(ctx, &rows, "Total__median"),
+		Query[TestSale]().GroupBy("Categroy").Aggregate(ctx, &rows, "count"),
+		Query[TestSale]().GroupBy("SoldAt__week").Aggregate(ctx, &rows, "count"),
+	} {
+		if err == nil {
+			t.Errorf("Query.Aggregate didn't return an error for an invalid aggregate")
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package uadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RecordVersion is the state of a record after a logged change
+type RecordVersion struct {
+	Version    int               `json:"version"`
+	LogID      uint              `json:"log_id"`
+	Action     Action            `json:"action"`
+	ActionName string            `json:"action_name"`
+	Username   string            `json:"username"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Deleted    bool              `json:"deleted"`
+	Values     map[string]string `json:"values"`
+}
+
+// VersionChange is a field that is different between two versions
+type VersionChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// GetRecordHistory returns all the versions of a record starting from the
+// oldest one. Values of password and encrypted fields are masked.
+func GetRecordHistory(modelName string, ID uint) ([]RecordVersion, error) {
+	versions, err := getRecordVersions(modelName, ID)
+	if err != nil {
+		return nil, err
+	}
+	s, _ := getSchema(modelName)
+	for i := range versions {
+		maskVersionValues(versions[i].Values, &s)
+	}
+	return versions, nil
+}
+
+// DiffRecordVersions returns the fields that changed between two versions
+// of a record
+func DiffRecordVersions(modelName string, ID uint, from int, to int) ([]VersionChange, error) {
+	versions, err := getRecordVersions(modelName, ID)
+	if err != nil {
+		return nil, err
+	}
+	if from < 1 || from > len(versions) || to < 1 || to > len(versions) {
+		return nil, fmt.Errorf("invalid version number. Record has %d versions", len(versions))
+	}
+	s, _ := getSchema(modelName)
+	fromValues := versions[from-1].Values
+	toValues := versions[to-1].Values
+	changes := []VersionChange{}
+	for _, f := range s.Fields {
+		key := f.Name
+		if f.Type == cFK {
+			key = f.Name + "ID"
+		}
+		if f.IsMethod || f.Type == cID || fromValues[key] == toValues[key] {
+			continue
+		}
+		change := VersionChange{
+			Field: key,
+			From:  fromValues[key],
+			To:    toValues[key],
+		}
+		if f.Type == cPASSWORD || f.Encrypt {
+			change.From = "*****"
+			change.To = "*****"
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// RestoreRecordVersion sets a record back to the values it had in a version.
+// Deleted records are restored. The change is recorded as a new log which is
+// returned.
+func RestoreRecordVersion(modelName string, ID uint, version int, user *User, r *http.Request) (*Log, error) {
+	versions, err := getRecordVersions(modelName, ID)
+	if err != nil {
+		return nil, err
+	}
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("invalid version number. Record has %d versions", len(versions))
+	}
+	target := versions[version-1]
+	if target.Deleted {
+		return nil, fmt.Errorf("version %d is a deleted version", version)
+	}
+
+	s, _ := getSchema(modelName)
+	model, _ := NewModel(modelName, true)
+	deleted, err := getRecordUnscoped(model.Interface(), ID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := getRecordSnapshot(model, &s)
+	action := Action(0).Modified()
+	if deleted {
+		err = modelDB(model.Interface()).Unscoped().Model(model.Interface()).Where("id = ?", ID).Update("deleted_at", nil).Error
+		if err != nil {
+			Trail(ERROR, "RestoreRecordVersion unable to restore %s(%d). %s", modelName, ID, err)
+			return nil, err
+		}
+		action = action.Restored()
+	}
+	applyLogSnapshot(model.Elem(), &s, target.Values)
+	if err = Save(model.Interface()); err != nil {
+		return nil, err
+	}
+
+	// Modified and restored logs store the record before the change
+	activity := before
+	activity["_RestoreVersion"] = fmt.Sprint(version)
+	if r != nil {
+		activity["_IP"] = GetRemoteIP(r)
+	}
+	b, _ := json.Marshal(activity)
+	log := &Log{
+		TableName: modelName,
+		TableID:   int(ID),
+		Action:    action,
+		Activity:  string(b),
+	}
+	if user != nil {
+		log.Username = user.Username
+	}
+	log.Save()
+	return log, nil
+}
+
+// getRecordVersions builds the versions of a record from its logs. Modified
+// and restored logs store the record before the change, so the state after a
+// change is taken from the next modified or restored log or the current
+// record for the last one. The record had no state before its first added
+// log.
+func getRecordVersions(modelName string, ID uint) ([]RecordVersion, error) {
+	modelName = strings.ToLower(modelName)
+	s, ok := getSchema(modelName)
+	if !ok {
+		return nil, fmt.Errorf("invalid model name: %s", modelName)
+	}
+
+	logs := []Log{}
+	action := Action(0)
+	FilterSorted("id", true, &logs, "table_name = ? AND table_id = ? AND action IN (?)",
+		modelName, ID, []Action{action.Added(), action.Modified(), action.Deleted(), action.Restored(), action.Purged()})
+
+	model, _ := NewModel(modelName, true)
+	state := map[string]string{}
+	if _, err := getRecordUnscoped(model.Interface(), ID); err == nil {
+		state = getRecordSnapshot(model, &s)
+	}
+
+	firstAdded := -1
+	for i := range logs {
+		if logs[i].Action == action.Added() {
+			firstAdded = i
+			break
+		}
+	}
+
+	versions := make([]RecordVersion, len(logs))
+	for i := len(logs) - 1; i >= 0; i-- {
+		versions[i] = RecordVersion{
+			Version:    i + 1,
+			LogID:      logs[i].ID,
+			Action:     logs[i].Action,
+			ActionName: getActionName(logs[i].Action),
+			Username:   logs[i].Username,
+			CreatedAt:  logs[i].CreatedAt,
+			Values:     state,
+		}
+		switch logs[i].Action {
+		case action.Modified(), action.Restored():
+			snapshot, err := parseLogActivity(logs[i].Activity, &s)
+			if err != nil {
+				Trail(WARNING, "getRecordVersions unable to parse activity of log %d. %s", logs[i].ID, err)
+				continue
+			}
+			state = map[string]string{}
+			for k, v := range snapshot {
+				if !strings.HasPrefix(k, "_") {
+					state[k] = v
+				}
+			}
+		case action.Added():
+			if i == firstAdded {
+				state = map[string]string{}
+			}
+		}
+	}
+
+	deleted := false
+	for i := range versions {
+		switch versions[i].Action {
+		case action.Added(), action.Modified(), action.Restored():
+			deleted = false
+		case action.Deleted(), action.Purged():
+			deleted = true
+		}
+		versions[i].Deleted = deleted
+	}
+	return versions, nil
+}
+
+// getRecordUnscoped gets a record including soft deleted records and returns
+// true if the record is deleted
+func getRecordUnscoped(a interface{}, ID uint) (bool, error) {
+	err := modelDB(a).Unscoped().Where("id = ?", ID).First(a).Error
+	if err != nil {
+		return false, err
+	}
+	customGet(a)
+	decryptRecord(a)
+	var count int64
+	modelDB(a).Unscoped().Model(a).Where("id = ? AND deleted_at IS NOT NULL", ID).Count(&count)
+	return count != 0, nil
+}
+
+func maskVersionValues(values map[string]string, s *ModelSchema) {
+	for _, f := range s.Fields {
+		if (f.Type == cPASSWORD || f.Encrypt) && values[f.Name] != "" {
+			values[f.Name] = "*****"
+		}
+	}
+}
+
+func getActionName(a Action) string {
+	switch a {
+	case a.Added():
+		return "Added"
+	case a.Modified():
+		return "Modified"
+	case a.Deleted():
+		return "Deleted"
+	case a.Restored():
+		return "Restored"
+	case a.Purged():
+		return "Purged"
+	}
+	return fmt.Sprint(int(a))
+}
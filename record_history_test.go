@@ -0,0 +1,120 @@
+package uadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TestRecordHistory is a unit testing function for GetRecordHistory(),
+// DiffRecordVersions(), RestoreRecordVersion() and dAPI history
+func (t *UAdminTests) TestRecordHistory() {
+	s1 := &Session{
+		UserID:    1,
+		Active:    true,
+		LoginTime: time.Now(),
+	}
+	s1.GenerateKey()
+	s1.Save()
+	Preload(s1)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	m := TestModelA{Name: "v1"}
+	Save(&m)
+	log := Log{}
+	log.ParseRecord(reflect.ValueOf(m), "testmodela", m.ID, &s1.User, log.Action.Added(), r)
+	log.Save()
+	for _, name := range []string{"v2", "v3"} {
+		log = Log{}
+		log.ParseRecord(reflect.ValueOf(m), "testmodela", m.ID, &s1.User, log.Action.Modified(), r)
+		log.Save()
+		m.Name = name
+		Save(&m)
+	}
+
+	versions, err := GetRecordHistory("testmodela", m.ID)
+	if err != nil {
+		t.Errorf("GetRecordHistory returned an error. %s", err)
+	}
+	if len(versions) != 3 {
+		t.Errorf("GetRecordHistory returned invalid number of versions. Got %d expected %d", len(versions), 3)
+	} else {
+		for i, name := range []string{"v1", "v2", "v3"} {
+			if versions[i].Values["Name"] != name {
+				t.Errorf("GetRecordHistory returned invalid Name for version %d. Got %s expected %s", i+1, versions[i].Values["Name"], name)
+			}
+			if versions[i].Username != s1.User.Username {
+				t.Errorf("GetRecordHistory returned invalid Username for version %d. Got %s expected %s", i+1, versions[i].Username, s1.User.Username)
+			}
+		}
+	}
+
+	changes, err := DiffRecordVersions("testmodela", m.ID, 1, 3)
+	if err != nil {
+		t.Errorf("DiffRecordVersions returned an error. %s", err)
+	}
+	if len(changes) != 1 || changes[0].Field != "Name" || changes[0].From != "v1" || changes[0].To != "v3" {
+		t.Errorf("DiffRecordVersions returned invalid changes. Got %#v", changes)
+	}
+	if _, err = DiffRecordVersions("testmodela", m.ID, 1, 4); err == nil {
+		t.Errorf("DiffRecordVersions didn't return an error for an invalid version")
+	}
+
+	// Read the history from dAPI
+	w := httptest.NewRecorder()
+	r = httptest.NewRequest("GET", fmt.Sprintf("/api/d/testmodela/history/%d/", m.ID), nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	apiHandler(w, r)
+	obj := map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &obj)
+	if result, ok := obj["versions"].([]interface{}); !ok || len(result) != 3 {
+		t.Errorf("dAPI history returned invalid versions. Got %s", w.Body.String())
+	}
+
+	// Restore version 1 from dAPI
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", fmt.Sprintf("/api/d/testmodela/history/%d/", m.ID), strings.NewReader(url.Values{
+		"$restore":     {"1"},
+		"x-csrf-token": {s1.Key},
+	}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	apiHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("dAPI history restore returned invalid code. Got %d expected %d. %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	mDB := TestModelA{}
+	Get(&mDB, "id = ?", m.ID)
+	if mDB.Name != "v1" {
+		t.Errorf("RestoreRecordVersion didn't restore the record. Got %s expected %s", mDB.Name, "v1")
+	}
+	versions, _ = GetRecordHistory("testmodela", m.ID)
+	if len(versions) != 4 || versions[3].Values["Name"] != "v1" {
+		t.Errorf("RestoreRecordVersion didn't add a new version. Got %#v", versions)
+	}
+
+	// Restoring a version of a deleted record logs it as restored and keeps
+	// the values of the versions before it
+	log = Log{}
+	log.ParseRecord(reflect.ValueOf(mDB), "testmodela", m.ID, &s1.User, log.Action.Deleted(), r)
+	log.Save()
+	Delete(mDB)
+	if _, err = RestoreRecordVersion("testmodela", m.ID, 2, &s1.User, r); err != nil {
+		t.Errorf("RestoreRecordVersion returned an error for a deleted record. %s", err)
+	}
+	versions, _ = GetRecordHistory("testmodela", m.ID)
+	if len(versions) != 6 || versions[5].Action != log.Action.Restored() || versions[5].Values["Name"] != "v2" {
+		t.Errorf("RestoreRecordVersion didn't add a restored version. Got %#v", versions)
+	} else if versions[0].Values["Name"] != "v1" || versions[4].Values["Name"] != "v1" || !versions[4].Deleted {
+		t.Errorf("GetRecordHistory lost the values of the versions before a restore. Got %#v", versions)
+	}
+
+	Delete(m)
+	Delete(s1)
+}
@@ -39,6 +39,22 @@ var CustomTranslation = []string{
 
 var modelList []interface{}
 
+// registeredModels returns the registered models. The map is replaced and
+// not changed when models are loaded so it is safe to read without a lock.
+func registeredModels() map[string]interface{} {
+	modelsMutex.RLock()
+	defer modelsMutex.RUnlock()
+	return models
+}
+
+// registeredSchema returns the global schema. Like registeredModels, the
+// map is replaced and not changed when models are loaded.
+func registeredSchema() map[string]ModelSchema {
+	modelsMutex.RLock()
+	defer modelsMutex.RUnlock()
+	return Schema
+}
+
 // Register is used to register models to uadmin
 func Register(m ...interface{}) {
 	modelList = []interface{}{}
@@ -59,10 +75,21 @@ func Register(m ...interface{}) {
 			Setting{},
 			SettingCategory{},
 			Approval{},
+			ApprovalWorkflow{},
+			ApprovalStage{},
+			ApprovalRequest{},
+			ApprovalComment{},
+			Job{},
+			JobRun{},
+			Task{},
+			DataExport{},
+			DataImport{},
+			Migration{},
+			MigrationLock{},
 			ABTest{},
 			ABTestValue{},
-			//Builder{},
-			//BuilderField{},
+			Builder{},
+			BuilderField{},
 		}
 	}
 
@@ -74,6 +101,10 @@ func Register(m ...interface{}) {
 		m...,
 	)
 
+	// Run the command in the arguments of the application instead of
+	// migrating the database and starting the server
+	handleCommand(modelList)
+
 	// Initialize the Database
 	initializeDB(modelList...)
 
@@ -103,7 +134,7 @@ func Register(m ...interface{}) {
 	for i := range modelList {
 		modelExists = false
 		t := reflect.TypeOf(modelList[i])
-		name := strings.ToLower(t.Name())
+		name := strings.ToLower(getTypeName(t))
 		models[name] = modelList[i]
 
 		// Get Hidden model status
@@ -137,7 +168,7 @@ func Register(m ...interface{}) {
 		// If not in dashboard, then add it
 		if !modelExists {
 			dashboard := DashboardMenu{
-				MenuName: inflection.Plural(strings.Join(helper.SplitCamelCase(t.Name()), " ")),
+				MenuName: inflection.Plural(strings.Join(helper.SplitCamelCase(getTypeName(t)), " ")),
 				URL:      name,
 				Hidden:   hideItem,
 				Cat:      cat,
@@ -156,66 +187,26 @@ func Register(m ...interface{}) {
 		}
 	}
 
-	// Check if encrypt key is there or generate it
-	if _, err := os.Stat(".key"); os.IsNotExist(err) && os.Getenv("UADMIN_KEY") == "" {
-		EncryptKey = generateByteArray(32)
-		ioutil.WriteFile(".key", EncryptKey, 0600)
-	} else {
-		EncryptKey = []byte(os.Getenv("UADMIN_KEY"))
-		if len(EncryptKey) == 0 {
-			EncryptKey, _ = ioutil.ReadFile(".key")
-		}
-	}
-
-	// Check if JWT key is there or generate it
-	if _, err := os.Stat(".jwt"); os.IsNotExist(err) && os.Getenv("UADMIN_JWT") == "" {
-		JWT = GenerateBase64(64)
-		ioutil.WriteFile(".jwt", []byte(JWT), 0600)
-	} else {
-		JWT = os.Getenv("UADMIN_JWT")
-		if len(JWT) == 0 {
-			buf, _ := ioutil.ReadFile(".jwt")
-			JWT = string(buf)
-		}
-	}
-	JWTIssuer = func() string {
-		hash := sha512.New()
-		hash.Write([]byte(JWT))
-		buf := hash.Sum(nil)
-		b64 := base64.RawURLEncoding.EncodeToString(buf)
-		return b64[:8]
-	}()
-
-	// Check if salt is there or generate it
+	// Load the keys and create a recovery admin if the salt was generated
 	users := []User{}
-	if _, err := os.Stat(".salt"); os.IsNotExist(err) && os.Getenv("UADMIN_SALT") == "" {
-		Salt = GenerateBase64(72)
-		ioutil.WriteFile(".salt", []byte(Salt), 0600)
-		if Count(&users, "") != 0 {
-			recoveryPass := GenerateBase64(24)
-			recoverUsername := GenerateBase64(8)
-			for Count(&users, "username = ?", recoverUsername) != 0 {
-				recoverUsername = GenerateBase64(8)
-			}
-			admin := User{
-				FirstName:    "System",
-				LastName:     "Recovery Admin",
-				Username:     recoverUsername,
-				Password:     hashPass(recoveryPass),
-				Admin:        true,
-				RemoteAccess: false,
-				Active:       true,
-			}
-			admin.Save()
-			Trail(WARNING, "Your salt file was missing, and a new one was generated NO USERS CAN LOGIN UNTIL PASSWORDS ARE RESET.")
-			Trail(INFO, "uAdmin generated a recovery user for you. Username:%s Password:%s", admin.Username, recoveryPass)
+	if initializeKeys() && Count(&users, "") != 0 {
+		recoveryPass := GenerateBase64(24)
+		recoverUsername := GenerateBase64(8)
+		for Count(&users, "username = ?", recoverUsername) != 0 {
+			recoverUsername = GenerateBase64(8)
 		}
-	} else {
-		Salt = os.Getenv("UADMIN_SALT")
-		if Salt == "" {
-			saltBytes, _ := ioutil.ReadFile(".salt")
-			Salt = string(saltBytes)
+		admin := User{
+			FirstName:    "System",
+			LastName:     "Recovery Admin",
+			Username:     recoverUsername,
+			Password:     hashPass(recoveryPass),
+			Admin:        true,
+			RemoteAccess: false,
+			Active:       true,
 		}
+		admin.Save()
+		Trail(WARNING, "Your salt file was missing, and a new one was generated NO USERS CAN LOGIN UNTIL PASSWORDS ARE RESET.")
+		Trail(INFO, "uAdmin generated a recovery user for you. Username:%s Password:%s", admin.Username, recoveryPass)
 	}
 
 	// Create an admin user if there is no user in the system
@@ -254,10 +245,33 @@ func Register(m ...interface{}) {
 		"ABTestValue": "ABTestID",
 	})
 
+	RegisterInlines(ApprovalWorkflow{}, map[string]string{
+		"ApprovalStage": "WorkflowID",
+	})
+
+	RegisterInlines(Job{}, map[string]string{
+		"JobRun": "JobID",
+	})
+
+	RegisterInlines(Builder{}, map[string]string{
+		"BuilderField": "BuilderID",
+	})
+
+	RegisterInlines(ApprovalRequest{}, map[string]string{
+		"Approval":        "ApprovalRequestID",
+		"ApprovalComment": "ApprovalRequestID",
+	})
+
 	for k, v := range models {
 		Schema[k], _ = getSchema(v)
 	}
 
+	// Build the models defined using the model builder
+	builderModels = map[string]bool{}
+	if err := loadBuilderModels(); err != nil {
+		Trail(ERROR, "Unable to build models of the model builder. %s", err)
+	}
+
 	// Register JS
 	s := Schema["abtest"]
 	s.IncludeFormJS = []string{"/static/uadmin/js/abtest_form.js"}
@@ -292,6 +306,53 @@ func Register(m ...interface{}) {
 	registered = true
 }
 
+// initializeKeys loads the encryption key, the JWT key and the salt or
+// generates the missing ones. It returns true if a new salt was generated.
+func initializeKeys() bool {
+	// Check if encrypt key is there or generate it
+	if _, err := os.Stat(".key"); os.IsNotExist(err) && os.Getenv("UADMIN_KEY") == "" {
+		EncryptKey = generateByteArray(32)
+		ioutil.WriteFile(".key", EncryptKey, 0600)
+	} else {
+		EncryptKey = []byte(os.Getenv("UADMIN_KEY"))
+		if len(EncryptKey) == 0 {
+			EncryptKey, _ = ioutil.ReadFile(".key")
+		}
+	}
+
+	// Check if JWT key is there or generate it
+	if _, err := os.Stat(".jwt"); os.IsNotExist(err) && os.Getenv("UADMIN_JWT") == "" {
+		JWT = GenerateBase64(64)
+		ioutil.WriteFile(".jwt", []byte(JWT), 0600)
+	} else {
+		JWT = os.Getenv("UADMIN_JWT")
+		if len(JWT) == 0 {
+			buf, _ := ioutil.ReadFile(".jwt")
+			JWT = string(buf)
+		}
+	}
+	JWTIssuer = func() string {
+		hash := sha512.New()
+		hash.Write([]byte(JWT))
+		buf := hash.Sum(nil)
+		b64 := base64.RawURLEncoding.EncodeToString(buf)
+		return b64[:8]
+	}()
+
+	// Check if salt is there or generate it
+	if _, err := os.Stat(".salt"); os.IsNotExist(err) && os.Getenv("UADMIN_SALT") == "" {
+		Salt = GenerateBase64(72)
+		ioutil.WriteFile(".salt", []byte(Salt), 0600)
+		return true
+	}
+	Salt = os.Getenv("UADMIN_SALT")
+	if Salt == "" {
+		saltBytes, _ := ioutil.ReadFile(".salt")
+		Salt = string(saltBytes)
+	}
+	return false
+}
+
 // RegisterInlines is a function to register a model as an inline for another model
 // Parameters:
 // ===========
@@ -323,7 +384,7 @@ func Register(m ...interface{}) {
 func RegisterInlines(model interface{}, fk map[string]string) {
 	// TODO: sanity check for the parameters
 	// Get the name of the model
-	modelName := strings.ToLower(reflect.TypeOf(model).Name())
+	modelName := strings.ToLower(getTypeName(reflect.TypeOf(model)))
 	if inlines == nil {
 		inlines = map[string][]interface{}{}
 	}
@@ -335,7 +396,7 @@ func RegisterInlines(model interface{}, fk map[string]string) {
 	for k, v := range fk {
 		kmodel, _ := NewModel(strings.ToLower(k), false)
 		t := reflect.TypeOf(kmodel.Interface())
-		fkMap[strings.ToLower(t.Name())] = GetDB().Config.NamingStrategy.ColumnName("", v)
+		fkMap[strings.ToLower(getTypeName(t))] = GetDB().Config.NamingStrategy.ColumnName("", v)
 		// Check if the field name is in the struct
 		if t.Kind() != reflect.Struct {
 			Trail(ERROR, "Unable to register inline for (%s) inline %s.%s. Please pass a struct as key.", reflect.TypeOf(model).Name(), t.Name(), v)
@@ -348,7 +409,7 @@ func RegisterInlines(model interface{}, fk map[string]string) {
 		inlineList = append(inlineList, kmodel.Interface())
 	}
 	inlines[modelName] = inlineList
-	inlines[reflect.TypeOf(model).Name()] = inlineList
+	inlines[getTypeName(reflect.TypeOf(model))] = inlineList
 	foreignKeys[modelName] = fkMap
 	delete(Schema, modelName)
 	Schema[modelName], _ = getSchema(model)
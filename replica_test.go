@@ -0,0 +1,85 @@
+package uadmin
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TestReadReplica is a unit testing function for routing reads to read
+// replicas
+func (t *UAdminTests) TestReadReplica() {
+	replicaFile := "uadmin_replica_test.db"
+	r, err := openDB(&DBSettings{Type: "sqlite", Name: replicaFile})
+	if err != nil {
+		t.Errorf("openReplica unable to open a SQLite replica. %s", err)
+		return
+	}
+	r.AutoMigrate(&TestModelA{})
+	r.Create(&TestModelA{Name: "From Replica"})
+
+	savedReplicas := replicas
+	savedWindow := ReadYourWritesWindow
+	replicas = []*gorm.DB{r}
+	if db.Callback().Create().Get("uadmin:mark_write") == nil {
+		db.Callback().Create().After("gorm:create").Register("uadmin:mark_write", markWrite)
+	}
+	defer func() {
+		replicas = savedReplicas
+		ReadYourWritesWindow = savedWindow
+		if sqlDB, err := r.DB(); err == nil {
+			sqlDB.Close()
+		}
+		os.Remove(replicaFile)
+	}()
+
+	// Reads go to the replica
+	m := TestModelA{}
+	Get(&m, "name = ?", "From Replica")
+	if m.ID == 0 {
+		t.Errorf("Get didn't read from the replica")
+	}
+	if n := Count(&[]TestModelA{}, "name = ?", "From Replica"); n != 1 {
+		t.Errorf("Count didn't read from the replica. Expected 1 got %d", n)
+	}
+
+	// Reads of a session go to the primary within the read your writes
+	// window after it writes
+	ReadYourWritesWindow = time.Minute
+	ctx := withReadSession(context.Background(), "writer")
+	w := TestModelA{Name: "From Primary"}
+	db.WithContext(ctx).Create(&w)
+	defer Delete(&w)
+	m = TestModelA{}
+	GetContext(ctx, &m, "name = ?", "From Primary")
+	if m.ID != w.ID {
+		t.Errorf("GetContext didn't read from the primary after a write of the session")
+	}
+
+	// Reads of other sessions still go to the replica
+	m = TestModelA{}
+	GetContext(withReadSession(context.Background(), "reader"), &m, "name = ?", "From Primary")
+	if m.ID != 0 {
+		t.Errorf("GetContext read from the primary after a write of another session")
+	}
+	m = TestModelA{}
+	Get(&m, "name = ?", "From Primary")
+	if m.ID != 0 {
+		t.Errorf("Get read from the primary without a session")
+	}
+
+	// Reads go back to the replica after the window
+	ReadYourWritesWindow = 0
+	m = TestModelA{}
+	GetContext(ctx, &m, "name = ?", "From Primary")
+	if m.ID != 0 {
+		t.Errorf("GetContext didn't read from the replica after the read your writes window")
+	}
+
+	// Replica settings default to the settings of the database
+	if s := getDBSettings(DBSettings{Host: "replica"}); s.Type != Database.Type || s.Name != Database.Name || s.Host != "replica" {
+		t.Errorf("getDBSettings didn't default to the settings of the database. Got %#v", s)
+	}
+}
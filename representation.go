@@ -101,7 +101,7 @@ func getModelName(a interface{}) string {
 	//if val, ok := a.(reflect.Type); ok {
 	//	return strings.ToLower(val.Name())
 	//}
-	return strings.ToLower(reflect.TypeOf(a).Name())
+	return strings.ToLower(getTypeName(reflect.TypeOf(a)))
 }
 
 // getModelName returns the name of a model
@@ -121,5 +121,15 @@ func getModelNameNorm(a interface{}) string {
 	//if val, ok := a.(reflect.Type); ok {
 	//	return strings.ToLower(val.Name())
 	//}
-	return reflect.TypeOf(a).Name()
+	return getTypeName(reflect.TypeOf(a))
+}
+
+// getTypeName returns the name of a type. Models built at runtime by the
+// model builder don't have a type name so their name is read from the tag
+// of their embedded Model.
+func getTypeName(t reflect.Type) string {
+	if t.Name() == "" && t.Kind() == reflect.Struct && t.NumField() != 0 && t.Field(0).Anonymous {
+		return t.Field(0).Tag.Get(builderModelTag)
+	}
+	return t.Name()
 }
@@ -0,0 +1,416 @@
+package uadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errRevertNotFound is returned when the record of a log does not exist
+var errRevertNotFound = errors.New("record does not exist")
+
+// RevertChange is a single field that changes when a log is reverted
+type RevertChange struct {
+	Field    string `json:"field"`
+	Current  string `json:"current"`
+	Reverted string `json:"reverted"`
+}
+
+// RevertPreview describes what reverting a log would do to a record
+type RevertPreview struct {
+	LogID     uint           `json:"log_id"`
+	ModelName string         `json:"model_name"`
+	ModelID   int            `json:"model_id"`
+	Action    Action         `json:"action"`
+	Operation string         `json:"operation"`
+	Changes   []RevertChange `json:"changes"`
+}
+
+// IsRevertable returns true if the log's action can be reverted
+func (l Log) IsRevertable() bool {
+	return l.Action == l.Action.Added() || l.Action == l.Action.Modified() || l.Action == l.Action.Deleted()
+}
+
+// PreviewRevertLog returns the changes that RevertLog would make without
+// applying them
+func PreviewRevertLog(l *Log) (*RevertPreview, error) {
+	preview, _, err := revertLog(nil, l, nil, nil, false)
+	return preview, err
+}
+
+// RevertLog reverts the change recorded in a log:
+//   - Added records are deleted
+//   - Deleted records are restored
+//   - Modified records are set back to the values they had before the change
+//
+// The revert is recorded as a new log which is returned.
+func RevertLog(l *Log, user *User, r *http.Request) (*Log, error) {
+	var newLog *Log
+	err := Transaction(requestContext(r), func(tx *Tx) (err error) {
+		_, newLog, err = revertLog(tx, l, user, r, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newLog, nil
+}
+
+// PreviewRevertUserLogs returns the changes that RevertUserLogs would
+// make without applying them. Each preview is calculated against the
+// current state of the record and logs of records that no longer exist
+// are skipped.
+func PreviewRevertUserLogs(username string, from time.Time, to time.Time) ([]RevertPreview, error) {
+	previews := []RevertPreview{}
+	for _, l := range getUserRevertableLogs(username, from, to) {
+		preview, err := PreviewRevertLog(&l)
+		if errors.Is(err, errRevertNotFound) {
+			continue
+		}
+		if err != nil {
+			return previews, err
+		}
+		previews = append(previews, *preview)
+	}
+	return previews, nil
+}
+
+// RevertUserLogs reverts all the changes made by a user between from and
+// to starting from the most recent one and returns the new logs. Logs of
+// records that no longer exist are skipped. The reverts run in one
+// transaction so none of them are applied if one of them fails.
+func RevertUserLogs(username string, from time.Time, to time.Time, user *User, r *http.Request) ([]Log, error) {
+	logs := []Log{}
+	err := Transaction(requestContext(r), func(tx *Tx) error {
+		for _, l := range getUserRevertableLogs(username, from, to) {
+			_, newLog, err := revertLog(tx, &l, user, r, true)
+			if errors.Is(err, errRevertNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			logs = append(logs, *newLog)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// requestContext returns the context of a request or the background context
+// if there is no request
+func requestContext(r *http.Request) context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return r.Context()
+}
+
+// getUserRevertableLogs returns the logs that can be reverted for a user
+// in a time window sorted from newest to oldest
+func getUserRevertableLogs(username string, from time.Time, to time.Time) []Log {
+	logs := []Log{}
+	action := Action(0)
+	FilterSorted("id", false, &logs, "username = ? AND created_at >= ? AND created_at <= ? AND action IN (?)",
+		username, from, to, []Action{action.Added(), action.Modified(), action.Deleted()})
+	return logs
+}
+
+// revertLog previews or applies the revert of a log. The revert is applied
+// in tx which is nil for previews.
+func revertLog(tx *Tx, l *Log, user *User, r *http.Request, apply bool) (*RevertPreview, *Log, error) {
+	if !l.IsRevertable() {
+		return nil, nil, fmt.Errorf("log %d with action %d cannot be reverted", l.ID, l.Action)
+	}
+	s, ok := getSchema(l.TableName)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid model name: %s", l.TableName)
+	}
+	model, _ := NewModel(l.TableName, true)
+	conn := func(a interface{}) *gorm.DB {
+		if tx == nil {
+			return modelDB(a)
+		}
+		return impactDB(tx, a)
+	}
+
+	preview := &RevertPreview{
+		LogID:     l.ID,
+		ModelName: l.TableName,
+		ModelID:   l.TableID,
+		Action:    l.Action,
+		Changes:   []RevertChange{},
+	}
+
+	var newAction Action
+	var before map[string]string
+	switch l.Action {
+	case l.Action.Added():
+		preview.Operation = "delete"
+		newAction = l.Action.Deleted()
+		getWith(conn(model.Interface()), model.Interface(), "id = ?", l.TableID)
+		if GetID(model) == 0 {
+			return nil, nil, fmt.Errorf("%s(%d): %w", l.TableName, l.TableID, errRevertNotFound)
+		}
+		if !apply {
+			return preview, nil, nil
+		}
+		if err := deleteWith(conn(model.Interface()), model.Interface()); err != nil {
+			return nil, nil, err
+		}
+	case l.Action.Deleted():
+		preview.Operation = "restore"
+		newAction = l.Action.Restored()
+		var count int64
+		conn(model.Interface()).Unscoped().Model(model.Interface()).Where("id = ? AND deleted_at IS NOT NULL", l.TableID).Count(&count)
+		if count == 0 {
+			return nil, nil, fmt.Errorf("deleted %s(%d): %w", l.TableName, l.TableID, errRevertNotFound)
+		}
+		if !apply {
+			return preview, nil, nil
+		}
+		err := conn(model.Interface()).Unscoped().Model(model.Interface()).Where("id = ?", l.TableID).Update("deleted_at", nil).Error
+		if err != nil {
+			Trail(ERROR, "RevertLog unable to restore %s(%d). %s", l.TableName, l.TableID, err)
+			return nil, nil, err
+		}
+		getWith(conn(model.Interface()), model.Interface(), "id = ?", l.TableID)
+	case l.Action.Modified():
+		preview.Operation = "update"
+		newAction = l.Action.Modified()
+		snapshot, err := parseLogActivity(l.Activity, &s)
+		if err != nil {
+			Trail(ERROR, "RevertLog unable to parse activity of log %d. %s", l.ID, err)
+			return nil, nil, err
+		}
+		getWith(conn(model.Interface()), model.Interface(), "id = ?", l.TableID)
+		if GetID(model) == 0 {
+			return nil, nil, fmt.Errorf("%s(%d): %w", l.TableName, l.TableID, errRevertNotFound)
+		}
+		before = getRecordSnapshot(model, &s)
+		applyLogSnapshot(model.Elem(), &s, snapshot)
+		after := getRecordSnapshot(model, &s)
+		for _, f := range s.Fields {
+			key := f.Name
+			if f.Type == cFK {
+				key = f.Name + "ID"
+			}
+			if f.IsMethod || before[key] == after[key] {
+				continue
+			}
+			change := RevertChange{
+				Field:    key,
+				Current:  before[key],
+				Reverted: after[key],
+			}
+			if f.Type == cPASSWORD || f.Encrypt {
+				change.Current = "*****"
+				change.Reverted = "*****"
+			}
+			preview.Changes = append(preview.Changes, change)
+		}
+		if !apply {
+			return preview, nil, nil
+		}
+		if err = saveWith(conn(model.Interface()), model.Interface()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Record the revert. Modified records keep the values before the
+	// revert like any other edit so the revert itself can be reverted
+	if before == nil {
+		before = getRecordSnapshot(model, &s)
+	}
+	before["_RevertOf"] = fmt.Sprint(l.ID)
+	if r != nil {
+		before["_IP"] = GetRemoteIP(r)
+	}
+	activity, _ := json.Marshal(before)
+	newLog := &Log{
+		TableName: l.TableName,
+		TableID:   l.TableID,
+		Action:    newAction,
+		Activity:  string(activity),
+	}
+	if user != nil {
+		newLog.Username = user.Username
+	}
+	if err := saveWith(conn(newLog), newLog); err != nil {
+		return nil, nil, err
+	}
+	return preview, newLog, nil
+}
+
+// parseLogActivity returns a log's activity as a snapshot in the same
+// format as getRecordSnapshot. It handles logs created from the admin
+// forms and the JSON records logged by dAPI.
+func parseLogActivity(activity string, s *ModelSchema) (map[string]string, error) {
+	snapshot := map[string]string{}
+	if err := json.Unmarshal([]byte(activity), &snapshot); err == nil {
+		return snapshot, nil
+	}
+
+	snapshot = map[string]string{}
+	record := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(activity)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	for k, v := range record {
+		switch val := v.(type) {
+		case nil:
+			snapshot[k] = ""
+		case string:
+			snapshot[k] = val
+		case bool, json.Number:
+			snapshot[k] = fmt.Sprint(val)
+		case []interface{}:
+			ids := []string{}
+			for _, item := range val {
+				if obj, ok := item.(map[string]interface{}); ok {
+					ids = append(ids, fmt.Sprint(obj["ID"]))
+				}
+			}
+			snapshot[k] = strings.Join(ids, ",")
+		}
+	}
+
+	// dAPI logs store dates in RFC3339
+	for _, f := range s.Fields {
+		if f.Type != cDATE || snapshot[f.Name] == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, snapshot[f.Name]); err == nil {
+			snapshot[f.Name] = t.Format(logTimeFormat)
+		}
+	}
+	return snapshot, nil
+}
+
+// applyLogSnapshot sets the fields of a model to the values in a snapshot.
+// Fields that are missing from the snapshot are not changed.
+func applyLogSnapshot(m reflect.Value, s *ModelSchema, snapshot map[string]string) {
+	for _, f := range s.Fields {
+		if f.IsMethod || f.Type == cID {
+			continue
+		}
+		field := m.FieldByName(f.Name)
+		if !field.IsValid() {
+			continue
+		}
+		switch f.Type {
+		case cFK:
+			v, ok := snapshot[f.Name+"ID"]
+			if !ok {
+				continue
+			}
+			setFieldFromString(m.FieldByName(f.Name+"ID"), v)
+			// Clear the loaded object to make sure the ID is saved
+			field.Set(reflect.Zero(field.Type()))
+		case cM2M:
+			v, ok := snapshot[f.Name]
+			// M2M values in old logs are not stored as IDs
+			if !ok || strings.HasPrefix(v, "[") {
+				continue
+			}
+			items := reflect.New(field.Type()).Elem()
+			for _, id := range strings.Split(v, ",") {
+				if id == "" {
+					continue
+				}
+				item := reflect.New(field.Type().Elem())
+				Get(item.Interface(), "id = ?", id)
+				if GetID(item) != 0 {
+					items = reflect.Append(items, item.Elem())
+				}
+			}
+			field.Set(items)
+		case cMULTILINGUAL:
+			field.SetString(mergeMultilingualSnapshot(field.String(), f.Name, snapshot))
+		default:
+			if v, ok := snapshot[f.Name]; ok {
+				setFieldFromString(field, v)
+			}
+		}
+	}
+}
+
+// mergeMultilingualSnapshot restores the languages in the snapshot and
+// keeps the current value of languages that were not in the snapshot
+func mergeMultilingualSnapshot(current string, name string, snapshot map[string]string) string {
+	value := map[string]string{}
+	json.Unmarshal([]byte(current), &value)
+	found := false
+	if v, ok := snapshot[name]; ok {
+		old := map[string]string{}
+		if err := json.Unmarshal([]byte(v), &old); err != nil {
+			return v
+		}
+		for code, translation := range old {
+			value[code] = translation
+		}
+		found = true
+	}
+	// Older logs store each language in a separate key (e.g. en-Name)
+	for k, v := range snapshot {
+		if strings.HasSuffix(k, "-"+name) {
+			value[strings.TrimSuffix(k, "-"+name)] = v
+			found = true
+		}
+	}
+	if !found {
+		return current
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// setFieldFromString parses a value from a snapshot into a field
+func setFieldFromString(field reflect.Value, v string) {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(v)
+	case reflect.Bool:
+		field.SetBool(v == cTRUE)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, err := strconv.ParseUint(v, 10, 64); err == nil {
+			field.SetUint(i)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			field.SetFloat(f)
+		}
+	case reflect.Struct:
+		if _, ok := field.Interface().(time.Time); ok {
+			if t, err := time.Parse(logTimeFormat, v); err == nil {
+				field.Set(reflect.ValueOf(t))
+			}
+		}
+	case reflect.Ptr:
+		if _, ok := field.Interface().(*time.Time); ok {
+			if v == "" {
+				field.Set(reflect.Zero(field.Type()))
+			} else if t, err := time.Parse(logTimeFormat, v); err == nil {
+				field.Set(reflect.ValueOf(&t))
+			}
+		}
+	}
+}
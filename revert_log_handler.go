@@ -1,15 +1,13 @@
 package uadmin
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
-	"reflect"
-	"strconv"
 	"time"
 )
 
-// RevertLogHandler !
+// RevertLogHandler reverts a log using log_id or all the changes of a user
+// using username and the time window from and to. Passing preview=1 returns
+// the changes without applying them.
 func revertLogHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if the request is coming from an authenticated user
 	session := IsAuthenticated(r)
@@ -30,6 +28,58 @@ func revertLogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	preview := r.FormValue("preview") == "1"
+
+	// Revert all changes by a user in a time window
+	if username := r.FormValue("username"); username != "" {
+		from, err := parseRevertTime(r.FormValue("from"), time.Time{})
+		if err != nil {
+			revertErrorHandler(w, r, "invalid from date. "+err.Error())
+			return
+		}
+		to, err := parseRevertTime(r.FormValue("to"), time.Now())
+		if err != nil {
+			revertErrorHandler(w, r, "invalid to date. "+err.Error())
+			return
+		}
+
+		// Check if the user has perission to revert all the logs
+		for _, l := range getUserRevertableLogs(username, from, to) {
+			if !canRevertLog(&session.User, &l) {
+				pageErrorHandler(w, r, nil)
+				return
+			}
+		}
+
+		if preview {
+			previews, err := PreviewRevertUserLogs(username, from, to)
+			if err != nil {
+				revertErrorHandler(w, r, err.Error())
+				return
+			}
+			ReturnJSON(w, r, map[string]interface{}{
+				"status":  "ok",
+				"changes": previews,
+			})
+			return
+		}
+
+		logs, err := RevertUserLogs(username, from, to, &session.User, r)
+		ids := []uint{}
+		for _, l := range logs {
+			ids = append(ids, l.ID)
+		}
+		if err != nil {
+			revertErrorHandler(w, r, err.Error(), map[string]interface{}{"logs": ids})
+			return
+		}
+		ReturnJSON(w, r, map[string]interface{}{
+			"status": "ok",
+			"logs":   ids,
+		})
+		return
+	}
+
 	// retrieve the log
 	log := Log{}
 	Get(&log, "id = ?", r.FormValue("log_id"))
@@ -39,100 +89,76 @@ func revertLogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the user has perission to edit the model
-	if !session.User.GetAccess(log.TableName).Edit {
+	// Check if the user has perission to revert the log
+	if !canRevertLog(&session.User, &log) {
 		pageErrorHandler(w, r, nil)
 		return
 	}
 
-	if log.Action == log.Action.Deleted() {
-		s, _ := getSchema(log.TableName)
-		tableName := s.TableName //GetDB().Config.NamingStrategy.TableName(log.TableName)
-		sql := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = %d", tableName, log.TableID)
-		err := db.Exec(sql).Error
-		if err != nil {
-			Trail(ERROR, "revertLogHandler was unable to exeture SQL (%s). %s", sql, err.Error())
-		}
-	} else if log.Action == log.Action.Modified() {
-		now := time.Now()
-		DType := reflect.TypeOf(now)
-		var langParser map[string]string
-		err := json.Unmarshal([]byte(log.Activity), &langParser)
+	if preview {
+		changes, err := PreviewRevertLog(&log)
 		if err != nil {
-			Trail(ERROR, "revertLogHandler.Unmarash Unable to parse JSON from a log: %s", err.Error())
+			revertErrorHandler(w, r, err.Error())
 			return
 		}
-		model, ok := NewModel(log.TableName, true)
-		if !ok {
-			Trail(ERROR, "revertLogHandler.NewModel Invalid model name: %s", log.TableName)
-		}
-		Get(model.Interface(), "id = ?", log.TableID)
-
-		t := reflect.TypeOf(model.Interface()).Elem()
-		for index := 0; index < t.NumField(); index++ {
-			if t.Field(index).Type.Kind() == reflect.Int {
-				_v := string(langParser[t.Field(index).Name])
-				//_v = fmt.Sprintf("%+v", _v)
-				i, _ := strconv.ParseInt(_v, 10, 64)
-
-				model.Elem().FieldByName(t.Field(index).Name).SetInt(i)
-			} else if t.Field(index).Type.Kind() == reflect.String {
-				// Check if Multilingual
-				val := ""
-				if t.Field(index).Tag.Get("multilingual") == cTRUE {
-					tVal := map[string]string{}
-					langs := []Language{}
-					Filter(&langs, "`active` = ?", true)
-					for _, lang := range langs {
-						tVal[lang.Code] = fmt.Sprint(langParser[lang.Code+"-"+t.Field(index).Name])
-					}
-					b, _ := json.Marshal(tVal)
-					val = string(b)
-				} else {
-					val = string(langParser[t.Field(index).Name])
-				}
-
-				model.Elem().FieldByName(t.Field(index).Name).SetString(val)
-			} else if t.Field(index).Type.Kind() == reflect.Bool {
-				var val bool
-				val = false
-				if string(langParser[t.Field(index).Name]) == "true" {
-					val = true
-				}
-				model.Elem().FieldByName(t.Field(index).Name).SetBool(val)
-			} else if t.Field(index).Type.Kind() == reflect.Uint {
-				_v := string(langParser[t.Field(index).Name])
-				i, _ := strconv.ParseInt(_v, 10, 64)
-				val := uint(i)
-				model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(val))
-			} else if t.Field(index).Type.Kind() == reflect.Float64 {
-				_v := string(langParser[t.Field(index).Name])
-				i, _ := strconv.ParseFloat(_v, 64)
-				model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(i))
-			} else if t.Field(index).Type.Kind() == reflect.Float32 {
-				_v := string(langParser[t.Field(index).Name])
-				i, _ := strconv.ParseFloat(_v, 32)
-				model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(float32(i)))
-			} else if t.Field(index).Type.Kind() == reflect.Int32 {
-				_v := string(langParser[t.Field(index).Name])
-				i, _ := strconv.ParseInt(_v, 10, 32)
-				model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(int32(i)))
-			} else if t.Field(index).Type.Kind() == reflect.Int64 {
-				_v := string(langParser[t.Field(index).Name])
-				i, _ := strconv.ParseInt(_v, 10, 64)
-				model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(i))
-			} else if t.Field(index).Type.Kind() == reflect.Ptr && t.Field(index).Type.Elem() == DType {
-				if fmt.Sprint(langParser[t.Field(index).Name]) != "" {
-					tm, _ := time.Parse("2006-01-02 15:04:05 -0700", string(langParser[t.Field(index).Name]))
-					model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(&tm))
-				}
-			} else if t.Field(index).Type == DType {
-				_v := string(langParser[t.Field(index).Name])
-				tm, _ := time.Parse("2006-01-02 15:04:05 -0700", _v)
-				model.Elem().FieldByName(t.Field(index).Name).Set(reflect.ValueOf(tm))
-			}
+		ReturnJSON(w, r, map[string]interface{}{
+			"status":  "ok",
+			"changes": changes,
+		})
+		return
+	}
+
+	newLog, err := RevertLog(&log, &session.User, r)
+	if err != nil {
+		Trail(ERROR, "revertLogHandler unable to revert log %d. %s", log.ID, err)
+		revertErrorHandler(w, r, err.Error())
+		return
+	}
+	ReturnJSON(w, r, map[string]interface{}{
+		"status": "ok",
+		"log_id": newLog.ID,
+	})
+}
+
+// canRevertLog checks if a user has the permission to do what reverting a
+// log does. Reverting an added record deletes it, reverting a deleted record
+// restores it and reverting a modified record edits it.
+func canRevertLog(user *User, l *Log) bool {
+	perm := user.GetAccess(l.TableName)
+	switch l.Action {
+	case l.Action.Added():
+		return perm.Delete
+	case l.Action.Deleted():
+		return perm.Add || perm.Restore
+	}
+	return perm.Edit
+}
+
+func revertErrorHandler(w http.ResponseWriter, r *http.Request, errMsg string, extra ...map[string]interface{}) {
+	response := map[string]interface{}{
+		"status":  "error",
+		"err_msg": errMsg,
+	}
+	for _, e := range extra {
+		for k, v := range e {
+			response[k] = v
 		}
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	ReturnJSON(w, r, response)
+}
 
-		Save(model.Elem().Addr().Interface())
+// parseRevertTime parses dates passed to revertLogHandler
+func parseRevertTime(v string, defaultValue time.Time) (time.Time, error) {
+	if v == "" {
+		return defaultValue, nil
+	}
+	var t time.Time
+	var err error
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err = time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
 	}
+	return t, err
 }
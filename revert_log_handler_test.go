@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
 	"time"
 )
 
@@ -181,6 +183,15 @@ func (t *UAdminTests) TestRevertLogHandler() {
 	if mB2DB.Name != backup.Name {
 		t.Errorf("revertLogHandler didn't return value after edit for Name. Got (%s) expected (%s)", mB2DB.Name, backup.Name)
 	}
+	if mB2DB.ItemCount != backup.ItemCount {
+		t.Errorf("revertLogHandler didn't return value after edit for ItemCount. Got (%d) expected (%d)", mB2DB.ItemCount, backup.ItemCount)
+	}
+	if len(mB2DB.ModelAList) != len(backup.ModelAList) {
+		t.Errorf("revertLogHandler didn't return value after edit for ModelAList. Got (%d) expected (%d)", len(mB2DB.ModelAList), len(backup.ModelAList))
+	}
+	if Count(&Log{}, "table_name = ? AND table_id = ? AND activity LIKE ?", "testmodelb", mB2.ID, "%_RevertOf%") != 1 {
+		t.Errorf("revertLogHandler didn't create a log for the revert")
+	}
 	//TODO: Check the rest of the fields
 
 	// Delete the record
@@ -217,6 +228,124 @@ func (t *UAdminTests) TestRevertLogHandler() {
 	if mB2DB.ID == 0 {
 		t.Errorf("revertLogHandler didn't undelete the record")
 	}
+	if Count(&Log{}, "table_name = ? AND table_id = ? AND action = ?", "testmodelb", mB2.ID, log.Action.Restored()) != 1 {
+		t.Errorf("revertLogHandler didn't log the undeleted record as restored")
+	}
+
+	// Revert an added record with a preview first
+	mB3 := TestModelB{Name: "Added"}
+	Save(&mB3)
+	log = Log{}
+	log.ParseRecord(reflect.ValueOf(mB3), "testmodelb", mB3.ID, &s1.User, log.Action.Added(), r)
+	log.Save()
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", RootURL+"revertHandler/?preview=1&log_id="+fmt.Sprint(log.ID)+"&x-csrf-token="+s1.Key, nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	r.ParseForm()
+
+	revertLogHandler(w, r)
+
+	if !strings.Contains(w.Body.String(), `"operation": "delete"`) {
+		t.Errorf("revertLogHandler preview returned invalid response. Got %s", w.Body.String())
+	}
+	if Count(&TestModelB{}, "id = ?", mB3.ID) != 1 {
+		t.Errorf("revertLogHandler preview deleted the record")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", RootURL+"revertHandler/?log_id="+fmt.Sprint(log.ID)+"&x-csrf-token="+s1.Key, nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	r.ParseForm()
+
+	revertLogHandler(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("revertLogHandler return invalid code. Got %d expected %d", w.Code, http.StatusOK)
+	}
+	if Count(&TestModelB{}, "id = ?", mB3.ID) != 0 {
+		t.Errorf("revertLogHandler didn't delete an added record")
+	}
+
+	// Revert all changes by a user in a time window
+	from := time.Now().Add(-time.Second)
+	mB4 := TestModelB{Name: "Bulk"}
+	Save(&mB4)
+	log = Log{}
+	log.ParseRecord(reflect.ValueOf(mB4), "testmodelb", mB4.ID, u1, log.Action.Modified(), r)
+	log.Save()
+	mB4.Name = "Bulk1"
+	Save(&mB4)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", RootURL+"revertHandler/?username="+u1.Username+"&from="+url.QueryEscape(from.Format(time.RFC3339))+"&x-csrf-token="+s1.Key, nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	r.ParseForm()
+
+	revertLogHandler(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("revertLogHandler return invalid code for user revert. Got %d expected %d. %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	mB4DB := TestModelB{}
+	Get(&mB4DB, "id = ?", mB4.ID)
+	if mB4DB.Name != "Bulk" {
+		t.Errorf("revertLogHandler didn't revert user changes. Got (%s) expected (%s)", mB4DB.Name, "Bulk")
+	}
+
+	// A failed revert rolls back the other reverts of the user
+	from = time.Now().Add(-time.Second)
+	mB5 := TestModelB{Name: "Broken"}
+	Save(&mB5)
+	broken := Log{Username: u1.Username, TableName: "testmodelb", TableID: int(mB5.ID), Action: log.Action.Modified(), Activity: "{broken"}
+	broken.Save()
+	log = Log{}
+	log.ParseRecord(reflect.ValueOf(mB4DB), "testmodelb", mB4DB.ID, u1, log.Action.Modified(), r)
+	log.Save()
+	mB4DB.Name = "Bulk2"
+	Save(&mB4DB)
+	logCount := Count(&Log{}, "")
+	if _, err := RevertUserLogs(u1.Username, from, time.Now(), &s1.User, r); err == nil {
+		t.Errorf("RevertUserLogs didn't return the error of a failed revert")
+	}
+	mB4DB = TestModelB{}
+	Get(&mB4DB, "id = ?", mB4.ID)
+	if mB4DB.Name != "Bulk2" {
+		t.Errorf("RevertUserLogs didn't roll back the reverts before a failed revert. Got (%s) expected (%s)", mB4DB.Name, "Bulk2")
+	}
+	if Count(&Log{}, "") != logCount {
+		t.Errorf("RevertUserLogs kept the logs of reverts that were rolled back")
+	}
+
+	// Reverting logs needs the permission to do what the revert does
+	menu := DashboardMenu{}
+	Get(&menu, "url = ?", "testmodelb")
+	up := &UserPermission{DashboardMenuID: menu.ID, UserID: u1.ID, Read: true, Edit: true}
+	up.Save()
+	examples := []struct {
+		action Action
+		allow  bool
+	}{
+		{Action(0).Added(), false},
+		{Action(0).Deleted(), false},
+		{Action(0).Modified(), true},
+	}
+	for _, e := range examples {
+		if allow := canRevertLog(u1, &Log{TableName: "testmodelb", Action: e.action}); allow != e.allow {
+			t.Errorf("canRevertLog returned invalid value for action %d. Got %v expected %v", e.action, allow, e.allow)
+		}
+	}
+	up.Edit = false
+	up.Delete = true
+	up.Restore = true
+	up.Save()
+	for _, e := range examples {
+		if allow := canRevertLog(u1, &Log{TableName: "testmodelb", Action: e.action}); allow == e.allow {
+			t.Errorf("canRevertLog returned invalid value for action %d. Got %v expected %v", e.action, allow, !e.allow)
+		}
+	}
+	Delete(up)
+	loadPermissions()
 
 	//Clean Up
 	Delete(s1)
@@ -225,5 +354,6 @@ func (t *UAdminTests) TestRevertLogHandler() {
 	Delete(mA1)
 	Delete(mB1)
 	Delete(mB2)
+	Delete(mB4)
 
 }
@@ -158,6 +158,9 @@ type F struct {
 	WebCam            bool
 	Stringer          bool
 	Deprecated        bool
+	Index             bool
+	Unique            bool
+	UniqueTogether    string
 }
 
 // MarshalJSON customizes F json export
@@ -204,6 +207,9 @@ func (f F) MarshalJSON() ([]byte, error) {
 		WebCam            bool
 		Stringer          bool
 		Deprecated        bool
+		Index             bool
+		Unique            bool
+		UniqueTogether    string
 	}{
 		Name:              f.Name,
 		DisplayName:       f.DisplayName,
@@ -258,6 +264,9 @@ func (f F) MarshalJSON() ([]byte, error) {
 		WebCam:         f.WebCam,
 		Stringer:       f.Stringer,
 		Deprecated:     f.Deprecated,
+		Index:          f.Index,
+		Unique:         f.Unique,
+		UniqueTogether: f.UniqueTogether,
 	})
 }
 
@@ -0,0 +1,536 @@
+package uadmin
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// schemaMigrationSource is the source of schema migrations in the
+// migrations table
+const schemaMigrationSource = "schema"
+
+// SchemaChangeType is the type of a change to the database schema
+type SchemaChangeType string
+
+// Schema change types
+const (
+	CreateTable    SchemaChangeType = "create_table"
+	CreateM2MTable SchemaChangeType = "create_m2m_table"
+	AddColumn      SchemaChangeType = "add_column"
+	RenameColumn   SchemaChangeType = "rename_column"
+	DropColumn     SchemaChangeType = "drop_column"
+	CreateIndex    SchemaChangeType = "create_index"
+)
+
+// SchemaChange is a difference between a registered model and the database
+// schema with the SQL to apply it
+type SchemaChange struct {
+	Type   SchemaChangeType
+	Table  string
+	Column string
+	// OldColumn is the name of a renamed column in the database
+	OldColumn string
+	// Index is the name of a created index
+	Index string
+	SQL   []string
+	// Destructive is a change that loses data like dropping a column
+	Destructive bool
+	// Conflicts are the records with the same values in the columns of a
+	// created unique index. They have to be fixed before the index can be
+	// created.
+	Conflicts []string
+}
+
+func (c SchemaChange) String() string {
+	switch c.Type {
+	case CreateTable, CreateM2MTable:
+		return fmt.Sprintf("Create table %s", c.Table)
+	case RenameColumn:
+		return fmt.Sprintf("Rename column %s.%s to %s", c.Table, c.OldColumn, c.Column)
+	case DropColumn:
+		return fmt.Sprintf("Drop column %s.%s", c.Table, c.Column)
+	case CreateIndex:
+		return fmt.Sprintf("Create index %s on %s", c.Index, c.Table)
+	}
+	return fmt.Sprintf("Add column %s.%s", c.Table, c.Column)
+}
+
+// destructiveSQL matches SQL statements that lose data
+var destructiveSQL = regexp.MustCompile(`(?i)^\s*(DROP|TRUNCATE|DELETE)\b|\bDROP\s+(COLUMN|TABLE|INDEX|CONSTRAINT)\b`)
+
+// sqlCaptureLogger is a gorm logger that collects the SQL of a dry run
+type sqlCaptureLogger struct {
+	logger.Interface
+	sql []string
+}
+
+func (l *sqlCaptureLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	l.sql = append(l.sql, sql)
+}
+
+// dryRunSQL returns the SQL that f would execute in the database
+func dryRunSQL(f func(tx *gorm.DB) error) ([]string, error) {
+	l := &sqlCaptureLogger{Interface: logger.Discard}
+	err := f(db.Session(&gorm.Session{DryRun: true, Logger: l}))
+	return l.sql, err
+}
+
+// getRenamedFrom returns the old name of a renamed field from its
+// renamed_from tag like `uadmin:"renamed_from:OldName"`
+func getRenamedFrom(field reflect.StructField) string {
+	for _, tag := range strings.Split(field.Tag.Get("uadmin"), ";") {
+		if strings.HasPrefix(tag, "renamed_from:") {
+			return db.Config.NamingStrategy.ColumnName("", strings.TrimPrefix(tag, "renamed_from:"))
+		}
+	}
+	return ""
+}
+
+// PlanSchemaMigration compares the registered models with the database
+// schema and returns the changes to migrate the database. Tables of models
+// that are not registered are not changed.
+func PlanSchemaMigration() ([]SchemaChange, error) {
+	models := registeredModels()
+	names := []string{}
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	modelList := []interface{}{}
+	for _, name := range names {
+		modelList = append(modelList, models[name])
+	}
+	return planSchemaChanges(modelList...)
+}
+
+// planSchemaChanges returns the schema changes of a list of models
+func planSchemaChanges(a ...interface{}) ([]SchemaChange, error) {
+	changes := []SchemaChange{}
+	for _, model := range a {
+		if autoMigrate, ok := model.(AutoMigrater); ok && !autoMigrate.AutoMigrate() {
+			continue
+		}
+		// SQL files are applied to the default database so models in
+		// named databases are migrated when the server starts
+		if getDBName(model) != "" {
+			continue
+		}
+		modelChanges, err := planModelChanges(model)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, modelChanges...)
+	}
+	return changes, nil
+}
+
+// planModelChanges returns the schema changes of a model
+func planModelChanges(model interface{}) ([]SchemaChange, error) {
+	conn := modelDB(model)
+	stmt := &gorm.Statement{DB: conn}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	table := stmt.Schema.Table
+	changes := []SchemaChange{}
+
+	if !conn.Migrator().HasTable(model) {
+		sql, err := dryRunSQL(func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(model)
+		})
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, SchemaChange{Type: CreateTable, Table: table, SQL: sql})
+	} else {
+		columnTypes, err := conn.Migrator().ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		columns := map[string]bool{}
+		for _, c := range columnTypes {
+			columns[c.Name()] = true
+		}
+
+		for _, dbName := range stmt.Schema.DBNames {
+			field := stmt.Schema.FieldsByDBName[dbName]
+			if field.IgnoreMigration {
+				continue
+			}
+			if columns[dbName] {
+				delete(columns, dbName)
+				continue
+			}
+			if oldName := getRenamedFrom(field.StructField); oldName != "" && columns[oldName] {
+				delete(columns, oldName)
+				sql, _ := dryRunSQL(func(tx *gorm.DB) error {
+					return tx.Exec("ALTER TABLE ? RENAME COLUMN ? TO ?", clause.Table{Name: table}, clause.Column{Name: oldName}, clause.Column{Name: dbName}).Error
+				})
+				changes = append(changes, SchemaChange{Type: RenameColumn, Table: table, Column: dbName, OldColumn: oldName, SQL: sql})
+				continue
+			}
+			sql, err := dryRunSQL(func(tx *gorm.DB) error {
+				return tx.Migrator().AddColumn(model, field.Name)
+			})
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, SchemaChange{Type: AddColumn, Table: table, Column: dbName, SQL: sql})
+		}
+
+		// Columns that are not in the model anymore
+		dropped := []string{}
+		for name := range columns {
+			dropped = append(dropped, name)
+		}
+		sort.Strings(dropped)
+		for _, name := range dropped {
+			sql, _ := dryRunSQL(func(tx *gorm.DB) error {
+				return tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: name}).Error
+			})
+			changes = append(changes, SchemaChange{Type: DropColumn, Table: table, Column: name, SQL: sql, Destructive: true})
+		}
+	}
+
+	// M2M tables
+	t := reflect.TypeOf(model)
+	for i := 0; i < t.NumField(); i++ {
+		if !strings.Contains(t.Field(i).Tag.Get("uadmin"), "disable_m2m") &&
+			t.Field(i).Type.Kind() == reflect.Slice && t.Field(i).Type.Elem().Kind() == reflect.Struct {
+			table1 := strings.ToLower(getTypeName(t))
+			table2 := strings.ToLower(getTypeName(t.Field(i).Type.Elem()))
+			if !conn.Migrator().HasTable(table1 + "_" + table2) {
+				sql := sqlDialect[getDBType(model)]["createM2MTable"]
+				sql = strings.Replace(sql, "{TABLE1}", table1, -1)
+				sql = strings.Replace(sql, "{TABLE2}", table2, -1)
+				changes = append(changes, SchemaChange{Type: CreateM2MTable, Table: table1 + "_" + table2, SQL: []string{sql}})
+			}
+		}
+	}
+
+	// Indexes
+	indexChanges, err := planIndexChanges(model)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, indexChanges...)
+	return changes, nil
+}
+
+// renameSchemaColumns renames the columns of a model that have a
+// renamed_from tag before AutoMigrate adds them as new empty columns
+func renameSchemaColumns(model interface{}) error {
+	t := reflect.TypeOf(model)
+	renamed := false
+	for i := 0; i < t.NumField() && !renamed; i++ {
+		renamed = getRenamedFrom(t.Field(i)) != ""
+	}
+	if !renamed || !modelDB(model).Migrator().HasTable(model) {
+		return nil
+	}
+	changes, err := planModelChanges(model)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if c.Type != RenameColumn {
+			continue
+		}
+		if err = modelDB(model).Migrator().RenameColumn(model, c.OldColumn, c.Column); err != nil {
+			return err
+		}
+		Trail(INFO, "Renamed column %s.%s to %s", c.Table, c.OldColumn, c.Column)
+	}
+	return nil
+}
+
+// MakeSchemaMigration writes the planned schema changes to a SQL file in
+// SchemaMigrationsPath to be reviewed and applied using MigrateSchema. It
+// returns the name of the file or an empty string if there are no changes.
+func MakeSchemaMigration(name string) (string, []SchemaChange, error) {
+	changes, err := PlanSchemaMigration()
+	if err != nil || len(changes) == 0 {
+		return "", changes, err
+	}
+
+	// Skip the changes of migration files that were not applied yet
+	pending, err := getPendingSchemaMigrations()
+	if err != nil {
+		return "", nil, err
+	}
+	planned := map[string]bool{}
+	for _, fileName := range pending {
+		statements, _ := readSchemaMigration(fileName)
+		for _, stmt := range statements {
+			planned[stmt] = true
+		}
+	}
+	newChanges := []SchemaChange{}
+	for _, c := range changes {
+		if len(c.SQL) == 0 || !planned[strings.TrimSuffix(c.SQL[0], ";")] {
+			newChanges = append(newChanges, c)
+		}
+	}
+	if len(newChanges) == 0 {
+		return "", newChanges, nil
+	}
+	fileName, err := writeSchemaMigration(name, newChanges)
+	return fileName, newChanges, err
+}
+
+// writeSchemaMigration writes schema changes to a new SQL file in
+// SchemaMigrationsPath
+func writeSchemaMigration(name string, changes []SchemaChange) (string, error) {
+	if name == "" {
+		name = "auto"
+	}
+	name = strings.ToLower(regexp.MustCompile(`[^a-zA-Z0-9_]+`).ReplaceAllString(name, "_"))
+	os.MkdirAll(SchemaMigrationsPath, 0755)
+	fileName := filepath.Join(SchemaMigrationsPath, time.Now().Format("20060102150405")+"_"+name+".sql")
+
+	content := "-- Schema migration generated on " + time.Now().Format("2006-01-02 15:04:05") + " for " + Database.Type + "\n"
+	content += "-- Review the statements and apply them with: uadmin migrate\n"
+	for _, c := range changes {
+		content += "\n-- " + c.String() + "\n"
+		if c.Destructive {
+			content += "-- DESTRUCTIVE: this change loses data\n"
+		}
+		for _, conflict := range c.Conflicts {
+			content += "-- CONFLICT: " + conflict + "\n"
+		}
+		for _, sql := range c.SQL {
+			content += strings.TrimSuffix(sql, ";") + ";\n"
+		}
+	}
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		Trail(ERROR, "writeSchemaMigration unable to write %s. %s", fileName, err)
+		return "", err
+	}
+	return fileName, nil
+}
+
+// readSchemaMigration returns the SQL statements of a schema migration file.
+// Statements end with a semicolon at the end of a line and lines that start
+// with -- are comments.
+func readSchemaMigration(fileName string) ([]string, error) {
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	statements := []string{}
+	stmt := ""
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if stmt == "" && (strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "--")) {
+			continue
+		}
+		if stmt != "" {
+			stmt += "\n"
+		}
+		stmt += line
+		if strings.HasSuffix(line, ";") {
+			statements = append(statements, strings.TrimSuffix(stmt, ";"))
+			stmt = ""
+		}
+	}
+	if strings.TrimSpace(stmt) != "" {
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
+
+// getPendingSchemaMigrations returns the schema migration files that were
+// not applied sorted by their names
+func getPendingSchemaMigrations() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(SchemaMigrationsPath, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	applied := []Migration{}
+	Filter(&applied, "source = ?", schemaMigrationSource)
+	appliedNames := map[string]bool{}
+	for _, m := range applied {
+		appliedNames[m.Name] = true
+	}
+	pending := []string{}
+	for _, fileName := range files {
+		if !appliedNames[filepath.Base(fileName)] {
+			pending = append(pending, fileName)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateSchema applies the schema migration files that were not applied
+// yet in the order of their names. Files with destructive statements like
+// DROP COLUMN are not applied unless confirmDestructive is true. It returns
+// the names of the applied files.
+func MigrateSchema(confirmDestructive bool) ([]string, error) {
+	pending, err := getPendingSchemaMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check all the files before applying any of them
+	migrations := map[string][]string{}
+	for _, fileName := range pending {
+		statements, err := readSchemaMigration(fileName)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range statements {
+			if destructiveSQL.MatchString(stmt) && !confirmDestructive {
+				return nil, fmt.Errorf("%s has destructive changes and was not confirmed: %s", filepath.Base(fileName), stmt)
+			}
+		}
+		migrations[fileName] = statements
+	}
+
+	names := []string{}
+	for _, fileName := range pending {
+		buf, _ := ioutil.ReadFile(fileName)
+		name := filepath.Base(fileName)
+		start := time.Now()
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range migrations[fileName] {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return fmt.Errorf("%s. %s", stmt, err)
+				}
+			}
+			return tx.Create(&Migration{
+				Name:      name,
+				Source:    schemaMigrationSource,
+				Checksum:  fmt.Sprintf("%x", sha256.Sum256(buf)),
+				AppliedAt: start,
+				Duration:  time.Since(start).String(),
+			}).Error
+		})
+		if err != nil {
+			Trail(ERROR, "MigrateSchema: unable to apply %s. %s", name, err)
+			return names, fmt.Errorf("unable to apply %s. %s", name, err)
+		}
+		Trail(INFO, "Applied schema migration %s", name)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// migrateCommand runs the migrate command:
+//
+//	migrate [up] [--yes]
+//	migrate plan
+//	migrate make [NAME]
+//	migrate status
+func migrateCommand(args []string) error {
+	subcommand := "up"
+	confirm := false
+	params := []string{}
+	for _, arg := range args {
+		if arg == "--yes" || arg == "-y" {
+			confirm = true
+		} else {
+			params = append(params, arg)
+		}
+	}
+	if len(params) != 0 {
+		subcommand = params[0]
+	}
+
+	switch subcommand {
+	case "plan":
+		changes, err := PlanSchemaMigration()
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			fmt.Println("The database schema is up to date")
+		}
+		conflicts := 0
+		for _, c := range changes {
+			fmt.Println("--", c.String())
+			for _, conflict := range c.Conflicts {
+				fmt.Println("-- CONFLICT:", conflict)
+			}
+			for _, sql := range c.SQL {
+				fmt.Println(strings.TrimSuffix(sql, ";") + ";")
+			}
+			if len(c.Conflicts) != 0 {
+				conflicts++
+			}
+		}
+		if conflicts != 0 {
+			return fmt.Errorf("%d unique indexes can't be created because records have the same values", conflicts)
+		}
+		return nil
+	case "make":
+		name := ""
+		if len(params) > 1 {
+			name = params[1]
+		}
+		fileName, changes, err := MakeSchemaMigration(name)
+		if err != nil {
+			return err
+		}
+		if fileName == "" {
+			fmt.Println("No schema changes to migrate")
+			return nil
+		}
+		fmt.Printf("Created %s with %d changes\n", fileName, len(changes))
+		return nil
+	case "status":
+		pending, err := getPendingSchemaMigrations()
+		if err != nil {
+			return err
+		}
+		applied := []Migration{}
+		FilterSorted("name", true, &applied, "source = ?", schemaMigrationSource)
+		for _, m := range applied {
+			fmt.Printf("%-50s applied at %s\n", m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		for _, fileName := range pending {
+			fmt.Printf("%-50s pending\n", filepath.Base(fileName))
+		}
+		changes, err := PlanSchemaMigration()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d schema changes are not applied to the database\n", len(changes))
+		return nil
+	case "up":
+		names, err := MigrateSchema(confirm)
+		if err != nil && !confirm && strings.Contains(err.Error(), "destructive") {
+			// Ask the user to confirm destructive changes
+			fmt.Println(err)
+			fmt.Print("Type yes to apply the destructive changes: ")
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if strings.TrimSpace(answer) != "yes" {
+				return fmt.Errorf("migration canceled")
+			}
+			names, err = MigrateSchema(true)
+		}
+		for _, name := range names {
+			fmt.Println("Applied", name)
+		}
+		if err == nil && len(names) == 0 {
+			fmt.Println("No schema migrations to apply")
+		}
+		return err
+	}
+	return fmt.Errorf("unknown migrate command: %s", subcommand)
+}
@@ -0,0 +1,119 @@
+package uadmin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type testSchemaMigrationOld struct {
+	Model
+	OldName string
+	Extra   string
+}
+
+func (testSchemaMigrationOld) TableName() string {
+	return "test_schema_migrations"
+}
+
+type testSchemaMigration struct {
+	Model
+	NewName string `uadmin:"renamed_from:OldName"`
+	Count   int
+}
+
+func (testSchemaMigration) TableName() string {
+	return "test_schema_migrations"
+}
+
+// TestSchemaMigration is a unit testing function for schema migrations
+func (t *UAdminTests) TestSchemaMigration() {
+	oldPath := SchemaMigrationsPath
+	SchemaMigrationsPath = filepath.Join(os.TempDir(), "uadmin_schema_"+GenerateBase32(8))
+	defer func() {
+		os.RemoveAll(SchemaMigrationsPath)
+		SchemaMigrationsPath = oldPath
+		db.Migrator().DropTable("test_schema_migrations")
+		db.Unscoped().Where("source = ?", schemaMigrationSource).Delete(&Migration{})
+	}()
+
+	// Registered models are migrated on startup
+	changes, err := PlanSchemaMigration()
+	if err != nil || len(changes) != 0 {
+		t.Errorf("PlanSchemaMigration returned changes for migrated models. Got %v %s", changes, err)
+	}
+	if fileName, _, err := MakeSchemaMigration("nothing"); err != nil || fileName != "" {
+		t.Errorf("MakeSchemaMigration created a file without changes. Got %s %s", fileName, err)
+	}
+
+	// New table
+	changes, err = planModelChanges(testSchemaMigrationOld{})
+	if err != nil || len(changes) != 1 || changes[0].Type != CreateTable || len(changes[0].SQL) == 0 {
+		t.Errorf("planModelChanges didn't plan a new table. Got %#v %s", changes, err)
+	}
+	db.AutoMigrate(&testSchemaMigrationOld{})
+	db.Create(&testSchemaMigrationOld{OldName: "Record", Extra: "Extra"})
+
+	// Renamed, added and dropped columns
+	changes, err = planModelChanges(testSchemaMigration{})
+	types := []string{}
+	for _, c := range changes {
+		types = append(types, string(c.Type)+":"+c.Column)
+	}
+	if err != nil || strings.Join(types, ",") != "rename_column:new_name,add_column:count,drop_column:extra" {
+		t.Errorf("planModelChanges returned invalid changes. Got %v %s", types, err)
+	}
+	if len(changes) == 3 && (changes[0].OldColumn != "old_name" || changes[0].Destructive || !changes[2].Destructive) {
+		t.Errorf("planModelChanges returned invalid changes. Got %#v", changes)
+	}
+
+	fileName, err := writeSchemaMigration("rename", changes)
+	if err != nil {
+		t.Errorf("writeSchemaMigration returned an error. %s", err)
+	}
+	statements, _ := readSchemaMigration(fileName)
+	if len(statements) != 3 {
+		t.Errorf("readSchemaMigration returned invalid statements. Got %v", statements)
+	}
+
+	// Destructive changes must be confirmed
+	if names, err := MigrateSchema(false); err == nil || len(names) != 0 || !strings.Contains(err.Error(), "destructive") {
+		t.Errorf("MigrateSchema applied destructive changes without confirmation. Got %v %v", names, err)
+	}
+	if names, err := MigrateSchema(true); err != nil || len(names) != 1 || names[0] != filepath.Base(fileName) {
+		t.Errorf("MigrateSchema didn't apply the migration. Got %v %s", names, err)
+	}
+	if changes, err = planModelChanges(testSchemaMigration{}); err != nil || len(changes) != 0 {
+		t.Errorf("planModelChanges returned changes after the migration. Got %v %s", changes, err)
+	}
+	record := testSchemaMigration{}
+	db.First(&record)
+	if record.NewName != "Record" {
+		t.Errorf("MigrateSchema didn't keep the data of the renamed column. Got %#v", record)
+	}
+
+	// Applied files are not applied again
+	if names, err := MigrateSchema(true); err != nil || len(names) != 0 {
+		t.Errorf("MigrateSchema applied a migration twice. Got %v %s", names, err)
+	}
+
+	// A failed file is rolled back
+	os.WriteFile(filepath.Join(SchemaMigrationsPath, "99999999999999_fail.sql"), []byte("ALTER TABLE test_schema_migrations ADD COLUMN note varchar(10);\nSELECT * FROM unknown_table;\n"), 0644)
+	if names, err := MigrateSchema(false); err == nil || len(names) != 0 {
+		t.Errorf("MigrateSchema didn't return an error for a failed file. Got %v", names)
+	}
+
+	// Renames are applied before auto migration
+	db.Migrator().DropTable("test_schema_migrations")
+	db.AutoMigrate(&testSchemaMigrationOld{})
+	db.Create(&testSchemaMigrationOld{OldName: "Renamed"})
+	if err = renameSchemaColumns(testSchemaMigration{}); err != nil {
+		t.Errorf("renameSchemaColumns returned an error. %s", err)
+	}
+	db.AutoMigrate(&testSchemaMigration{})
+	record = testSchemaMigration{}
+	db.First(&record)
+	if record.NewName != "Renamed" {
+		t.Errorf("renameSchemaColumns didn't rename the column. Got %#v", record)
+	}
+}
@@ -1,7 +1,9 @@
 package uadmin
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"mime"
 	"net/smtp"
@@ -35,10 +37,70 @@ func SendEmail(to, cc, bcc []string, subject, body string, attachments ...string
 	}
 
 	// Get the domain name of sender
-	domain := strings.Split(EmailFrom, "@")
-	if len(domain) < 2 {
+	if len(strings.Split(EmailFrom, "@")) < 2 {
 		return
 	}
+
+	// Send the email through the task queue so slow SMTP servers don't
+	// block the request and failed emails are retried. Emails can have
+	// secrets like password reset links so the payload is encrypted.
+	if dbOK {
+		buf, _ := json.Marshal(emailTask{
+			To:          to,
+			CC:          cc,
+			BCC:         bcc,
+			Subject:     subject,
+			Body:        body,
+			Attachments: attachments,
+		})
+		payload, err := encrypt(EncryptKey, string(buf))
+		if err != nil {
+			Trail(ERROR, "SendEmail unable to encrypt the email. %s", err)
+			return err
+		}
+		_, err = EnqueueTask(emailTaskName, payload)
+		return err
+	}
+
+	go func() {
+		if err := sendEmail(to, cc, bcc, subject, body, attachments...); err != nil {
+			Trail(WARNING, "Email was not sent. %s", err)
+		}
+	}()
+	return nil
+}
+
+// emailTaskName is the name of the task that sends emails
+const emailTaskName = "uadmin/email"
+
+// emailTask is the payload of the email task
+type emailTask struct {
+	To          []string
+	CC          []string
+	BCC         []string
+	Subject     string
+	Body        string
+	Attachments []string
+}
+
+func init() {
+	registerSecretTask(emailTaskName, func(ctx context.Context, task *Task) error {
+		payload, err := decrypt(EncryptKey, task.Payload)
+		if err != nil {
+			return err
+		}
+		e := emailTask{}
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			return err
+		}
+		return sendEmail(e.To, e.CC, e.BCC, e.Subject, e.Body, e.Attachments...)
+	})
+}
+
+// sendEmail sends an email to the SMTP server
+func sendEmail(to, cc, bcc []string, subject, body string, attachments ...string) error {
+	// Get the domain name of sender
+	domain := strings.Split(EmailFrom, "@")
 	domain[0] = strings.TrimSpace(domain[0])
 	domain[0] = strings.TrimSuffix(domain[0], ">")
 
@@ -94,17 +156,9 @@ func SendEmail(to, cc, bcc []string, subject, body string, attachments ...string
 		to = append(to, bcc...)
 	}
 
-	go func() {
-		err = smtp.SendMail(fmt.Sprintf("%s:%d", EmailSMTPServer, EmailSMTPServerPort),
-			smtp.PlainAuth(EmailFrom, EmailUsername, EmailPassword, EmailSMTPServer),
-			EmailFrom, to, []byte(msg))
-
-		if err != nil {
-			Trail(WARNING, "Email was not sent. %s", err)
-		}
-	}()
-
-	return nil
+	return smtp.SendMail(fmt.Sprintf("%s:%d", EmailSMTPServer, EmailSMTPServerPort),
+		smtp.PlainAuth(EmailFrom, EmailUsername, EmailPassword, EmailSMTPServer),
+		EmailFrom, to, []byte(msg))
 }
 
 func splitString(v string, maxLen int) []string {
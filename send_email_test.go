@@ -1,6 +1,7 @@
 package uadmin
 
 import (
+	"context"
 	"net"
 	"os"
 	"strings"
@@ -31,6 +32,21 @@ func (t *UAdminTests) TestSendEmail() {
 	}
 	receivedEmail = ""
 
+	// The payload of the email task is encrypted and removed after the
+	// email is sent
+	SendEmail([]string{"user@example.com"}, []string{}, []string{}, "subject", "secret-token")
+	task := Task{}
+	Get(&task, "name = ? AND id = (SELECT MAX(id) FROM tasks WHERE name = ?)", emailTaskName, emailTaskName)
+	if strings.Contains(task.Payload, "secret-token") {
+		t.Errorf("SendEmail stored the email body in plaintext")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if finished, err := WaitForTask(ctx, task.ID); err != nil || finished.Payload != "" {
+		t.Errorf("The email task didn't remove its payload after sending the email. %v", err)
+	}
+	receivedEmail = ""
+
 	// Not try sending an email with missing settings
 	temp := EmailUsername
 	EmailUsername = ""
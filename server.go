@@ -37,6 +37,11 @@ func StartServer() {
 	if !registered {
 		Register()
 	}
+	if MigrateDataOnStartup {
+		if _, err := MigrateData(); err != nil {
+			Trail(ERROR, "Unable to apply data migrations. %s", err)
+		}
+	}
 	if !settingsSynched {
 		syncSystemSettings()
 	}
@@ -51,6 +56,7 @@ func StartServer() {
 	}
 	// Synch model translation
 	// Get Global Schema
+	schemas := registeredSchema()
 	stat := map[string]int{}
 	for _, v := range CustomTranslation {
 		tempStat := syncCustomTranslation(v)
@@ -58,8 +64,8 @@ func StartServer() {
 			stat[k] += v
 		}
 	}
-	for k := range Schema {
-		tempStat := syncModelTranslation(Schema[k])
+	for k := range schemas {
+		tempStat := syncModelTranslation(schemas[k])
 		for k, v := range tempStat {
 			stat[k] += v
 		}
@@ -83,6 +89,11 @@ func StartSecureServer(certFile, keyFile string) {
 	if !registered {
 		Register()
 	}
+	if MigrateDataOnStartup {
+		if _, err := MigrateData(); err != nil {
+			Trail(ERROR, "Unable to apply data migrations. %s", err)
+		}
+	}
 	if !settingsSynched {
 		syncSystemSettings()
 	}
@@ -97,6 +108,7 @@ func StartSecureServer(certFile, keyFile string) {
 	}
 	// Synch model translation
 	// Get Global Schema
+	schemas := registeredSchema()
 	stat := map[string]int{}
 	for _, v := range CustomTranslation {
 		tempStat := syncCustomTranslation(v)
@@ -104,8 +116,8 @@ func StartSecureServer(certFile, keyFile string) {
 			stat[k] += v
 		}
 	}
-	for k := range Schema {
-		tempStat := syncModelTranslation(Schema[k])
+	for k := range schemas {
+		tempStat := syncModelTranslation(schemas[k])
 		for k, v := range tempStat {
 			stat[k] += v
 		}
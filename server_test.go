@@ -116,6 +116,7 @@ func TestRunner(t *testing.T) {
 		})
 		t.Run(dbSetup.Name+"=Approval", func(t *testing.T) {
 			uTest.TestApprovalStruct()
+			uTest.TestApprovalWorkflow()
 		})
 		t.Run(dbSetup.Name+"=Auth", func(t *testing.T) {
 			uTest.TestGenerateBase64()
@@ -130,6 +131,12 @@ func TestRunner(t *testing.T) {
 			uTest.TestGetSessionByKey()
 			uTest.TestGetSession()
 		})
+		t.Run(dbSetup.Name+"=Builder", func(t *testing.T) {
+			uTest.TestBuilder()
+		})
+		t.Run(dbSetup.Name+"=Cache", func(t *testing.T) {
+			uTest.TestCache()
+		})
 		t.Run(dbSetup.Name+"=Crop", func(t *testing.T) {
 			uTest.TestCropImageHandler()
 		})
@@ -142,6 +149,10 @@ func TestRunner(t *testing.T) {
 		t.Run(dbSetup.Name+"=DB", func(t *testing.T) {
 			uTest.TestInitializeDB()
 			uTest.TestSave()
+			uTest.TestDBContext()
+		})
+		t.Run(dbSetup.Name+"=DBRouter", func(t *testing.T) {
+			uTest.TestDBRouter()
 		})
 		t.Run(dbSetup.Name+"=DeleteHandler", func(t *testing.T) {
 			uTest.TestProcessDelete()
@@ -154,6 +165,9 @@ func TestRunner(t *testing.T) {
 		})
 		t.Run(dbSetup.Name+"=Export", func(t *testing.T) {
 			uTest.TestGetFilter()
+			uTest.TestDataExport()
+			uTest.TestExportFormat()
+			uTest.TestDataImport()
 		})
 		t.Run(dbSetup.Name+"=FieldType", func(t *testing.T) {
 			uTest.TestFieldType()
@@ -164,6 +178,9 @@ func TestRunner(t *testing.T) {
 		t.Run(dbSetup.Name+"=FormHandler", func(t *testing.T) {
 			uTest.TestFormHandler()
 		})
+		t.Run(dbSetup.Name+"=Fixture", func(t *testing.T) {
+			uTest.TestFixture()
+		})
 		t.Run(dbSetup.Name+"=GenerateTranslation", func(t *testing.T) {
 			uTest.TestSyncCustomTranslation()
 			uTest.TestSyncModelTranslation()
@@ -177,30 +194,74 @@ func TestRunner(t *testing.T) {
 		t.Run(dbSetup.Name+"=HomeHandler", func(t *testing.T) {
 			uTest.TestHomeHandler()
 		})
+		t.Run(dbSetup.Name+"=Index", func(t *testing.T) {
+			uTest.TestIndex()
+		})
+		t.Run(dbSetup.Name+"=InspectDB", func(t *testing.T) {
+			uTest.TestInspectDB()
+		})
 		t.Run(dbSetup.Name+"=Language", func(t *testing.T) {
 			uTest.TestLanguage()
 		})
 		t.Run(dbSetup.Name+"=ListHandler", func(t *testing.T) {
 			uTest.TestListHandler()
 		})
+		t.Run(dbSetup.Name+"=Job", func(t *testing.T) {
+			uTest.TestCronSpec()
+			uTest.TestJob()
+		})
 		t.Run(dbSetup.Name+"=LoginHandler", func(t *testing.T) {
 			uTest.TestLoginHandler()
 		})
 		t.Run(dbSetup.Name+"=MainHandler", func(t *testing.T) {
 			uTest.TestMainHandler()
 		})
+		t.Run(dbSetup.Name+"=Migration", func(t *testing.T) {
+			uTest.TestMigration()
+		})
+		t.Run(dbSetup.Name+"=OnDelete", func(t *testing.T) {
+			uTest.TestOnDelete()
+		})
 		t.Run(dbSetup.Name+"=ProfileHandler", func(t *testing.T) {
 			uTest.TestProfileHandler()
 		})
+		t.Run(dbSetup.Name+"=Query", func(t *testing.T) {
+			uTest.TestQuery()
+			uTest.TestQueryAggregate()
+		})
+		t.Run(dbSetup.Name+"=ReadReplica", func(t *testing.T) {
+			uTest.TestReadReplica()
+		})
+		t.Run(dbSetup.Name+"=RecordHistory", func(t *testing.T) {
+			uTest.TestRecordHistory()
+		})
 		t.Run(dbSetup.Name+"=RevertLogHandler", func(t *testing.T) {
 			uTest.TestRevertLogHandler()
 		})
+		t.Run(dbSetup.Name+"=SchemaMigration", func(t *testing.T) {
+			uTest.TestSchemaMigration()
+		})
 		t.Run(dbSetup.Name+"=SendEmail", func(t *testing.T) {
 			uTest.TestSendEmail()
 		})
 		t.Run(dbSetup.Name+"=SettingsHandler", func(t *testing.T) {
 			uTest.TestSettingsHandler()
 		})
+		t.Run(dbSetup.Name+"=SQLite", func(t *testing.T) {
+			uTest.TestSQLite()
+		})
+		t.Run(dbSetup.Name+"=UserCommand", func(t *testing.T) {
+			uTest.TestUserCommand()
+		})
+		t.Run(dbSetup.Name+"=Task", func(t *testing.T) {
+			uTest.TestTask()
+		})
+		t.Run(dbSetup.Name+"=Transaction", func(t *testing.T) {
+			uTest.TestTransaction()
+		})
+		t.Run(dbSetup.Name+"=Trash", func(t *testing.T) {
+			uTest.TestTrash()
+		})
 
 		teardownFunction()
 	}
@@ -315,6 +376,8 @@ func setupFunction() {
 func teardownFunction() {
 	// Remove Generated Files
 	os.Remove("uadmin.db")
+	os.Remove("uadmin.db-wal")
+	os.Remove("uadmin.db-shm")
 	os.Remove(".key")
 	os.Remove(".salt")
 	os.Remove(".uproj")
@@ -10,6 +10,8 @@ func init() {
 			time.Sleep(time.Second)
 		}
 		go abTestService()
+		go jobService()
+		taskService()
 	}()
 }
 
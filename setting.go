@@ -309,6 +309,12 @@ func (s *Setting) ApplyValue() {
 		MaxFilesCountPerUserInput = v.(int)
 	case "uAdmin.OriginSitePath":
 		OriginSitePath = strings.TrimSpace(v.(string))
+	case "uAdmin.SQLiteVacuumSchedule":
+		SQLiteVacuumSchedule = strings.TrimSpace(v.(string))
+		scheduleSQLiteMaintenance()
+	case "uAdmin.SQLiteAnalyzeSchedule":
+		SQLiteAnalyzeSchedule = strings.TrimSpace(v.(string))
+		scheduleSQLiteMaintenance()
 	}
 }
 
@@ -932,6 +938,20 @@ func syncSystemSettings() {
 			DataType:     t.String(),
 			Help:         "is the original site path where the application is hosted. Is used to generate correct URLs for Meta (X) crawlers (share functionality)",
 		},
+		{
+			Name:         "SQLite Vacuum Schedule",
+			Value:        SQLiteVacuumSchedule,
+			DefaultValue: "",
+			DataType:     t.String(),
+			Help:         "is the cron spec of the job that runs VACUUM on a SQLite database. Leave it empty to disable the job",
+		},
+		{
+			Name:         "SQLite Analyze Schedule",
+			Value:        SQLiteAnalyzeSchedule,
+			DefaultValue: "",
+			DataType:     t.String(),
+			Help:         "is the cron spec of the job that runs ANALYZE on a SQLite database. Leave it empty to disable the job",
+		},
 	}
 
 	// Prepare uAdmin Settings
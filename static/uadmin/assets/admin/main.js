@@ -603,6 +603,47 @@ function BuildDeleteList(me){
     $('#list_container_listID').html(list_container_listID);
     $('#Deletemodal').modal('show');
   });
+  ShowDeleteImpact(listID);
+};
+
+// ShowDeleteImpact lists the related records that are deleted or changed by
+// deleting the selected records and disables the delete if one is protected
+function ShowDeleteImpact(listID){
+  var container = $('#delete_impact');
+  if (container.length == 0 || listID === ""){
+    return;
+  }
+  container.html("");
+  $('#confirm_delete').prop('disabled', false);
+  $.post(container.data('url') + "?$dryrun=1&id__in=" + listID, {"x-csrf-token": $('#Deletemodal input[name="x-csrf-token"]').val()}, function(data){
+    if (data.status != "ok"){
+      return;
+    }
+    var related = [];
+    for (var i = 0; i < data.result.length; i++){
+      related = related.concat(data.result[i].related || []);
+    }
+    if (related.length == 0){
+      return;
+    }
+    var title = data.protected ? "These related records are protected and stop the delete:" : "These related records will also be changed:";
+    container.html("<h5 class='bold'>" + title + "</h5>" + BuildDeleteImpact(related));
+    $('#confirm_delete').prop('disabled', data.protected);
+  });
+};
+
+function BuildDeleteImpact(impacts){
+  var html = "";
+  var actions = {"delete": "Delete", "set_null": "Clear", "protect": "Protected"};
+  for (var i = 0; i < impacts.length; i++){
+    var name = $('<div>').text(impacts[i].model + ": " + impacts[i].name).html();
+    html += "<li><span class='bold'>" + actions[impacts[i].action] + "</span> " + name;
+    if (impacts[i].related){
+      html += BuildDeleteImpact(impacts[i].related);
+    }
+    html += "</li>";
+  }
+  return "<ul>" + html + "</ul>";
 };
 
 var categoryList = []
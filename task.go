@@ -0,0 +1,334 @@
+package uadmin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskStatus is the status of a task in the task queue
+type TaskStatus int
+
+// Queued is a task waiting for a worker
+func (TaskStatus) Queued() TaskStatus {
+	return 1
+}
+
+// Running is a task being processed by a worker
+func (TaskStatus) Running() TaskStatus {
+	return 2
+}
+
+// Succeeded is a task that finished without an error
+func (TaskStatus) Succeeded() TaskStatus {
+	return 3
+}
+
+// Retrying is a task that failed and is waiting to be retried
+func (TaskStatus) Retrying() TaskStatus {
+	return 4
+}
+
+// Dead is a task that failed in all its attempts
+func (TaskStatus) Dead() TaskStatus {
+	return 5
+}
+
+// Task is a model that stores a task in the background task queue
+type Task struct {
+	Model
+	Name        string     `uadmin:"read_only;filter;search"`
+	Payload     string     `uadmin:"read_only;code;list_exclude"`
+	Status      TaskStatus `uadmin:"read_only;filter"`
+	Attempts    int        `uadmin:"read_only"`
+	MaxAttempts int        `uadmin:"read_only"`
+	RunAt       time.Time  `uadmin:"read_only"`
+	FinishedAt  *time.Time `uadmin:"read_only"`
+	LastError   string     `uadmin:"read_only"`
+	Result      string     `uadmin:"read_only;list_exclude"`
+	LockedBy    string     `uadmin:"read_only;list_exclude"`
+	LockedUntil *time.Time `uadmin:"read_only;list_exclude"`
+	Requeue     bool       `uadmin:"list_exclude;help:Queue the task again to run as soon as possible"`
+}
+
+func (t Task) String() string {
+	return fmt.Sprintf("%s %d", t.Name, t.ID)
+}
+
+// Save overides save to requeue tasks from the admin without overwriting
+// the state of a running task
+func (t *Task) Save() {
+	if t.ID == 0 {
+		Save(t)
+		return
+	}
+	if !t.Requeue {
+		return
+	}
+	if err := requeueTask(t.ID); err != nil {
+		Trail(ERROR, "Task.Save unable to requeue %s. %s", t.String(), err)
+	}
+}
+
+// UnmarshalPayload parses the JSON payload of the task into v
+func (t *Task) UnmarshalPayload(v interface{}) error {
+	return json.Unmarshal([]byte(t.Payload), v)
+}
+
+// TaskFunc is a function that processes a task. It can set task.Result to
+// store the result of the task.
+type TaskFunc func(ctx context.Context, task *Task) error
+
+var taskFuncs = map[string]TaskFunc{}
+var taskFuncsMutex = sync.Mutex{}
+
+// secretTasks are the names of tasks with payloads that contain secrets.
+// Their payloads are removed when they succeed.
+var secretTasks = map[string]bool{}
+
+// taskCleanupJob is the name of the job that removes finished tasks
+const taskCleanupJob = "uadmin/task-cleanup"
+
+func init() {
+	RegisterJob(taskCleanupJob, "@every 1h", func(ctx context.Context) error {
+		return removeFinishedTasks(ctx)
+	})
+}
+
+// taskSignal wakes up a worker when a task is enqueued
+var taskSignal = make(chan struct{}, 1)
+
+// RegisterTask registers a function to process tasks with a name. Tasks are
+// added to the queue using EnqueueTask.
+//
+//	uadmin.RegisterTask("invoice", func(ctx context.Context, task *uadmin.Task) error {
+//		invoice := Invoice{}
+//		task.UnmarshalPayload(&invoice)
+//		return sendInvoice(ctx, invoice)
+//	})
+func RegisterTask(name string, f TaskFunc) {
+	taskFuncsMutex.Lock()
+	taskFuncs[name] = f
+	taskFuncsMutex.Unlock()
+}
+
+// registerSecretTask registers a function to process tasks with payloads that
+// contain secrets. The payload is removed when the task succeeds.
+func registerSecretTask(name string, f TaskFunc) {
+	taskFuncsMutex.Lock()
+	taskFuncs[name] = f
+	secretTasks[name] = true
+	taskFuncsMutex.Unlock()
+}
+
+// EnqueueTask adds a task to the background task queue. The payload is stored
+// as JSON unless it is a string or []byte. Failed tasks are retried up to
+// TaskMaxAttempts times with an exponential backoff starting at
+// TaskRetryBackoff. Tasks that fail in all their attempts are marked as dead.
+func EnqueueTask(name string, payload interface{}) (*Task, error) {
+	var buf []byte
+	switch v := payload.(type) {
+	case string:
+		buf = []byte(v)
+	case []byte:
+		buf = v
+	default:
+		var err error
+		if buf, err = json.Marshal(payload); err != nil {
+			Trail(ERROR, "EnqueueTask unable to encode payload of %s. %s", name, err)
+			return nil, err
+		}
+	}
+	task := &Task{
+		Name:        name,
+		Payload:     string(buf),
+		Status:      TaskStatus(0).Queued(),
+		MaxAttempts: TaskMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := Save(task); err != nil {
+		return nil, err
+	}
+	select {
+	case taskSignal <- struct{}{}:
+	default:
+	}
+	return task, nil
+}
+
+// WaitForTask waits until a task succeeds or dies and returns its final state
+func WaitForTask(ctx context.Context, ID uint) (*Task, error) {
+	for {
+		task := &Task{}
+		if err := db.Where("id = ?", ID).First(task).Error; err != nil {
+			return nil, err
+		}
+		if task.Status == task.Status.Succeeded() || task.Status == task.Status.Dead() {
+			return task, nil
+		}
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
+
+// requeueTask queues a task again with its attempts reset
+func requeueTask(ID uint) error {
+	return db.Model(&Task{}).Where("id = ? AND status <> ?", ID, TaskStatus(0).Running()).Updates(map[string]interface{}{
+		"status":      TaskStatus(0).Queued(),
+		"attempts":    0,
+		"run_at":      time.Now(),
+		"finished_at": nil,
+		"requeue":     false,
+	}).Error
+}
+
+func taskService() {
+	for i := 0; i < TaskWorkers; i++ {
+		go taskWorker()
+	}
+}
+
+func taskWorker() {
+	for {
+		for runNextTask() {
+		}
+		select {
+		case <-taskSignal:
+		case <-time.After(TaskPollInterval):
+		}
+	}
+}
+
+// runNextTask claims and runs the next task that is due. It returns false if
+// there are no tasks to run.
+func runNextTask() bool {
+	task := claimTask()
+	if task == nil {
+		return false
+	}
+	runTask(task)
+	return true
+}
+
+// claimTask locks the next task that is due for this instance. Running tasks
+// with an expired lock are claimed again since their worker stopped.
+func claimTask() *Task {
+	for {
+		now := time.Now()
+		tasks := []Task{}
+		err := db.Where("status IN (?) AND run_at <= ? AND (locked_until IS NULL OR locked_until < ?)",
+			[]TaskStatus{TaskStatus(0).Queued(), TaskStatus(0).Retrying(), TaskStatus(0).Running()}, now, now).
+			Order("run_at asc").Limit(1).Find(&tasks).Error
+		if err != nil {
+			Trail(ERROR, "Unable to get tasks. %s", err)
+			return nil
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		task := tasks[0]
+		lockedUntil := now.Add(TaskTimeout)
+		result := db.Model(&Task{}).Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", task.ID, now).Updates(map[string]interface{}{
+			"status":       task.Status.Running(),
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_by":    jobInstance,
+			"locked_until": lockedUntil,
+		})
+		if result.Error != nil {
+			Trail(ERROR, "Unable to lock task %s. %s", task.String(), result.Error)
+			return nil
+		}
+		// Another worker claimed the task first
+		if result.RowsAffected == 0 {
+			continue
+		}
+		task.Status = task.Status.Running()
+		task.Attempts++
+		task.LockedBy = jobInstance
+		task.LockedUntil = &lockedUntil
+		return &task
+	}
+}
+
+// runTask runs a claimed task and stores its result. Failed tasks are
+// scheduled for a retry or marked as dead after their last attempt.
+func runTask(task *Task) {
+	taskFuncsMutex.Lock()
+	f, ok := taskFuncs[task.Name]
+	secret := secretTasks[task.Name]
+	taskFuncsMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), TaskTimeout)
+	defer cancel()
+	var err error
+	if !ok {
+		err = fmt.Errorf("no function is registered for task %s", task.Name)
+	} else {
+		err = func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return f(ctx, task)
+		}()
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+		"result":       task.Result,
+	}
+	if err == nil {
+		updates["status"] = task.Status.Succeeded()
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+		if secret {
+			updates["payload"] = ""
+		}
+	} else if task.Attempts >= task.MaxAttempts {
+		updates["status"] = task.Status.Dead()
+		updates["finished_at"] = now
+		updates["last_error"] = err.Error()
+		Trail(ERROR, "Task %s failed after %d attempts. %s", task.String(), task.Attempts, err)
+	} else {
+		updates["status"] = task.Status.Retrying()
+		updates["run_at"] = now.Add(getTaskBackoff(task.Attempts))
+		updates["last_error"] = err.Error()
+		Trail(WARNING, "Task %s failed in attempt %d. %s", task.String(), task.Attempts, err)
+	}
+	if err = db.Model(&Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		Trail(ERROR, "Unable to update task %s. %s", task.String(), err)
+	}
+}
+
+// removeFinishedTasks permanently deletes succeeded and dead tasks that
+// finished more than TaskRetention ago
+func removeFinishedTasks(ctx context.Context) error {
+	if TaskRetention <= 0 {
+		return nil
+	}
+	return db.WithContext(ctx).Unscoped().Where("status IN (?) AND finished_at < ?",
+		[]TaskStatus{TaskStatus(0).Succeeded(), TaskStatus(0).Dead()}, time.Now().Add(-TaskRetention)).Delete(&Task{}).Error
+}
+
+// getTaskBackoff returns the delay before the next attempt of a failed task
+func getTaskBackoff(attempts int) time.Duration {
+	backoff := TaskRetryBackoff
+	for i := 1; i < attempts && backoff < time.Hour*24; i++ {
+		backoff *= 2
+	}
+	if backoff > time.Hour*24 {
+		backoff = time.Hour * 24
+	}
+	return backoff
+}
@@ -0,0 +1,119 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TestTask is a unit testing function for the background task queue
+func (t *UAdminTests) TestTask() {
+	tempBackoff := TaskRetryBackoff
+	tempMaxAttempts := TaskMaxAttempts
+	TaskRetryBackoff = 0
+	TaskMaxAttempts = 3
+	defer func() {
+		TaskRetryBackoff = tempBackoff
+		TaskMaxAttempts = tempMaxAttempts
+	}()
+
+	type payload struct {
+		Name     string
+		Failures int
+	}
+	failures := map[string]int{}
+	RegisterTask("test-task", func(ctx context.Context, task *Task) error {
+		p := payload{}
+		if err := task.UnmarshalPayload(&p); err != nil {
+			return err
+		}
+		if failures[p.Name] < p.Failures {
+			failures[p.Name]++
+			return fmt.Errorf("failure %d", failures[p.Name])
+		}
+		task.Result = "done " + p.Name
+		return nil
+	})
+
+	wait := func(task *Task, err error) *Task {
+		if err != nil {
+			t.Errorf("EnqueueTask returned an error. %s", err)
+			return &Task{}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		task, err = WaitForTask(ctx, task.ID)
+		if err != nil {
+			t.Errorf("WaitForTask returned an error. %s", err)
+			return &Task{}
+		}
+		return task
+	}
+
+	task := wait(EnqueueTask("test-task", payload{Name: "a"}))
+	if task.Status != task.Status.Succeeded() || task.Result != "done a" || task.Attempts != 1 || task.FinishedAt == nil {
+		t.Errorf("Task didn't succeed. Got %#v", task)
+	}
+
+	// Retry
+	task = wait(EnqueueTask("test-task", payload{Name: "b", Failures: 1}))
+	if task.Status != task.Status.Succeeded() || task.Attempts != 2 {
+		t.Errorf("Task didn't succeed after a retry. Got %#v", task)
+	}
+
+	// Dead letter
+	task = wait(EnqueueTask("test-task", payload{Name: "c", Failures: 3}))
+	if task.Status != task.Status.Dead() || task.Attempts != 3 || task.LastError != "failure 3" {
+		t.Errorf("Task didn't die after all attempts. Got %#v", task)
+	}
+	task = wait(EnqueueTask("test-unregistered-task", ""))
+	if task.Status != task.Status.Dead() {
+		t.Errorf("Task without a function didn't die. Got %#v", task)
+	}
+
+	// Requeue a dead task from the admin
+	task = wait(EnqueueTask("test-task", payload{Name: "d", Failures: 3}))
+	task.Requeue = true
+	task.Save()
+	task = wait(task, nil)
+	if task.Status != task.Status.Succeeded() || task.Attempts != 1 || task.Requeue {
+		t.Errorf("Task didn't succeed after requeue. Got %#v", task)
+	}
+
+	examples := []struct {
+		attempts int
+		backoff  time.Duration
+	}{
+		{1, time.Second * 30},
+		{2, time.Minute},
+		{3, time.Minute * 2},
+		{20, time.Hour * 24},
+	}
+	TaskRetryBackoff = time.Second * 30
+	for _, e := range examples {
+		if backoff := getTaskBackoff(e.attempts); backoff != e.backoff {
+			t.Errorf("getTaskBackoff returned invalid backoff for %d attempts. Got %s expected %s", e.attempts, backoff, e.backoff)
+		}
+	}
+
+	// Finished tasks are removed after TaskRetention
+	old := time.Now().Add(-TaskRetention - time.Hour)
+	oldTask := Task{Name: "test-task", Status: TaskStatus(0).Succeeded(), FinishedAt: &old}
+	Save(&oldTask)
+	queued := Task{Name: "test-task", Status: TaskStatus(0).Queued(), RunAt: time.Now().Add(time.Hour)}
+	Save(&queued)
+	if err := removeFinishedTasks(context.Background()); err != nil {
+		t.Errorf("removeFinishedTasks returned an error. %s", err)
+	}
+	if Count(&Task{}, "id = ?", oldTask.ID) != 0 {
+		t.Errorf("removeFinishedTasks didn't remove an old finished task")
+	}
+	if Count(&Task{}, "id = ?", queued.ID) != 1 {
+		t.Errorf("removeFinishedTasks removed a queued task")
+	}
+
+	taskFuncsMutex.Lock()
+	delete(taskFuncs, "test-task")
+	taskFuncsMutex.Unlock()
+	DeleteList(&[]Task{}, "name IN (?)", []string{"test-task", "test-unregistered-task"})
+}
@@ -0,0 +1,110 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Tx is a database transaction started by Transaction. Its helpers work like
+// the DB helpers with the same names but run in the transaction.
+type Tx struct {
+	db *gorm.DB
+}
+
+// Transaction runs fn in a transaction of the default database. The
+// transaction is committed if fn returns nil and rolled back if it returns
+// an error or panics. Use the helpers of tx inside fn because the DB
+// helpers run outside the transaction.
+func Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, invalidate := withCacheTables(ctx)
+	defer invalidate()
+	return GetDB().WithContext(ctx).Transaction(func(gtx *gorm.DB) error {
+		return fn(&Tx{db: gtx})
+	})
+}
+
+// Transaction runs fn in a nested transaction using a savepoint. Only the
+// changes of fn are rolled back if it returns an error.
+func (tx *Tx) Transaction(fn func(tx *Tx) error) error {
+	return tx.db.Transaction(func(gtx *gorm.DB) error {
+		return fn(&Tx{db: gtx})
+	})
+}
+
+// DB returns the gorm transaction for queries that are not covered by the
+// helpers
+func (tx *Tx) DB() *gorm.DB {
+	return tx.db
+}
+
+// Save saves the object in the transaction
+func (tx *Tx) Save(a interface{}) error {
+	if err := tx.checkDB(a); err != nil {
+		return err
+	}
+	return saveWith(tx.db, a)
+}
+
+// Get fetches the first record matching query and args in the transaction
+func (tx *Tx) Get(a interface{}, query interface{}, args ...interface{}) error {
+	if err := tx.checkDB(a); err != nil {
+		return err
+	}
+	return getWith(tx.db, a, query, args...)
+}
+
+// Filter fetches records in the transaction
+func (tx *Tx) Filter(a interface{}, query interface{}, args ...interface{}) error {
+	if err := tx.checkDB(a); err != nil {
+		return err
+	}
+	return filterWith(tx.db, a, query, args...)
+}
+
+// Count returns the count of records in the transaction
+func (tx *Tx) Count(a interface{}, query interface{}, args ...interface{}) int {
+	if err := tx.checkDB(a); err != nil {
+		return 0
+	}
+	return countWith(tx.db, a, query, args...)
+}
+
+// Delete deletes records in the transaction
+func (tx *Tx) Delete(a interface{}) error {
+	if err := tx.checkDB(a); err != nil {
+		return err
+	}
+	return deleteWith(tx.db, a)
+}
+
+// DeleteList deletes multiple records in the transaction
+func (tx *Tx) DeleteList(a interface{}, query interface{}, args ...interface{}) error {
+	if err := tx.checkDB(a); err != nil {
+		return err
+	}
+	return deleteListWith(tx.db, a, query, args...)
+}
+
+// Update updates a field of records in the transaction
+func (tx *Tx) Update(a interface{}, fieldName string, value interface{}, query string, args ...interface{}) error {
+	if err := tx.checkDB(a); err != nil {
+		return err
+	}
+	return updateWith(tx.db, a, fieldName, value, query, args...)
+}
+
+// checkDB returns an error for models in named databases because the
+// transaction is in the default database
+func (tx *Tx) checkDB(a interface{}) error {
+	if name := getDBName(a); name != "" {
+		err := fmt.Errorf("%s is in database %s and not in the database of the transaction", getModelName(a), name)
+		Trail(ERROR, "Tx: %s", err)
+		return err
+	}
+	return nil
+}
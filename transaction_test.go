@@ -0,0 +1,87 @@
+package uadmin
+
+import (
+	"context"
+	"fmt"
+)
+
+// TestTransaction is a unit testing function for Transaction
+func (t *UAdminTests) TestTransaction() {
+	defer DeleteList(&TestModelA{}, "name LIKE ?", "tx_%")
+
+	// Changes are committed when fn returns nil
+	err := Transaction(context.Background(), func(tx *Tx) error {
+		if err := tx.Save(&TestModelA{Name: "tx_commit_1"}); err != nil {
+			return err
+		}
+		if err := tx.Save(&TestModelA{Name: "tx_commit_2"}); err != nil {
+			return err
+		}
+		// The transaction reads its own changes
+		m := TestModelA{}
+		tx.Get(&m, "name = ?", "tx_commit_1")
+		if m.ID == 0 {
+			t.Errorf("Tx.Get didn't read a record saved in the transaction")
+		}
+		if n := tx.Count(&[]TestModelA{}, "name LIKE ?", "tx_commit_%"); n != 2 {
+			t.Errorf("Tx.Count didn't count records saved in the transaction. Expected 2 got %d", n)
+		}
+		return tx.Update(&m, "Name", "tx_commit_3", "id = ?", m.ID)
+	})
+	if err != nil {
+		t.Errorf("Transaction returned an error. %s", err)
+	}
+	if n := Count(&[]TestModelA{}, "name IN (?)", []string{"tx_commit_2", "tx_commit_3"}); n != 2 {
+		t.Errorf("Transaction didn't commit. Expected 2 got %d", n)
+	}
+
+	// Changes are rolled back when fn returns an error
+	err = Transaction(context.Background(), func(tx *Tx) error {
+		tx.Save(&TestModelA{Name: "tx_rollback"})
+		tx.DeleteList(&TestModelA{}, "name = ?", "tx_commit_2")
+		return fmt.Errorf("rollback")
+	})
+	if err == nil || err.Error() != "rollback" {
+		t.Errorf("Transaction didn't return the error of fn. Got %v", err)
+	}
+	if Count(&[]TestModelA{}, "name = ?", "tx_rollback") != 0 || Count(&[]TestModelA{}, "name = ?", "tx_commit_2") != 1 {
+		t.Errorf("Transaction didn't roll back after an error")
+	}
+
+	// Changes are rolled back when fn panics
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Transaction didn't panic again after rolling back")
+			}
+		}()
+		Transaction(context.Background(), func(tx *Tx) error {
+			tx.Save(&TestModelA{Name: "tx_panic"})
+			panic("panic")
+		})
+	}()
+	if Count(&[]TestModelA{}, "name = ?", "tx_panic") != 0 {
+		t.Errorf("Transaction didn't roll back after a panic")
+	}
+
+	// Nested transactions roll back their changes only
+	Transaction(context.Background(), func(tx *Tx) error {
+		tx.Save(&TestModelA{Name: "tx_outer"})
+		tx.Transaction(func(tx *Tx) error {
+			tx.Save(&TestModelA{Name: "tx_inner"})
+			return fmt.Errorf("rollback")
+		})
+		return nil
+	})
+	if Count(&[]TestModelA{}, "name = ?", "tx_outer") != 1 || Count(&[]TestModelA{}, "name = ?", "tx_inner") != 0 {
+		t.Errorf("Tx.Transaction didn't roll back the nested transaction only")
+	}
+
+	// Models in named databases are not in the transaction
+	err = Transaction(context.Background(), func(tx *Tx) error {
+		return tx.Save(&TestReportTag{Name: "tx_report"})
+	})
+	if err == nil {
+		t.Errorf("Tx.Save didn't return an error for a model in a named database")
+	}
+}
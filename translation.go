@@ -415,7 +415,7 @@ func TranslateSchema(s *ModelSchema, lang string) {
 		lang = "en"
 	}
 
-	pkgName := fmt.Sprint(reflect.TypeOf(models[s.ModelName]))
+	pkgName := fmt.Sprint(reflect.TypeOf(registeredModels()[s.ModelName]))
 	pkgName = strings.Split(pkgName, ".")[0]
 	fileName := "./static/i18n/" + pkgName + "/" + s.ModelName + "." + lang + ".json"
 
@@ -0,0 +1,235 @@
+package uadmin
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// trashQuery is the condition for soft deleted records
+const trashQuery = "deleted_at IS NOT NULL"
+
+// trashPage fetches a page of soft deleted records from the database
+func trashPage(order string, asc bool, offset int, limit int, a interface{}, query interface{}, args ...interface{}) (err error) {
+	if val, ok := query.(string); ok {
+		query = fixQueryEnclosure(val)
+	}
+	if order != "" {
+		order = strings.ToLower(order)
+		orderby := " desc"
+		if asc {
+			orderby = " asc"
+		}
+		order = columnEnclosure() + order + columnEnclosure() + orderby
+	} else {
+		order = "deleted_at desc"
+	}
+	TimeMetric("uadmin/db/duration", 1000, func() {
+		tx := modelDB(a).Unscoped().Where(trashQuery).Where(query, args...).Order(order)
+		if limit > 0 {
+			tx = tx.Offset(offset).Limit(limit)
+		}
+		err = tx.Find(a).Error
+	})
+	if err != nil {
+		Trail(ERROR, "DB error in trashPage(%v). %s\n", getModelName(a), err.Error())
+		return err
+	}
+	decryptArray(a)
+	return nil
+}
+
+// trashCount returns the number of soft deleted records matching query and args
+func trashCount(a interface{}, query interface{}, args ...interface{}) int {
+	if val, ok := query.(string); ok {
+		query = fixQueryEnclosure(val)
+	}
+	var count int64
+	var err error
+	TimeMetric("uadmin/db/duration", 1000, func() {
+		err = modelDB(a).Unscoped().Model(a).Where(trashQuery).Where(query, args...).Count(&count).Error
+	})
+	if err != nil {
+		Trail(ERROR, "DB error in trashCount(%v). %s\n", getModelName(a), err.Error())
+	}
+	return int(count)
+}
+
+// restoreRecord restores a soft deleted record and logs it. The records that
+// were deleted with it by the cascade on delete rule are restored with it in
+// one transaction. Records that were set to NULL by the set_null rule are not
+// changed and related records that were deleted from a model with a Delete
+// method must be restored separately.
+func restoreRecord(modelName string, ID uint, user *User, r *http.Request) error {
+	return Transaction(requestContext(r), func(tx *Tx) error {
+		return restoreRecordWith(tx, modelName, ID, user, r)
+	})
+}
+
+// restoreRecordWith restores a soft deleted record and the records deleted
+// with it in a transaction
+func restoreRecordWith(tx *Tx, modelName string, ID uint, user *User, r *http.Request) error {
+	m, ok := NewModel(modelName, true)
+	if !ok {
+		return fmt.Errorf("invalid model name: %s", modelName)
+	}
+	conn := impactDB(tx, m.Interface())
+	if err := conn.Unscoped().Where(trashQuery).Where("id = ?", ID).First(m.Interface()).Error; err != nil {
+		return fmt.Errorf("%s(%d) is not in the trash", modelName, ID)
+	}
+	deletedAt := m.Elem().FieldByName("DeletedAt").Interface()
+	result := conn.Unscoped().Model(m.Interface()).Where(trashQuery).Where("id = ?", ID).Update("deleted_at", nil)
+	if result.Error != nil {
+		Trail(ERROR, "restoreRecord unable to restore %s(%d). %s", modelName, ID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%s(%d) is not in the trash", modelName, ID)
+	}
+	if err := conn.Where("id = ?", ID).First(m.Interface()).Error; err != nil {
+		return err
+	}
+	decryptRecord(m.Interface())
+	customGet(m.Interface())
+
+	log := Log{}
+	log.ParseRecord(m, modelName, ID, user, log.Action.Restored(), r)
+	if err := tx.DB().Create(&log).Error; err != nil {
+		return err
+	}
+
+	// Restore the related records that were deleted at the same time
+	for _, rel := range getDeleteRelations(modelName) {
+		if rel.Rule != OnDeleteCascade {
+			continue
+		}
+		related, _ := NewModel(rel.Model, true)
+		ids := []uint{}
+		err := impactDB(tx, related.Interface()).Unscoped().Model(related.Interface()).
+			Where(fmt.Sprintf("%s = ? AND deleted_at = ?", columnEnclosure()+rel.Column+columnEnclosure()), ID, deletedAt).
+			Order("id").Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err = restoreRecordWith(tx, rel.Model, id, user, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// purgeRecord permanently deletes a soft deleted record with its M2M rows
+// and uploaded files and logs it
+func purgeRecord(modelName string, ID uint, user *User, r *http.Request) error {
+	s, _ := getSchema(modelName)
+	m, ok := NewModel(modelName, true)
+	if !ok {
+		return fmt.Errorf("invalid model name: %s", modelName)
+	}
+	deleted, err := getRecordUnscoped(m.Interface(), ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%s(%d) is not in the trash", modelName, ID)
+	}
+
+	log := Log{}
+	log.ParseRecord(m, modelName, ID, user, log.Action.Purged(), r)
+
+	tx := modelDB(m.Interface()).Begin()
+	for _, f := range s.Fields {
+		if f.Type != cM2M {
+			continue
+		}
+		sql := sqlDialect[getDBType(m.Interface())]["deleteM2M"]
+		sql = strings.Replace(sql, "{TABLE1}", s.ModelName, -1)
+		sql = strings.Replace(sql, "{TABLE2}", strings.ToLower(f.TypeName), -1)
+		if err := tx.Exec(sql, ID).Error; err != nil {
+			tx.Rollback()
+			Trail(ERROR, "purgeRecord unable to delete M2M of %s(%d). %s", modelName, ID, err)
+			return err
+		}
+	}
+	if err := tx.Unscoped().Where("id = ?", ID).Delete(m.Interface()).Error; err != nil {
+		tx.Rollback()
+		Trail(ERROR, "purgeRecord unable to purge %s(%d). %s", modelName, ID, err)
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		Trail(ERROR, "purgeRecord unable to purge %s(%d). %s", modelName, ID, err)
+		return err
+	}
+
+	// Remove uploaded files
+	for _, f := range s.Fields {
+		if f.Type != cIMAGE && f.Type != cFILE {
+			continue
+		}
+		removeUploadedFile(modelName, f.Name, m.Elem().FieldByName(f.Name).String())
+	}
+
+	log.Save()
+	return nil
+}
+
+// removeUploadedFile removes a file uploaded through a model's field. Uploads
+// are stored in their own folder which is removed with the raw and
+// cropped versions of the file.
+func removeUploadedFile(modelName string, fieldName string, fileName string) {
+	// Only files in the media folder are removed
+	if strings.Contains(fileName, "..") {
+		return
+	}
+	fileName = path.Clean(fileName)
+	if !strings.HasPrefix(fileName, "/media/") {
+		return
+	}
+	dir := path.Dir(fileName)
+	if dir != "/media" && strings.HasPrefix(path.Base(dir), modelName+"_"+fieldName+"_") {
+		if err := os.RemoveAll("." + dir); err != nil {
+			Trail(WARNING, "removeUploadedFile unable to remove %s. %s", dir, err)
+		}
+		return
+	}
+	if err := os.Remove("." + fileName); err != nil && !os.IsNotExist(err) {
+		Trail(WARNING, "removeUploadedFile unable to remove %s. %s", fileName, err)
+	}
+}
+
+// processTrash is a handler for restoring and purging records from the trash
+func processTrash(modelName string, w http.ResponseWriter, r *http.Request, session *Session, user *User) {
+	if r.FormValue("listID") == "" || r.FormValue("listID") == "," {
+		return
+	}
+
+	perm := user.GetAccess(modelName)
+	restore := r.FormValue("restore") == "restore"
+	purge := r.FormValue("purge") == "purge"
+	if (restore && !perm.Restore) || (purge && !perm.Purge) {
+		return
+	}
+
+	// Check CSRF
+	if CheckCSRF(r) {
+		pageErrorHandler(w, r, session)
+		return
+	}
+
+	for _, v := range strings.Split(r.FormValue("listID"), ",") {
+		ID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || ID == 0 {
+			continue
+		}
+		if restore {
+			restoreRecord(modelName, uint(ID), user, r)
+		} else if purge {
+			purgeRecord(modelName, uint(ID), user, r)
+		}
+	}
+}
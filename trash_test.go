@@ -0,0 +1,128 @@
+package uadmin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TestTrash is a unit testing function for the trash view in listHandler()
+func (t *UAdminTests) TestTrash() {
+	s1 := &Session{
+		Active:    true,
+		UserID:    1,
+		LoginTime: time.Now(),
+	}
+	s1.GenerateKey()
+	s1.Save()
+	Preload(s1)
+
+	u1 := &User{
+		Username: "u1",
+		Password: "u1",
+		Active:   true,
+	}
+	u1.Save()
+	s2 := &Session{
+		UserID:    u1.ID,
+		Active:    true,
+		LoginTime: time.Now(),
+	}
+	s2.GenerateKey()
+	s2.Save()
+	Preload(s2)
+
+	os.MkdirAll("./media/files/testmodelb_File_trash/", 0755)
+	os.WriteFile("./media/files/testmodelb_File_trash/a.txt", []byte("a"), 0644)
+
+	mA := TestModelA{Name: "TrashA"}
+	Save(&mA)
+	m := TestModelB{
+		Name:       "TrashedRecord",
+		ModelAList: []TestModelA{mA},
+		File:       "/media/files/testmodelb_File_trash/a.txt",
+	}
+	Save(&m)
+	Delete(m)
+
+	// A user without restore or purge permission cannot see the trash
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/testmodelb/?trash=1", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s2.Key})
+	listHandler(w, r, s2)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("listHandler trash returned invalid code for user without permission. Got %d expected %d", w.Code, http.StatusNotFound)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/testmodelb/?trash=1", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+	listHandler(w, r, s1)
+	if w.Code != http.StatusOK {
+		t.Errorf("listHandler trash returned invalid code. Got %d expected %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(w.Body.String(), m.Name) {
+		t.Errorf("listHandler trash didn't list the deleted record")
+	}
+
+	trashRequest := func(action string) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/testmodelb/?trash=1", nil)
+		r.Form = url.Values{}
+		r.Form[action] = []string{action}
+		r.Form["listID"] = []string{fmt.Sprint(m.ID)}
+		r.Form["x-csrf-token"] = []string{s1.Key}
+		r.AddCookie(&http.Cookie{Name: "session", Value: s1.Key})
+		listHandler(w, r, s1)
+	}
+
+	// Restore
+	trashRequest("restore")
+	if Count(&TestModelB{}, "id = ?", m.ID) != 1 {
+		t.Errorf("listHandler didn't restore the record")
+	}
+	if Count(&Log{}, "table_name = ? AND table_id = ? AND action = ?", "testmodelb", m.ID, Action(0).Restored()) != 1 {
+		t.Errorf("listHandler didn't log the restore")
+	}
+
+	// Purge only works on deleted records
+	trashRequest("purge")
+	if Count(&TestModelB{}, "id = ?", m.ID) != 1 {
+		t.Errorf("listHandler purged a record that is not deleted")
+	}
+
+	Delete(m)
+	trashRequest("purge")
+	var count int64
+	db.Unscoped().Model(&TestModelB{}).Where("id = ?", m.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("listHandler didn't purge the record")
+	}
+	db.Table("testmodelb_testmodela").Where("table1_id = ?", m.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("listHandler didn't purge the M2M records. Got %d", count)
+	}
+	if _, err := os.Stat("./media/files/testmodelb_File_trash/"); !os.IsNotExist(err) {
+		t.Errorf("listHandler didn't remove the uploaded files")
+	}
+	if Count(&Log{}, "table_name = ? AND table_id = ? AND action = ?", "testmodelb", m.ID, Action(0).Purged()) != 1 {
+		t.Errorf("listHandler didn't log the purge")
+	}
+
+	// Files outside the media folder are not removed
+	os.WriteFile("./trash_test_keep.txt", []byte("keep"), 0644)
+	defer os.Remove("./trash_test_keep.txt")
+	removeUploadedFile("testmodelb", "File", "/media/../trash_test_keep.txt")
+	if _, err := os.Stat("./trash_test_keep.txt"); err != nil {
+		t.Errorf("removeUploadedFile removed a file outside the media folder")
+	}
+
+	Delete(mA)
+	Delete(s1)
+	Delete(s2)
+	Delete(u1)
+}
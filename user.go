@@ -216,6 +216,8 @@ func (u *User) GetAccess(modelName string) UserPermission {
 		perm.Add = gPerm.Add
 		perm.Delete = gPerm.Delete
 		perm.Approval = gPerm.Approval
+		perm.Restore = gPerm.Restore
+		perm.Purge = gPerm.Purge
 	}
 	if uPerm.ID != 0 {
 		perm.Read = uPerm.Read
@@ -223,6 +225,8 @@ func (u *User) GetAccess(modelName string) UserPermission {
 		perm.Add = uPerm.Add
 		perm.Delete = uPerm.Delete
 		perm.Approval = uPerm.Approval
+		perm.Restore = uPerm.Restore
+		perm.Purge = uPerm.Purge
 	}
 	if u.Admin {
 		perm.Read = true
@@ -230,6 +234,8 @@ func (u *User) GetAccess(modelName string) UserPermission {
 		perm.Add = true
 		perm.Delete = true
 		perm.Approval = true
+		perm.Restore = true
+		perm.Purge = true
 	}
 	return perm
 }
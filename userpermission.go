@@ -20,6 +20,8 @@ type UserPermission struct {
 	Edit            bool          `uadmin:"filter"`
 	Delete          bool          `uadmin:"filter"`
 	Approval        bool          `uadmin:"filter"`
+	Restore         bool          `uadmin:"filter"`
+	Purge           bool          `uadmin:"filter"`
 }
 
 func (u UserPermission) String() string {